@@ -9,12 +9,23 @@ import (
 	"syscall"
 	"time"
 
+	"lukekorsman.com/store/internal/audit"
 	"lukekorsman.com/store/internal/auth"
 	"lukekorsman.com/store/internal/cache"
+	"lukekorsman.com/store/internal/cart"
 	"lukekorsman.com/store/internal/chat"
 	"lukekorsman.com/store/internal/config"
+	"lukekorsman.com/store/internal/database"
 	apphttp "lukekorsman.com/store/internal/http"
+	"lukekorsman.com/store/internal/inventory"
+	"lukekorsman.com/store/internal/jobs"
+	"lukekorsman.com/store/internal/media"
+	"lukekorsman.com/store/internal/metrics"
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/order"
+	"lukekorsman.com/store/internal/payment"
 	"lukekorsman.com/store/internal/product"
+	"lukekorsman.com/store/internal/promotion"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -27,21 +38,63 @@ func main() {
 	cfg := config.Load()
 
 	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.Use(audit.CaptureRequest)
 	r.Use(apphttp.RequestTimer)
 	r.Use(apphttp.MetricsMiddleware)
 
 	var store product.Store
+	var categoryStore product.CategoryStore
+	var variantStore product.VariantStore
+	var priceHistoryStore product.PriceHistoryStore
+	var imageStore product.ImageStore
+	var inventoryStore inventory.Store
+	var userCartStore cart.Store
+	var orderStore order.Store
+	var paymentStore payment.Store
+	var couponStore promotion.Store
+	var jobStore jobs.Store
+	var auditStore audit.Store
+	var userStore auth.UserStore
+	var refreshTokenStore auth.RefreshTokenStore
 	if dbURL := os.Getenv("DATABASE_URL"); dbURL != "" {
-		mysqlStore, err := product.NewMySQLStore(dbURL)
+		db, err := database.Open(dbURL)
 		if err != nil {
 			panic(err)
 		}
-		defer mysqlStore.Close()
-		store = mysqlStore
+		defer db.Close()
+		store = product.NewMySQLStore(db)
+		categoryStore = product.NewMySQLCategoryStore(db)
+		variantStore = product.NewMySQLVariantStore(db)
+		priceHistoryStore = product.NewMySQLPriceHistoryStore(db)
+		imageStore = product.NewMySQLImageStore(db)
+		inventoryStore = inventory.NewMySQLStore(db)
+		userCartStore = cart.NewMySQLStore(db)
+		orderStore = order.NewMySQLStore(db)
+		paymentStore = payment.NewMySQLStore(db)
+		couponStore = promotion.NewMySQLStore(db)
+		jobStore = jobs.NewMySQLStore(db)
+		auditStore = audit.NewMySQLStore(db)
+		userStore = auth.NewMySQLUserStore(db)
+		refreshTokenStore = auth.NewMySQLRefreshTokenStore(db)
 	} else {
-		store = product.NewMemoryStore()
+		memoryProducts := product.NewMemoryStore()
+		store = memoryProducts
+		categoryStore = product.NewMemoryCategoryStore()
+		variantStore = product.NewMemoryVariantStore()
+		priceHistoryStore = product.NewMemoryPriceHistoryStore()
+		imageStore = product.NewMemoryImageStore(memoryProducts)
+		inventoryStore = inventory.NewMemoryStore()
+		userCartStore = cart.NewMemoryStore(0)
+		orderStore = order.NewMemoryStore()
+		paymentStore = payment.NewMemoryStore()
+		couponStore = promotion.NewMemoryStore()
+		jobStore = jobs.NewMemoryStore()
+		auditStore = audit.NewMemoryStore()
+		userStore = auth.NewMemoryUserStore()
+		refreshTokenStore = auth.NewMemoryRefreshTokenStore()
 		fmt.Println("Using in-memory store")
 	}
 
@@ -53,9 +106,63 @@ func main() {
 		defer redisCache.Close()
 	}
 
-	userStore := auth.NewMemoryUserStore()
+	rates, err := money.LoadRates(cfg.RatesFile)
+	if err != nil {
+		fmt.Printf("Currency rates unavailable, prices only in %s: %v\n", product.BaseCurrency, err)
+	}
+
+	blobs, err := media.NewLocalStore(cfg.MediaDir, cfg.MediaURL)
+	if err != nil {
+		panic(err)
+	}
+
+	// Uploaded import files wait here for their job. They're kept out of
+	// MEDIA_DIR so they're never served.
+	importFiles, err := media.NewLocalStore(cfg.ImportDir, "")
+	if err != nil {
+		panic(err)
+	}
+
+	var anonymousCartStore cart.Store = cart.NewMemoryStore(cart.AnonymousCartTTL)
+	if redisCache != nil {
+		anonymousCartStore = cart.NewRedisStore(redisCache, cart.AnonymousCartTTL)
+	}
+
+	var idempotencyStore apphttp.IdempotencyStore = apphttp.NewMemoryIdempotencyStore()
+	if redisCache != nil {
+		idempotencyStore = apphttp.NewRedisIdempotencyStore(redisCache)
+	}
+	idempotent := apphttp.Idempotency(idempotencyStore, apphttp.IdempotencyTTL)
+
+	var revocations auth.RevocationStore = auth.NewMemoryRevocationStore()
+	if redisCache != nil {
+		revocations = auth.NewRedisRevocationStore(redisCache)
+	}
+
 	jwtManager := auth.NewJWTManager(cfg.JWTSecret, "store-api")
-	authHandler := auth.NewHandler(userStore, jwtManager)
+	hasher, err := auth.NewPasswordHasher(cfg.PasswordHash, cfg.BcryptCost, auth.Argon2Params{
+		Memory:  uint32(cfg.Argon2Memory),
+		Time:    uint32(cfg.Argon2Time),
+		Threads: uint8(cfg.Argon2Threads),
+	})
+	if err != nil {
+		panic(err)
+	}
+	if cfg.BootstrapAdminEmail != "" {
+		if err := auth.EnsureAdmin(context.Background(), userStore, hasher, cfg.BootstrapAdminEmail, cfg.BootstrapAdminPassword); err != nil {
+			panic(err)
+		}
+		fmt.Printf("Bootstrap admin: %s\n", cfg.BootstrapAdminEmail)
+	}
+	tokens := auth.NewTokenService(jwtManager, refreshTokenStore, revocations, cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	authHandler := auth.NewHandler(userStore, tokens, hasher)
+	auditLog := audit.NewLogger(auditStore)
+	authHandler.OnEvent(auditLog.AuthEvent)
+
+	promotionService := promotion.NewService(couponStore, categoryStore)
+	cartService := cart.NewService(store, anonymousCartStore, userCartStore, promotionService)
+	cartHandler := cart.NewHandler(cartService)
+	authHandler.OnLogin(cartHandler.MergeOnLogin)
 
 	// Start chat hub
 	hub := chat.NewHub()
@@ -68,24 +175,193 @@ func main() {
 	r.Get("/ws", chatHandler.ServeWS)
 	r.Get("/chat/stats", chatHandler.Stats)
 
+	r.Get("/media/*", media.NewHandler(blobs).Get)
+
 	r.Route("/auth", func(r chi.Router) {
-		r.Post("/register", authHandler.Register)
+		// Replays of a registration get fresh tokens rather than stored ones.
+		r.With(apphttp.IdempotencyWithReplayer(idempotencyStore, apphttp.IdempotencyTTL, auth.RegisterReplayer{Handler: authHandler})).
+			Post("/register", authHandler.Register)
 		r.Post("/login", authHandler.Login)
+		r.Post("/refresh", authHandler.Refresh)
+
+		r.Group(func(r chi.Router) {
+			r.Use(apphttp.JWTAuth(jwtManager, userStore, revocations))
+			r.Post("/logout", authHandler.Logout)
+			r.Post("/logout-all", authHandler.LogoutAll)
+		})
+	})
+
+	jobRunner := jobs.NewRunner(jobStore, cfg.JobWorkers)
+	jobHandler := jobs.NewHandler(jobRunner)
+	r.Route("/jobs", func(r chi.Router) {
+		r.Use(apphttp.JWTAuth(jwtManager, userStore, revocations))
+		r.Get("/{id}", jobHandler.Get)
+		r.Post("/{id}/cancel", jobHandler.Cancel)
 	})
 
-	productHandler := product.NewHandler(store, redisCache)
+	productHandler := product.NewHandler(store, categoryStore, imageStore, priceHistoryStore, redisCache, rates)
+	productHandler.EnableJobs(jobRunner, importFiles)
+	productHandler.OnChange(auditLog.ProductChanged)
+	variantHandler := product.NewVariantHandler(store, variantStore)
+	priceHistoryHandler := product.NewPriceHistoryHandler(store, priceHistoryStore)
+	imageHandler := product.NewImageHandler(store, imageStore, blobs, redisCache)
 	r.Route("/products", func(r chi.Router) {
 		r.Get("/", productHandler.List)
+		r.Get("/search", productHandler.Search)
 		r.Get("/{id}", productHandler.Get)
+		r.Get("/{id}/variants", variantHandler.List)
+		r.Get("/{id}/images", imageHandler.List)
 
 		r.Group(func(r chi.Router) {
-			r.Use(apphttp.JWTAuth(jwtManager, userStore))
+			r.Use(apphttp.JWTAuth(jwtManager, userStore, revocations))
+			r.Use(apphttp.RequirePermission(auth.PermProductsRead))
+			r.Get("/export", productHandler.Export)
+			r.Post("/export", productHandler.QueueExport)
+			r.Get("/export/{jobID}", productHandler.DownloadExport)
+			r.Get("/{id}/price-history", priceHistoryHandler.History)
+			r.Get("/{id}/scheduled-prices", priceHistoryHandler.Schedules)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(apphttp.JWTAuth(jwtManager, userStore, revocations))
+			r.Use(apphttp.RequirePermission(auth.PermProductsWrite))
+			r.Use(idempotent)
 			r.Post("/", productHandler.Create)
 			r.Put("/{id}", productHandler.Update)
+			r.Patch("/{id}", productHandler.Patch)
 			r.Delete("/{id}", productHandler.Delete)
+			r.Post("/{id}/restore", productHandler.Restore)
+			r.Post("/batch", productHandler.Batch)
+			r.Post("/import", productHandler.Import)
+
+			r.Post("/{id}/scheduled-prices", priceHistoryHandler.Schedule)
+			r.Delete("/{id}/scheduled-prices/{scheduleID}", priceHistoryHandler.Cancel)
+
+			r.Post("/{id}/images", imageHandler.Upload)
+			r.Put("/{id}/images/order", imageHandler.Reorder)
+			r.Delete("/{id}/images/{imageID}", imageHandler.Delete)
+
+			r.Put("/{id}/variants", variantHandler.Replace)
+			r.Post("/{id}/variants", variantHandler.Create)
+			r.Post("/{id}/variants/generate", variantHandler.Generate)
+			r.Put("/{id}/variants/{variantID}", variantHandler.Update)
+			r.Delete("/{id}/variants/{variantID}", variantHandler.Delete)
 		})
 	})
 
+	categoryHandler := product.NewCategoryHandler(categoryStore, store)
+	r.Route("/categories", func(r chi.Router) {
+		r.Get("/", categoryHandler.Tree)
+		r.Get("/{id}", categoryHandler.Get)
+
+		r.Group(func(r chi.Router) {
+			r.Use(apphttp.JWTAuth(jwtManager, userStore, revocations))
+			r.Use(apphttp.RequirePermission(auth.PermProductsWrite))
+			r.Post("/", categoryHandler.Create)
+			r.Put("/{id}", categoryHandler.Update)
+			r.Delete("/{id}", categoryHandler.Delete)
+		})
+	})
+
+	r.Route("/cart", func(r chi.Router) {
+		r.Use(apphttp.OptionalJWTAuth(jwtManager, userStore, revocations))
+		r.Get("/", cartHandler.Get)
+		r.Post("/items", cartHandler.AddItem)
+		r.Put("/items/{productID}", cartHandler.UpdateItem)
+		r.Delete("/items/{productID}", cartHandler.RemoveItem)
+		r.Post("/coupon", cartHandler.ApplyCoupon)
+		r.Delete("/coupon", cartHandler.RemoveCoupon)
+	})
+
+	inventoryService := inventory.NewService(inventoryStore)
+	inventoryService.OnLowStock(func(e inventory.LowStockEvent) {
+		metrics.LowStockEvents.Inc()
+		fmt.Printf("Low stock: product %d has %d available (threshold %d)\n", e.ProductID, e.Available, e.Threshold)
+	})
+	inventoryHandler := inventory.NewHandler(inventoryService, store)
+	r.Route("/inventory", func(r chi.Router) {
+		r.Use(apphttp.JWTAuth(jwtManager, userStore, revocations))
+		r.With(apphttp.RequirePermission(auth.PermProductsRead)).Get("/{id}", inventoryHandler.Get)
+		r.With(apphttp.RequirePermission(auth.PermProductsWrite)).Put("/{id}/threshold", inventoryHandler.SetThreshold)
+		r.With(apphttp.RequirePermission(auth.PermProductsRead)).Get("/{id}/adjustments", inventoryHandler.Adjustments)
+		r.With(apphttp.RequirePermission(auth.PermProductsWrite)).Post("/{id}/adjustments", inventoryHandler.Adjust)
+	})
+
+	orderService := order.NewService(orderStore, cartService, inventoryService, promotionService)
+	orderHandler := order.NewHandler(orderService)
+
+	// The fake gateway settles asynchronous payments by calling our own
+	// webhook endpoint.
+	webhookSecret := []byte(cfg.PaymentWebhookSecret)
+	gateway := payment.NewFakeGateway(webhookSecret, "http://localhost:"+cfg.Port+"/payments/webhook", 2*time.Second)
+	paymentHandler := payment.NewHandler(payment.NewService(gateway, paymentStore, orderService), orderService, webhookSecret)
+
+	r.Route("/orders", func(r chi.Router) {
+		r.Use(apphttp.JWTAuth(jwtManager, userStore, revocations))
+		r.Get("/", orderHandler.List)
+		r.Get("/{id}", orderHandler.Get)
+		r.Get("/{id}/payments", paymentHandler.List)
+
+		// Pay keys its attempts by Idempotency-Key itself, so a retry is
+		// answered from the attempt rather than a stored response.
+		r.Post("/{id}/payments", paymentHandler.Pay)
+
+		r.Group(func(r chi.Router) {
+			r.Use(idempotent)
+			r.Post("/", orderHandler.Create)
+			r.Post("/{id}/cancel", orderHandler.Cancel)
+		})
+	})
+	r.Post("/payments/webhook", paymentHandler.Webhook)
+
+	couponHandler := promotion.NewHandler(couponStore, categoryStore)
+	auditHandler := audit.NewHandler(auditStore)
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(apphttp.JWTAuth(jwtManager, userStore, revocations))
+		r.With(apphttp.RequirePermission(auth.PermProductsRead)).Get("/products", productHandler.AdminList)
+
+		r.Group(func(r chi.Router) {
+			r.Use(apphttp.RequirePermission(auth.PermOrdersManage))
+			r.Put("/orders/{id}/status", orderHandler.UpdateStatus)
+			r.Post("/orders/{id}/refund", paymentHandler.Refund)
+		})
+
+		r.With(apphttp.RequirePermission(auth.PermAuditRead)).Get("/audit", auditHandler.List)
+
+		r.Group(func(r chi.Router) {
+			r.Use(apphttp.RequirePermission(auth.PermCouponsManage))
+			r.Get("/coupons", couponHandler.List)
+			r.Post("/coupons", couponHandler.Create)
+			r.Get("/coupons/{id}", couponHandler.Get)
+			r.Put("/coupons/{id}", couponHandler.Update)
+			r.Delete("/coupons/{id}", couponHandler.Delete)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(apphttp.RequirePermission(auth.PermUsersManage))
+			r.Put("/users/{id}/roles/{role}", authHandler.GrantRole)
+			r.Delete("/users/{id}/roles/{role}", authHandler.RevokeRole)
+		})
+	})
+
+	// Apply scheduled price changes in the background until shutdown.
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	priceScheduler := product.NewPriceScheduler(store, priceHistoryStore, redisCache)
+	priceScheduler.OnChange(auditLog.ProductChanged)
+	go priceScheduler.Run(schedulerCtx, time.Minute)
+
+	// Run background jobs until shutdown. Jobs still running then are put
+	// back in the queue.
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	jobsDone := make(chan struct{})
+	go func() {
+		jobRunner.Run(jobsCtx)
+		close(jobsDone)
+	}()
+
 	srv := &http.Server{
 		Addr:    ":" + cfg.Port,
 		Handler: r,
@@ -104,6 +380,7 @@ func main() {
 
 	<-stop
 	fmt.Println("Shutting down...")
+	stopScheduler()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -112,5 +389,8 @@ func main() {
 		fmt.Println("shutdown error:", err)
 	}
 
+	stopJobs()
+	<-jobsDone
+
 	fmt.Println("Server gracefully stopped")
 }
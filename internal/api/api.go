@@ -0,0 +1,14 @@
+// Package api holds the request conventions shared by the HTTP handlers:
+// validation errors and offset pagination.
+package api
+
+// ValidationError reports a problem with one field of a request. Handlers
+// answer 400 with the errors in an "errors" array.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (v ValidationError) Error() string {
+	return v.Field + ": " + v.Message
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 100
+)
+
+// ParsePage reads the limit, offset and cursor parameters of a paginated
+// endpoint. A cursor holds an offset and takes precedence over offset.
+func ParsePage(q url.Values) (limit, offset int, errs []ValidationError) {
+	limit, offset, errs = ParseLimitOffset(q)
+
+	if v := q.Get("cursor"); v != "" {
+		n, err := decodeCursor(v)
+		if err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "cursor",
+				Message: "cursor is invalid",
+			})
+		} else {
+			offset = n
+		}
+	}
+
+	return limit, offset, errs
+}
+
+// ParseLimitOffset reads the limit and offset parameters, for endpoints
+// whose cursors aren't offsets.
+func ParseLimitOffset(q url.Values) (limit, offset int, errs []ValidationError) {
+	limit = DefaultLimit
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxLimit {
+			errs = append(errs, ValidationError{
+				Field:   "limit",
+				Message: fmt.Sprintf("limit must be between 1 and %d", MaxLimit),
+			})
+		} else {
+			limit = n
+		}
+	}
+
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			errs = append(errs, ValidationError{
+				Field:   "offset",
+				Message: "offset must be a non-negative integer",
+			})
+		} else {
+			offset = n
+		}
+	}
+
+	return limit, offset, errs
+}
+
+// SetPageHeaders sets X-Total-Count and RFC 8288 next/prev links carrying an
+// opaque cursor, the pagination contract shared by every listing endpoint.
+func SetPageHeaders(w http.ResponseWriter, r *http.Request, limit, offset, count, total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	var links []string
+	if next := offset + count; count > 0 && next < total {
+		links = append(links, PageLink(r, limit, encodeCursor(next), "next"))
+	}
+	if offset > 0 {
+		links = append(links, PageLink(r, limit, encodeCursor(max(offset-limit, 0)), "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// PageLink returns a Link header value pointing at the request's URL with
+// the given limit and cursor.
+func PageLink(r *http.Request, limit int, cursor, rel string) string {
+	q := r.URL.Query()
+	q.Del("offset")
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("cursor", cursor)
+
+	u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	s, ok := strings.CutPrefix(string(raw), "o:")
+	if !ok {
+		return 0, fmt.Errorf("malformed cursor")
+	}
+
+	offset, err := strconv.Atoi(s)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("malformed cursor")
+	}
+	return offset, nil
+}
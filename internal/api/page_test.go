@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantLimit  int
+		wantOffset int
+		wantErrs   []string
+	}{
+		{name: "defaults", query: "", wantLimit: DefaultLimit},
+		{name: "limit and offset", query: "limit=10&offset=20", wantLimit: 10, wantOffset: 20},
+		{name: "cursor overrides offset", query: "offset=5&cursor=" + encodeCursor(40), wantLimit: DefaultLimit, wantOffset: 40},
+		{name: "limit too large", query: "limit=101", wantLimit: DefaultLimit, wantErrs: []string{"limit"}},
+		{name: "negative offset", query: "offset=-1", wantLimit: DefaultLimit, wantErrs: []string{"offset"}},
+		{name: "bad cursor", query: "cursor=bogus", wantLimit: DefaultLimit, wantErrs: []string{"cursor"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			limit, offset, errs := ParsePage(q)
+			if limit != tt.wantLimit || offset != tt.wantOffset {
+				t.Errorf("got limit %d offset %d, want %d and %d", limit, offset, tt.wantLimit, tt.wantOffset)
+			}
+			var fields []string
+			for _, e := range errs {
+				fields = append(fields, e.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantErrs, ",") {
+				t.Errorf("got errors on %v, want %v", fields, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestSetPageHeaders(t *testing.T) {
+	r := httptest.NewRequest("GET", "/orders?status=paid&offset=20", nil)
+	w := httptest.NewRecorder()
+	SetPageHeaders(w, r, 10, 20, 10, 45)
+
+	if got := w.Header().Get("X-Total-Count"); got != "45" {
+		t.Errorf("X-Total-Count = %q, want 45", got)
+	}
+	link := w.Header().Get("Link")
+	for _, want := range []string{
+		"cursor=" + encodeCursor(30), `rel="next"`,
+		"cursor=" + encodeCursor(10), `rel="prev"`,
+		"status=paid",
+	} {
+		if !strings.Contains(link, want) {
+			t.Errorf("Link %q is missing %q", link, want)
+		}
+	}
+	if strings.Contains(link, "offset=") {
+		t.Errorf("Link %q still carries offset", link)
+	}
+
+	w = httptest.NewRecorder()
+	SetPageHeaders(w, httptest.NewRequest("GET", "/orders", nil), 10, 0, 5, 5)
+	if link := w.Header().Get("Link"); link != "" {
+		t.Errorf("expected no links on a single page, got %q", link)
+	}
+}
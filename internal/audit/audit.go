@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	ActionProductCreate  = "product.create"
+	ActionProductUpdate  = "product.update"
+	ActionProductDelete  = "product.delete"
+	ActionProductRestore = "product.restore"
+	ActionRegister       = "auth.register"
+	ActionLogin          = "auth.login"
+	ActionLoginFailed    = "auth.login_failed"
+	ActionRoleGrant      = "auth.role_grant"
+	ActionRoleRevoke     = "auth.role_revoke"
+
+	EntityProduct = "product"
+	EntityUser    = "user"
+)
+
+// Entry is one audited event. Changes maps each top-level field that
+// differs to its old and new JSON value; Details holds anything else worth
+// keeping, such as the email of a failed login.
+type Entry struct {
+	ID         int               `json:"id"`
+	Action     string            `json:"action"`
+	EntityType string            `json:"entity_type"`
+	EntityID   *int              `json:"entity_id"`
+	ActorID    *int              `json:"actor_id"`
+	IP         string            `json:"ip"`
+	UserAgent  string            `json:"user_agent"`
+	RequestID  string            `json:"request_id"`
+	Changes    map[string]Change `json:"changes,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+type Change struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// Filter selects entries for List. Zero values match everything; From is
+// inclusive and To exclusive.
+type Filter struct {
+	ActorID    *int
+	EntityType string
+	EntityID   *int
+	Action     string
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+func (f Filter) matches(e Entry) bool {
+	return (f.ActorID == nil || e.ActorID != nil && *e.ActorID == *f.ActorID) &&
+		(f.EntityType == "" || e.EntityType == f.EntityType) &&
+		(f.EntityID == nil || e.EntityID != nil && *e.EntityID == *f.EntityID) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.From.IsZero() || !e.CreatedAt.Before(f.From)) &&
+		(f.To.IsZero() || e.CreatedAt.Before(f.To))
+}
+
+// Diff compares the JSON objects before and after marshal to, field by
+// field. Either can be nil, or marshal to null, in which case every field of
+// the other is reported.
+func Diff(before, after any) (map[string]Change, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for k, v := range b {
+		if !bytes.Equal(v, a[k]) {
+			changes[k] = Change{Before: v, After: a[k]}
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = Change{After: v}
+		}
+	}
+	return changes, nil
+}
+
+func fields(v any) (map[string]json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+type requestInfoKey struct{}
+
+// requestInfo is the part of an entry taken from the request that caused it.
+type requestInfo struct {
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// CaptureRequest keeps the client's address, user agent and request ID in
+// the context for entries recorded while handling the request. It should
+// come after chi's RequestID middleware.
+func CaptureRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		info := requestInfo{
+			IP:        ip,
+			UserAgent: r.UserAgent(),
+			RequestID: middleware.GetReqID(r.Context()),
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
+	})
+}
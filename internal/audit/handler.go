@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lukekorsman.com/store/internal/api"
+)
+
+type Handler struct {
+	store Store
+}
+
+func NewHandler(store Store) *Handler {
+	return &Handler{store: store}
+}
+
+// List returns audit entries, newest first. Query parameters: actor (a
+// user ID), entity (product or user), entity_id, action, from and to
+// (RFC 3339 times), limit and offset.
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	f, errs := parseFilter(r)
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	entries, total, err := h.store.List(r.Context(), f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	api.SetPageHeaders(w, r, f.Limit, f.Offset, len(entries), total)
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func parseFilter(r *http.Request) (Filter, []api.ValidationError) {
+	q := r.URL.Query()
+	var f Filter
+	var errs []api.ValidationError
+	f.Limit, f.Offset, errs = api.ParsePage(q)
+
+	if v := q.Get("actor"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, api.ValidationError{Field: "actor", Message: "actor must be a user ID"})
+		}
+		f.ActorID = &id
+	}
+
+	f.EntityType = q.Get("entity")
+	switch f.EntityType {
+	case "", EntityProduct, EntityUser:
+	default:
+		errs = append(errs, api.ValidationError{Field: "entity", Message: "entity must be product or user"})
+	}
+
+	if v := q.Get("entity_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, api.ValidationError{Field: "entity_id", Message: "entity_id must be a number"})
+		}
+		f.EntityID = &id
+	}
+
+	f.Action = q.Get("action")
+
+	if v := q.Get("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			errs = append(errs, api.ValidationError{Field: "from", Message: "from must be an RFC 3339 time"})
+		}
+		f.From = from
+	}
+	if v := q.Get("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			errs = append(errs, api.ValidationError{Field: "to", Message: "to must be an RFC 3339 time"})
+		}
+		f.To = to
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		errs = append(errs, api.ValidationError{Field: "to", Message: "to must be after from"})
+	}
+
+	return f, errs
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListEntries(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	alice, bob, lamp := 1, 2, 10
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store.Record(ctx, Entry{Action: ActionRegister, EntityType: EntityUser, EntityID: &alice, ActorID: &alice, CreatedAt: start})
+	store.Record(ctx, Entry{Action: ActionProductCreate, EntityType: EntityProduct, EntityID: &lamp, ActorID: &alice, CreatedAt: start.Add(time.Hour)})
+	store.Record(ctx, Entry{Action: ActionProductUpdate, EntityType: EntityProduct, EntityID: &lamp, ActorID: &bob, CreatedAt: start.Add(2 * time.Hour)})
+	store.Record(ctx, Entry{Action: ActionLoginFailed, EntityType: EntityUser, CreatedAt: start.Add(3 * time.Hour)})
+
+	h := NewHandler(store)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []int
+		wantTotal  string
+	}{
+		{name: "everything, newest first", query: "", wantStatus: http.StatusOK, wantIDs: []int{4, 3, 2, 1}, wantTotal: "4"},
+		{name: "by actor", query: "?actor=1", wantStatus: http.StatusOK, wantIDs: []int{2, 1}, wantTotal: "2"},
+		{name: "by entity", query: "?entity=product&entity_id=10", wantStatus: http.StatusOK, wantIDs: []int{3, 2}, wantTotal: "2"},
+		{name: "by action", query: "?action=auth.login_failed", wantStatus: http.StatusOK, wantIDs: []int{4}, wantTotal: "1"},
+		{name: "by time range", query: "?from=2026-03-01T13:00:00Z&to=2026-03-01T15:00:00Z", wantStatus: http.StatusOK, wantIDs: []int{3, 2}, wantTotal: "2"},
+		{name: "paginated", query: "?limit=1&offset=1", wantStatus: http.StatusOK, wantIDs: []int{3}, wantTotal: "4"},
+		{name: "invalid actor", query: "?actor=alice", wantStatus: http.StatusBadRequest},
+		{name: "unknown entity", query: "?entity=order", wantStatus: http.StatusBadRequest},
+		{name: "invalid time", query: "?from=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "empty range", query: "?from=2026-03-02T00:00:00Z&to=2026-03-01T00:00:00Z", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.List(rec, httptest.NewRequest(http.MethodGet, "/admin/audit"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("X-Total-Count"); got != tt.wantTotal {
+				t.Fatalf("expected total %s, got %s", tt.wantTotal, got)
+			}
+
+			var entries []Entry
+			json.NewDecoder(rec.Body).Decode(&entries)
+			if len(entries) != len(tt.wantIDs) {
+				t.Fatalf("expected entries %v, got %+v", tt.wantIDs, entries)
+			}
+			for i, e := range entries {
+				if e.ID != tt.wantIDs[i] {
+					t.Fatalf("expected entries %v, got %+v", tt.wantIDs, entries)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/product"
+)
+
+// Logger writes entries to a Store, filling in the request details kept by
+// CaptureRequest. Its ProductChanged and AuthEvent methods are meant to be
+// registered as hooks on the product and auth handlers.
+type Logger struct {
+	store Store
+}
+
+func NewLogger(store Store) *Logger {
+	return &Logger{store: store}
+}
+
+// Record saves e. A failure is only logged since the event it describes has
+// already happened.
+func (l *Logger) Record(ctx context.Context, e Entry) {
+	if info, ok := ctx.Value(requestInfoKey{}).(requestInfo); ok {
+		e.IP = info.IP
+		e.UserAgent = info.UserAgent
+		e.RequestID = info.RequestID
+	}
+	if _, err := l.store.Record(ctx, e); err != nil {
+		fmt.Printf("Failed to record audit entry %s: %v\n", e.Action, err)
+	}
+}
+
+var productActions = map[product.ChangeAction]string{
+	product.ProductCreated:  ActionProductCreate,
+	product.ProductUpdated:  ActionProductUpdate,
+	product.ProductDeleted:  ActionProductDelete,
+	product.ProductRestored: ActionProductRestore,
+}
+
+// ProductChanged records a product write with the user in ctx as the actor.
+func (l *Logger) ProductChanged(ctx context.Context, c product.ProductChange) {
+	e := Entry{
+		Action:     productActions[c.Action],
+		EntityType: EntityProduct,
+		EntityID:   &c.ProductID,
+	}
+	if user, ok := auth.UserFromContext(ctx); ok {
+		e.ActorID = &user.ID
+	}
+
+	changes, err := Diff(c.Before, c.After)
+	if err != nil {
+		fmt.Printf("Failed to diff product %d: %v\n", c.ProductID, err)
+	}
+	// Images are managed separately and only sometimes loaded, so they'd
+	// show up as changes that didn't happen.
+	delete(changes, "images")
+	e.Changes = changes
+
+	l.Record(ctx, e)
+}
+
+var authActions = map[auth.EventType]string{
+	auth.EventRegistered:  ActionRegister,
+	auth.EventLoggedIn:    ActionLogin,
+	auth.EventLoginFailed: ActionLoginFailed,
+	auth.EventRoleGranted: ActionRoleGrant,
+	auth.EventRoleRevoked: ActionRoleRevoke,
+}
+
+// AuthEvent records a registration, login attempt or role change. The user
+// is the actor unless the login failed; role changes are made by the user in
+// the request context.
+func (l *Logger) AuthEvent(r *http.Request, e auth.Event) {
+	entry := Entry{
+		Action:     authActions[e.Type],
+		EntityType: EntityUser,
+		Details:    map[string]string{"email": e.Email},
+	}
+	if e.User.ID != 0 {
+		id := e.User.ID
+		entry.EntityID = &id
+		if e.Type != auth.EventLoginFailed {
+			entry.ActorID = &id
+		}
+	}
+	if e.Role != "" {
+		entry.Details["role"] = string(e.Role)
+		entry.ActorID = nil
+		if actor, ok := auth.UserFromContext(r.Context()); ok {
+			entry.ActorID = &actor.ID
+		}
+	}
+
+	l.Record(r.Context(), entry)
+}
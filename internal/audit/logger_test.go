@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/product"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDiff(t *testing.T) {
+	type doc struct {
+		Name  string   `json:"name"`
+		Price int      `json:"price"`
+		Tags  []string `json:"tags"`
+	}
+
+	changes, _ := Diff(doc{Name: "Lamp", Price: 100, Tags: []string{"a"}}, doc{Name: "Lamp", Price: 120, Tags: []string{"a", "b"}})
+	data, _ := json.Marshal(changes)
+	if want := `{"price":{"before":100,"after":120},"tags":{"before":["a"],"after":["a","b"]}}`; string(data) != want {
+		t.Fatalf("expected %s, got %s", want, data)
+	}
+
+	var missing *doc
+	changes, _ = Diff(missing, doc{Name: "Lamp"})
+	data, _ = json.Marshal(changes)
+	if want := `{"name":{"after":"Lamp"},"price":{"after":0},"tags":{"after":null}}`; string(data) != want {
+		t.Fatalf("expected %s, got %s", want, data)
+	}
+}
+
+func TestProductChanges(t *testing.T) {
+	store := NewMemoryStore()
+	logger := NewLogger(store)
+	products := product.NewMemoryStore()
+	handler := product.NewHandler(products, product.NewMemoryCategoryStore(), nil, nil, nil, nil)
+	handler.OnChange(logger.ProductChanged)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(CaptureRequest)
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.ContextWithUser(r.Context(), auth.User{ID: 7})))
+		})
+	})
+	r.Post("/products", handler.Create)
+	r.Put("/products/{id}", handler.Update)
+	r.Delete("/products/{id}", handler.Delete)
+
+	do := func(method, target, ifMatch, body string) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("User-Agent", "store-app/1.0")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code >= http.StatusBadRequest {
+			t.Fatalf("%s %s: %d %s", method, target, rec.Code, rec.Body.String())
+		}
+	}
+	do(http.MethodPost, "/products", "", `{"name":"Lamp","description":"Brass","price":{"amount":2500}}`)
+	do(http.MethodPut, "/products/1", `"1"`, `{"name":"Desk Lamp","description":"Brass","price":{"amount":2500}}`)
+	do(http.MethodDelete, "/products/1", `"2"`, "")
+
+	entries, total, _ := store.List(context.Background(), Filter{})
+	if total != 3 {
+		t.Fatalf("expected 3 entries, got %d", total)
+	}
+	wantActions := []string{ActionProductDelete, ActionProductUpdate, ActionProductCreate}
+	for i, e := range entries {
+		if e.Action != wantActions[i] || e.EntityType != EntityProduct || *e.EntityID != 1 || *e.ActorID != 7 {
+			t.Fatalf("unexpected entry %d: %+v", i, e)
+		}
+		if e.IP != "192.0.2.1" || e.UserAgent != "store-app/1.0" || e.RequestID == "" {
+			t.Fatalf("expected request details on entry %d, got %+v", i, e)
+		}
+		if _, ok := e.Changes["images"]; ok {
+			t.Fatalf("expected images to be left out of the changes, got %+v", e.Changes)
+		}
+	}
+
+	update := entries[1].Changes
+	if string(update["name"].Before) != `"Lamp"` || string(update["name"].After) != `"Desk Lamp"` {
+		t.Fatalf("expected the name change, got %+v", update)
+	}
+	if _, ok := update["description"]; ok {
+		t.Fatalf("expected unchanged fields to be left out, got %+v", update)
+	}
+	if string(entries[0].Changes["name"].Before) != `"Desk Lamp"` || entries[0].Changes["name"].After != nil {
+		t.Fatalf("expected the deleted product as before, got %+v", entries[0].Changes)
+	}
+}
+
+func TestAuthEvents(t *testing.T) {
+	store := NewMemoryStore()
+	logger := NewLogger(store)
+	handler := auth.NewHandler(auth.NewMemoryUserStore(), auth.NewTokenService(auth.NewJWTManager("secret", "test"), auth.NewMemoryRefreshTokenStore(), auth.NewMemoryRevocationStore(), time.Minute, time.Hour), auth.BcryptHasher{Cost: bcrypt.MinCost})
+	handler.OnEvent(logger.AuthEvent)
+
+	r := chi.NewRouter()
+	r.Use(CaptureRequest)
+	r.Post("/auth/register", handler.Register)
+	r.Post("/auth/login", handler.Login)
+
+	for _, req := range []struct{ path, body string }{
+		{"/auth/register", `{"email":"alice@example.com","password":"password123"}`},
+		{"/auth/login", `{"email":"alice@example.com","password":"password123"}`},
+		{"/auth/login", `{"email":"alice@example.com","password":"wrong-password"}`},
+		{"/auth/login", `{"email":"mallory@example.com","password":"password123"}`},
+	} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, req.path, strings.NewReader(req.body)))
+	}
+
+	entries, _, _ := store.List(context.Background(), Filter{})
+	tests := []struct {
+		action   string
+		email    string
+		entityID bool
+		actorID  bool
+	}{
+		{ActionLoginFailed, "mallory@example.com", false, false},
+		{ActionLoginFailed, "alice@example.com", true, false},
+		{ActionLogin, "alice@example.com", true, true},
+		{ActionRegister, "alice@example.com", true, true},
+	}
+	if len(entries) != len(tests) {
+		t.Fatalf("expected %d entries, got %+v", len(tests), entries)
+	}
+	for i, tt := range tests {
+		e := entries[i]
+		if e.Action != tt.action || e.EntityType != EntityUser || e.Details["email"] != tt.email ||
+			(e.EntityID != nil) != tt.entityID || (e.ActorID != nil) != tt.actorID || e.IP != "192.0.2.1" {
+			t.Fatalf("unexpected entry %d: %+v", i, e)
+		}
+	}
+}
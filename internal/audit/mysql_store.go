@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const entryColumns = "id, action, entity_type, entity_id, actor_id, ip, user_agent, request_id, changes, details, created_at"
+
+type MySQLStore struct {
+	db *sql.DB
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) Record(ctx context.Context, e Entry) (Entry, error) {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	changes, err := jsonOrNil(e.Changes, len(e.Changes))
+	if err != nil {
+		return Entry{}, err
+	}
+	details, err := jsonOrNil(e.Details, len(e.Details))
+	if err != nil {
+		return Entry{}, err
+	}
+
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO audit_log (action, entity_type, entity_id, actor_id, ip, user_agent, request_id, changes, details, created_at) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		e.Action, e.EntityType, e.EntityID, e.ActorID, e.IP, e.UserAgent, e.RequestID, changes, details, e.CreatedAt,
+	)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	id, _ := result.LastInsertId()
+	e.ID = int(id)
+	return e, nil
+}
+
+// jsonOrNil stores an empty map, of length n, as NULL.
+func jsonOrNil(m any, n int) (any, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
+func (s *MySQLStore) List(ctx context.Context, f Filter) ([]Entry, int, error) {
+	var conds []string
+	var args []any
+	if f.ActorID != nil {
+		conds = append(conds, "actor_id = ?")
+		args = append(args, *f.ActorID)
+	}
+	if f.EntityType != "" {
+		conds = append(conds, "entity_type = ?")
+		args = append(args, f.EntityType)
+	}
+	if f.EntityID != nil {
+		conds = append(conds, "entity_id = ?")
+		args = append(args, *f.EntityID)
+	}
+	if f.Action != "" {
+		conds = append(conds, "action = ?")
+		args = append(args, f.Action)
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, f.To.UTC())
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + entryColumns + " FROM audit_log" + where + " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, f.Limit, f.Offset)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var entityID, actorID sql.NullInt64
+		var changes, details []byte
+		err := rows.Scan(&e.ID, &e.Action, &e.EntityType, &entityID, &actorID, &e.IP, &e.UserAgent, &e.RequestID,
+			&changes, &details, &e.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		if entityID.Valid {
+			id := int(entityID.Int64)
+			e.EntityID = &id
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			e.ActorID = &id
+		}
+		if len(changes) > 0 {
+			if err := json.Unmarshal(changes, &e.Changes); err != nil {
+				return nil, 0, err
+			}
+		}
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &e.Details); err != nil {
+				return nil, 0, err
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, total, rows.Err()
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	Record(ctx context.Context, e Entry) (Entry, error)
+	// List returns the entries matching f, newest first, and how many there
+	// are in total.
+	List(ctx context.Context, f Filter) ([]Entry, int, error)
+}
+
+type MemoryStore struct {
+	entries []Entry
+	nextID  int
+	mu      sync.Mutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nextID: 1}
+}
+
+func (s *MemoryStore) Record(ctx context.Context, e Entry) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.ID = s.nextID
+	s.nextID++
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	s.entries = append(s.entries, e)
+	return e, nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, f Filter) ([]Entry, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matched := []Entry{}
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if f.matches(s.entries[i]) {
+			matched = append(matched, s.entries[i])
+		}
+	}
+
+	total := len(matched)
+	start := min(f.Offset, total)
+	end := total
+	if f.Limit > 0 {
+		end = min(start+f.Limit, total)
+	}
+	return matched[start:end], total, nil
+}
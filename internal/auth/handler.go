@@ -2,21 +2,69 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"time"
+	"strconv"
 
 	"lukekorsman.com/store/internal/metrics"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type Handler struct {
 	userStore UserStore
-	jwtManager *JWTManager
+	tokens *TokenService
+	hasher PasswordHasher
+	loginHooks []func(r *http.Request, user User)
+	eventHooks []func(r *http.Request, e Event)
 }
 
-func NewHandler(userStore UserStore, jwtManager *JWTManager) *Handler {
+func NewHandler(userStore UserStore, tokens *TokenService, hasher PasswordHasher) *Handler {
 	return &Handler{
 		userStore: userStore,
-		jwtManager: jwtManager,
+		tokens: tokens,
+		hasher: hasher,
+	}
+}
+
+// OnLogin registers fn to run after every successful login, before the
+// response is written.
+func (h *Handler) OnLogin(fn func(r *http.Request, user User)) {
+	h.loginHooks = append(h.loginHooks, fn)
+}
+
+type EventType string
+
+const (
+	EventRegistered  EventType = "registered"
+	EventLoggedIn    EventType = "logged_in"
+	EventLoginFailed EventType = "login_failed"
+	EventRoleGranted EventType = "role_granted"
+	EventRoleRevoked EventType = "role_revoked"
+)
+
+// Event is a registration, login attempt or role change reported to OnEvent
+// hooks. User is the zero User for a failed login with an unknown email;
+// for role changes it is the user whose roles changed, and the admin who
+// changed them is the user in the request context.
+type Event struct {
+	Type  EventType
+	Email string
+	User  User
+	Role  Role
+}
+
+// OnEvent registers fn to run after every registration, successful login,
+// failed login and role change.
+func (h *Handler) OnEvent(fn func(r *http.Request, e Event)) {
+	h.eventHooks = append(h.eventHooks, fn)
+}
+
+func (h *Handler) event(r *http.Request, e Event) {
+	for _, fn := range h.eventHooks {
+		fn(r, e)
 	}
 }
 
@@ -30,11 +78,32 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// AuthResponse carries an access token (Token), valid for ExpiresIn
+// seconds, and a refresh token to get the next one with.
 type AuthResponse struct {
 	Token string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn int `json:"expires_in"`
 	User  User	 `json:"user"`
 }
 
+func newAuthResponse(pair TokenPair, user User) AuthResponse {
+	return AuthResponse{
+		Token: pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn: int(pair.ExpiresIn.Seconds()),
+		User: user,
+	}
+}
+
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -42,6 +111,7 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Email = NormalizeEmail(req.Email)
 	if req.Email == "" || req.Password == "" {
 		http.Error(w, "email and password required", http.StatusBadRequest)
 		return
@@ -52,24 +122,83 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userStore.Create(r.Context(), req.Email, req.Password)
+	passwordHash, err := h.hasher.Hash(req.Password)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.userStore.Create(r.Context(), req.Email, passwordHash)
+	if errors.Is(err, ErrEmailTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to create user", http.StatusInternalServerError)
 		return 
 	}
 
 	metrics.UserRegistrations.Inc()
+	h.event(r, Event{Type: EventRegistered, Email: user.Email, User: user})
 
-	token, err := h.jwtManager.Generate(user.ID, user.Email, 24*time.Hour)
+	pair, err := h.tokens.Issue(r.Context(), user)
 	if err != nil {
 		http.Error(w, "failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	writeJSON(w, http.StatusCreated, newAuthResponse(pair, user))
+}
+
+// RegisterReplayer lets the idempotency middleware replay registrations
+// without storing their tokens: only the new user is kept, and a replay
+// answers with a fresh token pair for them, as a login would.
+type RegisterReplayer struct {
+	Handler *Handler
+}
+
+// Redact drops the tokens from a successful registration's response.
+func (rr RegisterReplayer) Redact(status int, body []byte) []byte {
+	if status != http.StatusCreated {
+		return body
+	}
+	var resp AuthResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil
+	}
+	redacted, err := json.Marshal(resp.User)
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+// Replay issues new tokens for the user a registration created. Other
+// responses are replayed as they were stored.
+func (rr RegisterReplayer) Replay(w http.ResponseWriter, r *http.Request, status int, body []byte) bool {
+	if status != http.StatusCreated {
+		return false
+	}
+	var stored User
+	if err := json.Unmarshal(body, &stored); err != nil {
+		http.Error(w, "failed to replay registration", http.StatusInternalServerError)
+		return true
+	}
+
+	user, err := rr.Handler.userStore.GetByID(r.Context(), stored.ID)
+	if err != nil {
+		http.Error(w, "failed to replay registration", http.StatusInternalServerError)
+		return true
+	}
+
+	pair, err := rr.Handler.tokens.Issue(r.Context(), user)
+	if err != nil {
+		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		return true
+	}
+
+	writeJSON(w, http.StatusCreated, newAuthResponse(pair, user))
+	return true
 }
 
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
@@ -79,37 +208,191 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Email = NormalizeEmail(req.Email)
 	user, err := h.userStore.GetByEmail(r.Context(), req.Email)
 	if err != nil {
 		metrics.LoginAttempts.WithLabelValues("failures").Inc()
+		h.event(r, Event{Type: EventLoginFailed, Email: req.Email})
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
-	memStore, ok := h.userStore.(*MemoryUserStore)
-	if !ok {
-		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
-	}
-
-	if err := memStore.ValidatePassword(user.Password, req.Password); err != nil {
+	if err := h.hasher.Verify(user.Password, req.Password); err != nil {
 		metrics.LoginAttempts.WithLabelValues("failure").Inc()
+		h.event(r, Event{Type: EventLoginFailed, Email: req.Email, User: user})
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
+	if h.hasher.NeedsRehash(user.Password) {
+		h.rehash(r, user, req.Password)
+	}
+
 	metrics.LoginAttempts.WithLabelValues("success").Inc()
+	h.event(r, Event{Type: EventLoggedIn, Email: user.Email, User: user})
 
-	token, err := h.jwtManager.Generate(user.ID, user.Email, 24*time.Hour)
+	for _, fn := range h.loginHooks {
+		fn(r, user)
+	}
+
+	pair, err := h.tokens.Issue(r.Context(), user)
 	if err != nil {
 		http.Error(w, "failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, AuthResponse{
-		Token: token,
-		User: user,
-	})
+	writeJSON(w, http.StatusOK, newAuthResponse(pair, user))
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token.
+// Each refresh token works once; replaying one logs out every session that
+// came from the same login.
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.RefreshToken == "" {
+		http.Error(w, "refresh_token required", http.StatusBadRequest)
+		return
+	}
+
+	rotated, err := h.tokens.Rotate(r.Context(), req.RefreshToken)
+	if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.userStore.GetByID(r.Context(), rotated.UserID)
+	if err != nil {
+		http.Error(w, ErrInvalidRefreshToken.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	pair, err := h.tokens.Continue(r.Context(), user, rotated)
+	if err != nil {
+		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAuthResponse(pair, user))
+}
+
+// Logout revokes the access token the request was made with. A refresh
+// token in the body, which is optional, is revoked along with it.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tokens.Logout(r.Context(), claims, req.RefreshToken); err != nil {
+		http.Error(w, "failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll revokes every access and refresh token of the current user.
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	user, ok := UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.tokens.LogoutAll(r.Context(), user.ID); err != nil {
+		http.Error(w, "failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GrantRole gives the user in the URL the role in the URL and responds with
+// the updated user.
+func (h *Handler) GrantRole(w http.ResponseWriter, r *http.Request) {
+	h.changeRole(w, r, EventRoleGranted)
+}
+
+// RevokeRole takes the role in the URL away from the user in the URL. Admins
+// can't revoke their own admin role, so there is always one left.
+func (h *Handler) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	h.changeRole(w, r, EventRoleRevoked)
+}
+
+func (h *Handler) changeRole(w http.ResponseWriter, r *http.Request, change EventType) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	role := Role(chi.URLParam(r, "role"))
+	if !role.Valid() {
+		http.Error(w, "unknown role", http.StatusBadRequest)
+		return
+	}
+
+	if change == EventRoleRevoked && role == RoleAdmin {
+		if actor, ok := UserFromContext(r.Context()); ok && actor.ID == id {
+			http.Error(w, "cannot revoke your own admin role", http.StatusConflict)
+			return
+		}
+	}
+
+	if change == EventRoleGranted {
+		err = h.userStore.GrantRole(r.Context(), id, role)
+	} else {
+		err = h.userStore.RevokeRole(r.Context(), id, role)
+	}
+	if errors.Is(err, ErrUserNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to change role", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.userStore.GetByID(r.Context(), id)
+	if errors.Is(err, ErrUserNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to load user", http.StatusInternalServerError)
+		return
+	}
+
+	h.event(r, Event{Type: change, Email: user.Email, User: user, Role: role})
+	writeJSON(w, http.StatusOK, user)
+}
+
+// rehash replaces a hash made with an outdated algorithm or parameters. The
+// login goes ahead if it fails; it is tried again next time.
+func (h *Handler) rehash(r *http.Request, user User, password string) {
+	passwordHash, err := h.hasher.Hash(password)
+	if err == nil {
+		err = h.userStore.UpdatePassword(r.Context(), user.ID, passwordHash)
+	}
+	if err != nil {
+		fmt.Printf("Failed to rehash password for user %d: %v\n", user.ID, err)
+	}
 }
 
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRegister(t *testing.T) {
+	h := NewHandler(NewMemoryUserStore(), NewTokenService(NewJWTManager("secret", "test"), NewMemoryRefreshTokenStore(), NewMemoryRevocationStore(), time.Minute, time.Hour), BcryptHasher{Cost: bcrypt.MinCost})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "new user", body: `{"email":"alice@example.com","password":"password123"}`, wantStatus: http.StatusCreated},
+		{name: "email taken", body: `{"email":"alice@example.com","password":"another-password"}`, wantStatus: http.StatusConflict},
+		{name: "email taken in another case", body: `{"email":" Alice@Example.COM ","password":"another-password"}`, wantStatus: http.StatusConflict},
+		{name: "blank email", body: `{"email":"  ","password":"password123"}`, wantStatus: http.StatusBadRequest},
+		{name: "missing password", body: `{"email":"bob@example.com"}`, wantStatus: http.StatusBadRequest},
+		{name: "short password", body: `{"email":"bob@example.com","password":"12345"}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid JSON", body: `{`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.Register(rec, httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	if user, err := h.userStore.GetByEmail(context.Background(), "ALICE@example.com"); err != nil || user.Email != "alice@example.com" {
+		t.Fatalf("expected emails to match regardless of case, got %+v, %v", user, err)
+	}
+}
+
+func TestLoginRehashesOutdatedPasswords(t *testing.T) {
+	store := NewMemoryUserStore()
+	old, _ := BcryptHasher{Cost: bcrypt.MinCost}.Hash("password123")
+	user, _ := store.Create(context.Background(), "alice@example.com", old)
+
+	hasher := Argon2idHasher{Params: Argon2Params{Memory: 64, Time: 1, Threads: 1}}
+	h := NewHandler(store, NewTokenService(NewJWTManager("secret", "test"), NewMemoryRefreshTokenStore(), NewMemoryRevocationStore(), time.Minute, time.Hour), hasher)
+
+	login := func(password string) int {
+		rec := httptest.NewRecorder()
+		body := `{"email":"alice@example.com","password":"` + password + `"}`
+		h.Login(rec, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	if code := login("wrong-password"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", code)
+	}
+	if user, _ = store.GetByID(context.Background(), user.ID); user.Password != old {
+		t.Fatalf("expected a failed login to keep the hash, got %s", user.Password)
+	}
+
+	if code := login("password123"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	user, _ = store.GetByID(context.Background(), user.ID)
+	if !strings.HasPrefix(user.Password, "$argon2id$") || hasher.NeedsRehash(user.Password) {
+		t.Fatalf("expected the password to be rehashed with argon2id, got %s", user.Password)
+	}
+
+	rehashed := user.Password
+	if code := login("password123"); code != http.StatusOK {
+		t.Fatalf("expected the new hash to work, got %d", code)
+	}
+	if user, _ = store.GetByID(context.Background(), user.ID); user.Password != rehashed {
+		t.Fatalf("expected a current hash to be kept, got %s", user.Password)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	store := NewMemoryUserStore()
+	hasher := BcryptHasher{Cost: bcrypt.MinCost}
+	passwordHash, _ := hasher.Hash("password123")
+	store.Create(context.Background(), "alice@example.com", passwordHash)
+
+	refreshTokens := NewMemoryRefreshTokenStore()
+	h := NewHandler(store, NewTokenService(NewJWTManager("secret", "test"), refreshTokens, NewMemoryRevocationStore(), time.Minute, time.Hour), hasher)
+
+	call := func(handle http.HandlerFunc, body string) (int, AuthResponse) {
+		rec := httptest.NewRecorder()
+		handle(rec, httptest.NewRequest(http.MethodPost, "/auth", strings.NewReader(body)))
+		var resp AuthResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp
+	}
+	login := func() string {
+		_, resp := call(h.Login, `{"email":"alice@example.com","password":"password123"}`)
+		if resp.RefreshToken == "" || resp.ExpiresIn != 60 {
+			t.Fatalf("expected a refresh token and a one-minute access token, got %+v", resp)
+		}
+		return resp.RefreshToken
+	}
+	refresh := func(token string) (int, string) {
+		code, resp := call(h.Refresh, `{"refresh_token":"`+token+`"}`)
+		return code, resp.RefreshToken
+	}
+
+	first := login()
+	other := login()
+
+	code, second := refresh(first)
+	if code != http.StatusOK || second == "" || second == first {
+		t.Fatalf("expected a new refresh token, got %d %q", code, second)
+	}
+	code, third := refresh(second)
+	if code != http.StatusOK {
+		t.Fatalf("expected the rotated token to work, got %d", code)
+	}
+
+	// Replaying a used token revokes everything issued after it.
+	if code, _ := refresh(first); code != http.StatusUnauthorized {
+		t.Fatalf("expected a replayed token to be rejected, got %d", code)
+	}
+	if code, _ := refresh(third); code != http.StatusUnauthorized {
+		t.Fatalf("expected the family to be revoked, got %d", code)
+	}
+	if code, _ := refresh(other); code != http.StatusOK {
+		t.Fatalf("expected other logins to keep working, got %d", code)
+	}
+
+	expired := login()
+	stored, _ := refreshTokens.GetByHash(context.Background(), hashToken(expired))
+	stored.ExpiresAt = time.Now().Add(-time.Second)
+	refreshTokens.tokens[stored.ID] = stored
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "expired", body: `{"refresh_token":"` + expired + `"}`, wantStatus: http.StatusUnauthorized},
+		{name: "unknown", body: `{"refresh_token":"not-a-token"}`, wantStatus: http.StatusUnauthorized},
+		{name: "missing", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid JSON", body: `{`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _ := call(h.Refresh, tt.body); code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, code)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryUserStore()
+	hasher := BcryptHasher{Cost: bcrypt.MinCost}
+	passwordHash, _ := hasher.Hash("password123")
+	user, _ := store.Create(ctx, "alice@example.com", passwordHash)
+
+	jwtManager := NewJWTManager("secret", "test")
+	revocations := NewMemoryRevocationStore()
+	h := NewHandler(store, NewTokenService(jwtManager, NewMemoryRefreshTokenStore(), revocations, time.Minute, time.Hour), hasher)
+
+	login := func() AuthResponse {
+		rec := httptest.NewRecorder()
+		h.Login(rec, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email":"alice@example.com","password":"password123"}`)))
+		var resp AuthResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return resp
+	}
+	refresh := func(token string) int {
+		rec := httptest.NewRecorder()
+		h.Refresh(rec, httptest.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refresh_token":"`+token+`"}`)))
+		return rec.Code
+	}
+	authenticated := func(handle http.HandlerFunc, session AuthResponse, body string) (int, *Claims) {
+		claims, _ := jwtManager.Verify(session.Token)
+		req := httptest.NewRequest(http.MethodPost, "/auth/logout", strings.NewReader(body))
+		req = req.WithContext(ContextWithClaims(ContextWithUser(req.Context(), user), claims))
+		rec := httptest.NewRecorder()
+		handle(rec, req)
+		return rec.Code, claims
+	}
+
+	phone, laptop, tablet := login(), login(), login()
+
+	code, claims := authenticated(h.Logout, phone, `{"refresh_token":"`+phone.RefreshToken+`"}`)
+	if code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+	if revoked, _ := IsRevoked(ctx, revocations, claims); !revoked {
+		t.Fatalf("expected the access token to be revoked")
+	}
+	if code := refresh(phone.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected the refresh token to be revoked, got %d", code)
+	}
+
+	// Without a body only the access token is revoked.
+	code, claims = authenticated(h.Logout, laptop, "")
+	if revoked, _ := IsRevoked(ctx, revocations, claims); code != http.StatusNoContent || !revoked {
+		t.Fatalf("expected 204 and a revoked token, got %d %v", code, revoked)
+	}
+	if code := refresh(laptop.RefreshToken); code != http.StatusOK {
+		t.Fatalf("expected the refresh token to keep working, got %d", code)
+	}
+
+	if code, _ := authenticated(h.LogoutAll, tablet, ""); code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+	if code := refresh(tablet.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected every refresh token to be revoked, got %d", code)
+	}
+	if before, _ := revocations.RevokedBefore(ctx, user.ID); before.IsZero() {
+		t.Fatalf("expected older access tokens to be revoked")
+	}
+	// The tablet logged in moments earlier, most likely in the same second.
+	claims, _ = jwtManager.Verify(tablet.Token)
+	if revoked, _ := IsRevoked(ctx, revocations, claims); !revoked {
+		t.Fatalf("expected an access token issued just before logging out everywhere to be revoked")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if revoked, _ := IsRevoked(ctx, revocations, claims); !revoked {
+		t.Fatalf("expected the token to stay revoked")
+	}
+	fresh := login()
+	claims, _ = jwtManager.Verify(fresh.Token)
+	if revoked, _ := IsRevoked(ctx, revocations, claims); revoked {
+		t.Fatalf("expected a token issued after logging out everywhere to work")
+	}
+}
+
+func TestRegisterReplayer(t *testing.T) {
+	h := NewHandler(NewMemoryUserStore(), NewTokenService(NewJWTManager("secret", "test"), NewMemoryRefreshTokenStore(), NewMemoryRevocationStore(), time.Minute, time.Hour), BcryptHasher{Cost: bcrypt.MinCost})
+	replayer := RegisterReplayer{Handler: h}
+
+	rec := httptest.NewRecorder()
+	h.Register(rec, httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(`{"email":"alice@example.com","password":"password123"}`)))
+	var first AuthResponse
+	json.NewDecoder(bytes.NewReader(rec.Body.Bytes())).Decode(&first)
+
+	stored := replayer.Redact(rec.Code, rec.Body.Bytes())
+	if bytes.Contains(stored, []byte(first.RefreshToken)) || bytes.Contains(stored, []byte(first.Token)) {
+		t.Fatalf("expected the tokens to be redacted, got %s", stored)
+	}
+
+	replayed := httptest.NewRecorder()
+	if !replayer.Replay(replayed, httptest.NewRequest(http.MethodPost, "/auth/register", nil), rec.Code, stored) {
+		t.Fatal("expected the registration to be replayed")
+	}
+	var resp AuthResponse
+	json.NewDecoder(replayed.Body).Decode(&resp)
+	if replayed.Code != http.StatusCreated || resp.User.ID != first.User.ID || resp.RefreshToken == "" || resp.RefreshToken == first.RefreshToken {
+		t.Fatalf("expected the same user with a new refresh token, got %d %+v", replayed.Code, resp)
+	}
+
+	if replayer.Replay(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/auth/register", nil), http.StatusConflict, []byte("email already registered")) {
+		t.Fatal("expected errors to be replayed as stored")
+	}
+}
@@ -1,18 +1,31 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Claims are the access token's claims. RegisteredClaims.ID is the token's
+// jti, used to revoke it on logout. Roles are the user's roles when the
+// token was issued, for clients; permissions are checked against the
+// current roles.
 type Claims struct {
 	UserID int		`json:"user_id"`
 	Email  string	`json:"email"`
+	Roles  []Role	`json:"roles,omitempty"`
 	jwt.RegisteredClaims
 }
 
+func init() {
+	// Token timestamps default to whole seconds, which would let a token
+	// issued a moment before logging out everywhere survive it.
+	jwt.TimePrecision = time.Millisecond
+}
+
 type JWTManager struct {
 	secretKey []byte
 	issuer    string 
@@ -25,14 +38,21 @@ func NewJWTManager(secretKey, issuer string) *JWTManager {
 	}
 }
 
-func (m *JWTManager) Generate(userID int, email string, duration time.Duration) (string, error) {
+func (m *JWTManager) Generate(userID int, email string, roles []Role, duration time.Duration) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
 	claims := Claims{
 		UserID: userID,
 		Email: email,
+		Roles: roles,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
 			IssuedAt: jwt.NewNumericDate(time.Now()),
 			Issuer: m.issuer,
+			ID: hex.EncodeToString(jti),
 		},
 	}
 
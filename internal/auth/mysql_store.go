@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL error numbers for a unique key violation and a missing foreign key
+// parent row.
+const (
+	mysqlDuplicateEntry  = 1062
+	mysqlNoReferencedRow = 1452
+)
+
+// userQuery selects users with their roles joined into a comma-separated
+// list; callers add a WHERE clause on u.
+const userQuery = "SELECT u.id, u.email, u.password, COALESCE(GROUP_CONCAT(r.role ORDER BY r.role), '') " +
+	"FROM users u LEFT JOIN user_roles r ON r.user_id = u.id "
+
+type MySQLUserStore struct {
+	db *sql.DB
+}
+
+func NewMySQLUserStore(db *sql.DB) *MySQLUserStore {
+	return &MySQLUserStore{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (User, error) {
+	var u User
+	var roles string
+	err := row.Scan(&u.ID, &u.Email, &u.Password, &roles)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, err
+	}
+	u.Roles = []Role{}
+	if roles != "" {
+		for _, r := range strings.Split(roles, ",") {
+			u.Roles = append(u.Roles, Role(r))
+		}
+	}
+	return u, nil
+}
+
+func (s *MySQLUserStore) Create(ctx context.Context, email, passwordHash string) (User, error) {
+	email = NormalizeEmail(email)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return User{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO users (email, password) VALUES (?, ?)",
+		email, passwordHash,
+	)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
+		return User{}, ErrEmailTaken
+	}
+	if err != nil {
+		return User{}, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return User{}, err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO user_roles (user_id, role) VALUES (?, ?)", id, RoleCustomer); err != nil {
+		return User{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return User{}, err
+	}
+	return User{ID: int(id), Email: email, Password: passwordHash, Roles: []Role{RoleCustomer}}, nil
+}
+
+func (s *MySQLUserStore) GetByEmail(ctx context.Context, email string) (User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, userQuery+"WHERE u.email = ? GROUP BY u.id", NormalizeEmail(email)))
+}
+
+func (s *MySQLUserStore) GetByID(ctx context.Context, id int) (User, error) {
+	return scanUser(s.db.QueryRowContext(ctx, userQuery+"WHERE u.id = ? GROUP BY u.id", id))
+}
+
+func (s *MySQLUserStore) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	result, err := s.db.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", passwordHash, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *MySQLUserStore) GrantRole(ctx context.Context, id int, role Role) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO user_roles (user_id, role) VALUES (?, ?) ON DUPLICATE KEY UPDATE role = role", id, role)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlNoReferencedRow {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+func (s *MySQLUserStore) RevokeRole(ctx context.Context, id int, role Role) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = ? AND role = ?", id, role)
+	return err
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	argon := Argon2idHasher{Params: Argon2Params{Memory: 64, Time: 1, Threads: 1}}
+	bcryptHasher := BcryptHasher{Cost: bcrypt.MinCost}
+
+	argonHash, err := argon.Hash("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := bcryptHasher.Hash("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		hasher     PasswordHasher
+		hash       string
+		password   string
+		wantErr    error
+		wantRehash bool
+	}{
+		{name: "argon2id", hasher: argon, hash: argonHash, password: "password123"},
+		{name: "argon2id wrong password", hasher: argon, hash: argonHash, password: "password124", wantErr: ErrPasswordMismatch},
+		{name: "bcrypt", hasher: bcryptHasher, hash: bcryptHash, password: "password123"},
+		{name: "bcrypt wrong password", hasher: bcryptHasher, hash: bcryptHash, password: "password124", wantErr: ErrPasswordMismatch},
+		{name: "bcrypt hash under argon2id", hasher: argon, hash: bcryptHash, password: "password123", wantRehash: true},
+		{name: "argon2id hash under bcrypt", hasher: bcryptHasher, hash: argonHash, password: "password123", wantRehash: true},
+		{name: "higher bcrypt cost", hasher: BcryptHasher{Cost: bcrypt.MinCost + 1}, hash: bcryptHash, password: "password123", wantRehash: true},
+		{name: "more argon2id memory", hasher: Argon2idHasher{Params: Argon2Params{Memory: 128, Time: 1, Threads: 1}}, hash: argonHash, password: "password123", wantRehash: true},
+		{name: "more argon2id passes", hasher: Argon2idHasher{Params: Argon2Params{Memory: 64, Time: 2, Threads: 1}}, hash: argonHash, password: "password123", wantRehash: true},
+		{name: "unknown format", hasher: argon, hash: "plaintext", password: "plaintext", wantErr: ErrUnknownHash, wantRehash: true},
+		{name: "malformed argon2id", hasher: argon, hash: "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5", password: "password123", wantErr: ErrUnknownHash, wantRehash: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.hasher.Verify(tt.hash, tt.password); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.wantRehash {
+				t.Fatalf("expected NeedsRehash %v, got %v", tt.wantRehash, got)
+			}
+		})
+	}
+}
+
+func TestNewPasswordHasher(t *testing.T) {
+	params := Argon2Params{Memory: 64, Time: 1, Threads: 1}
+
+	tests := []struct {
+		name      string
+		algorithm string
+		cost      int
+		params    Argon2Params
+		want      PasswordHasher
+	}{
+		{name: "bcrypt", algorithm: "bcrypt", cost: 12, want: BcryptHasher{Cost: 12}},
+		{name: "argon2id", algorithm: "argon2id", params: params, want: Argon2idHasher{Params: params}},
+		{name: "bcrypt cost too high", algorithm: "bcrypt", cost: 32},
+		{name: "argon2id without threads", algorithm: "argon2id", params: Argon2Params{Memory: 64, Time: 1}},
+		{name: "unknown algorithm", algorithm: "md5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPasswordHasher(tt.algorithm, tt.cost, tt.params)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("expected %v, got %v, %v", tt.want, got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// RefreshToken is the stored side of an opaque refresh token; only the
+// SHA-256 of the token is kept. Every token issued by rotating another
+// shares its FamilyID, which goes back to a single login.
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// TokenPair is what a client gets on login and on every refresh.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// TokenService issues short-lived access tokens with long-lived refresh
+// tokens, and rotates refresh tokens so each can be used once.
+type TokenService struct {
+	jwtManager    *JWTManager
+	refreshTokens RefreshTokenStore
+	revocations   RevocationStore
+	accessTTL     time.Duration
+	refreshTTL    time.Duration
+}
+
+func NewTokenService(jwtManager *JWTManager, refreshTokens RefreshTokenStore, revocations RevocationStore, accessTTL, refreshTTL time.Duration) *TokenService {
+	return &TokenService{
+		jwtManager:    jwtManager,
+		refreshTokens: refreshTokens,
+		revocations:   revocations,
+		accessTTL:     accessTTL,
+		refreshTTL:    refreshTTL,
+	}
+}
+
+// Issue starts a new token family for user.
+func (s *TokenService) Issue(ctx context.Context, user User) (TokenPair, error) {
+	familyID, err := randomToken(16, hex.EncodeToString)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return s.issue(ctx, user, familyID)
+}
+
+// Rotate uses up refreshToken and returns its stored record, which Continue
+// takes to issue the next pair. Using a token a second time revokes its whole
+// family, since either the client or an attacker holds a stolen copy.
+func (s *TokenService) Rotate(ctx context.Context, refreshToken string) (RefreshToken, error) {
+	t, err := s.refreshTokens.GetByHash(ctx, hashToken(refreshToken))
+	if err != nil || t.RevokedAt != nil || !time.Now().Before(t.ExpiresAt) {
+		return RefreshToken{}, ErrInvalidRefreshToken
+	}
+
+	marked := false
+	if t.UsedAt == nil {
+		marked, err = s.refreshTokens.MarkUsed(ctx, t.ID)
+		if err != nil {
+			return RefreshToken{}, err
+		}
+	}
+	if !marked {
+		if err := s.refreshTokens.RevokeFamily(ctx, t.FamilyID); err != nil {
+			return RefreshToken{}, err
+		}
+		fmt.Printf("Refresh token reused for user %d, revoked family %s\n", t.UserID, t.FamilyID)
+		return RefreshToken{}, ErrRefreshTokenReused
+	}
+	return t, nil
+}
+
+// Continue issues a new pair in the family of a token returned by Rotate.
+func (s *TokenService) Continue(ctx context.Context, user User, rotated RefreshToken) (TokenPair, error) {
+	return s.issue(ctx, user, rotated.FamilyID)
+}
+
+// Logout revokes the access token with claims and, if refreshToken is one
+// of the same user's, its family. Unknown refresh tokens are ignored.
+func (s *TokenService) Logout(ctx context.Context, claims *Claims, refreshToken string) error {
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := s.revocations.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+	t, err := s.refreshTokens.GetByHash(ctx, hashToken(refreshToken))
+	if err != nil || t.UserID != claims.UserID {
+		return nil
+	}
+	return s.refreshTokens.RevokeFamily(ctx, t.FamilyID)
+}
+
+// LogoutAll revokes every access and refresh token issued to the user so
+// far.
+func (s *TokenService) LogoutAll(ctx context.Context, userID int) error {
+	if err := s.revocations.RevokeUser(ctx, userID, time.Now(), s.accessTTL); err != nil {
+		return err
+	}
+	return s.refreshTokens.RevokeUser(ctx, userID)
+}
+
+func (s *TokenService) issue(ctx context.Context, user User, familyID string) (TokenPair, error) {
+	accessToken, err := s.jwtManager.Generate(user.ID, user.Email, user.Roles, s.accessTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refreshToken, err := randomToken(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	_, err = s.refreshTokens.Create(ctx, RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.accessTTL,
+	}, nil
+}
+
+func randomToken(n int, encode func([]byte) string) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encode(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
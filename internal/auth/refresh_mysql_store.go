@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const refreshTokenColumns = "id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at"
+
+type MySQLRefreshTokenStore struct {
+	db *sql.DB
+}
+
+func NewMySQLRefreshTokenStore(db *sql.DB) *MySQLRefreshTokenStore {
+	return &MySQLRefreshTokenStore{db: db}
+}
+
+func scanRefreshToken(row rowScanner) (RefreshToken, error) {
+	var t RefreshToken
+	var usedAt, revokedAt sql.NullTime
+	err := row.Scan(&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.ExpiresAt, &usedAt, &revokedAt, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RefreshToken{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	if usedAt.Valid {
+		t.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+	return t, nil
+}
+
+func (s *MySQLRefreshTokenStore) Create(ctx context.Context, t RefreshToken) (RefreshToken, error) {
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		t.UserID, t.FamilyID, t.TokenHash, t.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	t.ID = int(id)
+	t.CreatedAt = time.Now()
+	return t, nil
+}
+
+func (s *MySQLRefreshTokenStore) GetByHash(ctx context.Context, hash string) (RefreshToken, error) {
+	return scanRefreshToken(s.db.QueryRowContext(ctx,
+		"SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE token_hash = ?", hash))
+}
+
+func (s *MySQLRefreshTokenStore) MarkUsed(ctx context.Context, id int) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = ? AND used_at IS NULL", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (s *MySQLRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = ? AND revoked_at IS NULL", familyID)
+	return err
+}
+
+func (s *MySQLRefreshTokenStore) RevokeUser(ctx context.Context, userID int) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL", userID)
+	return err
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type RefreshTokenStore interface {
+	Create(ctx context.Context, t RefreshToken) (RefreshToken, error)
+	GetByHash(ctx context.Context, hash string) (RefreshToken, error)
+	// MarkUsed sets UsedAt unless it is already set, and reports whether it
+	// did, so only one of two concurrent rotations wins.
+	MarkUsed(ctx context.Context, id int) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeUser(ctx context.Context, userID int) error
+}
+
+type MemoryRefreshTokenStore struct {
+	tokens map[int]RefreshToken
+	hashes map[string]int
+	nextID int
+	mu     sync.Mutex
+}
+
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{
+		tokens: make(map[int]RefreshToken),
+		hashes: make(map[string]int),
+		nextID: 1,
+	}
+}
+
+func (s *MemoryRefreshTokenStore) Create(ctx context.Context, t RefreshToken) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.ID = s.nextID
+	s.nextID++
+	t.CreatedAt = time.Now()
+	s.tokens[t.ID] = t
+	s.hashes[t.TokenHash] = t.ID
+	return t, nil
+}
+
+func (s *MemoryRefreshTokenStore) GetByHash(ctx context.Context, hash string) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.hashes[hash]
+	if !ok {
+		return RefreshToken{}, ErrInvalidRefreshToken
+	}
+	return s.tokens[id], nil
+}
+
+func (s *MemoryRefreshTokenStore) MarkUsed(ctx context.Context, id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[id]
+	if !ok || t.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	t.UsedAt = &now
+	s.tokens[id] = t
+	return true, nil
+}
+
+func (s *MemoryRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range s.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+			s.tokens[id] = t
+		}
+	}
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) RevokeUser(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, t := range s.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+			s.tokens[id] = t
+		}
+	}
+	return nil
+}
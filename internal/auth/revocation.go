@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"lukekorsman.com/store/internal/cache"
+)
+
+// RevocationStore remembers access tokens that were logged out before they
+// expired. Entries only need to outlive the tokens they reject.
+type RevocationStore interface {
+	// RevokeToken rejects the token with jti until expiresAt.
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeUser rejects every token issued to the user before at. ttl is
+	// how long such a token can still be valid.
+	RevokeUser(ctx context.Context, userID int, at time.Time, ttl time.Duration) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokedBefore returns the time passed to the latest RevokeUser, or the
+	// zero time.
+	RevokedBefore(ctx context.Context, userID int) (time.Time, error)
+}
+
+// IsRevoked reports whether claims belong to a token that was logged out,
+// either on its own or with all of its user's sessions.
+func IsRevoked(ctx context.Context, revocations RevocationStore, claims *Claims) (bool, error) {
+	if claims.ID != "" {
+		revoked, err := revocations.IsTokenRevoked(ctx, claims.ID)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	before, err := revocations.RevokedBefore(ctx, claims.UserID)
+	if err != nil || before.IsZero() {
+		return false, err
+	}
+	// iat has millisecond precision (see jwt.go). A token issued in the
+	// same millisecond as the logout is rejected along with older ones.
+	return claims.IssuedAt == nil || !claims.IssuedAt.Time.After(before.Truncate(time.Millisecond)), nil
+}
+
+type MemoryRevocationStore struct {
+	tokens map[string]time.Time
+	users  map[int]userRevocation
+	mu     sync.Mutex
+}
+
+type userRevocation struct {
+	at      time.Time
+	expires time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens: make(map[string]time.Time),
+		users:  make(map[int]userRevocation),
+	}
+}
+
+func (s *MemoryRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	s.tokens[jti] = expiresAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) RevokeUser(ctx context.Context, userID int, at time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	s.users[userID] = userRevocation{at: at, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.tokens[jti]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func (s *MemoryRevocationStore) RevokedBefore(ctx context.Context, userID int) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok || !time.Now().Before(u.expires) {
+		return time.Time{}, nil
+	}
+	return u.at, nil
+}
+
+// prune drops entries whose tokens have expired anyway. Callers hold mu.
+func (s *MemoryRevocationStore) prune() {
+	now := time.Now()
+	for jti, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, jti)
+		}
+	}
+	for id, u := range s.users {
+		if !now.Before(u.expires) {
+			delete(s.users, id)
+		}
+	}
+}
+
+// RedisRevocationStore keeps revocations as Redis keys that expire with the
+// tokens they reject.
+type RedisRevocationStore struct {
+	cache *cache.RedisCache
+}
+
+func NewRedisRevocationStore(c *cache.RedisCache) *RedisRevocationStore {
+	return &RedisRevocationStore{cache: c}
+}
+
+func revokedTokenKey(jti string) string {
+	return "revoked:token:" + jti
+}
+
+func revokedUserKey(userID int) string {
+	return fmt.Sprintf("revoked:user:%d", userID)
+}
+
+func (s *RedisRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.cache.Set(ctx, revokedTokenKey(jti), true, ttl)
+}
+
+func (s *RedisRevocationStore) RevokeUser(ctx context.Context, userID int, at time.Time, ttl time.Duration) error {
+	return s.cache.Set(ctx, revokedUserKey(userID), at, ttl)
+}
+
+func (s *RedisRevocationStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return s.cache.Exists(ctx, revokedTokenKey(jti))
+}
+
+func (s *RedisRevocationStore) RevokedBefore(ctx context.Context, userID int) (time.Time, error) {
+	var at time.Time
+	err := s.cache.Get(ctx, revokedUserKey(userID), &at)
+	if errors.Is(err, cache.ErrNotFound) {
+		return time.Time{}, nil
+	}
+	return at, err
+}
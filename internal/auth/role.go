@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleEditor   Role = "editor"
+	RoleViewer   Role = "viewer"
+	RoleCustomer Role = "customer"
+)
+
+// Permission names an action guarded by RequirePermission.
+type Permission string
+
+const (
+	PermProductsRead  Permission = "products:read"
+	PermProductsWrite Permission = "products:write"
+	PermOrdersManage  Permission = "orders:manage"
+	PermCouponsManage Permission = "coupons:manage"
+	PermAuditRead     Permission = "audit:read"
+	PermUsersManage   Permission = "users:manage"
+)
+
+// rolePermissions lists what each role may do on top of what every
+// logged-in user can (carts, their own orders and jobs).
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermProductsRead, PermProductsWrite, PermOrdersManage,
+		PermCouponsManage, PermAuditRead, PermUsersManage,
+	},
+	RoleEditor:   {PermProductsRead, PermProductsWrite},
+	RoleViewer:   {PermProductsRead},
+	RoleCustomer: {},
+}
+
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// HasPermission reports whether any of roles grants p.
+func HasPermission(roles []Role, p Permission) bool {
+	for _, r := range roles {
+		if slices.Contains(rolePermissions[r], p) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureAdmin gives the user with email the admin role, creating the user
+// with password first if there is none.
+func EnsureAdmin(ctx context.Context, store UserStore, hasher PasswordHasher, email, password string) error {
+	user, err := store.GetByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		if password == "" {
+			return fmt.Errorf("admin %s doesn't exist and no password was given to create it", email)
+		}
+		passwordHash, err := hasher.Hash(password)
+		if err != nil {
+			return err
+		}
+		user, err = store.Create(ctx, email, passwordHash)
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if slices.Contains(user.Roles, RoleAdmin) {
+		return nil
+	}
+	return store.GrantRole(ctx, user.ID, RoleAdmin)
+}
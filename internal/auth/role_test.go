@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestChangeRole(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryUserStore()
+	admin, _ := store.Create(ctx, "admin@example.com", "hash")
+	store.GrantRole(ctx, admin.ID, RoleAdmin)
+	alice, _ := store.Create(ctx, "alice@example.com", "hash")
+
+	h := NewHandler(store, NewTokenService(NewJWTManager("secret", "test"), NewMemoryRefreshTokenStore(), NewMemoryRevocationStore(), time.Minute, time.Hour), BcryptHasher{Cost: bcrypt.MinCost})
+	var events []Event
+	h.OnEvent(func(r *http.Request, e Event) { events = append(events, e) })
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(ContextWithUser(r.Context(), admin)))
+		})
+	})
+	r.Put("/admin/users/{id}/roles/{role}", h.GrantRole)
+	r.Delete("/admin/users/{id}/roles/{role}", h.RevokeRole)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+		wantRoles  []Role
+	}{
+		{name: "grant", method: http.MethodPut, target: "/admin/users/2/roles/editor", wantStatus: http.StatusOK, wantRoles: []Role{RoleCustomer, RoleEditor}},
+		{name: "grant again", method: http.MethodPut, target: "/admin/users/2/roles/editor", wantStatus: http.StatusOK, wantRoles: []Role{RoleCustomer, RoleEditor}},
+		{name: "revoke", method: http.MethodDelete, target: "/admin/users/2/roles/customer", wantStatus: http.StatusOK, wantRoles: []Role{RoleEditor}},
+		{name: "revoke a role the user doesn't have", method: http.MethodDelete, target: "/admin/users/2/roles/viewer", wantStatus: http.StatusOK, wantRoles: []Role{RoleEditor}},
+		{name: "unknown role", method: http.MethodPut, target: "/admin/users/2/roles/owner", wantStatus: http.StatusBadRequest},
+		{name: "invalid user ID", method: http.MethodPut, target: "/admin/users/alice/roles/editor", wantStatus: http.StatusBadRequest},
+		{name: "unknown user", method: http.MethodPut, target: "/admin/users/99/roles/editor", wantStatus: http.StatusNotFound},
+		{name: "revoke own admin role", method: http.MethodDelete, target: "/admin/users/1/roles/admin", wantStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var user User
+			json.NewDecoder(rec.Body).Decode(&user)
+			if user.ID != alice.ID || !slices.Equal(user.Roles, tt.wantRoles) {
+				t.Fatalf("expected user %d with roles %v, got %+v", alice.ID, tt.wantRoles, user)
+			}
+		})
+	}
+
+	if len(events) != 4 || events[0].Type != EventRoleGranted || events[0].Role != RoleEditor || events[2].Type != EventRoleRevoked {
+		t.Fatalf("expected an event per change, got %+v", events)
+	}
+	if admin, _ = store.GetByID(ctx, admin.ID); !slices.Contains(admin.Roles, RoleAdmin) {
+		t.Fatalf("expected the admin to keep the admin role, got %v", admin.Roles)
+	}
+}
+
+func TestEnsureAdmin(t *testing.T) {
+	ctx := context.Background()
+	hasher := BcryptHasher{Cost: bcrypt.MinCost}
+
+	t.Run("creates the user", func(t *testing.T) {
+		store := NewMemoryUserStore()
+		if err := EnsureAdmin(ctx, store, hasher, "admin@example.com", "password123"); err != nil {
+			t.Fatal(err)
+		}
+		user, _ := store.GetByEmail(ctx, "admin@example.com")
+		if !slices.Contains(user.Roles, RoleAdmin) || hasher.Verify(user.Password, "password123") != nil {
+			t.Fatalf("expected an admin with the given password, got %+v", user)
+		}
+	})
+
+	t.Run("promotes an existing user", func(t *testing.T) {
+		store := NewMemoryUserStore()
+		existing, _ := store.Create(ctx, "admin@example.com", "hash")
+		for range 2 {
+			if err := EnsureAdmin(ctx, store, hasher, "admin@example.com", "ignored-password"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		user, _ := store.GetByID(ctx, existing.ID)
+		if !slices.Equal(user.Roles, []Role{RoleAdmin, RoleCustomer}) || user.Password != "hash" {
+			t.Fatalf("expected the admin role added and the password kept, got %+v", user)
+		}
+	})
+
+	t.Run("missing user without a password", func(t *testing.T) {
+		if err := EnsureAdmin(ctx, NewMemoryUserStore(), hasher, "admin@example.com", ""); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
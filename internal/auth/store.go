@@ -2,16 +2,36 @@ package auth
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"slices"
+	"strings"
 	"sync"
+)
 
-	"golang.org/x/crypto/bcrypt"
+var (
+	// ErrEmailTaken is returned by Create when another user has the email.
+	ErrEmailTaken   = errors.New("email already registered")
+	ErrUserNotFound = errors.New("user not found")
 )
 
+// NormalizeEmail trims and lowercases an email, so addresses differing only
+// in case belong to one user in every store, as they do under MySQL's
+// case-insensitive collation.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// UserStore keeps users with their password hashes and roles; hashing is
+// left to a PasswordHasher. New users get the customer role.
 type UserStore interface {
-	Create(ctx context.Context, email, password string) (User, error) 
+	Create(ctx context.Context, email, passwordHash string) (User, error) 
 	GetByEmail(ctx context.Context, email string) (User, error)
 	GetByID(ctx context.Context, id int) (User, error)
+	UpdatePassword(ctx context.Context, id int, passwordHash string) error
+	// GrantRole and RevokeRole do nothing if the user already has, or
+	// doesn't have, the role.
+	GrantRole(ctx context.Context, id int, role Role) error
+	RevokeRole(ctx context.Context, id int, role Role) error
 }
 
 type MemoryUserStore struct {
@@ -29,23 +49,20 @@ func NewMemoryUserStore() *MemoryUserStore {
 	}
 }
 
-func (s *MemoryUserStore) Create(ctx context.Context, email, password string) (User, error) {
+func (s *MemoryUserStore) Create(ctx context.Context, email, passwordHash string) (User, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	email = NormalizeEmail(email)
 	if _, exists := s.emails[email]; exists {
-		return User{}, fmt.Errorf("email already registered")
-	}
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return User{}, err 
+		return User{}, ErrEmailTaken
 	}
 
 	user := User{
 		ID: s.nextID,
 		Email: email,
-		Password: string(hashedPassword),
+		Password: passwordHash,
+		Roles: []Role{RoleCustomer},
 	}
 
 	s.users[user.ID] = user
@@ -59,9 +76,9 @@ func (s *MemoryUserStore) GetByEmail(ctx context.Context, email string) (User, e
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	userID, exists := s.emails[email]
+	userID, exists := s.emails[NormalizeEmail(email)]
 	if !exists {
-		return User{}, fmt.Errorf("user not found")
+		return User{}, ErrUserNotFound
 	}
 
 	return s.users[userID], nil
@@ -73,12 +90,55 @@ func (s *MemoryUserStore) GetByID(ctx context.Context, id int) (User, error) {
 
 	user, exists := s.users[id]
 	if !exists {
-		return User{}, fmt.Errorf("user not found")
+		return User{}, ErrUserNotFound
 	}
 
 	return user, nil
 }
 
-func (s *MemoryUserStore) ValidatePassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+func (s *MemoryUserStore) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	user.Password = passwordHash
+	s.users[id] = user
+	return nil
+}
+
+func (s *MemoryUserStore) GrantRole(ctx context.Context, id int, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return ErrUserNotFound
+	}
+	if slices.Contains(user.Roles, role) {
+		return nil
+	}
+
+	// Copy so users handed out earlier keep the roles they were read with.
+	user.Roles = append(slices.Clone(user.Roles), role)
+	slices.Sort(user.Roles)
+	s.users[id] = user
+	return nil
+}
+
+func (s *MemoryUserStore) RevokeRole(ctx context.Context, id int, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	user.Roles = slices.DeleteFunc(slices.Clone(user.Roles), func(r Role) bool { return r == role })
+	s.users[id] = user
+	return nil
 }
\ No newline at end of file
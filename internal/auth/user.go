@@ -8,11 +8,15 @@ type User struct {
 	ID 		int 	`json:"id"`
 	Email	string	`json:"email"`
 	Password string `json:"-"`
+	Roles	[]Role	`json:"roles"`
 }
 
 type contextKey string
 
-const userKey contextKey = "user"
+const (
+	userKey contextKey = "user"
+	claimsKey contextKey = "claims"
+)
 
 func UserFromContext(ctx context.Context) (User, bool) {
 	user, ok := ctx.Value(userKey).(User)
@@ -21,4 +25,15 @@ func UserFromContext(ctx context.Context) (User, bool) {
 
 func ContextWithUser(ctx context.Context, user User) context.Context {
 	return context.WithValue(ctx, userKey, user)
+}
+
+// ClaimsFromContext returns the claims of the access token that
+// authenticated the request.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	return claims, ok
+}
+
+func ContextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
 }
\ No newline at end of file
@@ -3,12 +3,16 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrNotFound is returned by Get when the key doesn't exist or has expired.
+var ErrNotFound = errors.New("key not found")
+
 type RedisCache struct {
 	client *redis.Client
 }
@@ -39,10 +43,21 @@ func (c *RedisCache) Set(ctx context.Context, key string, value interface{}, exp
 	return c.client.Set(ctx, key, data, expiration).Err()
 }
 
+// SetNX stores value only if key doesn't exist yet and reports whether it
+// was stored.
+func (c *RedisCache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	return c.client.SetNX(ctx, key, data, expiration).Result()
+}
+
 func (c *RedisCache) Get(ctx context.Context, key string, dest interface{}) error {
     data, err := c.client.Get(ctx, key).Result()
     if err == redis.Nil {
-        return fmt.Errorf("key not found")
+        return ErrNotFound
     }
     if err != nil {
         return err
@@ -55,6 +70,25 @@ func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
     return c.client.Del(ctx, keys...).Err()
 }
 
+// DeletePattern removes every key matching a glob pattern. It walks the
+// keyspace with SCAN so large caches don't block Redis.
+func (c *RedisCache) DeletePattern(ctx context.Context, pattern string) error {
+	iter := c.client.Scan(ctx, 0, pattern, 100).Iterator()
+
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.client.Del(ctx, keys...).Err()
+}
+
 func (c *RedisCache) Exists(ctx context.Context, key string) (bool, error) {
     result, err := c.client.Exists(ctx, key).Result()
     if err != nil {
@@ -0,0 +1,109 @@
+package cart
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/product"
+)
+
+// MaxQuantity is the most units of one product a cart line can hold.
+const MaxQuantity = 99
+
+// ErrUnknownProduct is returned when adding a product that doesn't exist or
+// has been archived.
+var ErrUnknownProduct = errors.New("product not found")
+
+// Item is a stored cart line. Prices aren't stored; they're looked up when
+// the cart is read so the cart always reflects current prices.
+type Item struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// Line is an item priced at the product's current price.
+type Line struct {
+	ProductID int         `json:"product_id"`
+	Name      string      `json:"name"`
+	UnitPrice money.Money `json:"unit_price"`
+	Quantity  int         `json:"quantity"`
+	Subtotal  money.Money `json:"subtotal"`
+	// Discount is this line's share of the coupon discount.
+	Discount money.Money `json:"discount"`
+}
+
+// AppliedCoupon is the coupon stored on a cart. A coupon that no longer
+// applies, say because an item was removed, stays on the cart with Error
+// explaining why and no discount.
+type AppliedCoupon struct {
+	Code     string      `json:"code"`
+	Discount money.Money `json:"discount"`
+	Error    string      `json:"error,omitempty"`
+}
+
+type Cart struct {
+	// ID identifies an anonymous cart and is empty for a user's cart.
+	ID        string         `json:"id,omitempty"`
+	Lines     []Line         `json:"items"`
+	ItemCount int            `json:"item_count"`
+	Subtotal  money.Money    `json:"subtotal"`
+	Coupon    *AppliedCoupon `json:"coupon,omitempty"`
+	Discount  money.Money    `json:"discount"`
+	Total     money.Money    `json:"total"`
+}
+
+// emptyCart is a cart with no lines and zero totals in the catalog currency.
+func emptyCart() Cart {
+	zero := money.New(0, product.BaseCurrency)
+	return Cart{Lines: []Line{}, Subtotal: zero, Discount: zero, Total: zero}
+}
+
+// Owner is whoever a cart belongs to: a logged-in user or, when UserID is
+// zero, the holder of an anonymous cart ID.
+type Owner struct {
+	UserID int
+	CartID string
+}
+
+func (o Owner) Anonymous() bool {
+	return o.UserID == 0
+}
+
+// key is the ID the owner's cart is stored under.
+func (o Owner) key() string {
+	if o.Anonymous() {
+		return o.CartID
+	}
+	return strconv.Itoa(o.UserID)
+}
+
+// NewCartID returns a random ID for an anonymous cart.
+func NewCartID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidCartID reports whether id looks like an ID from NewCartID.
+func ValidCartID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// addItem adds quantity units of productID to items, capping the line at
+// MaxQuantity.
+func addItem(items []Item, productID, quantity int) []Item {
+	for i, item := range items {
+		if item.ProductID == productID {
+			items[i].Quantity = min(item.Quantity+quantity, MaxQuantity)
+			return items
+		}
+	}
+	return append(items, Item{ProductID: productID, Quantity: min(quantity, MaxQuantity)})
+}
@@ -0,0 +1,246 @@
+package cart
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"lukekorsman.com/store/internal/api"
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/promotion"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CartIDHeader carries an anonymous cart's ID. The server sets it when it
+// creates a cart and clients send it back on later requests.
+const CartIDHeader = "X-Cart-ID"
+
+type Handler struct {
+	carts *Service
+}
+
+func NewHandler(carts *Service) *Handler {
+	return &Handler{carts: carts}
+}
+
+type AddItemRequest struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+type UpdateItemRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+type ApplyCouponRequest struct {
+	Code string `json:"code"`
+}
+
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	o := owner(r)
+	if o.Anonymous() && o.CartID == "" {
+		writeJSON(w, http.StatusOK, emptyCart())
+		return
+	}
+
+	c, err := h.carts.Get(r.Context(), o)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeCart(w, o, http.StatusOK, c)
+}
+
+func (h *Handler) AddItem(w http.ResponseWriter, r *http.Request) {
+	var req AddItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if errs := validateQuantity(req.Quantity, 1); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	o := owner(r)
+	if o.Anonymous() && o.CartID == "" {
+		o.CartID = NewCartID()
+	}
+
+	c, err := h.carts.Add(r.Context(), o, req.ProductID, req.Quantity)
+	if errors.Is(err, ErrUnknownProduct) {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []api.ValidationError{{Field: "product_id", Message: err.Error()}},
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeCart(w, o, http.StatusOK, c)
+}
+
+// UpdateItem sets a line's quantity; zero removes the line.
+func (h *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "productID"))
+	if err != nil {
+		http.Error(w, "invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if errs := validateQuantity(req.Quantity, 0); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	h.update(w, r, productID, req.Quantity)
+}
+
+func (h *Handler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "productID"))
+	if err != nil {
+		http.Error(w, "invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	h.update(w, r, productID, 0)
+}
+
+func (h *Handler) update(w http.ResponseWriter, r *http.Request, productID, quantity int) {
+	o := owner(r)
+	if o.Anonymous() && o.CartID == "" {
+		http.Error(w, "cart not found", http.StatusNotFound)
+		return
+	}
+
+	c, err := h.carts.Update(r.Context(), o, productID, quantity)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeCart(w, o, http.StatusOK, c)
+}
+
+// ApplyCoupon puts a coupon on the cart. Coupons that don't exist or don't
+// apply to the cart are rejected with a validation error.
+func (h *Handler) ApplyCoupon(w http.ResponseWriter, r *http.Request) {
+	var req ApplyCouponRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if promotion.NormalizeCode(req.Code) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []api.ValidationError{{Field: "code", Message: "code is required"}},
+		})
+		return
+	}
+
+	o := owner(r)
+	if o.Anonymous() && o.CartID == "" {
+		http.Error(w, "cart not found", http.StatusNotFound)
+		return
+	}
+
+	c, err := h.carts.ApplyCoupon(r.Context(), o, req.Code)
+	if errors.Is(err, promotion.ErrCouponNotFound) || errors.Is(err, promotion.ErrNotApplicable) {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []api.ValidationError{{Field: "code", Message: err.Error()}},
+		})
+		return
+	}
+	if errors.Is(err, ErrCouponsDisabled) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeCart(w, o, http.StatusOK, c)
+}
+
+func (h *Handler) RemoveCoupon(w http.ResponseWriter, r *http.Request) {
+	o := owner(r)
+	if o.Anonymous() && o.CartID == "" {
+		http.Error(w, "cart not found", http.StatusNotFound)
+		return
+	}
+
+	c, err := h.carts.RemoveCoupon(r.Context(), o)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeCart(w, o, http.StatusOK, c)
+}
+
+// MergeOnLogin folds the anonymous cart named in the login request into the
+// user's cart. It's registered with auth.Handler.OnLogin.
+func (h *Handler) MergeOnLogin(r *http.Request, user auth.User) {
+	cartID := r.Header.Get(CartIDHeader)
+	if !ValidCartID(cartID) {
+		return
+	}
+
+	if err := h.carts.Merge(r.Context(), cartID, user.ID); err != nil {
+		fmt.Printf("Failed to merge cart %s into user %d: %v\n", cartID, user.ID, err)
+	}
+}
+
+// owner is the logged-in user, or else the anonymous cart in the request
+// header. Malformed cart IDs are ignored.
+func owner(r *http.Request) Owner {
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		return Owner{UserID: user.ID}
+	}
+
+	if cartID := r.Header.Get(CartIDHeader); ValidCartID(cartID) {
+		return Owner{CartID: cartID}
+	}
+	return Owner{}
+}
+
+func validateQuantity(quantity, minimum int) []api.ValidationError {
+	if quantity < minimum || quantity > MaxQuantity {
+		return []api.ValidationError{{
+			Field:   "quantity",
+			Message: fmt.Sprintf("quantity must be between %d and %d", minimum, MaxQuantity),
+		}}
+	}
+	return nil
+}
+
+func writeCart(w http.ResponseWriter, o Owner, status int, c Cart) {
+	if o.Anonymous() {
+		w.Header().Set(CartIDHeader, o.CartID)
+	}
+	writeJSON(w, status, c)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,260 @@
+package cart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/money/moneytest"
+	"lukekorsman.com/store/internal/product"
+	"lukekorsman.com/store/internal/promotion"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newCartRouter(h *Handler) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/cart", h.Get)
+	r.Post("/cart/items", h.AddItem)
+	r.Put("/cart/items/{productID}", h.UpdateItem)
+	r.Delete("/cart/items/{productID}", h.RemoveItem)
+	r.Post("/cart/coupon", h.ApplyCoupon)
+	r.Delete("/cart/coupon", h.RemoveCoupon)
+	return r
+}
+
+func doCart(t *testing.T, router http.Handler, method, path, cartID, body string) (*httptest.ResponseRecorder, Cart) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if cartID != "" {
+		req.Header.Set(CartIDHeader, cartID)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var c Cart
+	json.NewDecoder(rec.Body).Decode(&c)
+	return rec, c
+}
+
+func TestAnonymousCart(t *testing.T) {
+	ctx := context.Background()
+	products := product.NewMemoryStore()
+	mug, _ := products.Create(ctx, product.Product{Name: "Mug", Price: moneytest.USD(499)})
+	pen, _ := products.Create(ctx, product.Product{Name: "Pen", Price: moneytest.USD(150)})
+
+	service := NewService(products, NewMemoryStore(AnonymousCartTTL), NewMemoryStore(0), nil)
+	router := newCartRouter(NewHandler(service))
+
+	rec, c := doCart(t, router, http.MethodPost, "/cart/items", "", `{"product_id":1,"quantity":3}`)
+	cartID := rec.Header().Get(CartIDHeader)
+	if rec.Code != http.StatusOK || !ValidCartID(cartID) {
+		t.Fatalf("expected a new cart, got %d with ID %q", rec.Code, cartID)
+	}
+	if c.Subtotal != moneytest.USD(1497) || c.ItemCount != 3 {
+		t.Fatalf("expected 3 items totalling 14.97, got %+v", c)
+	}
+
+	doCart(t, router, http.MethodPost, "/cart/items", cartID, `{"product_id":2,"quantity":2}`)
+	doCart(t, router, http.MethodPost, "/cart/items", cartID, `{"product_id":1,"quantity":1}`)
+
+	// Prices come from the product store when the cart is read.
+	mug.Price = moneytest.USD(500)
+	products.Update(ctx, mug.ID, mug)
+
+	_, c = doCart(t, router, http.MethodGet, "/cart", cartID, "")
+	if len(c.Lines) != 2 || c.Lines[0].Quantity != 4 || c.Lines[0].Subtotal != moneytest.USD(2000) || c.Subtotal != moneytest.USD(2300) {
+		t.Fatalf("unexpected cart %+v", c)
+	}
+
+	_, c = doCart(t, router, http.MethodPut, "/cart/items/1", cartID, `{"quantity":0}`)
+	if len(c.Lines) != 1 || c.Lines[0].ProductID != pen.ID {
+		t.Fatalf("expected only the pen left, got %+v", c)
+	}
+
+	// Archived products drop out of the cart.
+	products.Delete(ctx, pen.ID, 0)
+	_, c = doCart(t, router, http.MethodGet, "/cart", cartID, "")
+	if len(c.Lines) != 0 || !c.Subtotal.IsZero() {
+		t.Fatalf("expected an empty cart, got %+v", c)
+	}
+}
+
+func TestCartItemErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "unknown product",
+			method:     http.MethodPost,
+			path:       "/cart/items",
+			body:       `{"product_id":99,"quantity":1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "zero quantity",
+			method:     http.MethodPost,
+			path:       "/cart/items",
+			body:       `{"product_id":1,"quantity":0}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too many",
+			method:     http.MethodPut,
+			path:       "/cart/items/1",
+			body:       `{"quantity":100}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not in cart",
+			method:     http.MethodDelete,
+			path:       "/cart/items/2",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			products := product.NewMemoryStore()
+			products.Create(ctx, product.Product{Name: "Mug", Price: moneytest.USD(499)})
+			products.Create(ctx, product.Product{Name: "Pen", Price: moneytest.USD(150)})
+
+			service := NewService(products, NewMemoryStore(AnonymousCartTTL), NewMemoryStore(0), nil)
+			cartID := NewCartID()
+			service.Add(ctx, Owner{CartID: cartID}, 1, 1)
+
+			rec, _ := doCart(t, newCartRouter(NewHandler(service)), tt.method, tt.path, cartID, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCartCoupon(t *testing.T) {
+	ctx := context.Background()
+	products := product.NewMemoryStore()
+	products.Create(ctx, product.Product{Name: "Mug", Price: moneytest.USD(499)})
+	products.Create(ctx, product.Product{Name: "Pen", Price: moneytest.USD(150)})
+
+	five, ten := moneytest.USD(500), moneytest.USD(1000)
+	coupons := promotion.NewMemoryStore()
+	coupons.Create(ctx, promotion.Coupon{Code: "SAVE5", Kind: promotion.KindFixed, Amount: &five, MinSpend: &ten, Active: true})
+	promotions := promotion.NewService(coupons, product.NewMemoryCategoryStore())
+
+	service := NewService(products, NewMemoryStore(AnonymousCartTTL), NewMemoryStore(0), promotions)
+	router := newCartRouter(NewHandler(service))
+	cartID := NewCartID()
+	service.Add(ctx, Owner{CartID: cartID}, 1, 2)
+	service.Add(ctx, Owner{CartID: cartID}, 2, 1)
+
+	if rec, _ := doCart(t, router, http.MethodPost, "/cart/coupon", cartID, `{"code":"NOPE"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown code to be rejected, got %d", rec.Code)
+	}
+
+	rec, c := doCart(t, router, http.MethodPost, "/cart/coupon", cartID, `{"code":"save5"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if c.Coupon == nil || c.Coupon.Code != "SAVE5" || c.Discount != moneytest.USD(500) || c.Total != moneytest.USD(648) {
+		t.Fatalf("unexpected cart %+v", c)
+	}
+	if c.Lines[0].Discount != moneytest.USD(435) || c.Lines[1].Discount != moneytest.USD(65) {
+		t.Fatalf("expected the discount spread by subtotal, got %+v", c.Lines)
+	}
+
+	// Dropping below the minimum spend keeps the coupon but removes the discount.
+	_, c = doCart(t, router, http.MethodPut, "/cart/items/1", cartID, `{"quantity":1}`)
+	if c.Coupon == nil || c.Coupon.Error == "" || !c.Discount.IsZero() || c.Total != c.Subtotal {
+		t.Fatalf("expected an inapplicable coupon, got %+v", c)
+	}
+
+	rec, c = doCart(t, router, http.MethodDelete, "/cart/coupon", cartID, "")
+	if rec.Code != http.StatusOK || c.Coupon != nil {
+		t.Fatalf("expected the coupon to be removed, got %+v", c)
+	}
+
+	// The coupon follows the cart when its owner logs in.
+	service.Update(ctx, Owner{CartID: cartID}, 1, 2)
+	if _, err := service.ApplyCoupon(ctx, Owner{CartID: cartID}, "SAVE5"); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+	req.Header.Set(CartIDHeader, cartID)
+	NewHandler(service).MergeOnLogin(req, auth.User{ID: 7})
+	if c, _ := service.Get(ctx, Owner{UserID: 7}); c.Coupon == nil || c.Coupon.Code != "SAVE5" || c.Discount != moneytest.USD(500) {
+		t.Fatalf("expected the coupon to carry over, got %+v", c)
+	}
+}
+
+func TestMergeOnLogin(t *testing.T) {
+	ctx := context.Background()
+	products := product.NewMemoryStore()
+	products.Create(ctx, product.Product{Name: "Mug", Price: moneytest.USD(499)})
+	products.Create(ctx, product.Product{Name: "Pen", Price: moneytest.USD(150)})
+
+	service := NewService(products, NewMemoryStore(AnonymousCartTTL), NewMemoryStore(0), nil)
+	user := Owner{UserID: 42}
+	service.Add(ctx, user, 1, 98)
+
+	cartID := NewCartID()
+	service.Add(ctx, Owner{CartID: cartID}, 1, 5)
+	service.Add(ctx, Owner{CartID: cartID}, 2, 2)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+	req.Header.Set(CartIDHeader, cartID)
+	NewHandler(service).MergeOnLogin(req, auth.User{ID: 42})
+
+	c, _ := service.Get(ctx, user)
+	if len(c.Lines) != 2 || c.Lines[0].Quantity != MaxQuantity || c.Lines[1].Quantity != 2 {
+		t.Fatalf("expected merged cart capped at %d, got %+v", MaxQuantity, c)
+	}
+
+	if items, _ := service.Items(ctx, Owner{CartID: cartID}); len(items) != 0 {
+		t.Fatalf("expected the anonymous cart to be deleted, got %+v", items)
+	}
+}
+
+// flakyProducts fails lookups while down, the way an unreachable database
+// would.
+type flakyProducts struct {
+	*product.MemoryStore
+	down bool
+}
+
+func (s *flakyProducts) GetByID(ctx context.Context, id int) (product.Product, error) {
+	if s.down {
+		return product.Product{}, errors.New("connection refused")
+	}
+	return s.MemoryStore.GetByID(ctx, id)
+}
+
+func TestCartProductLookupFailure(t *testing.T) {
+	ctx := context.Background()
+	products := &flakyProducts{MemoryStore: product.NewMemoryStore()}
+	products.Create(ctx, product.Product{Name: "Mug", Price: moneytest.USD(499)})
+
+	service := NewService(products, NewMemoryStore(AnonymousCartTTL), NewMemoryStore(0), nil)
+	owner := Owner{CartID: NewCartID()}
+	if _, err := service.Add(ctx, owner, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	products.down = true
+	if _, err := service.Get(ctx, owner); err == nil {
+		t.Fatal("expected the lookup failure instead of a cart without the mug")
+	}
+	if _, err := service.Add(ctx, owner, 1, 1); err == nil || errors.Is(err, ErrUnknownProduct) {
+		t.Fatalf("expected the lookup failure, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package cart
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// MySQLStore keeps logged-in users' carts. Cart IDs are user IDs.
+type MySQLStore struct {
+	db *sql.DB
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func userID(id string) (int, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user cart ID %q", id)
+	}
+	return n, nil
+}
+
+func (s *MySQLStore) Get(ctx context.Context, id string) ([]Item, error) {
+	uid, err := userID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT product_id, quantity FROM cart_items WHERE user_id = ? ORDER BY position",
+		uid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Item{}
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func (s *MySQLStore) Save(ctx context.Context, id string, items []Item) error {
+	uid, err := userID(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM cart_items WHERE user_id = ?", uid); err != nil {
+		return err
+	}
+	for i, item := range items {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO cart_items (user_id, product_id, quantity, position) VALUES (?, ?, ?, ?)",
+			uid, item.ProductID, item.Quantity, i,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *MySQLStore) Coupon(ctx context.Context, id string) (string, error) {
+	uid, err := userID(id)
+	if err != nil {
+		return "", err
+	}
+
+	var code string
+	err = s.db.QueryRowContext(ctx, "SELECT coupon_code FROM carts WHERE user_id = ?", uid).Scan(&code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return code, err
+}
+
+func (s *MySQLStore) SetCoupon(ctx context.Context, id, code string) error {
+	uid, err := userID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		"INSERT INTO carts (user_id, coupon_code) VALUES (?, ?) ON DUPLICATE KEY UPDATE coupon_code = VALUES(coupon_code)",
+		uid, code,
+	)
+	return err
+}
+
+func (s *MySQLStore) Delete(ctx context.Context, id string) error {
+	uid, err := userID(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM cart_items WHERE user_id = ?", uid); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM carts WHERE user_id = ?", uid); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,264 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/product"
+	"lukekorsman.com/store/internal/promotion"
+)
+
+// ErrCouponsDisabled is returned when applying a coupon to a service
+// created without promotions.
+var ErrCouponsDisabled = errors.New("coupons are not enabled")
+
+// Service prices carts against the product store and keeps anonymous and
+// user carts in their own stores. Coupons are applied through promotions,
+// which may be nil.
+type Service struct {
+	products   product.Store
+	anonymous  Store
+	users      Store
+	promotions *promotion.Service
+}
+
+func NewService(products product.Store, anonymous, users Store, promotions *promotion.Service) *Service {
+	return &Service{
+		products:   products,
+		anonymous:  anonymous,
+		users:      users,
+		promotions: promotions,
+	}
+}
+
+func (s *Service) store(o Owner) Store {
+	if o.Anonymous() {
+		return s.anonymous
+	}
+	return s.users
+}
+
+// Items returns the owner's stored items without pricing them.
+func (s *Service) Items(ctx context.Context, o Owner) ([]Item, error) {
+	return s.store(o).Get(ctx, o.key())
+}
+
+// Get returns the owner's cart priced at current product prices, with its
+// coupon applied. Items whose product has since been archived are left out.
+func (s *Service) Get(ctx context.Context, o Owner) (Cart, error) {
+	c, lines, err := s.price(ctx, o)
+	if err != nil {
+		return Cart{}, err
+	}
+
+	code, err := s.store(o).Coupon(ctx, o.key())
+	if err != nil {
+		return Cart{}, err
+	}
+	if code == "" || s.promotions == nil {
+		return c, nil
+	}
+
+	c.Coupon = &AppliedCoupon{Code: code}
+	result, err := s.promotions.Evaluate(ctx, code, o.UserID, lines)
+	if errors.Is(err, promotion.ErrNotApplicable) || errors.Is(err, promotion.ErrCouponNotFound) {
+		c.Coupon.Error = err.Error()
+		return c, nil
+	}
+	if err != nil {
+		return Cart{}, err
+	}
+
+	applyDiscount(&c, result)
+	return c, nil
+}
+
+// price prices the owner's items and returns the cart without a coupon
+// alongside the lines the promotion engine needs.
+func (s *Service) price(ctx context.Context, o Owner) (Cart, []promotion.Line, error) {
+	items, err := s.Items(ctx, o)
+	if err != nil {
+		return Cart{}, nil, err
+	}
+
+	c := emptyCart()
+	if o.Anonymous() {
+		c.ID = o.CartID
+	}
+	var lines []promotion.Line
+	for _, item := range items {
+		p, err := s.products.GetByID(ctx, item.ProductID)
+		if errors.Is(err, product.ErrProductNotFound) {
+			// The product was deleted since it was added; drop the line.
+			continue
+		}
+		if err != nil {
+			return Cart{}, nil, err
+		}
+
+		line := Line{
+			ProductID: p.ID,
+			Name:      p.Name,
+			UnitPrice: p.Price,
+			Quantity:  item.Quantity,
+			Subtotal:  p.Price.Mul(item.Quantity),
+			Discount:  money.New(0, p.Price.Currency),
+		}
+		c.Lines = append(c.Lines, line)
+		c.ItemCount += line.Quantity
+		c.Subtotal = c.Subtotal.Add(line.Subtotal)
+		lines = append(lines, promotion.Line{
+			ProductID:  p.ID,
+			CategoryID: p.CategoryID,
+			UnitPrice:  p.Price,
+			Quantity:   item.Quantity,
+		})
+	}
+	c.Total = c.Subtotal
+	return c, lines, nil
+}
+
+// ApplyCoupon puts the coupon with code on the cart. It fails with
+// promotion.ErrCouponNotFound or promotion.ErrNotApplicable if the coupon
+// can't be used on the cart as it stands.
+func (s *Service) ApplyCoupon(ctx context.Context, o Owner, code string) (Cart, error) {
+	if s.promotions == nil {
+		return Cart{}, ErrCouponsDisabled
+	}
+
+	_, lines, err := s.price(ctx, o)
+	if err != nil {
+		return Cart{}, err
+	}
+
+	result, err := s.promotions.Evaluate(ctx, code, o.UserID, lines)
+	if err != nil {
+		return Cart{}, err
+	}
+
+	if err := s.store(o).SetCoupon(ctx, o.key(), result.Code); err != nil {
+		return Cart{}, err
+	}
+	return s.Get(ctx, o)
+}
+
+func (s *Service) RemoveCoupon(ctx context.Context, o Owner) (Cart, error) {
+	if err := s.store(o).SetCoupon(ctx, o.key(), ""); err != nil {
+		return Cart{}, err
+	}
+	return s.Get(ctx, o)
+}
+
+// Add puts quantity more units of a product in the cart.
+func (s *Service) Add(ctx context.Context, o Owner, productID, quantity int) (Cart, error) {
+	_, err := s.products.GetByID(ctx, productID)
+	if errors.Is(err, product.ErrProductNotFound) {
+		return Cart{}, fmt.Errorf("%w: %d", ErrUnknownProduct, productID)
+	}
+	if err != nil {
+		return Cart{}, err
+	}
+
+	items, err := s.Items(ctx, o)
+	if err != nil {
+		return Cart{}, err
+	}
+
+	return s.save(ctx, o, addItem(items, productID, quantity))
+}
+
+// Update sets the quantity of a product already in the cart. A quantity of
+// zero removes it.
+func (s *Service) Update(ctx context.Context, o Owner, productID, quantity int) (Cart, error) {
+	items, err := s.Items(ctx, o)
+	if err != nil {
+		return Cart{}, err
+	}
+
+	for i, item := range items {
+		if item.ProductID != productID {
+			continue
+		}
+		if quantity == 0 {
+			items = append(items[:i], items[i+1:]...)
+		} else {
+			items[i].Quantity = quantity
+		}
+		return s.save(ctx, o, items)
+	}
+
+	return Cart{}, fmt.Errorf("product %d is not in the cart", productID)
+}
+
+func (s *Service) Remove(ctx context.Context, o Owner, productID int) (Cart, error) {
+	return s.Update(ctx, o, productID, 0)
+}
+
+// Clear empties the owner's cart.
+func (s *Service) Clear(ctx context.Context, o Owner) error {
+	return s.store(o).Delete(ctx, o.key())
+}
+
+// Merge moves an anonymous cart's items into a user's cart and deletes the
+// anonymous cart.
+func (s *Service) Merge(ctx context.Context, cartID string, userID int) error {
+	anonymous := Owner{CartID: cartID}
+	items, err := s.Items(ctx, anonymous)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+
+	user := Owner{UserID: userID}
+	merged, err := s.Items(ctx, user)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		merged = addItem(merged, item.ProductID, item.Quantity)
+	}
+
+	if err := s.store(user).Save(ctx, user.key(), merged); err != nil {
+		return err
+	}
+
+	// The anonymous cart's coupon carries over unless the user already has one.
+	code, err := s.store(anonymous).Coupon(ctx, anonymous.key())
+	if err != nil {
+		return err
+	}
+	if code != "" {
+		current, err := s.store(user).Coupon(ctx, user.key())
+		if err != nil {
+			return err
+		}
+		if current == "" {
+			if err := s.store(user).SetCoupon(ctx, user.key(), code); err != nil {
+				return err
+			}
+		}
+	}
+	return s.Clear(ctx, anonymous)
+}
+
+func (s *Service) save(ctx context.Context, o Owner, items []Item) (Cart, error) {
+	if err := s.store(o).Save(ctx, o.key(), items); err != nil {
+		return Cart{}, err
+	}
+	return s.Get(ctx, o)
+}
+
+// applyDiscount copies a coupon result onto the cart's lines and totals.
+func applyDiscount(c *Cart, result promotion.Result) {
+	for _, d := range result.Lines {
+		for i := range c.Lines {
+			if c.Lines[i].ProductID == d.ProductID {
+				c.Lines[i].Discount = d.Discount
+			}
+		}
+	}
+	c.Coupon.Discount = result.Discount
+	c.Discount = result.Discount
+	c.Total = c.Subtotal.Sub(c.Discount)
+}
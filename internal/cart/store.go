@@ -0,0 +1,154 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"lukekorsman.com/store/internal/cache"
+)
+
+// AnonymousCartTTL is how long an anonymous cart survives without changes.
+const AnonymousCartTTL = 7 * 24 * time.Hour
+
+// Store persists cart items and the applied coupon code by cart ID. A cart
+// that doesn't exist reads as empty with no coupon.
+type Store interface {
+	Get(ctx context.Context, id string) ([]Item, error)
+	Save(ctx context.Context, id string, items []Item) error
+	Coupon(ctx context.Context, id string) (string, error)
+	// SetCoupon stores the cart's coupon code; an empty code removes it.
+	SetCoupon(ctx context.Context, id, code string) error
+	// Delete removes the cart's items and coupon.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore keeps carts in memory. A non-zero ttl expires carts that
+// haven't been saved for that long.
+type MemoryStore struct {
+	carts   map[string][]Item
+	coupons map[string]string
+	expires map[string]time.Time
+	ttl     time.Duration
+	mu      sync.RWMutex
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		carts:   make(map[string][]Item),
+		coupons: make(map[string]string),
+		expires: make(map[string]time.Time),
+		ttl:     ttl,
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) ([]Item, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if expires, ok := s.expires[id]; ok && time.Now().After(expires) {
+		return []Item{}, nil
+	}
+	return append([]Item{}, s.carts[id]...), nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, id string, items []Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.carts[id] = append([]Item{}, items...)
+	if s.ttl > 0 {
+		s.expires[id] = time.Now().Add(s.ttl)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Coupon(ctx context.Context, id string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if expires, ok := s.expires[id]; ok && time.Now().After(expires) {
+		return "", nil
+	}
+	return s.coupons[id], nil
+}
+
+func (s *MemoryStore) SetCoupon(ctx context.Context, id, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if code == "" {
+		delete(s.coupons, id)
+	} else {
+		s.coupons[id] = code
+	}
+	if s.ttl > 0 {
+		s.expires[id] = time.Now().Add(s.ttl)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.carts, id)
+	delete(s.coupons, id)
+	delete(s.expires, id)
+	return nil
+}
+
+// RedisStore keeps anonymous carts in Redis. Every save restarts the TTL.
+type RedisStore struct {
+	cache *cache.RedisCache
+	ttl   time.Duration
+}
+
+func NewRedisStore(cache *cache.RedisCache, ttl time.Duration) *RedisStore {
+	return &RedisStore{cache: cache, ttl: ttl}
+}
+
+func redisKey(id string) string {
+	return "cart:" + id
+}
+
+func (s *RedisStore) Get(ctx context.Context, id string) ([]Item, error) {
+	var items []Item
+	err := s.cache.Get(ctx, redisKey(id), &items)
+	if errors.Is(err, cache.ErrNotFound) {
+		return []Item{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, id string, items []Item) error {
+	return s.cache.Set(ctx, redisKey(id), items, s.ttl)
+}
+
+func couponKey(id string) string {
+	return "cart:" + id + ":coupon"
+}
+
+func (s *RedisStore) Coupon(ctx context.Context, id string) (string, error) {
+	var code string
+	err := s.cache.Get(ctx, couponKey(id), &code)
+	if errors.Is(err, cache.ErrNotFound) {
+		return "", nil
+	}
+	return code, err
+}
+
+func (s *RedisStore) SetCoupon(ctx context.Context, id, code string) error {
+	if code == "" {
+		return s.cache.Delete(ctx, couponKey(id))
+	}
+	return s.cache.Set(ctx, couponKey(id), code, s.ttl)
+}
+
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	return s.cache.Delete(ctx, redisKey(id), couponKey(id))
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -10,6 +12,21 @@ type Config struct {
 	RedisURL		string
 	JWTSecret		string
 	Environment		string
+	PaymentWebhookSecret	string
+	RatesFile		string
+	MediaDir		string
+	MediaURL		string
+	ImportDir		string
+	JobWorkers		int
+	PasswordHash		string
+	BcryptCost		int
+	Argon2Memory		int
+	Argon2Time		int
+	Argon2Threads		int
+	AccessTokenTTL		time.Duration
+	RefreshTokenTTL		time.Duration
+	BootstrapAdminEmail	string
+	BootstrapAdminPassword	string
 }
 
 func Load() *Config {
@@ -19,6 +36,21 @@ func Load() *Config {
 		RedisURL:    getEnv("REDIS_URL", "localhost:6379"),
         JWTSecret:   getEnv("JWT_SECRET", "xxxxx"),
         Environment: getEnv("ENVIRONMENT", "development"),
+        PaymentWebhookSecret: getEnv("PAYMENT_WEBHOOK_SECRET", "dev-webhook-secret"),
+        RatesFile:   getEnv("RATES_FILE", "rates.json"),
+        MediaDir:    getEnv("MEDIA_DIR", "media"),
+        MediaURL:    getEnv("MEDIA_URL", "/media"),
+        ImportDir:   getEnv("IMPORT_DIR", "imports"),
+        JobWorkers:  getEnvInt("JOB_WORKERS", 4),
+        PasswordHash: getEnv("PASSWORD_HASH", "argon2id"),
+        BcryptCost:  getEnvInt("BCRYPT_COST", 10),
+        Argon2Memory: getEnvInt("ARGON2_MEMORY", 19456),
+        Argon2Time:  getEnvInt("ARGON2_TIME", 2),
+        Argon2Threads: getEnvInt("ARGON2_THREADS", 1),
+        AccessTokenTTL: getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+        RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+        BootstrapAdminEmail: getEnv("BOOTSTRAP_ADMIN_EMAIL", ""),
+        BootstrapAdminPassword: getEnv("BOOTSTRAP_ADMIN_PASSWORD", ""),
 	}
 }
 
@@ -27,4 +59,18 @@ func getEnv(key, defaultVal string) string {
         return val
     }
     return defaultVal
+}
+
+func getEnvInt(key string, defaultVal int) int {
+    if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+        return n
+    }
+    return defaultVal
+}
+
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+    if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+        return d
+    }
+    return defaultVal
 }
\ No newline at end of file
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// Open connects to MySQL and brings the schema up to date. The returned pool
+// is shared by every MySQL-backed store.
+func Open(connStr string) (*sql.DB, error) {
+	db, err := sql.Open("mysql", connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := RunMigrations(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migration failed: %w", err)
+	}
+
+	return db, nil
+}
@@ -0,0 +1,346 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/cache"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotencyReplayedHeader is set on responses replayed from the store.
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	// IdempotencyTTL is how long a response is kept for replays.
+	IdempotencyTTL = 24 * time.Hour
+	// idempotencyLockTTL bounds how long a request that never finishes, e.g.
+	// because the API crashed, blocks retries with the same key. The lock is
+	// renewed while the request runs, so slow requests keep it.
+	idempotencyLockTTL = time.Minute
+
+	// MaxIdempotentBodySize is the largest body the middleware reads. It has
+	// to be at least the largest body any write route accepts (10 MB imports
+	// and image uploads), or those requests fail with 413 before reaching it.
+	MaxIdempotentBodySize = 32 << 20
+
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyRecord is a request seen with an Idempotency-Key. Until the
+// request finishes only the fingerprint is set.
+type IdempotencyRecord struct {
+	Fingerprint string      `json:"fingerprint"`
+	Done        bool        `json:"done"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+type IdempotencyStore interface {
+	// Reserve saves rec under key unless the key is taken, in which case it
+	// returns the record already there and false.
+	Reserve(ctx context.Context, key string, rec IdempotencyRecord, ttl time.Duration) (IdempotencyRecord, bool, error)
+	Save(ctx context.Context, key string, rec IdempotencyRecord, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Replayer keeps secrets such as tokens out of the responses a route
+// stores. Redact returns the body to store for a response, and Replay
+// answers a repeat of the request from it, or returns false to have the
+// stored response written back as it is.
+type Replayer interface {
+	Redact(status int, body []byte) []byte
+	Replay(w http.ResponseWriter, r *http.Request, status int, body []byte) bool
+}
+
+// Idempotency makes POST, PUT, PATCH and DELETE requests that carry an
+// Idempotency-Key header safe to retry. The first response with a status
+// below 500 is stored for ttl and replayed for later requests with the same
+// key and body. Reusing a key with a different body, or while the first
+// request is still running, is a 409.
+//
+// Keys are scoped to the authenticated user, so the middleware should come
+// after JWTAuth on protected routes.
+func Idempotency(store IdempotencyStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return IdempotencyWithReplayer(store, ttl, nil)
+}
+
+// IdempotencyWithReplayer is Idempotency for routes whose responses can't be
+// stored as they are. Responses are passed through replayer before they're
+// stored, and replays are answered by it.
+func IdempotencyWithReplayer(store IdempotencyStore, ttl time.Duration, replayer Replayer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || !mutating(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength), http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxIdempotentBodySize))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			storeKey := idempotencyStoreKey(r, key)
+			fingerprint := requestFingerprint(r, body)
+
+			existing, reserved, err := store.Reserve(r.Context(), storeKey, IdempotencyRecord{Fingerprint: fingerprint}, idempotencyLockTTL)
+			if err != nil {
+				// Don't turn a Redis outage into an outage of the endpoint.
+				fmt.Printf("Failed to reserve idempotency key: %v\n", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !reserved {
+				switch {
+				case existing.Fingerprint != fingerprint:
+					http.Error(w, "Idempotency-Key has already been used for a different request", http.StatusConflict)
+				case !existing.Done:
+					http.Error(w, "a request with this Idempotency-Key is still in progress", http.StatusConflict)
+				case replayer != nil:
+					w.Header().Set(IdempotencyReplayedHeader, "true")
+					if !replayer.Replay(w, r, existing.Status, existing.Body) {
+						replay(w, existing)
+					}
+				default:
+					replay(w, existing)
+				}
+				return
+			}
+
+			// Record the response even if the client has gone away, since
+			// that's exactly when it will retry.
+			ctx := context.WithoutCancel(r.Context())
+
+			release := holdIdempotencyLock(ctx, store, storeKey, IdempotencyRecord{Fingerprint: fingerprint})
+			rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			release()
+
+			if rec.status >= http.StatusInternalServerError {
+				// Let the client retry failures that may be transient.
+				if err := store.Delete(ctx, storeKey); err != nil {
+					fmt.Printf("Failed to release idempotency key: %v\n", err)
+				}
+				return
+			}
+			stored := rec.body.Bytes()
+			if replayer != nil {
+				stored = replayer.Redact(rec.status, stored)
+			}
+			err = store.Save(ctx, storeKey, IdempotencyRecord{
+				Fingerprint: fingerprint,
+				Done:        true,
+				Status:      rec.status,
+				Header:      rec.header,
+				Body:        stored,
+			}, ttl)
+			if err != nil {
+				fmt.Printf("Failed to save idempotent response: %v\n", err)
+			}
+		})
+	}
+}
+
+// holdIdempotencyLock renews a reserved key's lock until the returned
+// function is called, so a request that runs longer than
+// idempotencyLockTTL isn't run a second time by a retry.
+func holdIdempotencyLock(ctx context.Context, store IdempotencyStore, key string, rec IdempotencyRecord) (release func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(idempotencyLockTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if err := store.Save(ctx, key, rec, idempotencyLockTTL); err != nil {
+				fmt.Printf("Failed to renew idempotency key: %v\n", err)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func idempotencyStoreKey(r *http.Request, key string) string {
+	owner := "anonymous"
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		owner = strconv.Itoa(user.ID)
+	}
+	return "idempotency:" + owner + ":" + key
+}
+
+// requestFingerprint identifies a request by its method, URL and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, rec IdempotencyRecord) {
+	for k, v := range rec.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set(IdempotencyReplayedHeader, "true")
+	w.WriteHeader(rec.Status)
+	w.Write(rec.Body)
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = status
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+type MemoryIdempotencyStore struct {
+	records map[string]memoryIdempotencyRecord
+	// swept is when expired records were last deleted.
+	swept time.Time
+	mu    sync.Mutex
+}
+
+type memoryIdempotencyRecord struct {
+	IdempotencyRecord
+	expiresAt time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]memoryIdempotencyRecord)}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, key string, rec IdempotencyRecord, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+	if existing, ok := s.records[key]; ok && now.Before(existing.expiresAt) {
+		return existing.IdempotencyRecord, false, nil
+	}
+	s.records[key] = memoryIdempotencyRecord{IdempotencyRecord: rec, expiresAt: now.Add(ttl)}
+	return rec, true, nil
+}
+
+func (s *MemoryIdempotencyStore) Save(ctx context.Context, key string, rec IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+	s.records[key] = memoryIdempotencyRecord{IdempotencyRecord: rec, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// sweep deletes expired records, at most once a minute.
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < time.Minute {
+		return
+	}
+	s.swept = now
+	for key, rec := range s.records {
+		if !now.Before(rec.expiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
+
+func (s *MemoryIdempotencyStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+type RedisIdempotencyStore struct {
+	cache *cache.RedisCache
+}
+
+func NewRedisIdempotencyStore(cache *cache.RedisCache) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{cache: cache}
+}
+
+func (s *RedisIdempotencyStore) Reserve(ctx context.Context, key string, rec IdempotencyRecord, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	ok, err := s.cache.SetNX(ctx, key, rec, ttl)
+	if err != nil || ok {
+		return rec, ok, err
+	}
+
+	var existing IdempotencyRecord
+	err = s.cache.Get(ctx, key, &existing)
+	if errors.Is(err, cache.ErrNotFound) {
+		// The key expired or was released in between; report it as still
+		// in progress so the client retries.
+		return IdempotencyRecord{Fingerprint: rec.Fingerprint}, false, nil
+	}
+	return existing, false, err
+}
+
+func (s *RedisIdempotencyStore) Save(ctx context.Context, key string, rec IdempotencyRecord, ttl time.Duration) error {
+	return s.cache.Set(ctx, key, rec, ttl)
+}
+
+func (s *RedisIdempotencyStore) Delete(ctx context.Context, key string) error {
+	return s.cache.Delete(ctx, key)
+}
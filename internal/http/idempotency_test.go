@@ -0,0 +1,188 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lukekorsman.com/store/internal/auth"
+)
+
+func TestIdempotency(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	calls := 0
+	status := http.StatusCreated
+	handler := Idempotency(store, IdempotencyTTL)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Location", "/products/1")
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+
+	do := func(method, key, body string, user *auth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/products", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		if user != nil {
+			req = req.WithContext(auth.ContextWithUser(req.Context(), *user))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	alice := &auth.User{ID: 1}
+	bob := &auth.User{ID: 2}
+
+	tests := []struct {
+		name         string
+		method       string
+		key          string
+		body         string
+		user         *auth.User
+		status       int
+		wantStatus   int
+		wantCalls    int
+		wantReplayed bool
+	}{
+		{name: "first request runs", method: http.MethodPost, key: "a", body: `{"n":1}`, user: alice, wantStatus: http.StatusCreated, wantCalls: 1},
+		{name: "repeat is replayed", method: http.MethodPost, key: "a", body: `{"n":1}`, user: alice, wantStatus: http.StatusCreated, wantCalls: 1, wantReplayed: true},
+		{name: "different body conflicts", method: http.MethodPost, key: "a", body: `{"n":2}`, user: alice, wantStatus: http.StatusConflict, wantCalls: 1},
+		{name: "keys are scoped to the user", method: http.MethodPost, key: "a", body: `{"n":2}`, user: bob, wantStatus: http.StatusCreated, wantCalls: 2},
+		{name: "anonymous keys are separate", method: http.MethodPost, key: "a", body: `{"n":1}`, wantStatus: http.StatusCreated, wantCalls: 3},
+		{name: "no key", method: http.MethodPost, body: `{"n":1}`, user: alice, wantStatus: http.StatusCreated, wantCalls: 4},
+		{name: "reads are not stored", method: http.MethodGet, key: "b", user: alice, wantStatus: http.StatusCreated, wantCalls: 5},
+		{name: "reads are not replayed", method: http.MethodGet, key: "b", user: alice, wantStatus: http.StatusCreated, wantCalls: 6},
+		{name: "server errors are not stored", method: http.MethodPost, key: "c", user: alice, status: http.StatusInternalServerError, wantStatus: http.StatusInternalServerError, wantCalls: 7},
+		{name: "server errors can be retried", method: http.MethodPost, key: "c", user: alice, wantStatus: http.StatusCreated, wantCalls: 8},
+		{name: "key too long", method: http.MethodPost, key: strings.Repeat("k", maxIdempotencyKeyLength+1), user: alice, wantStatus: http.StatusBadRequest, wantCalls: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status = http.StatusCreated
+			if tt.status != 0 {
+				status = tt.status
+			}
+
+			rec := do(tt.method, tt.key, tt.body, tt.user)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if calls != tt.wantCalls {
+				t.Fatalf("expected the handler to have run %d times, got %d", tt.wantCalls, calls)
+			}
+			if replayed := rec.Header().Get(IdempotencyReplayedHeader) == "true"; replayed != tt.wantReplayed {
+				t.Fatalf("expected replayed %v, got %v", tt.wantReplayed, replayed)
+			}
+			if tt.wantReplayed && (rec.Body.String() != tt.body || rec.Header().Get("Location") != "/products/1") {
+				t.Fatalf("expected the first response to be replayed, got %q with headers %v", rec.Body.String(), rec.Header())
+			}
+		})
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := Idempotency(store, IdempotencyTTL)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "k")
+		return req
+	}
+
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest())
+		done <- rec.Code
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest())
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected %d while the first request runs, got %d", http.StatusConflict, rec.Code)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d", http.StatusCreated, code)
+	}
+
+	if _, reserved, _ := store.Reserve(context.Background(), "idempotency:anonymous:k", IdempotencyRecord{}, IdempotencyTTL); reserved {
+		t.Fatal("expected the finished response to keep the key")
+	}
+}
+
+type stubReplayer struct{}
+
+func (stubReplayer) Redact(status int, body []byte) []byte {
+	return []byte("redacted")
+}
+
+func (stubReplayer) Replay(w http.ResponseWriter, r *http.Request, status int, body []byte) bool {
+	w.WriteHeader(status)
+	w.Write([]byte("fresh from " + string(body)))
+	return true
+}
+
+func TestIdempotencyWithReplayer(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	calls := 0
+	handler := IdempotencyWithReplayer(store, IdempotencyTTL, stubReplayer{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("secret"))
+	}))
+
+	do := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "k")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(); rec.Body.String() != "secret" {
+		t.Fatalf("expected the handler's response, got %q", rec.Body.String())
+	}
+	existing, _, _ := store.Reserve(context.Background(), "idempotency:anonymous:k", IdempotencyRecord{}, IdempotencyTTL)
+	if string(existing.Body) != "redacted" {
+		t.Fatalf("expected the redacted body to be stored, got %q", existing.Body)
+	}
+
+	rec := do()
+	if calls != 1 {
+		t.Fatalf("expected the handler to run once, ran %d times", calls)
+	}
+	if rec.Code != http.StatusCreated || rec.Body.String() != "fresh from redacted" || rec.Header().Get(IdempotencyReplayedHeader) != "true" {
+		t.Fatalf("expected the replayer to answer, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMemoryIdempotencyStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryIdempotencyStore()
+	store.Save(ctx, "expired", IdempotencyRecord{Done: true}, -time.Second)
+	// A minute later the next write sweeps.
+	store.swept = store.swept.Add(-time.Minute)
+	store.Reserve(ctx, "live", IdempotencyRecord{}, time.Minute)
+
+	if _, ok := store.records["expired"]; ok || len(store.records) != 1 {
+		t.Fatalf("expected only the live record to be kept, got %v", store.records)
+	}
+}
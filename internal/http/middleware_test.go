@@ -1,9 +1,14 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"lukekorsman.com/store/internal/auth"
 )
 
 func TestSimpleAuth(t *testing.T) {
@@ -61,4 +66,179 @@ func TestSimpleAuth(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestOptionalJWTAuth(t *testing.T) {
+	jwtManager := auth.NewJWTManager("secret", "test")
+	userStore := auth.NewMemoryUserStore()
+	user, _ := userStore.Create(context.Background(), "a@example.com", "password")
+	token, _ := jwtManager.Generate(user.ID, user.Email, user.Roles, time.Hour)
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantUserID int
+	}{
+		{
+			name:       "anonymous",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "valid token",
+			header:     "Bearer " + token,
+			wantStatus: http.StatusOK,
+			wantUserID: user.ID,
+		},
+		{
+			name:       "invalid token",
+			header:     "Bearer nope",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID := 0
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if u, ok := auth.UserFromContext(r.Context()); ok {
+					gotUserID = u.ID
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			OptionalJWTAuth(jwtManager, userStore, auth.NewMemoryRevocationStore())(next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if gotUserID != tt.wantUserID {
+				t.Fatalf("expected user %d, got %d", tt.wantUserID, gotUserID)
+			}
+		})
+	}
+}
+
+func TestJWTAuthRevocation(t *testing.T) {
+	ctx := context.Background()
+	jwtManager := auth.NewJWTManager("secret", "test")
+	userStore := auth.NewMemoryUserStore()
+	revocations := auth.NewMemoryRevocationStore()
+	alice, _ := userStore.Create(ctx, "alice@example.com", "hash")
+	bob, _ := userStore.Create(ctx, "bob@example.com", "hash")
+
+	loggedOut, _ := jwtManager.Generate(alice.ID, alice.Email, alice.Roles, time.Hour)
+	active, _ := jwtManager.Generate(alice.ID, alice.Email, alice.Roles, time.Hour)
+	everywhere, _ := jwtManager.Generate(bob.ID, bob.Email, bob.Roles, time.Hour)
+
+	claims, _ := jwtManager.Verify(loggedOut)
+	revocations.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time)
+	revocations.RevokeUser(ctx, bob.ID, time.Now().Add(2*time.Second), time.Hour)
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "logged out token", token: loggedOut, wantStatus: http.StatusUnauthorized},
+		{name: "other token of the same user", token: active, wantStatus: http.StatusOK},
+		{name: "token issued before logging out everywhere", token: everywhere, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if claims, ok := auth.ClaimsFromContext(r.Context()); !ok || claims.ID == "" {
+					t.Fatalf("expected the token's claims in the context")
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/products", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			JWTAuth(jwtManager, userStore, revocations)(next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+// unreachableRevocations fails every lookup, like a Redis store that can't
+// be reached.
+type unreachableRevocations struct {
+	auth.RevocationStore
+}
+
+func (unreachableRevocations) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func (unreachableRevocations) RevokedBefore(ctx context.Context, userID int) (time.Time, error) {
+	return time.Time{}, errors.New("connection refused")
+}
+
+func TestJWTAuthRevocationUnavailable(t *testing.T) {
+	jwtManager := auth.NewJWTManager("secret", "test")
+	userStore := auth.NewMemoryUserStore()
+	alice, _ := userStore.Create(context.Background(), "alice@example.com", "hash")
+	token, _ := jwtManager.Generate(alice.ID, alice.Email, alice.Roles, time.Hour)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("expected the request to be stopped")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	JWTAuth(jwtManager, userStore, unreachableRevocations{})(next).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		roles      []auth.Role
+		anonymous  bool
+		permission auth.Permission
+		wantStatus int
+	}{
+		{name: "no user", anonymous: true, permission: auth.PermProductsRead, wantStatus: http.StatusUnauthorized},
+		{name: "customer", roles: []auth.Role{auth.RoleCustomer}, permission: auth.PermProductsRead, wantStatus: http.StatusForbidden},
+		{name: "no roles", permission: auth.PermProductsRead, wantStatus: http.StatusForbidden},
+		{name: "viewer reads", roles: []auth.Role{auth.RoleViewer}, permission: auth.PermProductsRead, wantStatus: http.StatusOK},
+		{name: "viewer writes", roles: []auth.Role{auth.RoleViewer}, permission: auth.PermProductsWrite, wantStatus: http.StatusForbidden},
+		{name: "editor writes", roles: []auth.Role{auth.RoleEditor}, permission: auth.PermProductsWrite, wantStatus: http.StatusOK},
+		{name: "editor manages users", roles: []auth.Role{auth.RoleEditor}, permission: auth.PermUsersManage, wantStatus: http.StatusForbidden},
+		{name: "customer and editor", roles: []auth.Role{auth.RoleCustomer, auth.RoleEditor}, permission: auth.PermProductsWrite, wantStatus: http.StatusOK},
+		{name: "admin manages users", roles: []auth.Role{auth.RoleAdmin}, permission: auth.PermUsersManage, wantStatus: http.StatusOK},
+		{name: "unknown permission", roles: []auth.Role{auth.RoleAdmin}, permission: "reports:read", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/products", nil)
+			if !tt.anonymous {
+				req = req.WithContext(auth.ContextWithUser(req.Context(), auth.User{ID: 1, Roles: tt.roles}))
+			}
+			rec := httptest.NewRecorder()
+			RequirePermission(tt.permission)(next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"lukekorsman.com/store/internal/api"
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/product"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	inventory *Service
+	products  product.Store
+}
+
+func NewHandler(inventory *Service, products product.Store) *Handler {
+	return &Handler{
+		inventory: inventory,
+		products:  products,
+	}
+}
+
+type AdjustmentRequest struct {
+	Delta  int    `json:"delta"`
+	Reason Reason `json:"reason"`
+	Note   string `json:"note"`
+}
+
+type AdjustmentResponse struct {
+	Stock      Stock      `json:"stock"`
+	Adjustment Adjustment `json:"adjustment"`
+}
+
+type ThresholdRequest struct {
+	LowStockThreshold int `json:"low_stock_threshold"`
+}
+
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.productID(w, r)
+	if !ok {
+		return
+	}
+
+	stock, err := h.inventory.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stock)
+}
+
+// Adjust records a manual stock change, e.g. a delivery or a write-off.
+func (h *Handler) Adjust(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.productID(w, r)
+	if !ok {
+		return
+	}
+
+	var req AdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	a := Adjustment{
+		ProductID: id,
+		Delta:     req.Delta,
+		Reason:    req.Reason,
+		Note:      req.Note,
+	}
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		a.ActorID = &user.ID
+	}
+
+	if errs := ValidateAdjustment(a); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	stock, a, err := h.inventory.Adjust(r.Context(), a)
+	if errors.Is(err, ErrInsufficientStock) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, product.ErrProductNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, AdjustmentResponse{Stock: stock, Adjustment: a})
+}
+
+// Adjustments lists the product's ledger, newest first.
+func (h *Handler) Adjustments(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.productID(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, errs := api.ParsePage(r.URL.Query())
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	adjustments, total, err := h.inventory.Adjustments(r.Context(), id, limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	api.SetPageHeaders(w, r, limit, offset, len(adjustments), total)
+	writeJSON(w, http.StatusOK, adjustments)
+}
+
+func (h *Handler) SetThreshold(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.productID(w, r)
+	if !ok {
+		return
+	}
+
+	var req ThresholdRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.LowStockThreshold < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []api.ValidationError{{
+				Field:   "low_stock_threshold",
+				Message: "low_stock_threshold must be 0 or greater",
+			}},
+		})
+		return
+	}
+
+	stock, err := h.inventory.SetThreshold(r.Context(), id, req.LowStockThreshold)
+	if errors.Is(err, product.ErrProductNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stock)
+}
+
+// productID reads the product ID from the URL and checks the product exists.
+func (h *Handler) productID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	_, err = h.products.GetByID(r.Context(), id)
+	if errors.Is(err, product.ErrProductNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return 0, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return 0, false
+	}
+
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
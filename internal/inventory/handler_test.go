@@ -0,0 +1,150 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/product"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestAdjustStock(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantOnHand int
+	}{
+		{
+			name:       "receive",
+			body:       `{"delta":5,"reason":"received","note":"PO-1001"}`,
+			wantStatus: http.StatusCreated,
+			wantOnHand: 15,
+		},
+		{
+			name:       "write off",
+			body:       `{"delta":-2,"reason":"damaged"}`,
+			wantStatus: http.StatusCreated,
+			wantOnHand: 8,
+		},
+		{
+			name:       "cannot remove reserved stock",
+			body:       `{"delta":-8,"reason":"lost"}`,
+			wantStatus: http.StatusConflict,
+			wantOnHand: 10,
+		},
+		{
+			name:       "sale is not a manual reason",
+			body:       `{"delta":-1,"reason":"sale"}`,
+			wantStatus: http.StatusBadRequest,
+			wantOnHand: 10,
+		},
+		{
+			name:       "zero delta",
+			body:       `{"delta":0,"reason":"correction"}`,
+			wantStatus: http.StatusBadRequest,
+			wantOnHand: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			products := product.NewMemoryStore()
+			p, _ := products.Create(ctx, product.Product{Name: "Widget", Price: money.New(500, money.USD)})
+
+			store := NewMemoryStore()
+			stocked(t, store, map[int]int{p.ID: 10})
+			service := NewService(store)
+			service.Reserve(ctx, []Item{{ProductID: p.ID, Quantity: 3}})
+
+			r := chi.NewRouter()
+			r.Post("/inventory/{id}/adjustments", NewHandler(service, products).Adjust)
+
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/inventory/%d/adjustments", p.ID), strings.NewReader(tt.body))
+			req = req.WithContext(auth.ContextWithUser(ctx, auth.User{ID: 7}))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if stock, _ := service.Get(ctx, p.ID); stock.OnHand != tt.wantOnHand {
+				t.Fatalf("expected on_hand %d, got %d", tt.wantOnHand, stock.OnHand)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			var resp AdjustmentResponse
+			json.NewDecoder(rec.Body).Decode(&resp)
+			if resp.Adjustment.ActorID == nil || *resp.Adjustment.ActorID != 7 || resp.Adjustment.OnHand != tt.wantOnHand {
+				t.Fatalf("unexpected ledger entry %+v", resp.Adjustment)
+			}
+		})
+	}
+}
+
+func TestListAdjustments(t *testing.T) {
+	ctx := context.Background()
+	products := product.NewMemoryStore()
+	p, _ := products.Create(ctx, product.Product{Name: "Widget", Price: money.New(500, money.USD)})
+
+	store := NewMemoryStore()
+	stocked(t, store, map[int]int{p.ID: 10})
+	service := NewService(store)
+	service.Reserve(ctx, []Item{{ProductID: p.ID, Quantity: 2}})
+	service.Commit(ctx, []Item{{ProductID: p.ID, Quantity: 2}})
+
+	r := chi.NewRouter()
+	r.Get("/inventory/{id}/adjustments", NewHandler(service, products).Adjustments)
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/inventory/%d/adjustments", p.ID), nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	var ledger []Adjustment
+	json.NewDecoder(rec.Body).Decode(&ledger)
+	if rec.Header().Get("X-Total-Count") != "2" || len(ledger) != 2 {
+		t.Fatalf("expected 2 ledger entries, got %+v", ledger)
+	}
+	if ledger[0].Reason != ReasonSale || ledger[0].Delta != -2 || ledger[0].OnHand != 8 {
+		t.Fatalf("expected the sale first, got %+v", ledger[0])
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/inventory/99/adjustments", nil)
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown product, got %d", rec.Code)
+	}
+}
+
+// brokenProducts fails every lookup the way an unreachable database would.
+type brokenProducts struct {
+	product.Store
+}
+
+func (brokenProducts) GetByID(ctx context.Context, id int) (product.Product, error) {
+	return product.Product{}, errors.New("connection refused")
+}
+
+func TestProductLookupFailure(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/inventory/{id}", NewHandler(NewService(NewMemoryStore()), brokenProducts{}).Get)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inventory/1", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 when the product can't be looked up, got %d", rec.Code)
+	}
+}
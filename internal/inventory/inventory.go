@@ -0,0 +1,173 @@
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"lukekorsman.com/store/internal/api"
+)
+
+// DefaultLowStockThreshold is used for products that have no threshold of
+// their own yet.
+const DefaultLowStockThreshold = 5
+
+var (
+	// ErrInsufficientStock is returned when a reservation or adjustment needs
+	// more units than are available.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrNotReserved is returned when committing or releasing more units than
+	// are reserved.
+	ErrNotReserved = errors.New("quantity not reserved")
+)
+
+// Stock is the inventory position of one product. Reserved units are held
+// for pending orders and are still counted in OnHand until committed.
+type Stock struct {
+	ProductID         int       `json:"product_id"`
+	OnHand            int       `json:"on_hand"`
+	Reserved          int       `json:"reserved"`
+	LowStockThreshold int       `json:"low_stock_threshold"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func (s Stock) Available() int {
+	return s.OnHand - s.Reserved
+}
+
+func (s Stock) Low() bool {
+	return s.Available() <= s.LowStockThreshold
+}
+
+func (s Stock) MarshalJSON() ([]byte, error) {
+	type stock Stock
+	return json.Marshal(struct {
+		stock
+		Available int  `json:"available"`
+		Low       bool `json:"low"`
+	}{stock(s), s.Available(), s.Low()})
+}
+
+// Reason explains why on-hand stock changed.
+type Reason string
+
+const (
+	ReasonReceived   Reason = "received"
+	ReasonReturned   Reason = "returned"
+	ReasonDamaged    Reason = "damaged"
+	ReasonLost       Reason = "lost"
+	ReasonCorrection Reason = "correction"
+	// ReasonSale is recorded when reserved stock is committed and can't be
+	// used for manual adjustments.
+	ReasonSale Reason = "sale"
+)
+
+var manualReasons = map[Reason]bool{
+	ReasonReceived:   true,
+	ReasonReturned:   true,
+	ReasonDamaged:    true,
+	ReasonLost:       true,
+	ReasonCorrection: true,
+}
+
+// Adjustment is one entry in the stock ledger.
+type Adjustment struct {
+	ID        int    `json:"id"`
+	ProductID int    `json:"product_id"`
+	Delta     int    `json:"delta"`
+	Reason    Reason `json:"reason"`
+	Note      string `json:"note"`
+	ActorID   *int   `json:"actor_id"`
+	// OnHand is the on-hand quantity after the adjustment was applied.
+	OnHand    int       `json:"on_hand"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Item is a quantity of one product to reserve, commit or release.
+type Item struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+func ValidateAdjustment(a Adjustment) []api.ValidationError {
+	var errs []api.ValidationError
+
+	if a.Delta == 0 {
+		errs = append(errs, api.ValidationError{
+			Field:   "delta",
+			Message: "delta must not be zero",
+		})
+	}
+
+	if !manualReasons[a.Reason] {
+		errs = append(errs, api.ValidationError{
+			Field:   "reason",
+			Message: "reason must be one of received, returned, damaged, lost, correction",
+		})
+	}
+
+	if len(a.Note) > 500 {
+		errs = append(errs, api.ValidationError{
+			Field:   "note",
+			Message: "note must be 500 characters or less",
+		})
+	}
+
+	return errs
+}
+
+// mergeItems sums quantities per product and orders the result by product ID
+// so every caller locks rows in the same order.
+func mergeItems(items []Item) ([]Item, error) {
+	byProduct := make(map[int]int)
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("quantity for product %d must be positive", item.ProductID)
+		}
+		byProduct[item.ProductID] += item.Quantity
+	}
+
+	merged := make([]Item, 0, len(byProduct))
+	for id, quantity := range byProduct {
+		merged = append(merged, Item{ProductID: id, Quantity: quantity})
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].ProductID < merged[j].ProductID
+	})
+	return merged, nil
+}
+
+// apply changes s in place for one operation, or returns why it can't.
+func apply(s *Stock, op string, quantity int) error {
+	switch op {
+	case "reserve":
+		if s.Available() < quantity {
+			return fmt.Errorf("%w: product %d has %d available, %d requested", ErrInsufficientStock, s.ProductID, s.Available(), quantity)
+		}
+		s.Reserved += quantity
+	case "commit":
+		if s.Reserved < quantity {
+			return fmt.Errorf("%w: product %d has %d reserved, %d requested", ErrNotReserved, s.ProductID, s.Reserved, quantity)
+		}
+		s.Reserved -= quantity
+		s.OnHand -= quantity
+	case "release":
+		if s.Reserved < quantity {
+			return fmt.Errorf("%w: product %d has %d reserved, %d requested", ErrNotReserved, s.ProductID, s.Reserved, quantity)
+		}
+		s.Reserved -= quantity
+	}
+	return nil
+}
+
+// adjust applies delta to on-hand stock. Stock that is reserved can't be
+// adjusted away.
+func adjust(s *Stock, delta int) error {
+	if s.OnHand+delta < s.Reserved {
+		return fmt.Errorf("%w: product %d has %d available, cannot remove %d", ErrInsufficientStock, s.ProductID, s.Available(), -delta)
+	}
+	s.OnHand += delta
+	return nil
+}
@@ -0,0 +1,228 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lukekorsman.com/store/internal/product"
+)
+
+const stockColumns = "product_id, on_hand, reserved, low_stock_threshold, updated_at"
+
+type MySQLStore struct {
+	db *sql.DB
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanStock(row rowScanner) (Stock, error) {
+	var s Stock
+	err := row.Scan(&s.ProductID, &s.OnHand, &s.Reserved, &s.LowStockThreshold, &s.UpdatedAt)
+	return s, err
+}
+
+func (s *MySQLStore) Get(ctx context.Context, productID int) (Stock, error) {
+	stock, err := scanStock(s.db.QueryRowContext(ctx,
+		"SELECT "+stockColumns+" FROM inventory WHERE product_id = ?", productID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Stock{ProductID: productID, LowStockThreshold: DefaultLowStockThreshold}, nil
+	}
+	return stock, err
+}
+
+// lock creates the inventory row for productID if needed and locks it for
+// the rest of tx.
+func (s *MySQLStore) lock(ctx context.Context, tx *sql.Tx, productID int) (Stock, error) {
+	// The foreign key turns this into a no-op for unknown products, which the
+	// SELECT below then reports.
+	if _, err := tx.ExecContext(ctx, "INSERT IGNORE INTO inventory (product_id) VALUES (?)", productID); err != nil {
+		return Stock{}, err
+	}
+
+	stock, err := scanStock(tx.QueryRowContext(ctx,
+		"SELECT "+stockColumns+" FROM inventory WHERE product_id = ? FOR UPDATE", productID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Stock{}, fmt.Errorf("%w: %d", product.ErrProductNotFound, productID)
+	}
+	return stock, err
+}
+
+func (s *MySQLStore) save(ctx context.Context, tx *sql.Tx, stock Stock) error {
+	_, err := tx.ExecContext(ctx,
+		"UPDATE inventory SET on_hand = ?, reserved = ?, low_stock_threshold = ? WHERE product_id = ?",
+		stock.OnHand, stock.Reserved, stock.LowStockThreshold, stock.ProductID,
+	)
+	return err
+}
+
+func (s *MySQLStore) record(ctx context.Context, tx *sql.Tx, a Adjustment) (Adjustment, error) {
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO inventory_adjustments (product_id, delta, reason, note, actor_id, on_hand) VALUES (?, ?, ?, ?, ?, ?)",
+		a.ProductID, a.Delta, a.Reason, a.Note, a.ActorID, a.OnHand,
+	)
+	if err != nil {
+		return Adjustment{}, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Adjustment{}, err
+	}
+	a.ID = int(id)
+	a.CreatedAt = time.Now().UTC()
+	return a, nil
+}
+
+func (s *MySQLStore) SetThreshold(ctx context.Context, productID, threshold int) (Stock, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Stock{}, err
+	}
+	defer tx.Rollback()
+
+	stock, err := s.lock(ctx, tx, productID)
+	if err != nil {
+		return Stock{}, err
+	}
+	stock.LowStockThreshold = threshold
+	if err := s.save(ctx, tx, stock); err != nil {
+		return Stock{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Stock{}, err
+	}
+	return s.Get(ctx, productID)
+}
+
+func (s *MySQLStore) Adjust(ctx context.Context, a Adjustment) (Stock, Adjustment, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Stock{}, Adjustment{}, err
+	}
+	defer tx.Rollback()
+
+	stock, err := s.lock(ctx, tx, a.ProductID)
+	if err != nil {
+		return Stock{}, Adjustment{}, err
+	}
+	if err := adjust(&stock, a.Delta); err != nil {
+		return Stock{}, Adjustment{}, err
+	}
+	if err := s.save(ctx, tx, stock); err != nil {
+		return Stock{}, Adjustment{}, err
+	}
+
+	a.OnHand = stock.OnHand
+	a, err = s.record(ctx, tx, a)
+	if err != nil {
+		return Stock{}, Adjustment{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Stock{}, Adjustment{}, err
+	}
+	stock.UpdatedAt = a.CreatedAt
+	return stock, a, nil
+}
+
+func (s *MySQLStore) Adjustments(ctx context.Context, productID, limit, offset int) ([]Adjustment, int, error) {
+	var total int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM inventory_adjustments WHERE product_id = ?", productID,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, product_id, delta, reason, note, actor_id, on_hand, created_at FROM inventory_adjustments "+
+			"WHERE product_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		productID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	adjustments := []Adjustment{}
+	for rows.Next() {
+		var a Adjustment
+		var actorID sql.NullInt64
+		err := rows.Scan(&a.ID, &a.ProductID, &a.Delta, &a.Reason, &a.Note, &actorID, &a.OnHand, &a.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			a.ActorID = &id
+		}
+		adjustments = append(adjustments, a)
+	}
+
+	return adjustments, total, rows.Err()
+}
+
+func (s *MySQLStore) Reserve(ctx context.Context, items []Item) ([]Stock, error) {
+	return s.update(ctx, items, "reserve")
+}
+
+func (s *MySQLStore) Commit(ctx context.Context, items []Item) ([]Stock, error) {
+	return s.update(ctx, items, "commit")
+}
+
+func (s *MySQLStore) Release(ctx context.Context, items []Item) ([]Stock, error) {
+	return s.update(ctx, items, "release")
+}
+
+// update locks every row in product ID order, applies op and commits only if
+// all items succeed.
+func (s *MySQLStore) update(ctx context.Context, items []Item, op string) ([]Stock, error) {
+	merged, err := mergeItems(items)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	updated := make([]Stock, len(merged))
+	for i, item := range merged {
+		stock, err := s.lock(ctx, tx, item.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if err := apply(&stock, op, item.Quantity); err != nil {
+			return nil, err
+		}
+		if err := s.save(ctx, tx, stock); err != nil {
+			return nil, err
+		}
+		if op == "commit" {
+			sale := Adjustment{ProductID: stock.ProductID, Delta: -item.Quantity, Reason: ReasonSale, OnHand: stock.OnHand}
+			if _, err := s.record(ctx, tx, sale); err != nil {
+				return nil, err
+			}
+		}
+		stock.UpdatedAt = now
+		updated[i] = stock
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
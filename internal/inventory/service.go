@@ -0,0 +1,103 @@
+package inventory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LowStockEvent is emitted when a product's available quantity drops to or
+// below its low-stock threshold.
+type LowStockEvent struct {
+	ProductID int       `json:"product_id"`
+	Available int       `json:"available"`
+	Threshold int       `json:"threshold"`
+	At        time.Time `json:"at"`
+}
+
+// Service wraps a Store and emits low-stock events for changes that take a
+// product across its threshold.
+type Service struct {
+	store     Store
+	listeners []func(LowStockEvent)
+	mu        sync.RWMutex
+}
+
+func NewService(store Store) *Service {
+	return &Service{store: store}
+}
+
+// OnLowStock registers fn to be called for every low-stock event. Listeners
+// run synchronously after the change is stored and should not block.
+func (s *Service) OnLowStock(fn func(LowStockEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *Service) Get(ctx context.Context, productID int) (Stock, error) {
+	return s.store.Get(ctx, productID)
+}
+
+func (s *Service) SetThreshold(ctx context.Context, productID, threshold int) (Stock, error) {
+	return s.store.SetThreshold(ctx, productID, threshold)
+}
+
+func (s *Service) Adjustments(ctx context.Context, productID, limit, offset int) ([]Adjustment, int, error) {
+	return s.store.Adjustments(ctx, productID, limit, offset)
+}
+
+func (s *Service) Adjust(ctx context.Context, a Adjustment) (Stock, Adjustment, error) {
+	stock, a, err := s.store.Adjust(ctx, a)
+	if err != nil {
+		return Stock{}, Adjustment{}, err
+	}
+
+	s.check(stock, -a.Delta)
+	return stock, a, nil
+}
+
+func (s *Service) Reserve(ctx context.Context, items []Item) ([]Stock, error) {
+	updated, err := s.store.Reserve(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, _ := mergeItems(items)
+	for i, stock := range updated {
+		s.check(stock, merged[i].Quantity)
+	}
+	return updated, nil
+}
+
+// Commit turns reservations into sales. Available stock doesn't change, so
+// no events are emitted.
+func (s *Service) Commit(ctx context.Context, items []Item) ([]Stock, error) {
+	return s.store.Commit(ctx, items)
+}
+
+func (s *Service) Release(ctx context.Context, items []Item) ([]Stock, error) {
+	return s.store.Release(ctx, items)
+}
+
+// check emits an event if taking reduction away from the available quantity
+// moved stock from above its threshold to at or below it.
+func (s *Service) check(stock Stock, reduction int) {
+	if reduction <= 0 || !stock.Low() || stock.Available()+reduction <= stock.LowStockThreshold {
+		return
+	}
+
+	event := LowStockEvent{
+		ProductID: stock.ProductID,
+		Available: stock.Available(),
+		Threshold: stock.LowStockThreshold,
+		At:        time.Now().UTC(),
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, fn := range s.listeners {
+		fn(event)
+	}
+}
@@ -0,0 +1,158 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func stocked(t *testing.T, store Store, levels map[int]int) {
+	t.Helper()
+	for id, quantity := range levels {
+		if _, _, err := store.Adjust(context.Background(), Adjustment{ProductID: id, Delta: quantity, Reason: ReasonReceived}); err != nil {
+			t.Fatalf("failed to stock product %d: %v", id, err)
+		}
+	}
+}
+
+func TestReserveCommitRelease(t *testing.T) {
+	tests := []struct {
+		name         string
+		reserve      []Item
+		commit       []Item
+		release      []Item
+		wantErr      error
+		wantOnHand   map[int]int
+		wantReserved map[int]int
+	}{
+		{
+			name:         "reserve",
+			reserve:      []Item{{ProductID: 1, Quantity: 4}, {ProductID: 2, Quantity: 1}},
+			wantOnHand:   map[int]int{1: 10, 2: 2},
+			wantReserved: map[int]int{1: 4, 2: 1},
+		},
+		{
+			name:         "reserve is all or nothing",
+			reserve:      []Item{{ProductID: 1, Quantity: 4}, {ProductID: 2, Quantity: 3}},
+			wantErr:      ErrInsufficientStock,
+			wantOnHand:   map[int]int{1: 10, 2: 2},
+			wantReserved: map[int]int{1: 0, 2: 0},
+		},
+		{
+			name:         "duplicate items are summed",
+			reserve:      []Item{{ProductID: 2, Quantity: 1}, {ProductID: 2, Quantity: 2}},
+			wantErr:      ErrInsufficientStock,
+			wantOnHand:   map[int]int{2: 2},
+			wantReserved: map[int]int{2: 0},
+		},
+		{
+			name:         "commit removes reserved stock",
+			reserve:      []Item{{ProductID: 1, Quantity: 4}},
+			commit:       []Item{{ProductID: 1, Quantity: 3}},
+			wantOnHand:   map[int]int{1: 7},
+			wantReserved: map[int]int{1: 1},
+		},
+		{
+			name:         "release returns reserved stock",
+			reserve:      []Item{{ProductID: 1, Quantity: 4}},
+			release:      []Item{{ProductID: 1, Quantity: 4}},
+			wantOnHand:   map[int]int{1: 10},
+			wantReserved: map[int]int{1: 0},
+		},
+		{
+			name:         "commit more than reserved",
+			reserve:      []Item{{ProductID: 1, Quantity: 1}},
+			commit:       []Item{{ProductID: 1, Quantity: 2}},
+			wantErr:      ErrNotReserved,
+			wantOnHand:   map[int]int{1: 10},
+			wantReserved: map[int]int{1: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+			stocked(t, store, map[int]int{1: 10, 2: 2})
+			service := NewService(store)
+
+			var err error
+			if tt.reserve != nil {
+				_, err = service.Reserve(ctx, tt.reserve)
+			}
+			if err == nil && tt.commit != nil {
+				_, err = service.Commit(ctx, tt.commit)
+			}
+			if err == nil && tt.release != nil {
+				_, err = service.Release(ctx, tt.release)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			for id, want := range tt.wantOnHand {
+				stock, _ := service.Get(ctx, id)
+				if stock.OnHand != want || stock.Reserved != tt.wantReserved[id] {
+					t.Errorf("product %d: expected on_hand %d reserved %d, got %d and %d",
+						id, want, tt.wantReserved[id], stock.OnHand, stock.Reserved)
+				}
+			}
+		})
+	}
+}
+
+func TestReserve_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	stocked(t, store, map[int]int{1: 25})
+	service := NewService(store)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.Reserve(ctx, []Item{{ProductID: 1, Quantity: 1}}); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	stock, _ := service.Get(ctx, 1)
+	if succeeded != 25 || stock.Reserved != 25 || stock.Available() != 0 {
+		t.Fatalf("expected exactly 25 reservations, got %d (stock %+v)", succeeded, stock)
+	}
+}
+
+func TestLowStockEvents(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	stocked(t, store, map[int]int{1: 8})
+	service := NewService(store)
+
+	var events []LowStockEvent
+	service.OnLowStock(func(e LowStockEvent) {
+		events = append(events, e)
+	})
+
+	// 8 -> 6 stays above the default threshold of 5.
+	service.Reserve(ctx, []Item{{ProductID: 1, Quantity: 2}})
+	// 6 -> 4 crosses it.
+	service.Reserve(ctx, []Item{{ProductID: 1, Quantity: 2}})
+	// 4 -> 3 is already below, and commits don't change availability.
+	service.Adjust(ctx, Adjustment{ProductID: 1, Delta: -1, Reason: ReasonDamaged})
+	service.Commit(ctx, []Item{{ProductID: 1, Quantity: 4}})
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %+v", events)
+	}
+	if events[0].Available != 4 || events[0].Threshold != DefaultLowStockThreshold {
+		t.Fatalf("unexpected event %+v", events[0])
+	}
+}
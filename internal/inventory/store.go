@@ -0,0 +1,148 @@
+package inventory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps stock levels and the adjustment ledger. Reserve, Commit and
+// Release apply to every item or to none of them.
+type Store interface {
+	Get(ctx context.Context, productID int) (Stock, error)
+	SetThreshold(ctx context.Context, productID, threshold int) (Stock, error)
+	Adjust(ctx context.Context, a Adjustment) (Stock, Adjustment, error)
+	Adjustments(ctx context.Context, productID, limit, offset int) ([]Adjustment, int, error)
+	Reserve(ctx context.Context, items []Item) ([]Stock, error)
+	Commit(ctx context.Context, items []Item) ([]Stock, error)
+	Release(ctx context.Context, items []Item) ([]Stock, error)
+}
+
+type MemoryStore struct {
+	stock       map[int]Stock
+	adjustments []Adjustment
+	nextID      int
+	mu          sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		stock:  make(map[int]Stock),
+		nextID: 1,
+	}
+}
+
+// get returns the stock for productID, defaulting products that have never
+// been stocked. The caller must hold the lock.
+func (s *MemoryStore) get(productID int) Stock {
+	if stock, ok := s.stock[productID]; ok {
+		return stock
+	}
+	return Stock{ProductID: productID, LowStockThreshold: DefaultLowStockThreshold}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, productID int) (Stock, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.get(productID), nil
+}
+
+func (s *MemoryStore) SetThreshold(ctx context.Context, productID, threshold int) (Stock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stock := s.get(productID)
+	stock.LowStockThreshold = threshold
+	stock.UpdatedAt = time.Now().UTC()
+	s.stock[productID] = stock
+	return stock, nil
+}
+
+func (s *MemoryStore) Adjust(ctx context.Context, a Adjustment) (Stock, Adjustment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stock := s.get(a.ProductID)
+	if err := adjust(&stock, a.Delta); err != nil {
+		return Stock{}, Adjustment{}, err
+	}
+
+	now := time.Now().UTC()
+	stock.UpdatedAt = now
+	s.stock[a.ProductID] = stock
+	return stock, s.record(a, stock, now), nil
+}
+
+// record appends a to the ledger. The caller must hold the lock.
+func (s *MemoryStore) record(a Adjustment, stock Stock, now time.Time) Adjustment {
+	a.ID = s.nextID
+	s.nextID++
+	a.OnHand = stock.OnHand
+	a.CreatedAt = now
+	s.adjustments = append(s.adjustments, a)
+	return a
+}
+
+func (s *MemoryStore) Adjustments(ctx context.Context, productID, limit, offset int) ([]Adjustment, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Newest first.
+	var matched []Adjustment
+	for i := len(s.adjustments) - 1; i >= 0; i-- {
+		if s.adjustments[i].ProductID == productID {
+			matched = append(matched, s.adjustments[i])
+		}
+	}
+
+	total := len(matched)
+	if offset > total {
+		offset = total
+	}
+	end := min(offset+limit, total)
+	return append([]Adjustment{}, matched[offset:end]...), total, nil
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, items []Item) ([]Stock, error) {
+	return s.update(items, "reserve")
+}
+
+func (s *MemoryStore) Commit(ctx context.Context, items []Item) ([]Stock, error) {
+	return s.update(items, "commit")
+}
+
+func (s *MemoryStore) Release(ctx context.Context, items []Item) ([]Stock, error) {
+	return s.update(items, "release")
+}
+
+// update applies op to every item on copies first so a failure part way
+// through leaves the store untouched.
+func (s *MemoryStore) update(items []Item, op string) ([]Stock, error) {
+	merged, err := mergeItems(items)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	updated := make([]Stock, len(merged))
+	for i, item := range merged {
+		stock := s.get(item.ProductID)
+		if err := apply(&stock, op, item.Quantity); err != nil {
+			return nil, err
+		}
+		stock.UpdatedAt = now
+		updated[i] = stock
+	}
+
+	for i, stock := range updated {
+		s.stock[stock.ProductID] = stock
+		if op == "commit" {
+			s.record(Adjustment{ProductID: stock.ProductID, Delta: -merged[i].Quantity, Reason: ReasonSale}, stock, now)
+		}
+	}
+	return updated, nil
+}
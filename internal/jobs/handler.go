@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"lukekorsman.com/store/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	runner *Runner
+}
+
+func NewHandler(runner *Runner) *Handler {
+	return &Handler{runner: runner}
+}
+
+// Get reports a job's status, progress and result.
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	j, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, j)
+}
+
+// Cancel stops a queued job straight away. A running job is asked to stop
+// and shows cancel_requested until its worker does.
+func (h *Handler) Cancel(w http.ResponseWriter, r *http.Request) {
+	j, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+
+	j, err := h.runner.Cancel(r.Context(), j.ID)
+	if errors.Is(err, ErrJobFinished) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, j)
+}
+
+// job loads the job in the URL. Users only see the jobs they started.
+func (h *Handler) job(w http.ResponseWriter, r *http.Request) (Job, bool) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return Job{}, false
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid job ID", http.StatusBadRequest)
+		return Job{}, false
+	}
+
+	j, err := h.runner.Get(r.Context(), id)
+	if err == nil && j.CreatedBy != nil && *j.CreatedBy != user.ID {
+		err = ErrJobNotFound
+	}
+	if errors.Is(err, ErrJobNotFound) {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return Job{}, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return Job{}, false
+	}
+
+	return j, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lukekorsman.com/store/internal/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	owner, other := 1, 2
+	queued, _ := store.Create(ctx, Job{Type: "test", MaxAttempts: 1, CreatedBy: &owner})
+	system, _ := store.Create(ctx, Job{Type: "test", MaxAttempts: 1})
+
+	h := NewHandler(NewRunner(store, 1))
+	router := chi.NewRouter()
+	router.Get("/jobs/{id}", h.Get)
+	router.Post("/jobs/{id}/cancel", h.Cancel)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		userID     int
+		wantStatus int
+	}{
+		{"owner sees the job", http.MethodGet, "/jobs/1", owner, http.StatusOK},
+		{"other users don't", http.MethodGet, "/jobs/1", other, http.StatusNotFound},
+		{"jobs without an owner are visible", http.MethodGet, "/jobs/2", other, http.StatusOK},
+		{"unknown job", http.MethodGet, "/jobs/99", owner, http.StatusNotFound},
+		{"invalid ID", http.MethodGet, "/jobs/abc", owner, http.StatusBadRequest},
+		{"other users can't cancel", http.MethodPost, "/jobs/1/cancel", other, http.StatusNotFound},
+		{"owner cancels", http.MethodPost, "/jobs/1/cancel", owner, http.StatusOK},
+		{"cancelling twice", http.MethodPost, "/jobs/1/cancel", owner, http.StatusConflict},
+		{"anonymous", http.MethodGet, "/jobs/1", 0, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.userID != 0 {
+				req = req.WithContext(auth.ContextWithUser(req.Context(), auth.User{ID: tt.userID}))
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	if j, _ := store.Get(ctx, queued.ID); j.Status != StatusCancelled {
+		t.Fatalf("expected the job to be cancelled, got %s", j.Status)
+	}
+	if j, _ := store.Get(ctx, system.ID); j.Status != StatusQueued {
+		t.Fatalf("expected the other job to be untouched, got %s", j.Status)
+	}
+}
@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	// ErrNoJob is returned by Claim when nothing is due.
+	ErrNoJob = errors.New("no job is due")
+	// ErrJobChanged means a worker no longer holds the job, because it
+	// finished, was cancelled or its lease expired and another worker took it.
+	ErrJobChanged  = errors.New("job changed")
+	ErrJobFinished = errors.New("job has already finished")
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+func (s Status) Finished() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCancelled
+}
+
+// DefaultMaxAttempts is how many times a job runs before it fails for good.
+const DefaultMaxAttempts = 5
+
+type Job struct {
+	ID      int             `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+	Status  Status          `json:"status"`
+	// Progress is a percentage reported by the job while it runs.
+	Progress int             `json:"progress"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	// Error is the error of the latest failed attempt.
+	Error           string `json:"error,omitempty"`
+	Attempts        int    `json:"attempts"`
+	MaxAttempts     int    `json:"max_attempts"`
+	CancelRequested bool   `json:"cancel_requested"`
+	CreatedBy       *int   `json:"created_by"`
+	// RunAt is when a queued job is next due, later than CreatedAt while it
+	// waits out a retry backoff.
+	RunAt time.Time `json:"run_at"`
+	// LockedUntil is when a running job's lease expires. A worker renews it
+	// while the job runs; if the worker dies the job is due again.
+	LockedUntil *time.Time `json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// LastAttempt reports whether a failure of the current attempt is final.
+func (j Job) LastAttempt() bool {
+	return j.Attempts >= j.MaxAttempts
+}
+
+// Outcome is how a running attempt ended. A Status of StatusQueued puts the
+// job back to be retried at RunAt.
+type Outcome struct {
+	Status   Status
+	Progress int
+	Result   json.RawMessage
+	Error    string
+	RunAt    time.Time
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a job error that retrying won't fix, such as a bad
+// payload, so the job fails straight away.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
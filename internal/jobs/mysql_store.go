@@ -0,0 +1,207 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const jobColumns = "id, type, payload, status, progress, result, COALESCE(error, ''), attempts, max_attempts, " +
+	"cancel_requested, created_by, run_at, locked_until, created_at, updated_at, finished_at"
+
+type MySQLStore struct {
+	db *sql.DB
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (Job, error) {
+	var j Job
+	var payload, result []byte
+	var createdBy sql.NullInt64
+	var lockedUntil, finishedAt sql.NullTime
+	err := row.Scan(&j.ID, &j.Type, &payload, &j.Status, &j.Progress, &result, &j.Error, &j.Attempts, &j.MaxAttempts,
+		&j.CancelRequested, &createdBy, &j.RunAt, &lockedUntil, &j.CreatedAt, &j.UpdatedAt, &finishedAt)
+	if err != nil {
+		return Job{}, err
+	}
+
+	j.Payload = payload
+	if len(result) > 0 {
+		j.Result = result
+	}
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		j.CreatedBy = &id
+	}
+	if lockedUntil.Valid {
+		j.LockedUntil = &lockedUntil.Time
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return j, nil
+}
+
+func (s *MySQLStore) Create(ctx context.Context, j Job) (Job, error) {
+	if j.RunAt.IsZero() {
+		j.RunAt = time.Now()
+	}
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO jobs (type, payload, status, max_attempts, created_by, run_at) VALUES (?, ?, ?, ?, ?, ?)",
+		j.Type, []byte(j.Payload), StatusQueued, j.MaxAttempts, j.CreatedBy, j.RunAt.UTC(),
+	)
+	if err != nil {
+		return Job{}, err
+	}
+
+	id, _ := result.LastInsertId()
+	return s.Get(ctx, int(id))
+}
+
+func (s *MySQLStore) Get(ctx context.Context, id int) (Job, error) {
+	j, err := scanJob(s.db.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Job{}, fmt.Errorf("%w: %d", ErrJobNotFound, id)
+	}
+	return j, err
+}
+
+// Claim uses SKIP LOCKED so workers on several API instances each take a
+// different job instead of queueing behind one another's row locks.
+func (s *MySQLStore) Claim(ctx context.Context, now, lockedUntil time.Time) (Job, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Job{}, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx,
+		"SELECT id FROM jobs WHERE (status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?) "+
+			"ORDER BY run_at, id LIMIT 1 FOR UPDATE SKIP LOCKED",
+		StatusQueued, now.UTC(), StatusRunning, now.UTC(),
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Job{}, ErrNoJob
+	}
+	if err != nil {
+		return Job{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE jobs SET status = ?, attempts = attempts + 1, locked_until = ? WHERE id = ?",
+		StatusRunning, lockedUntil.UTC(), id,
+	); err != nil {
+		return Job{}, err
+	}
+
+	j, err := scanJob(tx.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = ?", id))
+	if err != nil {
+		return Job{}, err
+	}
+	return j, tx.Commit()
+}
+
+func (s *MySQLStore) Heartbeat(ctx context.Context, id, attempt, progress int, lockedUntil time.Time) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE jobs SET progress = ?, locked_until = ? WHERE id = ? AND status = ? AND attempts = ?",
+		progress, lockedUntil.UTC(), id, StatusRunning, attempt,
+	)
+	if err != nil {
+		return false, err
+	}
+	if err := s.held(ctx, result, id, attempt); err != nil {
+		return false, err
+	}
+
+	var cancelRequested bool
+	err = s.db.QueryRowContext(ctx, "SELECT cancel_requested FROM jobs WHERE id = ?", id).Scan(&cancelRequested)
+	return cancelRequested, err
+}
+
+func (s *MySQLStore) Finish(ctx context.Context, id, attempt int, o Outcome) error {
+	query := "UPDATE jobs SET status = ?, progress = ?, result = ?, error = ?, locked_until = NULL"
+	args := []any{o.Status, o.Progress, nullableJSON(o.Result), o.Error}
+	if o.Status == StatusQueued {
+		query += ", run_at = ?"
+		args = append(args, o.RunAt.UTC())
+	} else {
+		query += ", finished_at = CURRENT_TIMESTAMP"
+	}
+	query += " WHERE id = ? AND status = ? AND attempts = ?"
+	args = append(args, id, StatusRunning, attempt)
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return s.held(ctx, result, id, attempt)
+}
+
+// held checks that an update guarded by status and attempt matched the job.
+// MySQL reports 0 rows affected when nothing changed, so the job is read
+// back before giving up.
+func (s *MySQLStore) held(ctx context.Context, result sql.Result, id, attempt int) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+	j, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if j.Status != StatusRunning || j.Attempts != attempt {
+		return fmt.Errorf("%w: job %d attempt %d", ErrJobChanged, id, attempt)
+	}
+	return nil
+}
+
+func (s *MySQLStore) Cancel(ctx context.Context, id int) (Job, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE jobs SET status = ?, finished_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?",
+		StatusCancelled, id, StatusQueued,
+	)
+	if err != nil {
+		return Job{}, err
+	}
+	cancelled, err := result.RowsAffected()
+	if err != nil {
+		return Job{}, err
+	}
+
+	if cancelled == 0 {
+		if _, err := s.db.ExecContext(ctx,
+			"UPDATE jobs SET cancel_requested = TRUE WHERE id = ? AND status = ?", id, StatusRunning,
+		); err != nil {
+			return Job{}, err
+		}
+	}
+
+	j, err := s.Get(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+	if cancelled == 0 && j.Status != StatusRunning {
+		return Job{}, fmt.Errorf("%w: job %d is %s", ErrJobFinished, id, j.Status)
+	}
+	return j, nil
+}
+
+func nullableJSON(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
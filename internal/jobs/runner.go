@@ -0,0 +1,299 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HandlerFunc runs one attempt of a job. It should return soon after ctx is
+// cancelled and can report progress as a percentage. A non-nil result is
+// saved on the job even when err is set.
+type HandlerFunc func(ctx context.Context, job Job, progress func(percent int)) (result any, err error)
+
+// Runner is a pool of workers that claim jobs from a Store and run the
+// handler registered for each job's type.
+type Runner struct {
+	store    Store
+	workers  int
+	handlers map[string]HandlerFunc
+	onCancel map[string]func(ctx context.Context, j Job)
+	mu       sync.RWMutex
+	// wake lets Enqueue start a job without waiting for the next poll.
+	wake chan struct{}
+
+	pollInterval      time.Duration
+	heartbeatInterval time.Duration
+	lease             time.Duration
+	backoff           func(attempt int) time.Duration
+}
+
+func NewRunner(store Store, workers int) *Runner {
+	return &Runner{
+		store:             store,
+		workers:           max(workers, 1),
+		handlers:          make(map[string]HandlerFunc),
+		onCancel:          make(map[string]func(ctx context.Context, j Job)),
+		wake:              make(chan struct{}, 1),
+		pollInterval:      time.Second,
+		heartbeatInterval: 10 * time.Second,
+		lease:             time.Minute,
+		backoff:           Backoff,
+	}
+}
+
+// Backoff doubles the wait after each failed attempt, from 5 seconds up to
+// 10 minutes.
+func Backoff(attempt int) time.Duration {
+	d := 5 * time.Second
+	for i := 1; i < attempt && d < 10*time.Minute; i++ {
+		d *= 2
+	}
+	return min(d, 10*time.Minute)
+}
+
+// Register sets the handler for a job type. Handlers should be registered
+// before Run.
+func (r *Runner) Register(jobType string, h HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[jobType] = h
+}
+
+// OnCancel sets fn to run once a job of jobType has been cancelled, to clean
+// up what the job would have used, such as an uploaded file.
+func (r *Runner) OnCancel(jobType string, fn func(ctx context.Context, j Job)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onCancel[jobType] = fn
+}
+
+func (r *Runner) handler(jobType string) HandlerFunc {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.handlers[jobType]
+}
+
+// Enqueue stores a job to be run by the next free worker. payload is saved
+// as JSON for the handler to decode.
+func (r *Runner) Enqueue(ctx context.Context, jobType string, payload any, createdBy *int) (Job, error) {
+	if r.handler(jobType) == nil {
+		return Job{}, fmt.Errorf("no handler registered for job type %q", jobType)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+
+	j, err := r.store.Create(ctx, Job{
+		Type:        jobType,
+		Payload:     data,
+		MaxAttempts: DefaultMaxAttempts,
+		CreatedBy:   createdBy,
+	})
+	if err != nil {
+		return Job{}, err
+	}
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return j, nil
+}
+
+func (r *Runner) Get(ctx context.Context, id int) (Job, error) {
+	return r.store.Get(ctx, id)
+}
+
+// Cancel stops a queued job straight away and asks a running one to stop.
+// The job type's OnCancel function runs once the job is cancelled, for a
+// running job after its worker has stopped it.
+func (r *Runner) Cancel(ctx context.Context, id int) (Job, error) {
+	j, err := r.store.Cancel(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+	if j.Status == StatusCancelled {
+		r.cancelled(ctx, j)
+	}
+	return j, nil
+}
+
+func (r *Runner) cancelled(ctx context.Context, j Job) {
+	r.mu.RLock()
+	fn := r.onCancel[j.Type]
+	r.mu.RUnlock()
+	if fn != nil {
+		fn(ctx, j)
+	}
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every
+// worker has finished its current job.
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *Runner) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		ran, err := r.RunNext(ctx)
+		if err != nil {
+			fmt.Printf("Failed to claim job: %v\n", err)
+		}
+		if ran {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-r.wake:
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// RunNext claims and runs one due job, reporting whether there was one.
+func (r *Runner) RunNext(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	j, err := r.store.Claim(ctx, now, now.Add(r.lease))
+	if errors.Is(err, ErrNoJob) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	r.execute(ctx, j)
+	return true, nil
+}
+
+func (r *Runner) execute(ctx context.Context, j Job) {
+	var outcome Outcome
+	h := r.handler(j.Type)
+	switch {
+	case j.CancelRequested:
+		outcome = Outcome{Status: StatusCancelled, Error: "cancelled"}
+	case h == nil:
+		outcome = Outcome{Status: StatusFailed, Error: fmt.Sprintf("no handler registered for job type %q", j.Type)}
+	case j.Attempts > j.MaxAttempts:
+		// Only reachable when earlier attempts lost their lease without
+		// finishing, e.g. because the API restarted.
+		outcome = Outcome{Status: StatusFailed, Error: fmt.Sprintf("gave up after %d attempts", j.MaxAttempts)}
+	default:
+		outcome = r.attempt(ctx, j, h)
+	}
+
+	// Record the outcome even if ctx was cancelled by a shutdown.
+	ctx = context.WithoutCancel(ctx)
+	err := r.store.Finish(ctx, j.ID, j.Attempts, outcome)
+	if err != nil {
+		fmt.Printf("Failed to finish job %d: %v\n", j.ID, err)
+		return
+	}
+	if outcome.Status == StatusCancelled {
+		r.cancelled(ctx, j)
+	}
+}
+
+// attempt runs the handler while a heartbeat renews the lease, saves
+// progress and watches for cancellation.
+func (r *Runner) attempt(ctx context.Context, j Job, h HandlerFunc) Outcome {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var progress atomic.Int64
+	progress.Store(int64(j.Progress))
+	var cancelled atomic.Bool
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(r.heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			requested, err := r.store.Heartbeat(ctx, j.ID, j.Attempts, int(progress.Load()), time.Now().UTC().Add(r.lease))
+			switch {
+			case errors.Is(err, ErrJobChanged):
+				// Another worker has taken over, so this attempt's result
+				// can't be saved anyway.
+				cancel()
+				return
+			case err != nil:
+				fmt.Printf("Failed to renew job %d: %v\n", j.ID, err)
+			case requested:
+				cancelled.Store(true)
+				cancel()
+			}
+		}
+	}()
+
+	result, err := call(jobCtx, h, j, func(percent int) {
+		progress.Store(int64(min(max(percent, 0), 100)))
+	})
+	close(stop)
+	<-stopped
+
+	o := Outcome{Progress: int(progress.Load())}
+	if result != nil {
+		data, marshalErr := json.Marshal(result)
+		if marshalErr != nil && err == nil {
+			err = Permanent(marshalErr)
+		}
+		o.Result = data
+	}
+
+	switch {
+	case err == nil:
+		o.Status = StatusSucceeded
+		o.Progress = 100
+	case cancelled.Load():
+		o.Status = StatusCancelled
+		o.Error = "cancelled"
+	case ctx.Err() != nil:
+		// Shutting down; leave the job for the next worker to pick up.
+		o.Status = StatusQueued
+		o.Error = err.Error()
+		o.RunAt = time.Now().UTC()
+	case IsPermanent(err) || j.LastAttempt():
+		o.Status = StatusFailed
+		o.Error = err.Error()
+	default:
+		o.Status = StatusQueued
+		o.Error = err.Error()
+		o.RunAt = time.Now().UTC().Add(r.backoff(j.Attempts))
+	}
+	return o
+}
+
+// call runs h, turning a panic into an error so one bad job can't take the
+// worker down.
+func call(ctx context.Context, h HandlerFunc, j Job, progress func(int)) (result any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return h(ctx, j, progress)
+}
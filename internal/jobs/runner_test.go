@@ -0,0 +1,261 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newTestRunner(store Store) *Runner {
+	r := NewRunner(store, 2)
+	r.pollInterval = 5 * time.Millisecond
+	r.heartbeatInterval = 5 * time.Millisecond
+	r.backoff = func(int) time.Duration { return 0 }
+	return r
+}
+
+// runAll runs due jobs until there are none left.
+func runAll(t *testing.T, r *Runner) {
+	t.Helper()
+	for {
+		ran, err := r.RunNext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ran {
+			return
+		}
+	}
+}
+
+func TestRunner(t *testing.T) {
+	user := 7
+	tests := []struct {
+		name         string
+		handler      HandlerFunc
+		wantStatus   Status
+		wantAttempts int
+		wantError    string
+		wantResult   string
+		wantProgress int
+	}{
+		{
+			name: "succeeds with a result",
+			handler: func(ctx context.Context, j Job, progress func(int)) (any, error) {
+				progress(40)
+				return map[string]int{"rows": 3}, nil
+			},
+			wantStatus:   StatusSucceeded,
+			wantAttempts: 1,
+			wantResult:   `{"rows":3}`,
+			wantProgress: 100,
+		},
+		{
+			name: "retries until it succeeds",
+			handler: func(ctx context.Context, j Job, progress func(int)) (any, error) {
+				if j.Attempts < 3 {
+					return nil, fmt.Errorf("attempt %d failed", j.Attempts)
+				}
+				return nil, nil
+			},
+			wantStatus:   StatusSucceeded,
+			wantAttempts: 3,
+			wantError:    "",
+			wantProgress: 100,
+		},
+		{
+			name: "gives up after max attempts",
+			handler: func(ctx context.Context, j Job, progress func(int)) (any, error) {
+				return nil, errors.New("still broken")
+			},
+			wantStatus:   StatusFailed,
+			wantAttempts: DefaultMaxAttempts,
+			wantError:    "still broken",
+		},
+		{
+			name: "permanent errors aren't retried",
+			handler: func(ctx context.Context, j Job, progress func(int)) (any, error) {
+				progress(60)
+				return map[string]string{"detail": "line 3"}, Permanent(errors.New("bad payload"))
+			},
+			wantStatus:   StatusFailed,
+			wantAttempts: 1,
+			wantError:    "bad payload",
+			wantResult:   `{"detail":"line 3"}`,
+			wantProgress: 60,
+		},
+		{
+			name: "panics are retried like errors",
+			handler: func(ctx context.Context, j Job, progress func(int)) (any, error) {
+				if j.Attempts == 1 {
+					panic("boom")
+				}
+				return nil, nil
+			},
+			wantStatus:   StatusSucceeded,
+			wantAttempts: 2,
+			wantProgress: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			r := newTestRunner(store)
+			r.Register("test", tt.handler)
+
+			j, err := r.Enqueue(context.Background(), "test", map[string]int{"n": 1}, &user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(j.Payload) != `{"n":1}` || j.Status != StatusQueued {
+				t.Fatalf("unexpected queued job %+v", j)
+			}
+
+			runAll(t, r)
+
+			j, _ = store.Get(context.Background(), j.ID)
+			if j.Status != tt.wantStatus || j.Attempts != tt.wantAttempts || j.Error != tt.wantError {
+				t.Fatalf("expected %s after %d attempts with error %q, got %s after %d with %q",
+					tt.wantStatus, tt.wantAttempts, tt.wantError, j.Status, j.Attempts, j.Error)
+			}
+			if string(j.Result) != tt.wantResult {
+				t.Fatalf("expected result %s, got %s", tt.wantResult, j.Result)
+			}
+			if j.Progress != tt.wantProgress {
+				t.Fatalf("expected progress %d, got %d", tt.wantProgress, j.Progress)
+			}
+			if j.FinishedAt == nil {
+				t.Fatal("expected the job to be finished")
+			}
+		})
+	}
+}
+
+func TestRunnerBackoff(t *testing.T) {
+	store := NewMemoryStore()
+	r := NewRunner(store, 1)
+	r.Register("flaky", func(ctx context.Context, j Job, progress func(int)) (any, error) {
+		return nil, errors.New("unavailable")
+	})
+
+	j, _ := r.Enqueue(context.Background(), "flaky", nil, nil)
+	before := time.Now().UTC()
+	runAll(t, r)
+
+	j, _ = store.Get(context.Background(), j.ID)
+	if j.Status != StatusQueued || j.Attempts != 1 || j.RunAt.Before(before.Add(Backoff(1))) {
+		t.Fatalf("expected a retry no sooner than %v, got %+v", Backoff(1), j)
+	}
+
+	if got := []time.Duration{Backoff(1), Backoff(2), Backoff(3), Backoff(20)}; fmt.Sprint(got) != "[5s 10s 20s 10m0s]" {
+		t.Fatalf("unexpected backoff %v", got)
+	}
+
+	if _, err := r.Enqueue(context.Background(), "unknown", nil, nil); err == nil {
+		t.Fatal("expected a job type without a handler to be rejected")
+	}
+}
+
+func TestRunnerCancel(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	r := newTestRunner(store)
+
+	started := make(chan int)
+	r.Register("slow", func(ctx context.Context, j Job, progress func(int)) (any, error) {
+		progress(25)
+		started <- j.ID
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	cancelled := make(chan int, 2)
+	r.OnCancel("slow", func(ctx context.Context, j Job) {
+		cancelled <- j.ID
+	})
+	waitCancelled := func(id int) {
+		t.Helper()
+		select {
+		case got := <-cancelled:
+			if got != id {
+				t.Fatalf("expected OnCancel for job %d, got %d", id, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected OnCancel to run for job %d", id)
+		}
+	}
+
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		r.Run(runCtx)
+		close(done)
+	}()
+
+	running, _ := r.Enqueue(ctx, "slow", nil, nil)
+	<-started
+	if j, err := r.Cancel(ctx, running.ID); err != nil || !j.CancelRequested || j.Status != StatusRunning {
+		t.Fatalf("expected cancellation to be requested, got %+v, %v", j, err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		j, _ := store.Get(ctx, running.ID)
+		if j.Status == StatusCancelled {
+			if j.Progress != 25 {
+				t.Fatalf("expected progress to be saved, got %d", j.Progress)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the job to be cancelled, got %+v", j)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitCancelled(running.ID)
+
+	// A job cancelled while queued never runs.
+	queued, _ := store.Create(ctx, Job{Type: "slow", MaxAttempts: 1, RunAt: time.Now().Add(time.Hour)})
+	if j, err := r.Cancel(ctx, queued.ID); err != nil || j.Status != StatusCancelled {
+		t.Fatalf("expected the queued job to be cancelled, got %+v, %v", j, err)
+	}
+	waitCancelled(queued.ID)
+	if _, err := r.Cancel(ctx, queued.ID); !errors.Is(err, ErrJobFinished) {
+		t.Fatalf("expected %v, got %v", ErrJobFinished, err)
+	}
+
+	stop()
+	<-done
+}
+
+func TestExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	j, _ := store.Create(ctx, Job{Type: "test", MaxAttempts: 3})
+
+	now := time.Now().UTC()
+	first, _ := store.Claim(ctx, now, now.Add(time.Minute))
+	if _, err := store.Claim(ctx, now, now.Add(time.Minute)); !errors.Is(err, ErrNoJob) {
+		t.Fatalf("expected a leased job not to be claimed twice, got %v", err)
+	}
+
+	// The first worker stops renewing, so the job is due again once the
+	// lease runs out.
+	later := now.Add(2 * time.Minute)
+	second, err := store.Claim(ctx, later, later.Add(time.Minute))
+	if err != nil || second.ID != j.ID || second.Attempts != 2 {
+		t.Fatalf("expected the job to be claimed again, got %+v, %v", second, err)
+	}
+
+	if _, err := store.Heartbeat(ctx, j.ID, first.Attempts, 50, later); !errors.Is(err, ErrJobChanged) {
+		t.Fatalf("expected the first attempt to have lost the job, got %v", err)
+	}
+	if err := store.Finish(ctx, j.ID, first.Attempts, Outcome{Status: StatusSucceeded}); !errors.Is(err, ErrJobChanged) {
+		t.Fatalf("expected the first attempt to have lost the job, got %v", err)
+	}
+	if err := store.Finish(ctx, j.ID, second.Attempts, Outcome{Status: StatusSucceeded}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,180 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	Create(ctx context.Context, j Job) (Job, error)
+	Get(ctx context.Context, id int) (Job, error)
+	// Claim marks the next due job running, counts the attempt and leases it
+	// until lockedUntil. Queued jobs are due at RunAt and running jobs once
+	// their lease has expired. It returns ErrNoJob if nothing is due.
+	Claim(ctx context.Context, now, lockedUntil time.Time) (Job, error)
+	// Heartbeat saves progress, extends the lease of the given attempt and
+	// reports whether cancellation has been requested. It fails with
+	// ErrJobChanged if the attempt no longer holds the job.
+	Heartbeat(ctx context.Context, id, attempt, progress int, lockedUntil time.Time) (cancelRequested bool, err error)
+	// Finish records the outcome of a running attempt, failing with
+	// ErrJobChanged if the attempt no longer holds the job.
+	Finish(ctx context.Context, id, attempt int, o Outcome) error
+	// Cancel stops a queued job at once and asks a running one to stop. It
+	// fails with ErrJobFinished for a job that has already ended.
+	Cancel(ctx context.Context, id int) (Job, error)
+}
+
+type MemoryStore struct {
+	jobs   []Job
+	nextID int
+	mu     sync.Mutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nextID: 1}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, j Job) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	j.ID = s.nextID
+	s.nextID++
+	j.Status = StatusQueued
+	j.CreatedAt = now
+	j.UpdatedAt = now
+	if j.RunAt.IsZero() {
+		j.RunAt = now
+	}
+	s.jobs = append(s.jobs, j)
+	return j, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id int) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.index(id)
+	if err != nil {
+		return Job{}, err
+	}
+	return s.jobs[i], nil
+}
+
+func (s *MemoryStore) index(id int) (int, error) {
+	for i, j := range s.jobs {
+		if j.ID == id {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("%w: %d", ErrJobNotFound, id)
+}
+
+func (s *MemoryStore) Claim(ctx context.Context, now, lockedUntil time.Time) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []int
+	for i, j := range s.jobs {
+		if j.Status == StatusQueued && !j.RunAt.After(now) ||
+			j.Status == StatusRunning && j.LockedUntil != nil && j.LockedUntil.Before(now) {
+			due = append(due, i)
+		}
+	}
+	if len(due) == 0 {
+		return Job{}, ErrNoJob
+	}
+	sort.Slice(due, func(a, b int) bool {
+		ja, jb := s.jobs[due[a]], s.jobs[due[b]]
+		if !ja.RunAt.Equal(jb.RunAt) {
+			return ja.RunAt.Before(jb.RunAt)
+		}
+		return ja.ID < jb.ID
+	})
+
+	j := &s.jobs[due[0]]
+	j.Status = StatusRunning
+	j.Attempts++
+	j.LockedUntil = &lockedUntil
+	j.UpdatedAt = now
+	return *j, nil
+}
+
+// held finds the job if the given attempt is still running it.
+func (s *MemoryStore) held(id, attempt int) (*Job, error) {
+	i, err := s.index(id)
+	if err != nil {
+		return nil, err
+	}
+	j := &s.jobs[i]
+	if j.Status != StatusRunning || j.Attempts != attempt {
+		return nil, fmt.Errorf("%w: job %d attempt %d", ErrJobChanged, id, attempt)
+	}
+	return j, nil
+}
+
+func (s *MemoryStore) Heartbeat(ctx context.Context, id, attempt, progress int, lockedUntil time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, err := s.held(id, attempt)
+	if err != nil {
+		return false, err
+	}
+	j.Progress = progress
+	j.LockedUntil = &lockedUntil
+	j.UpdatedAt = time.Now().UTC()
+	return j.CancelRequested, nil
+}
+
+func (s *MemoryStore) Finish(ctx context.Context, id, attempt int, o Outcome) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, err := s.held(id, attempt)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	j.Status = o.Status
+	j.Progress = o.Progress
+	j.Result = o.Result
+	j.Error = o.Error
+	j.LockedUntil = nil
+	j.UpdatedAt = now
+	if o.Status == StatusQueued {
+		j.RunAt = o.RunAt
+	} else {
+		j.FinishedAt = &now
+	}
+	return nil
+}
+
+func (s *MemoryStore) Cancel(ctx context.Context, id int) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.index(id)
+	if err != nil {
+		return Job{}, err
+	}
+
+	j := &s.jobs[i]
+	now := time.Now().UTC()
+	switch j.Status {
+	case StatusQueued:
+		j.Status = StatusCancelled
+		j.FinishedAt = &now
+	case StatusRunning:
+		j.CancelRequested = true
+	default:
+		return Job{}, fmt.Errorf("%w: job %d is %s", ErrJobFinished, id, j.Status)
+	}
+	j.UpdatedAt = now
+	return *j, nil
+}
@@ -0,0 +1,60 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBlobStores(t *testing.T) {
+	local, err := NewLocalStore(t.TempDir(), "/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]BlobStore{
+		"local": local,
+		"s3":    NewS3Store(NewMemoryS3(), "store-media", "https://cdn.example.com"),
+	}
+	wantURL := map[string]string{
+		"local": "/media/products/1/a.png",
+		"s3":    "https://cdn.example.com/products/1/a.png",
+	}
+
+	for name, blobs := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			key := "products/1/a.png"
+
+			if err := blobs.Put(ctx, key, strings.NewReader("png bytes"), 9, "image/png"); err != nil {
+				t.Fatal(err)
+			}
+			body, err := blobs.Get(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(body)
+			body.Close()
+			if string(data) != "png bytes" {
+				t.Fatalf("expected the stored bytes back, got %q", data)
+			}
+
+			if got := blobs.URL(key); got != wantURL[name] {
+				t.Fatalf("expected URL %s, got %s", wantURL[name], got)
+			}
+
+			if err := blobs.Delete(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := blobs.Get(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+				t.Fatalf("expected %v after delete, got %v", ErrBlobNotFound, err)
+			}
+
+			if err := blobs.Put(ctx, "../escape.png", strings.NewReader("x"), 1, "image/png"); err == nil {
+				t.Fatal("expected a key outside the store to be rejected")
+			}
+		})
+	}
+}
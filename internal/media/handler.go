@@ -0,0 +1,49 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler serves blobs from a BlobStore, for stores that have no public URL
+// of their own such as LocalStore.
+type Handler struct {
+	blobs BlobStore
+}
+
+func NewHandler(blobs BlobStore) *Handler {
+	return &Handler{blobs: blobs}
+}
+
+// Get serves the blob named by the route's wildcard. Keys are random, so
+// responses can be cached indefinitely.
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+	if !validKey(key) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := h.blobs.Get(r.Context(), key)
+	if errors.Is(err, ErrBlobNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.Copy(w, body)
+}
@@ -0,0 +1,153 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxImageSize is the largest upload accepted, in bytes.
+	MaxImageSize = 10 << 20
+	// MaxImagePixels caps width*height so a small, highly compressed file
+	// can't expand into gigabytes when decoded.
+	MaxImagePixels = 40_000_000
+	// ThumbnailSize is the longest side of a generated thumbnail.
+	ThumbnailSize = 320
+)
+
+var (
+	ErrUnsupportedImage = errors.New("unsupported image type")
+	ErrImageTooLarge    = errors.New("image too large")
+	ErrInvalidImage     = errors.New("invalid image")
+)
+
+// imageTypes maps the content types we accept to their file extension.
+// WebP is sniffed by http.DetectContentType but has no standard decoder.
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Image is a decoded upload along with its thumbnail, ready to store.
+type Image struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	Data        []byte
+
+	ThumbnailContentType string
+	ThumbnailExt         string
+	Thumbnail            []byte
+}
+
+// ProcessImage checks that data is a supported image within the size
+// limits and generates its thumbnail. The content type comes from the bytes
+// themselves, not from whatever the client claimed.
+func ProcessImage(data []byte) (Image, error) {
+	if len(data) > MaxImageSize {
+		return Image{}, fmt.Errorf("%w: %d bytes, the limit is %d", ErrImageTooLarge, len(data), MaxImageSize)
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := imageTypes[contentType]
+	if !ok {
+		return Image{}, fmt.Errorf("%w: %s", ErrUnsupportedImage, contentType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return Image{}, fmt.Errorf("%w: %dx%d pixels", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	img := Image{
+		ContentType: contentType,
+		Ext:         ext,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		Data:        data,
+	}
+
+	// JPEG thumbnails are much smaller, but PNG keeps transparency.
+	var buf bytes.Buffer
+	thumb := Thumbnail(src, ThumbnailSize)
+	if contentType == "image/jpeg" {
+		img.ThumbnailContentType, img.ThumbnailExt = "image/jpeg", ".jpg"
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+	} else {
+		img.ThumbnailContentType, img.ThumbnailExt = "image/png", ".png"
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	img.Thumbnail = buf.Bytes()
+
+	return img, nil
+}
+
+// Thumbnail scales src down to fit in a size x size box, keeping its aspect
+// ratio, by averaging the source pixels behind each target pixel. Images
+// that already fit are copied at their original size.
+func Thumbnail(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, h*size/w)
+		} else {
+			tw, th = max(1, w*size/h), size
+		}
+	}
+
+	at := func(x, y int) color.RGBA64 {
+		return color.RGBA64Model.Convert(src.At(x, y)).(color.RGBA64)
+	}
+	if fast, ok := src.(image.RGBA64Image); ok {
+		at = fast.RGBA64At
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := at(sx, sy)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
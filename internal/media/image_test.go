@@ -0,0 +1,82 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encode(t *testing.T, format string, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		wantType      string
+		wantThumbType string
+		wantThumbW    int
+		wantThumbH    int
+		wantErr       error
+	}{
+		{name: "wide png", data: encode(t, "png", 800, 400), wantType: "image/png", wantThumbType: "image/png", wantThumbW: 320, wantThumbH: 160},
+		{name: "tall jpeg", data: encode(t, "jpeg", 300, 900), wantType: "image/jpeg", wantThumbType: "image/jpeg", wantThumbW: 106, wantThumbH: 320},
+		{name: "small gif kept at size", data: encode(t, "gif", 40, 30), wantType: "image/gif", wantThumbType: "image/png", wantThumbW: 40, wantThumbH: 30},
+		{name: "text", data: []byte("definitely not an image"), wantErr: ErrUnsupportedImage},
+		{name: "truncated png", data: encode(t, "png", 50, 50)[:20], wantErr: ErrInvalidImage},
+		{name: "too many bytes", data: make([]byte, MaxImageSize+1), wantErr: ErrImageTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := ProcessImage(tt.data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if img.ContentType != tt.wantType || img.ThumbnailContentType != tt.wantThumbType {
+				t.Fatalf("expected %s with a %s thumbnail, got %s and %s", tt.wantType, tt.wantThumbType, img.ContentType, img.ThumbnailContentType)
+			}
+			thumb, _, err := image.DecodeConfig(bytes.NewReader(img.Thumbnail))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if thumb.Width != tt.wantThumbW || thumb.Height != tt.wantThumbH {
+				t.Fatalf("expected a %dx%d thumbnail, got %dx%d", tt.wantThumbW, tt.wantThumbH, thumb.Width, thumb.Height)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// S3Client is the subset of an S3-compatible object storage API that
+// S3Store needs. An AWS SDK, MinIO or R2 client can satisfy it with a thin
+// adapter; MemoryS3 is a local stand-in for development and tests.
+type S3Client interface {
+	PutObject(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string) error
+	// GetObject fails with ErrBlobNotFound for a missing key.
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	DeleteObject(ctx context.Context, bucket, key string) error
+}
+
+// S3Store keeps blobs in a bucket of an S3-compatible service.
+type S3Store struct {
+	client S3Client
+	bucket string
+	// baseURL is the bucket's public address, e.g. a CDN in front of it.
+	baseURL string
+}
+
+func NewS3Store(client S3Client, bucket, baseURL string) *S3Store {
+	return &S3Store{
+		client:  client,
+		bucket:  bucket,
+		baseURL: baseURL,
+	}
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if !validKey(key) {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	return s.client.PutObject(ctx, s.bucket, key, r, size, contentType)
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.client.GetObject(ctx, s.bucket, key)
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	return s.client.DeleteObject(ctx, s.bucket, key)
+}
+
+func (s *S3Store) URL(key string) string {
+	return joinURL(s.baseURL, key)
+}
+
+type memoryObject struct {
+	data        []byte
+	contentType string
+}
+
+// MemoryS3 is an in-memory S3Client.
+type MemoryS3 struct {
+	buckets map[string]map[string]memoryObject
+	mu      sync.RWMutex
+}
+
+func NewMemoryS3() *MemoryS3 {
+	return &MemoryS3{buckets: make(map[string]map[string]memoryObject)}
+}
+
+func (m *MemoryS3) PutObject(ctx context.Context, bucket, key string, body io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if size >= 0 && int64(len(data)) != size {
+		return fmt.Errorf("put %s/%s: read %d bytes, expected %d", bucket, key, len(data), size)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.buckets[bucket] == nil {
+		m.buckets[bucket] = make(map[string]memoryObject)
+	}
+	m.buckets[bucket][key] = memoryObject{data: data, contentType: contentType}
+	return nil
+}
+
+func (m *MemoryS3) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, ok := m.buckets[bucket][key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s/%s", ErrBlobNotFound, bucket, key)
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+func (m *MemoryS3) DeleteObject(ctx context.Context, bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.buckets[bucket], key)
+	return nil
+}
@@ -75,6 +75,14 @@ var (
         },
     )
     
+    // Inventory metrics
+    LowStockEvents = promauto.NewCounter(
+        prometheus.CounterOpts{
+            Name: "inventory_low_stock_events_total",
+            Help: "Total number of low-stock events",
+        },
+    )
+    
     // Auth metrics
     UserRegistrations = promauto.NewCounter(
         prometheus.CounterOpts{
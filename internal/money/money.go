@@ -0,0 +1,209 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code.
+type Currency string
+
+const (
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	GBP Currency = "GBP"
+	JPY Currency = "JPY"
+	CAD Currency = "CAD"
+	AUD Currency = "AUD"
+	CHF Currency = "CHF"
+	SEK Currency = "SEK"
+)
+
+// exponents holds the number of minor-unit digits of each supported
+// currency. Currencies with three decimals are left out because prices are
+// stored as DECIMAL(10,2).
+var exponents = map[Currency]int{
+	USD: 2,
+	EUR: 2,
+	GBP: 2,
+	JPY: 0,
+	CAD: 2,
+	AUD: 2,
+	CHF: 2,
+	SEK: 2,
+}
+
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// ParseCurrency upper-cases code and checks it's supported.
+func ParseCurrency(code string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	if !c.Valid() {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
+
+func (c Currency) Valid() bool {
+	_, ok := exponents[c]
+	return ok
+}
+
+// Exponent is the number of digits after the decimal point, e.g. 2 for USD
+// and 0 for JPY.
+func (c Currency) Exponent() int {
+	return exponents[c]
+}
+
+// Money is an amount in a currency's minor units, e.g. cents.
+type Money struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+func New(amount int64, c Currency) Money {
+	return Money{Amount: amount, Currency: c}
+}
+
+// Parse reads a decimal amount in major units, such as "19.99", without
+// going through a float. More fractional digits than the currency has are
+// an error rather than being rounded away.
+func Parse(s string, c Currency) (Money, error) {
+	if !c.Valid() {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, c)
+	}
+
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+
+	exp := c.Exponent()
+	if whole == "" || len(frac) > exp && strings.TrimRight(frac[min(exp, len(frac)):], "0") != "" {
+		return Money{}, fmt.Errorf("invalid %s amount %q", c, s)
+	}
+	frac = (frac + strings.Repeat("0", exp))[:exp]
+
+	digits := whole + frac
+	if strings.ContainsFunc(digits, func(r rune) bool { return r < '0' || r > '9' }) {
+		return Money{}, fmt.Errorf("invalid %s amount %q", c, s)
+	}
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid %s amount %q", c, s)
+	}
+
+	if negative {
+		amount = -amount
+	}
+	return New(amount, c), nil
+}
+
+// Decimal formats the amount in major units, such as "19.99", which is the
+// form DECIMAL columns take.
+func (m Money) Decimal() string {
+	exp := m.Currency.Exponent()
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	s := strconv.FormatInt(amount, 10)
+	if exp == 0 {
+		return sign + s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exp] + "." + s[len(s)-exp:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.Currency)
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// Add returns m+o. Adding different currencies is a programming error and
+// panics.
+func (m Money) Add(o Money) Money {
+	m.mustMatch(o)
+	return New(m.Amount+o.Amount, m.Currency)
+}
+
+// Sub returns m-o and panics on a currency mismatch like Add.
+func (m Money) Sub(o Money) Money {
+	m.mustMatch(o)
+	return New(m.Amount-o.Amount, m.Currency)
+}
+
+func (m Money) Mul(n int) Money {
+	return New(m.Amount*int64(n), m.Currency)
+}
+
+// Percent returns pct percent of m, rounded half away from zero to the
+// nearest minor unit.
+func (m Money) Percent(pct float64) Money {
+	return New(int64(math.Round(float64(m.Amount)*pct/100)), m.Currency)
+}
+
+// Min returns the smaller of m and o.
+func (m Money) Min(o Money) Money {
+	m.mustMatch(o)
+	if o.Amount < m.Amount {
+		return o
+	}
+	return m
+}
+
+// Allocate splits m in proportion to weights. Each share is rounded down and
+// the leftover minor units go to the shares with the largest remainders, so
+// the shares always add up to m exactly.
+func (m Money) Allocate(weights []int64) []Money {
+	shares := make([]Money, len(weights))
+	var total int64
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 {
+		for i := range shares {
+			shares[i] = New(0, m.Currency)
+		}
+		return shares
+	}
+
+	remainders := make([]int64, len(weights))
+	left := m.Amount
+	for i, w := range weights {
+		shares[i] = New(m.Amount*w/total, m.Currency)
+		remainders[i] = m.Amount * w % total
+		left -= shares[i].Amount
+	}
+
+	for ; left > 0; left-- {
+		largest := 0
+		for i := range remainders {
+			if remainders[i] > remainders[largest] {
+				largest = i
+			}
+		}
+		shares[largest].Amount++
+		remainders[largest] = -1
+	}
+	return shares
+}
+
+func (m Money) mustMatch(o Money) {
+	if m.Currency != o.Currency {
+		panic(fmt.Sprintf("money: currency mismatch %s and %s", m.Currency, o.Currency))
+	}
+}
@@ -0,0 +1,137 @@
+package money
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency Currency
+		want     int64
+		wantErr  bool
+	}{
+		{input: "19.99", currency: USD, want: 1999},
+		{input: "19.9", currency: USD, want: 1990},
+		{input: "19", currency: USD, want: 1900},
+		{input: "0.07", currency: EUR, want: 7},
+		{input: "19.990", currency: USD, want: 1999},
+		{input: "-1.50", currency: USD, want: -150},
+		{input: "1500", currency: JPY, want: 1500},
+		{input: "19.999", currency: USD, wantErr: true},
+		{input: "1500.5", currency: JPY, wantErr: true},
+		{input: ".5", currency: USD, wantErr: true},
+		{input: "1e3", currency: USD, wantErr: true},
+		{input: "abc", currency: USD, wantErr: true},
+		{input: "1.00", currency: "XYZ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			m, err := Parse(tt.input, tt.currency)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", m)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Amount != tt.want || m.Currency != tt.currency {
+				t.Fatalf("expected %d %s, got %v", tt.want, tt.currency, m)
+			}
+		})
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{New(1999, USD), "19.99"},
+		{New(5, USD), "0.05"},
+		{New(0, USD), "0.00"},
+		{New(-150, EUR), "-1.50"},
+		{New(1500, JPY), "1500"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.m.Decimal(); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+		if back, _ := Parse(tt.m.Decimal(), tt.m.Currency); back != tt.m {
+			t.Errorf("expected %v to round-trip, got %v", tt.m, back)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       Money
+		weights []int64
+		want    []int64
+	}{
+		{name: "even", m: New(900, USD), weights: []int64{1, 1, 1}, want: []int64{300, 300, 300}},
+		{name: "remainder to largest fraction", m: New(500, USD), weights: []int64{998, 150}, want: []int64{435, 65}},
+		{name: "one cent over three", m: New(100, USD), weights: []int64{1, 1, 1}, want: []int64{34, 33, 33}},
+		{name: "zero weights", m: New(100, USD), weights: []int64{0, 0}, want: []int64{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int64
+			for _, share := range tt.m.Allocate(tt.weights) {
+				got = append(got, share.Amount)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	rates, err := NewRates(USD, map[Currency]string{EUR: "0.92", JPY: "151.37", GBP: "0.79"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		m       Money
+		to      Currency
+		want    Money
+		wantErr error
+	}{
+		{name: "same currency", m: New(1999, USD), to: USD, want: New(1999, USD)},
+		{name: "from base", m: New(1999, USD), to: EUR, want: New(1839, EUR)},
+		{name: "to base", m: New(1839, EUR), to: USD, want: New(1999, USD)},
+		{name: "zero-decimal target", m: New(1999, USD), to: JPY, want: New(3026, JPY)},
+		{name: "zero-decimal source", m: New(3026, JPY), to: USD, want: New(1999, USD)},
+		{name: "cross rate", m: New(1000, EUR), to: GBP, want: New(859, GBP)},
+		{name: "no rate", m: New(1000, USD), to: CHF, wantErr: ErrNoRate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rates.Convert(tt.m, tt.to)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
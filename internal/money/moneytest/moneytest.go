@@ -0,0 +1,9 @@
+// Package moneytest provides helpers for tests that work with money.
+package moneytest
+
+import "lukekorsman.com/store/internal/money"
+
+// USD returns cents as a US dollar amount.
+func USD(cents int64) money.Money {
+	return money.New(cents, money.USD)
+}
@@ -0,0 +1,130 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+var ErrNoRate = errors.New("no conversion rate")
+
+// Rates converts between currencies using rates quoted against a base
+// currency. Rates are kept as exact fractions so a conversion only rounds
+// once.
+type Rates struct {
+	Base  Currency
+	rates map[Currency]*big.Rat
+}
+
+// ratesFile is the JSON layout LoadRates reads, e.g.
+//
+//	{"base": "USD", "rates": {"EUR": "0.92", "JPY": "151.37"}}
+//
+// Each rate is how many units of the currency one unit of base buys.
+type ratesFile struct {
+	Base  string                 `json:"base"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+// LoadRates reads a conversion-rate table from a local JSON file.
+func LoadRates(path string) (*Rates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f ratesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	base, err := ParseCurrency(f.Base)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: base: %w", path, err)
+	}
+
+	rates := map[Currency]string{}
+	for code, rate := range f.Rates {
+		c, err := ParseCurrency(code)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		rates[c] = rate.String()
+	}
+
+	r, err := NewRates(base, rates)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// NewRates builds a table from decimal rate strings quoted against base.
+func NewRates(base Currency, rates map[Currency]string) (*Rates, error) {
+	r := &Rates{
+		Base:  base,
+		rates: map[Currency]*big.Rat{base: big.NewRat(1, 1)},
+	}
+	for c, s := range rates {
+		rate, ok := new(big.Rat).SetString(s)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate %q for %s", s, c)
+		}
+		r.rates[c] = rate
+	}
+	return r, nil
+}
+
+// Convert returns m in currency to, rounded half away from zero to the
+// target's minor unit. Currencies that aren't quoted against the base
+// convert through it.
+func (r *Rates) Convert(m Money, to Currency) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+
+	from, ok := r.rates[m.Currency]
+	if !ok {
+		return Money{}, fmt.Errorf("%w for %s", ErrNoRate, m.Currency)
+	}
+	rate, ok := r.rates[to]
+	if !ok {
+		return Money{}, fmt.Errorf("%w for %s", ErrNoRate, to)
+	}
+
+	// amount * rate/from, rescaled from the source's minor units to the
+	// target's.
+	x := new(big.Rat).SetInt64(m.Amount)
+	x.Mul(x, rate)
+	x.Quo(x, from)
+	x.Mul(x, scale(to.Exponent()))
+	x.Quo(x, scale(m.Currency.Exponent()))
+
+	return New(roundRat(x), to), nil
+}
+
+// Has reports whether c can be converted to and from.
+func (r *Rates) Has(c Currency) bool {
+	_, ok := r.rates[c]
+	return ok
+}
+
+func scale(exp int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+}
+
+// roundRat rounds x half away from zero.
+func roundRat(x *big.Rat) int64 {
+	num := new(big.Int).Abs(x.Num())
+	den := x.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if x.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
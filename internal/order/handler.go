@@ -0,0 +1,180 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"lukekorsman.com/store/internal/api"
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/inventory"
+	"lukekorsman.com/store/internal/promotion"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	orders *Service
+}
+
+func NewHandler(orders *Service) *Handler {
+	return &Handler{orders: orders}
+}
+
+type StatusRequest struct {
+	Status Status `json:"status"`
+}
+
+// Create checks out the user's cart.
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	o, err := h.orders.Checkout(r.Context(), user.ID)
+	if errors.Is(err, ErrEmptyCart) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, inventory.ErrInsufficientStock) || errors.Is(err, ErrCouponInvalid) ||
+		errors.Is(err, promotion.ErrNotApplicable) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, o)
+}
+
+// List returns the user's own orders, newest first.
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, offset, errs := api.ParsePage(r.URL.Query())
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	orders, total, err := h.orders.ListByUser(r.Context(), user.ID, limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	api.SetPageHeaders(w, r, limit, offset, len(orders), total)
+	writeJSON(w, http.StatusOK, orders)
+}
+
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	o, ok := h.ownOrder(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, o)
+}
+
+// Cancel lets a user cancel their own order while it's still pending.
+func (h *Handler) Cancel(w http.ResponseWriter, r *http.Request) {
+	o, ok := h.ownOrder(w, r)
+	if !ok {
+		return
+	}
+
+	h.transition(w, r, o.ID, StatusCancelled)
+}
+
+// UpdateStatus moves any order to a new status. It's mounted under /admin.
+// Orders only become paid or refunded through the payment service, so that
+// the order always agrees with its payment attempts.
+func (h *Handler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req StatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if !req.Status.Valid() {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []api.ValidationError{{
+				Field:   "status",
+				Message: "status must be one of pending, paid, shipped, delivered, cancelled, refunded",
+			}},
+		})
+		return
+	}
+	if req.Status == StatusPaid || req.Status == StatusRefunded {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []api.ValidationError{{
+				Field:   "status",
+				Message: "orders become paid and refunded through payments; use POST /admin/orders/{id}/refund to refund",
+			}},
+		})
+		return
+	}
+
+	h.transition(w, r, id, req.Status)
+}
+
+func (h *Handler) transition(w http.ResponseWriter, r *http.Request, id int, to Status) {
+	o, err := h.orders.Transition(r.Context(), id, to)
+	if errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrStatusChanged) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, o)
+}
+
+// ownOrder loads the order in the URL if it belongs to the current user.
+// Other users' orders are reported as not found.
+func (h *Handler) ownOrder(w http.ResponseWriter, r *http.Request) (Order, bool) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return Order{}, false
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return Order{}, false
+	}
+
+	o, err := h.orders.Get(r.Context(), id)
+	if err != nil || o.UserID != user.ID {
+		http.Error(w, "order not found", http.StatusNotFound)
+		return Order{}, false
+	}
+
+	return o, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
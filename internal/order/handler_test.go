@@ -0,0 +1,300 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/cart"
+	"lukekorsman.com/store/internal/inventory"
+	"lukekorsman.com/store/internal/money/moneytest"
+	"lukekorsman.com/store/internal/order/ordertest"
+	"lukekorsman.com/store/internal/product"
+	"lukekorsman.com/store/internal/promotion"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type fixture struct {
+	orders    *Service
+	products  *product.MemoryStore
+	carts     *cart.Service
+	inventory *inventory.Service
+	coupons   *promotion.MemoryStore
+	router    http.Handler
+}
+
+func newFixture(t *testing.T) fixture {
+	t.Helper()
+	d := ordertest.New()
+	orders := NewService(NewMemoryStore(), d.Carts, d.Inventory, d.Promotions)
+	h := NewHandler(orders)
+
+	r := chi.NewRouter()
+	r.Post("/orders", h.Create)
+	r.Get("/orders", h.List)
+	r.Get("/orders/{id}", h.Get)
+	r.Post("/orders/{id}/cancel", h.Cancel)
+	r.Put("/admin/orders/{id}/status", h.UpdateStatus)
+
+	return fixture{orders: orders, products: d.Products, carts: d.Carts, inventory: d.Inventory, coupons: d.Coupons, router: r}
+}
+
+func (f fixture) do(t *testing.T, userID int, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.User{ID: userID}))
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCheckout(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	f.carts.Add(ctx, cart.Owner{UserID: 1}, 1, 2)
+	f.carts.Add(ctx, cart.Owner{UserID: 1}, 2, 1)
+
+	rec := f.do(t, 1, http.MethodPost, "/orders", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	var o Order
+	json.NewDecoder(rec.Body).Decode(&o)
+	if o.Status != StatusPending || len(o.Lines) != 2 || o.Total != moneytest.USD(1148) {
+		t.Fatalf("unexpected order %+v", o)
+	}
+
+	// Lines keep the name and price they had at checkout.
+	mug, _ := f.products.GetByID(ctx, 1)
+	mug.Name, mug.Price = "Big Mug", moneytest.USD(900)
+	f.products.Update(ctx, mug.ID, mug)
+
+	rec = f.do(t, 1, http.MethodGet, fmt.Sprintf("/orders/%d", o.ID), "")
+	json.NewDecoder(rec.Body).Decode(&o)
+	if o.Lines[0].Name != "Mug" || o.Lines[0].UnitPrice != moneytest.USD(499) {
+		t.Fatalf("expected the checkout snapshot, got %+v", o.Lines[0])
+	}
+
+	if items, _ := f.carts.Items(ctx, cart.Owner{UserID: 1}); len(items) != 0 {
+		t.Fatalf("expected the cart to be emptied, got %+v", items)
+	}
+	if s, _ := f.inventory.Get(ctx, 1); s.Reserved != 2 {
+		t.Fatalf("expected 2 mugs reserved, got %d", s.Reserved)
+	}
+
+	if rec := f.do(t, 2, http.MethodGet, fmt.Sprintf("/orders/%d", o.ID), ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected another user's order to be hidden, got %d", rec.Code)
+	}
+	if rec := f.do(t, 1, http.MethodPost, "/orders", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected an empty cart to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestCheckout_Coupon(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	coupon, _ := f.coupons.Create(ctx, promotion.Coupon{
+		Code: "ONCE", Kind: promotion.KindPercentage, Value: 10, UsageLimitPerUser: 1, Active: true,
+	})
+	owner := cart.Owner{UserID: 1}
+
+	f.carts.Add(ctx, owner, 1, 2)
+	if _, err := f.carts.ApplyCoupon(ctx, owner, "once"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := f.do(t, 1, http.MethodPost, "/orders", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var o Order
+	json.NewDecoder(rec.Body).Decode(&o)
+	if o.CouponCode != "ONCE" || o.Discount != moneytest.USD(100) || o.Total != moneytest.USD(898) || o.Lines[0].Discount != moneytest.USD(100) {
+		t.Fatalf("unexpected order %+v", o)
+	}
+
+	// A second cart can't take the coupon once it's used up.
+	f.carts.Add(ctx, owner, 1, 1)
+	if _, err := f.carts.ApplyCoupon(ctx, owner, "ONCE"); !errors.Is(err, promotion.ErrNotApplicable) {
+		t.Fatalf("expected ErrNotApplicable, got %v", err)
+	}
+
+	// Nor can a cart that applied it before it was used up elsewhere.
+	f.coupons.CancelRedemption(ctx, 1)
+	if _, err := f.carts.ApplyCoupon(ctx, owner, "ONCE"); err != nil {
+		t.Fatal(err)
+	}
+	f.coupons.Redeem(ctx, coupon, 1)
+
+	if rec := f.do(t, 1, http.MethodPost, "/orders", ""); rec.Code != http.StatusConflict {
+		t.Fatalf("expected %d, got %d", http.StatusConflict, rec.Code)
+	}
+	if s, _ := f.inventory.Get(ctx, 1); s.Reserved != 2 {
+		t.Fatalf("expected the failed checkout to reserve nothing, got %d reserved", s.Reserved)
+	}
+}
+
+func TestCancel_GivesBackCoupon(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	f.coupons.Create(ctx, promotion.Coupon{
+		Code: "ONCE", Kind: promotion.KindPercentage, Value: 10, UsageLimitPerUser: 1, Active: true,
+	})
+	owner := cart.Owner{UserID: 1}
+
+	f.carts.Add(ctx, owner, 1, 2)
+	f.carts.ApplyCoupon(ctx, owner, "ONCE")
+	if rec := f.do(t, 1, http.MethodPost, "/orders", ""); rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	if rec := f.do(t, 1, http.MethodPost, "/orders/1/cancel", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	if n, _ := f.coupons.Redemptions(ctx, 1, 1); n != 0 {
+		t.Fatalf("expected the redemption to be cancelled, got %d", n)
+	}
+	f.carts.Add(ctx, owner, 1, 1)
+	if _, err := f.carts.ApplyCoupon(ctx, owner, "ONCE"); err != nil {
+		t.Fatalf("expected the coupon to be usable again, got %v", err)
+	}
+}
+
+func TestCheckout_InsufficientStock(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	f.carts.Add(ctx, cart.Owner{UserID: 1}, 1, 1)
+	f.carts.Add(ctx, cart.Owner{UserID: 1}, 2, 2)
+
+	if rec := f.do(t, 1, http.MethodPost, "/orders", ""); rec.Code != http.StatusConflict {
+		t.Fatalf("expected %d, got %d", http.StatusConflict, rec.Code)
+	}
+	if s, _ := f.inventory.Get(ctx, 1); s.Reserved != 0 {
+		t.Fatalf("expected nothing reserved, got %d", s.Reserved)
+	}
+	if items, _ := f.carts.Items(ctx, cart.Owner{UserID: 1}); len(items) != 2 {
+		t.Fatalf("expected the cart to be kept, got %+v", items)
+	}
+}
+
+func TestOrderTransitions(t *testing.T) {
+	tests := []struct {
+		name         string
+		steps        []Status
+		wantStatus   int
+		wantOnHand   int
+		wantReserved int
+	}{
+		{
+			name:         "cancel pending",
+			steps:        []Status{StatusCancelled},
+			wantStatus:   http.StatusOK,
+			wantOnHand:   10,
+			wantReserved: 0,
+		},
+		{
+			name:         "ship commits stock",
+			steps:        []Status{StatusPaid, StatusShipped, StatusDelivered},
+			wantStatus:   http.StatusOK,
+			wantOnHand:   7,
+			wantReserved: 0,
+		},
+		{
+			name:         "refund before shipping releases stock",
+			steps:        []Status{StatusPaid, StatusRefunded},
+			wantStatus:   http.StatusOK,
+			wantOnHand:   10,
+			wantReserved: 0,
+		},
+		{
+			name:         "cannot ship unpaid",
+			steps:        []Status{StatusShipped},
+			wantStatus:   http.StatusConflict,
+			wantOnHand:   10,
+			wantReserved: 3,
+		},
+		{
+			name:         "cannot cancel shipped",
+			steps:        []Status{StatusPaid, StatusShipped, StatusCancelled},
+			wantStatus:   http.StatusConflict,
+			wantOnHand:   7,
+			wantReserved: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t)
+			f.carts.Add(ctx, cart.Owner{UserID: 1}, 1, 3)
+			f.do(t, 1, http.MethodPost, "/orders", "")
+
+			// Payments move orders to paid and refunded, so those steps go
+			// through the service rather than the admin endpoint.
+			code := http.StatusOK
+			for _, status := range tt.steps {
+				if status == StatusPaid || status == StatusRefunded {
+					if _, err := f.orders.Transition(ctx, 1, status); err != nil {
+						t.Fatalf("moving to %s: %v", status, err)
+					}
+					continue
+				}
+				code = f.do(t, 1, http.MethodPut, "/admin/orders/1/status", fmt.Sprintf(`{"status":%q}`, status)).Code
+			}
+
+			if code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, code)
+			}
+			s, _ := f.inventory.Get(ctx, 1)
+			if s.OnHand != tt.wantOnHand || s.Reserved != tt.wantReserved {
+				t.Fatalf("expected on_hand %d reserved %d, got %+v", tt.wantOnHand, tt.wantReserved, s)
+			}
+		})
+	}
+}
+
+func TestUpdateStatusRejectsPaymentStatuses(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	f.carts.Add(ctx, cart.Owner{UserID: 1}, 1, 1)
+	f.do(t, 1, http.MethodPost, "/orders", "")
+
+	for _, status := range []Status{StatusPaid, StatusRefunded} {
+		rec := f.do(t, 1, http.MethodPut, "/admin/orders/1/status", fmt.Sprintf(`{"status":%q}`, status))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected %s to be rejected with %d, got %d", status, http.StatusBadRequest, rec.Code)
+		}
+	}
+	if o, _ := f.orders.Get(ctx, 1); o.Status != StatusPending {
+		t.Fatalf("expected the order to stay pending, got %s", o.Status)
+	}
+}
+
+func TestListOrders(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	for _, userID := range []int{1, 2, 1} {
+		f.carts.Add(ctx, cart.Owner{UserID: userID}, 1, 1)
+		f.do(t, userID, http.MethodPost, "/orders", "")
+	}
+
+	rec := f.do(t, 1, http.MethodGet, "/orders", "")
+	var orders []Order
+	json.NewDecoder(rec.Body).Decode(&orders)
+
+	if rec.Header().Get("X-Total-Count") != "2" || len(orders) != 2 || orders[0].ID != 3 || orders[1].ID != 1 {
+		t.Fatalf("expected user 1's orders newest first, got %+v", orders)
+	}
+
+	if rec := f.do(t, 2, http.MethodPost, "/orders/1/cancel", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected cancelling another user's order to 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,208 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/product"
+)
+
+const orderColumns = "id, user_id, status, subtotal, discount, coupon_code, total, created_at, updated_at"
+
+type MySQLStore struct {
+	db *sql.DB
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOrder(row rowScanner) (Order, error) {
+	var o Order
+	var subtotal, discount, total string
+	err := row.Scan(&o.ID, &o.UserID, &o.Status, &subtotal, &discount, &o.CouponCode, &total, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return Order{}, err
+	}
+	err = parseAmounts([]string{subtotal, discount, total}, &o.Subtotal, &o.Discount, &o.Total)
+	return o, err
+}
+
+// parseAmounts parses DECIMAL columns, read as strings so no precision is
+// lost on the way, into base-currency amounts.
+func parseAmounts(values []string, dst ...*money.Money) error {
+	for i, v := range values {
+		m, err := money.Parse(v, product.BaseCurrency)
+		if err != nil {
+			return err
+		}
+		*dst[i] = m
+	}
+	return nil
+}
+
+func (s *MySQLStore) Create(ctx context.Context, o Order) (Order, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Order{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO orders (user_id, status, subtotal, discount, coupon_code, total) VALUES (?, ?, ?, ?, ?, ?)",
+		o.UserID, o.Status, o.Subtotal.Decimal(), o.Discount.Decimal(), o.CouponCode, o.Total.Decimal(),
+	)
+	if err != nil {
+		return Order{}, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Order{}, err
+	}
+
+	for _, line := range o.Lines {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO order_lines (order_id, product_id, name, unit_price, quantity, subtotal, discount) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			id, line.ProductID, line.Name, line.UnitPrice.Decimal(), line.Quantity, line.Subtotal.Decimal(), line.Discount.Decimal(),
+		)
+		if err != nil {
+			return Order{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Order{}, err
+	}
+
+	return s.GetByID(ctx, int(id))
+}
+
+func (s *MySQLStore) GetByID(ctx context.Context, id int) (Order, error) {
+	o, err := scanOrder(s.db.QueryRowContext(ctx,
+		"SELECT "+orderColumns+" FROM orders WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Order{}, fmt.Errorf("order %d not found", id)
+	}
+	if err != nil {
+		return Order{}, err
+	}
+
+	orders := []Order{o}
+	if err := s.attachLines(ctx, orders); err != nil {
+		return Order{}, err
+	}
+	return orders[0], nil
+}
+
+func (s *MySQLStore) ListByUser(ctx context.Context, userID, limit, offset int) ([]Order, int, error) {
+	var total int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM orders WHERE user_id = ?", userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+orderColumns+" FROM orders WHERE user_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		userID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	orders := []Order{}
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.attachLines(ctx, orders); err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+// attachLines loads the lines of every order in one query.
+func (s *MySQLStore) attachLines(ctx context.Context, orders []Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ids := make([]any, len(orders))
+	index := make(map[int]int, len(orders))
+	for i, o := range orders {
+		ids[i] = o.ID
+		index[o.ID] = i
+		orders[i].Lines = []Line{}
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT order_id, product_id, name, unit_price, quantity, subtotal, discount FROM order_lines "+
+			"WHERE order_id IN ("+placeholders(len(ids))+") ORDER BY id",
+		ids...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID int
+		var line Line
+		var unitPrice, subtotal, discount string
+		err := rows.Scan(&orderID, &line.ProductID, &line.Name, &unitPrice, &line.Quantity, &subtotal, &discount)
+		if err != nil {
+			return err
+		}
+		if err := parseAmounts([]string{unitPrice, subtotal, discount}, &line.UnitPrice, &line.Subtotal, &line.Discount); err != nil {
+			return err
+		}
+		i := index[orderID]
+		orders[i].Lines = append(orders[i].Lines, line)
+	}
+
+	return rows.Err()
+}
+
+func (s *MySQLStore) UpdateStatus(ctx context.Context, id int, from, to Status) (Order, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE orders SET status = ? WHERE id = ? AND status = ?",
+		to, id, from,
+	)
+	if err != nil {
+		return Order{}, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return Order{}, err
+	}
+
+	o, err := s.GetByID(ctx, id)
+	if err != nil {
+		return Order{}, err
+	}
+	if rows == 0 {
+		return Order{}, fmt.Errorf("%w: order %d is %s", ErrStatusChanged, id, o.Status)
+	}
+	return o, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
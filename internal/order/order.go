@@ -0,0 +1,84 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"lukekorsman.com/store/internal/money"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusPaid      Status = "paid"
+	StatusShipped   Status = "shipped"
+	StatusDelivered Status = "delivered"
+	StatusCancelled Status = "cancelled"
+	StatusRefunded  Status = "refunded"
+)
+
+// transitions lists the statuses each status may move to. Cancelled and
+// refunded orders are final.
+var transitions = map[Status][]Status{
+	StatusPending:   {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusShipped, StatusRefunded},
+	StatusShipped:   {StatusDelivered, StatusRefunded},
+	StatusDelivered: {StatusRefunded},
+}
+
+var (
+	ErrEmptyCart = errors.New("cart is empty")
+	// ErrCouponInvalid is returned when the cart's coupon no longer applies.
+	ErrCouponInvalid = errors.New("cart coupon can't be used")
+	// ErrInvalidTransition is returned for a status change the state machine
+	// doesn't allow.
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrStatusChanged is returned by a Store when the order is no longer in
+	// the status the caller read, because another request moved it first.
+	ErrStatusChanged = errors.New("order status changed concurrently")
+)
+
+// Line is a snapshot of a cart line at checkout. Name and price don't
+// follow later product changes.
+type Line struct {
+	ProductID int         `json:"product_id"`
+	Name      string      `json:"name"`
+	UnitPrice money.Money `json:"unit_price"`
+	Quantity  int         `json:"quantity"`
+	Subtotal  money.Money `json:"subtotal"`
+	Discount  money.Money `json:"discount"`
+}
+
+type Order struct {
+	ID       int         `json:"id"`
+	UserID   int         `json:"user_id"`
+	Status   Status      `json:"status"`
+	Lines    []Line      `json:"lines"`
+	Subtotal money.Money `json:"subtotal"`
+	// Discount is the coupon discount; Total is Subtotal less Discount.
+	Discount   money.Money `json:"discount"`
+	CouponCode string      `json:"coupon_code,omitempty"`
+	Total      money.Money `json:"total"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+func (s Status) Valid() bool {
+	_, ok := transitions[s]
+	return ok || s == StatusCancelled || s == StatusRefunded
+}
+
+// CanTransition reports whether an order may move from s to next.
+func (s Status) CanTransition(next Status) bool {
+	return slices.Contains(transitions[s], next)
+}
+
+func checkTransition(from, to Status) error {
+	if !from.CanTransition(to) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Package ordertest builds the in-memory catalog, stock, coupons and carts
+// that tests construct an order.Service from.
+package ordertest
+
+import (
+	"context"
+
+	"lukekorsman.com/store/internal/cart"
+	"lukekorsman.com/store/internal/inventory"
+	"lukekorsman.com/store/internal/money/moneytest"
+	"lukekorsman.com/store/internal/product"
+	"lukekorsman.com/store/internal/promotion"
+)
+
+// Deps are the services an order.Service needs, backed by memory stores.
+type Deps struct {
+	Products   *product.MemoryStore
+	Inventory  *inventory.Service
+	Coupons    *promotion.MemoryStore
+	Promotions *promotion.Service
+	Carts      *cart.Service
+}
+
+// New stocks two products: product 1 is a Mug at $4.99 with 10 on hand and
+// product 2 a Pen at $1.50 with 1 on hand.
+func New() Deps {
+	ctx := context.Background()
+
+	products := product.NewMemoryStore()
+	products.Create(ctx, product.Product{Name: "Mug", Price: moneytest.USD(499)})
+	products.Create(ctx, product.Product{Name: "Pen", Price: moneytest.USD(150)})
+
+	stock := inventory.NewService(inventory.NewMemoryStore())
+	stock.Adjust(ctx, inventory.Adjustment{ProductID: 1, Delta: 10, Reason: inventory.ReasonReceived})
+	stock.Adjust(ctx, inventory.Adjustment{ProductID: 2, Delta: 1, Reason: inventory.ReasonReceived})
+
+	coupons := promotion.NewMemoryStore()
+	promotions := promotion.NewService(coupons, product.NewMemoryCategoryStore())
+	carts := cart.NewService(products, cart.NewMemoryStore(0), cart.NewMemoryStore(0), promotions)
+
+	return Deps{
+		Products:   products,
+		Inventory:  stock,
+		Coupons:    coupons,
+		Promotions: promotions,
+		Carts:      carts,
+	}
+}
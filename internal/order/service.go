@@ -0,0 +1,169 @@
+package order
+
+import (
+	"context"
+	"fmt"
+
+	"lukekorsman.com/store/internal/cart"
+	"lukekorsman.com/store/internal/inventory"
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/promotion"
+)
+
+// Service places orders from carts and moves them through their statuses,
+// keeping inventory reservations in step. Coupon use is recorded through
+// promotions, which may be nil when coupons are disabled.
+type Service struct {
+	store      Store
+	carts      *cart.Service
+	inventory  *inventory.Service
+	promotions *promotion.Service
+}
+
+func NewService(store Store, carts *cart.Service, inventory *inventory.Service, promotions *promotion.Service) *Service {
+	return &Service{
+		store:      store,
+		carts:      carts,
+		inventory:  inventory,
+		promotions: promotions,
+	}
+}
+
+func (s *Service) Get(ctx context.Context, id int) (Order, error) {
+	return s.store.GetByID(ctx, id)
+}
+
+func (s *Service) ListByUser(ctx context.Context, userID, limit, offset int) ([]Order, int, error) {
+	return s.store.ListByUser(ctx, userID, limit, offset)
+}
+
+// Checkout turns the user's cart into a pending order at current prices,
+// reserves its stock, redeems its coupon and empties the cart. A coupon that
+// no longer applies fails the checkout with ErrCouponInvalid so the user
+// isn't charged more than the cart showed.
+func (s *Service) Checkout(ctx context.Context, userID int) (Order, error) {
+	owner := cart.Owner{UserID: userID}
+	c, err := s.carts.Get(ctx, owner)
+	if err != nil {
+		return Order{}, err
+	}
+	if len(c.Lines) == 0 {
+		return Order{}, ErrEmptyCart
+	}
+
+	o := Order{
+		UserID:   userID,
+		Status:   StatusPending,
+		Subtotal: c.Subtotal,
+		Discount: money.New(0, c.Subtotal.Currency),
+		Total:    c.Subtotal,
+	}
+	if c.Coupon != nil && c.Coupon.Error != "" {
+		return Order{}, fmt.Errorf("%w: %s", ErrCouponInvalid, c.Coupon.Error)
+	}
+	if c.Coupon != nil {
+		o.CouponCode = c.Coupon.Code
+		o.Discount = c.Discount
+		o.Total = c.Total
+	}
+	for _, l := range c.Lines {
+		line := Line{
+			ProductID: l.ProductID,
+			Name:      l.Name,
+			UnitPrice: l.UnitPrice,
+			Quantity:  l.Quantity,
+			Subtotal:  l.Subtotal,
+			Discount:  money.New(0, l.Subtotal.Currency),
+		}
+		if o.CouponCode != "" {
+			line.Discount = l.Discount
+		}
+		o.Lines = append(o.Lines, line)
+	}
+
+	items := inventoryItems(o)
+	if _, err := s.inventory.Reserve(ctx, items); err != nil {
+		return Order{}, err
+	}
+
+	var redemptionID int
+	if o.CouponCode != "" {
+		redemptionID, err = s.promotions.Redeem(ctx, o.CouponCode, userID)
+		if err != nil {
+			s.release(ctx, items)
+			return Order{}, err
+		}
+	}
+
+	o, err = s.store.Create(ctx, o)
+	if err != nil {
+		s.release(ctx, items)
+		if redemptionID != 0 {
+			if err := s.promotions.CancelRedemption(ctx, redemptionID); err != nil {
+				fmt.Printf("Failed to cancel coupon redemption %d: %v\n", redemptionID, err)
+			}
+		}
+		return Order{}, err
+	}
+
+	if redemptionID != 0 {
+		if err := s.promotions.AttachOrder(ctx, redemptionID, o.ID); err != nil {
+			fmt.Printf("Failed to attach coupon redemption %d to order %d: %v\n", redemptionID, o.ID, err)
+		}
+	}
+
+	if err := s.carts.Clear(ctx, owner); err != nil {
+		fmt.Printf("Failed to clear cart for user %d after order %d: %v\n", userID, o.ID, err)
+	}
+	return o, nil
+}
+
+// Transition moves an order to a new status. Shipping commits the reserved
+// stock; cancelling or refunding before shipment releases it. Cancelling
+// also gives back the order's coupon use.
+func (s *Service) Transition(ctx context.Context, id int, to Status) (Order, error) {
+	o, err := s.store.GetByID(ctx, id)
+	if err != nil {
+		return Order{}, err
+	}
+	if err := checkTransition(o.Status, to); err != nil {
+		return Order{}, err
+	}
+
+	updated, err := s.store.UpdateStatus(ctx, id, o.Status, to)
+	if err != nil {
+		return Order{}, err
+	}
+
+	items := inventoryItems(o)
+	switch {
+	case to == StatusShipped:
+		if _, err := s.inventory.Commit(ctx, items); err != nil {
+			fmt.Printf("Failed to commit stock for order %d: %v\n", id, err)
+		}
+	case to == StatusCancelled, to == StatusRefunded && o.Status == StatusPaid:
+		s.release(ctx, items)
+	}
+	if to == StatusCancelled && o.CouponCode != "" && s.promotions != nil {
+		if err := s.promotions.CancelOrderRedemption(ctx, id); err != nil {
+			fmt.Printf("Failed to cancel coupon redemption for order %d: %v\n", id, err)
+		}
+	}
+	return updated, nil
+}
+
+// release frees reserved stock. Failures are logged rather than returned
+// since the order change they follow has already happened.
+func (s *Service) release(ctx context.Context, items []inventory.Item) {
+	if _, err := s.inventory.Release(ctx, items); err != nil {
+		fmt.Printf("Failed to release stock %v: %v\n", items, err)
+	}
+}
+
+func inventoryItems(o Order) []inventory.Item {
+	items := make([]inventory.Item, len(o.Lines))
+	for i, l := range o.Lines {
+		items[i] = inventory.Item{ProductID: l.ProductID, Quantity: l.Quantity}
+	}
+	return items
+}
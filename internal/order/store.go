@@ -0,0 +1,91 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	Create(ctx context.Context, o Order) (Order, error)
+	GetByID(ctx context.Context, id int) (Order, error)
+	ListByUser(ctx context.Context, userID, limit, offset int) ([]Order, int, error)
+	// UpdateStatus moves an order from one status to another, failing with
+	// ErrStatusChanged if it's no longer in from.
+	UpdateStatus(ctx context.Context, id int, from, to Status) (Order, error)
+}
+
+type MemoryStore struct {
+	orders []Order
+	nextID int
+	mu     sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nextID: 1}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, o Order) (Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	o.ID = s.nextID
+	s.nextID++
+	o.Lines = append([]Line{}, o.Lines...)
+	o.CreatedAt = now
+	o.UpdatedAt = now
+	s.orders = append(s.orders, o)
+	return o, nil
+}
+
+func (s *MemoryStore) GetByID(ctx context.Context, id int) (Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, o := range s.orders {
+		if o.ID == id {
+			return o, nil
+		}
+	}
+	return Order{}, fmt.Errorf("order %d not found", id)
+}
+
+func (s *MemoryStore) ListByUser(ctx context.Context, userID, limit, offset int) ([]Order, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Newest first.
+	var matched []Order
+	for i := len(s.orders) - 1; i >= 0; i-- {
+		if s.orders[i].UserID == userID {
+			matched = append(matched, s.orders[i])
+		}
+	}
+
+	total := len(matched)
+	if offset > total {
+		offset = total
+	}
+	end := min(offset+limit, total)
+	return append([]Order{}, matched[offset:end]...), total, nil
+}
+
+func (s *MemoryStore) UpdateStatus(ctx context.Context, id int, from, to Status) (Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, o := range s.orders {
+		if o.ID != id {
+			continue
+		}
+		if o.Status != from {
+			return Order{}, fmt.Errorf("%w: order %d is %s", ErrStatusChanged, id, o.Status)
+		}
+		s.orders[i].Status = to
+		s.orders[i].UpdatedAt = time.Now().UTC()
+		return s.orders[i], nil
+	}
+	return Order{}, fmt.Errorf("order %d not found", id)
+}
@@ -0,0 +1,133 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"lukekorsman.com/store/internal/money"
+)
+
+type AttemptStatus string
+
+const (
+	AttemptPending  AttemptStatus = "pending"
+	AttemptCaptured AttemptStatus = "captured"
+	AttemptDeclined AttemptStatus = "declined"
+	// AttemptFailed means the provider couldn't be reached; the attempt can
+	// be retried with the same idempotency key.
+	AttemptFailed   AttemptStatus = "failed"
+	AttemptVoided   AttemptStatus = "voided"
+	AttemptRefunded AttemptStatus = "refunded"
+)
+
+var (
+	ErrAttemptNotFound = errors.New("payment attempt not found")
+	ErrDuplicateKey    = errors.New("idempotency key already used")
+)
+
+// Attempt records one try at paying for an order, keyed by the client's
+// idempotency key.
+type Attempt struct {
+	ID             int           `json:"id"`
+	OrderID        int           `json:"order_id"`
+	IdempotencyKey string        `json:"idempotency_key"`
+	TransactionID  string        `json:"transaction_id"`
+	Amount         money.Money   `json:"amount"`
+	Status         AttemptStatus `json:"status"`
+	Error          string        `json:"error,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+type Store interface {
+	// Create fails with ErrDuplicateKey if the idempotency key is taken.
+	Create(ctx context.Context, a Attempt) (Attempt, error)
+	GetByKey(ctx context.Context, key string) (Attempt, error)
+	GetByTransaction(ctx context.Context, transactionID string) (Attempt, error)
+	ListByOrder(ctx context.Context, orderID int) ([]Attempt, error)
+	// Update saves the attempt's transaction ID, status and error.
+	Update(ctx context.Context, a Attempt) (Attempt, error)
+}
+
+type MemoryStore struct {
+	attempts []Attempt
+	nextID   int
+	mu       sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nextID: 1}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, a Attempt) (Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.attempts {
+		if existing.IdempotencyKey == a.IdempotencyKey {
+			return Attempt{}, fmt.Errorf("%w: %s", ErrDuplicateKey, a.IdempotencyKey)
+		}
+	}
+
+	now := time.Now().UTC()
+	a.ID = s.nextID
+	s.nextID++
+	a.CreatedAt = now
+	a.UpdatedAt = now
+	s.attempts = append(s.attempts, a)
+	return a, nil
+}
+
+func (s *MemoryStore) GetByKey(ctx context.Context, key string) (Attempt, error) {
+	return s.find(func(a Attempt) bool { return a.IdempotencyKey == key })
+}
+
+func (s *MemoryStore) GetByTransaction(ctx context.Context, transactionID string) (Attempt, error) {
+	return s.find(func(a Attempt) bool { return a.TransactionID != "" && a.TransactionID == transactionID })
+}
+
+func (s *MemoryStore) find(match func(Attempt) bool) (Attempt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, a := range s.attempts {
+		if match(a) {
+			return a, nil
+		}
+	}
+	return Attempt{}, ErrAttemptNotFound
+}
+
+func (s *MemoryStore) ListByOrder(ctx context.Context, orderID int) ([]Attempt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	attempts := []Attempt{}
+	for _, a := range s.attempts {
+		if a.OrderID == orderID {
+			attempts = append(attempts, a)
+		}
+	}
+	return attempts, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, a Attempt) (Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.attempts {
+		if existing.ID != a.ID {
+			continue
+		}
+		existing.TransactionID = a.TransactionID
+		existing.Status = a.Status
+		existing.Error = a.Error
+		existing.UpdatedAt = time.Now().UTC()
+		s.attempts[i] = existing
+		return existing, nil
+	}
+	return Attempt{}, fmt.Errorf("%w: %d", ErrAttemptNotFound, a.ID)
+}
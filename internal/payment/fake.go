@@ -0,0 +1,221 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"lukekorsman.com/store/internal/money"
+)
+
+// Payment tokens understood by FakeGateway.
+const (
+	TokenSuccess = "tok_success"
+	TokenDecline = "tok_decline"
+	TokenTimeout = "tok_timeout"
+	// TokenPending is accepted without an answer; the gateway later captures
+	// it and reports the result by webhook.
+	TokenPending = "tok_pending"
+	// TokenPendingDecline is like TokenPending but is declined when settled.
+	TokenPendingDecline = "tok_pending_decline"
+)
+
+// FakeGateway is a local Provider for development and tests. The outcome of
+// a payment is chosen by its token, and asynchronous payments are reported
+// to webhookURL with a signed callback.
+type FakeGateway struct {
+	secret       []byte
+	webhookURL   string
+	settleDelay  time.Duration
+	client       *http.Client
+	transactions map[string]*fakeTransaction
+	byKey        map[string]string
+	nextID       int
+	mu           sync.Mutex
+}
+
+type fakeTransaction struct {
+	Transaction
+	token    string
+	refunded money.Money
+}
+
+// NewFakeGateway returns a gateway that signs webhooks with secret. With a
+// positive settleDelay pending payments settle on their own after that
+// long; otherwise they wait for Settle.
+func NewFakeGateway(secret []byte, webhookURL string, settleDelay time.Duration) *FakeGateway {
+	return &FakeGateway{
+		secret:       secret,
+		webhookURL:   webhookURL,
+		settleDelay:  settleDelay,
+		client:       &http.Client{Timeout: 5 * time.Second},
+		transactions: make(map[string]*fakeTransaction),
+		byKey:        make(map[string]string),
+		nextID:       1,
+	}
+}
+
+func (g *FakeGateway) Authorize(ctx context.Context, req AuthorizeRequest) (Transaction, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if id, ok := g.byKey[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		return g.result(g.transactions[id])
+	}
+
+	if req.Token == TokenTimeout {
+		return Transaction{}, ErrTimeout
+	}
+
+	tx := &fakeTransaction{
+		Transaction: Transaction{
+			ID:     fmt.Sprintf("fake_tx_%d", g.nextID),
+			Status: TransactionAuthorized,
+			Amount: req.Amount,
+		},
+		token:    req.Token,
+		refunded: money.New(0, req.Amount.Currency),
+	}
+	g.nextID++
+
+	switch req.Token {
+	case TokenDecline:
+		tx.Status = TransactionDeclined
+		tx.DeclineReason = "card_declined"
+	case TokenPending, TokenPendingDecline:
+		tx.Status = TransactionPending
+		if g.settleDelay > 0 {
+			id := tx.ID
+			time.AfterFunc(g.settleDelay, func() {
+				if err := g.Settle(context.Background(), id); err != nil {
+					fmt.Printf("Fake gateway failed to settle %s: %v\n", id, err)
+				}
+			})
+		}
+	}
+
+	g.transactions[tx.ID] = tx
+	if req.IdempotencyKey != "" {
+		g.byKey[req.IdempotencyKey] = tx.ID
+	}
+	return g.result(tx)
+}
+
+func (g *FakeGateway) Capture(ctx context.Context, transactionID string) (Transaction, error) {
+	return g.move(transactionID, TransactionAuthorized, TransactionCaptured)
+}
+
+func (g *FakeGateway) Void(ctx context.Context, transactionID string) (Transaction, error) {
+	return g.move(transactionID, TransactionAuthorized, TransactionVoided)
+}
+
+func (g *FakeGateway) Refund(ctx context.Context, transactionID string, amount money.Money) (Transaction, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	tx, ok := g.transactions[transactionID]
+	if !ok {
+		return Transaction{}, fmt.Errorf("transaction %s not found", transactionID)
+	}
+	if tx.Status != TransactionCaptured && tx.Status != TransactionRefunded {
+		return Transaction{}, fmt.Errorf("transaction %s is %s, not captured", transactionID, tx.Status)
+	}
+	if amount.Currency != tx.Amount.Currency {
+		return Transaction{}, fmt.Errorf("refund in %s for a transaction in %s", amount.Currency, tx.Amount.Currency)
+	}
+	remaining := tx.Amount.Sub(tx.refunded)
+	if !amount.IsPositive() || amount.Amount > remaining.Amount {
+		return Transaction{}, fmt.Errorf("refund of %s exceeds the remaining %s", amount, remaining)
+	}
+
+	tx.refunded = tx.refunded.Add(amount)
+	tx.Status = TransactionRefunded
+	return tx.Transaction, nil
+}
+
+// Settle completes a pending payment, capturing or declining it according
+// to its token, and sends the matching webhook.
+func (g *FakeGateway) Settle(ctx context.Context, transactionID string) error {
+	g.mu.Lock()
+	tx, ok := g.transactions[transactionID]
+	if !ok || tx.Status != TransactionPending {
+		g.mu.Unlock()
+		return fmt.Errorf("transaction %s is not pending", transactionID)
+	}
+
+	event := Event{
+		ID:            fmt.Sprintf("evt_%s", tx.ID),
+		Type:          EventCaptured,
+		TransactionID: tx.ID,
+		Amount:        tx.Amount,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if tx.token == TokenPendingDecline {
+		tx.Status = TransactionDeclined
+		tx.DeclineReason = "insufficient_funds"
+		event.Type = EventDeclined
+		event.Reason = tx.DeclineReason
+	} else {
+		tx.Status = TransactionCaptured
+	}
+	g.mu.Unlock()
+
+	return g.sendWebhook(ctx, event)
+}
+
+func (g *FakeGateway) move(transactionID string, from, to TransactionStatus) (Transaction, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	tx, ok := g.transactions[transactionID]
+	if !ok {
+		return Transaction{}, fmt.Errorf("transaction %s not found", transactionID)
+	}
+	if tx.Status != from {
+		return Transaction{}, fmt.Errorf("transaction %s is %s, not %s", transactionID, tx.Status, from)
+	}
+
+	tx.Status = to
+	return tx.Transaction, nil
+}
+
+// result reports a declined transaction as ErrDeclined as well.
+func (g *FakeGateway) result(tx *fakeTransaction) (Transaction, error) {
+	if tx.Status == TransactionDeclined {
+		return tx.Transaction, fmt.Errorf("%w: %s", ErrDeclined, tx.DeclineReason)
+	}
+	return tx.Transaction, nil
+}
+
+func (g *FakeGateway) sendWebhook(ctx context.Context, event Event) error {
+	if g.webhookURL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(g.secret, body, time.Now()))
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package payment
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lukekorsman.com/store/internal/api"
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/order"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// IdempotencyKeyHeader must be sent with every payment request. Retrying
+// with the same key never charges twice.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxWebhookBytes caps the size of a webhook body.
+const maxWebhookBytes = 1 << 20
+
+type Handler struct {
+	payments      *Service
+	orders        *order.Service
+	webhookSecret []byte
+}
+
+func NewHandler(payments *Service, orders *order.Service, webhookSecret []byte) *Handler {
+	return &Handler{
+		payments:      payments,
+		orders:        orders,
+		webhookSecret: webhookSecret,
+	}
+}
+
+type PayRequest struct {
+	Token string `json:"token"`
+}
+
+// Pay charges the order total. The response code follows the attempt: 201
+// captured, 202 awaiting the provider, 402 declined, 504 provider timeout.
+func (h *Handler) Pay(w http.ResponseWriter, r *http.Request) {
+	o, ok := h.ownOrder(w, r)
+	if !ok {
+		return
+	}
+
+	var req PayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	key := r.Header.Get(IdempotencyKeyHeader)
+	var errs []api.ValidationError
+	if key == "" || len(key) > 255 {
+		errs = append(errs, api.ValidationError{
+			Field:   IdempotencyKeyHeader,
+			Message: "Idempotency-Key header is required and must be 255 characters or less",
+		})
+	}
+	if req.Token == "" {
+		errs = append(errs, api.ValidationError{
+			Field:   "token",
+			Message: "token is required",
+		})
+	}
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	// Once an attempt exists its status decides the response, whatever the
+	// error.
+	a, err := h.payments.Pay(r.Context(), o, key, req.Token)
+	if a.ID == 0 {
+		if errors.Is(err, ErrKeyMismatch) || errors.Is(err, ErrNotPayable) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	switch a.Status {
+	case AttemptCaptured:
+		status = http.StatusCreated
+	case AttemptPending:
+		status = http.StatusAccepted
+	case AttemptDeclined:
+		status = http.StatusPaymentRequired
+	case AttemptFailed:
+		status = http.StatusBadGateway
+		if errors.Is(err, ErrTimeout) {
+			status = http.StatusGatewayTimeout
+		}
+	case AttemptVoided, AttemptRefunded:
+		status = http.StatusConflict
+	}
+	writeJSON(w, status, a)
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	o, ok := h.ownOrder(w, r)
+	if !ok {
+		return
+	}
+
+	attempts, err := h.payments.Attempts(r.Context(), o.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, attempts)
+}
+
+// Refund returns an order's captured payment. It's mounted under /admin.
+func (h *Handler) Refund(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	a, err := h.payments.Refund(r.Context(), id)
+	if errors.Is(err, order.ErrInvalidTransition) || errors.Is(err, ErrAttemptNotFound) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, a)
+}
+
+// Webhook receives provider callbacks. Requests without a valid signature
+// are rejected before the body is parsed.
+func (h *Handler) Webhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBytes))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	if err := VerifySignature(h.webhookSecret, body, r.Header.Get(SignatureHeader), time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var e Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	err = h.payments.HandleWebhook(r.Context(), e)
+	if errors.Is(err, ErrAttemptNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownOrder loads the order in the URL if it belongs to the current user.
+func (h *Handler) ownOrder(w http.ResponseWriter, r *http.Request) (order.Order, bool) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return order.Order{}, false
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return order.Order{}, false
+	}
+
+	o, err := h.orders.Get(r.Context(), id)
+	if err != nil || o.UserID != user.ID {
+		http.Error(w, "order not found", http.StatusNotFound)
+		return order.Order{}, false
+	}
+
+	return o, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
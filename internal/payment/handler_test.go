@@ -0,0 +1,272 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/cart"
+	"lukekorsman.com/store/internal/money/moneytest"
+	"lukekorsman.com/store/internal/order"
+	"lukekorsman.com/store/internal/order/ordertest"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var testSecret = []byte("whsec_test")
+
+type fixture struct {
+	orders  *order.Service
+	gateway *FakeGateway
+	router  http.Handler
+}
+
+// newFixture wires the payment handler to a fake gateway whose webhooks are
+// delivered back to the same router over HTTP.
+func newFixture(t *testing.T) fixture {
+	t.Helper()
+	ctx := context.Background()
+	d := ordertest.New()
+	orders := order.NewService(order.NewMemoryStore(), d.Carts, d.Inventory, d.Promotions)
+
+	r := chi.NewRouter()
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	gateway := NewFakeGateway(testSecret, server.URL+"/payments/webhook", 0)
+	h := NewHandler(NewService(gateway, NewMemoryStore(), orders), orders, testSecret)
+	r.Post("/orders/{id}/payments", h.Pay)
+	r.Get("/orders/{id}/payments", h.List)
+	r.Post("/admin/orders/{id}/refund", h.Refund)
+	r.Post("/payments/webhook", h.Webhook)
+
+	// Two pending orders for user 1.
+	for range 2 {
+		d.Carts.Add(ctx, cart.Owner{UserID: 1}, 1, 2)
+		if _, err := orders.Checkout(ctx, 1); err != nil {
+			t.Fatalf("checkout failed: %v", err)
+		}
+	}
+
+	return fixture{orders: orders, gateway: gateway, router: r}
+}
+
+func (f fixture) pay(t *testing.T, orderID int, key, token string) (*httptest.ResponseRecorder, Attempt) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/orders/%d/payments", orderID),
+		strings.NewReader(fmt.Sprintf(`{"token":%q}`, token)))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.User{ID: 1}))
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+
+	var a Attempt
+	json.Unmarshal(rec.Body.Bytes(), &a)
+	return rec, a
+}
+
+func (f fixture) orderStatus(t *testing.T, id int) order.Status {
+	t.Helper()
+	o, err := f.orders.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to load order %d: %v", id, err)
+	}
+	return o.Status
+}
+
+func TestPay(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       string
+		wantStatus  int
+		wantAttempt AttemptStatus
+		wantOrder   order.Status
+	}{
+		{
+			name:        "success",
+			token:       TokenSuccess,
+			wantStatus:  http.StatusCreated,
+			wantAttempt: AttemptCaptured,
+			wantOrder:   order.StatusPaid,
+		},
+		{
+			name:        "decline",
+			token:       TokenDecline,
+			wantStatus:  http.StatusPaymentRequired,
+			wantAttempt: AttemptDeclined,
+			wantOrder:   order.StatusPending,
+		},
+		{
+			name:        "timeout",
+			token:       TokenTimeout,
+			wantStatus:  http.StatusGatewayTimeout,
+			wantAttempt: AttemptFailed,
+			wantOrder:   order.StatusPending,
+		},
+		{
+			name:        "pending",
+			token:       TokenPending,
+			wantStatus:  http.StatusAccepted,
+			wantAttempt: AttemptPending,
+			wantOrder:   order.StatusPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+
+			rec, a := f.pay(t, 1, "key-1", tt.token)
+			if rec.Code != tt.wantStatus || a.Status != tt.wantAttempt {
+				t.Fatalf("expected %d/%s, got %d: %s", tt.wantStatus, tt.wantAttempt, rec.Code, rec.Body.String())
+			}
+			if a.Amount != moneytest.USD(998) || a.OrderID != 1 {
+				t.Fatalf("unexpected attempt %+v", a)
+			}
+			if got := f.orderStatus(t, 1); got != tt.wantOrder {
+				t.Fatalf("expected order %s, got %s", tt.wantOrder, got)
+			}
+		})
+	}
+}
+
+func TestPay_Idempotency(t *testing.T) {
+	f := newFixture(t)
+
+	_, first := f.pay(t, 1, "key-1", TokenSuccess)
+	rec, replay := f.pay(t, 1, "key-1", TokenSuccess)
+	if rec.Code != http.StatusCreated || replay.ID != first.ID || replay.TransactionID != first.TransactionID {
+		t.Fatalf("expected the first attempt to be replayed, got %d %+v", rec.Code, replay)
+	}
+
+	if rec, _ := f.pay(t, 2, "key-1", TokenSuccess); rec.Code != http.StatusConflict {
+		t.Fatalf("expected a key reused for another order to conflict, got %d", rec.Code)
+	}
+
+	// A timed out attempt is retried under the same key.
+	_, failed := f.pay(t, 2, "key-2", TokenTimeout)
+	rec, retried := f.pay(t, 2, "key-2", TokenSuccess)
+	if rec.Code != http.StatusCreated || retried.ID != failed.ID {
+		t.Fatalf("expected the failed attempt to be retried, got %d %+v", rec.Code, retried)
+	}
+
+	if rec, _ := f.pay(t, 2, "key-3", TokenSuccess); rec.Code != http.StatusConflict {
+		t.Fatalf("expected paying a paid order to conflict, got %d", rec.Code)
+	}
+}
+
+func TestPay_WebhookSettlement(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       string
+		wantAttempt AttemptStatus
+		wantOrder   order.Status
+	}{
+		{
+			name:        "captured",
+			token:       TokenPending,
+			wantAttempt: AttemptCaptured,
+			wantOrder:   order.StatusPaid,
+		},
+		{
+			name:        "declined",
+			token:       TokenPendingDecline,
+			wantAttempt: AttemptDeclined,
+			wantOrder:   order.StatusPending,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			_, a := f.pay(t, 1, "key-1", tt.token)
+
+			if err := f.gateway.Settle(context.Background(), a.TransactionID); err != nil {
+				t.Fatalf("settle failed: %v", err)
+			}
+
+			_, a = f.pay(t, 1, "key-1", tt.token)
+			if a.Status != tt.wantAttempt {
+				t.Fatalf("expected attempt %s, got %+v", tt.wantAttempt, a)
+			}
+			if got := f.orderStatus(t, 1); got != tt.wantOrder {
+				t.Fatalf("expected order %s, got %s", tt.wantOrder, got)
+			}
+		})
+	}
+}
+
+func TestWebhook_RejectsBadSignature(t *testing.T) {
+	f := newFixture(t)
+	_, a := f.pay(t, 1, "key-1", TokenPending)
+
+	body, _ := json.Marshal(Event{ID: "evt_forged", Type: EventCaptured, TransactionID: a.TransactionID})
+	req := httptest.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewReader(body))
+	req.Header.Set(SignatureHeader, Sign([]byte("wrong"), body, time.Now()))
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+	if got := f.orderStatus(t, 1); got != order.StatusPending {
+		t.Fatalf("expected the order to stay pending, got %s", got)
+	}
+}
+
+func TestRefund(t *testing.T) {
+	f := newFixture(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/orders/1/refund", nil)
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected refunding an unpaid order to conflict, got %d", rec.Code)
+	}
+
+	f.pay(t, 1, "key-1", TokenSuccess)
+
+	rec = httptest.NewRecorder()
+	f.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/orders/1/refund", nil))
+
+	var a Attempt
+	json.NewDecoder(rec.Body).Decode(&a)
+	if rec.Code != http.StatusOK || a.Status != AttemptRefunded {
+		t.Fatalf("expected a refunded attempt, got %d %+v", rec.Code, a)
+	}
+	if got := f.orderStatus(t, 1); got != order.StatusRefunded {
+		t.Fatalf("expected order refunded, got %s", got)
+	}
+}
+
+func TestWebhook_RefundsShippedOrder(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t)
+	_, a := f.pay(t, 1, "key-1", TokenSuccess)
+	if _, err := f.orders.Transition(ctx, 1, order.StatusShipped); err != nil {
+		t.Fatalf("ship failed: %v", err)
+	}
+
+	body, _ := json.Marshal(Event{ID: "evt_refund", Type: EventRefunded, TransactionID: a.TransactionID})
+	req := httptest.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewReader(body))
+	req.Header.Set(SignatureHeader, Sign(testSecret, body, time.Now()))
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+	if got := f.orderStatus(t, 1); got != order.StatusRefunded {
+		t.Fatalf("expected order refunded, got %s", got)
+	}
+	if _, a = f.pay(t, 1, "key-1", TokenSuccess); a.Status != AttemptRefunded {
+		t.Fatalf("expected a refunded attempt, got %+v", a)
+	}
+}
@@ -0,0 +1,115 @@
+package payment
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/product"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlDuplicateEntry is the MySQL error number for a unique key violation.
+const mysqlDuplicateEntry = 1062
+
+const attemptColumns = "id, order_id, idempotency_key, COALESCE(transaction_id, ''), amount, status, error, created_at, updated_at"
+
+type MySQLStore struct {
+	db *sql.DB
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAttempt(row rowScanner) (Attempt, error) {
+	var a Attempt
+	var amount string
+	err := row.Scan(&a.ID, &a.OrderID, &a.IdempotencyKey, &a.TransactionID, &amount, &a.Status, &a.Error, &a.CreatedAt, &a.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Attempt{}, ErrAttemptNotFound
+	}
+	if err != nil {
+		return Attempt{}, err
+	}
+	a.Amount, err = money.Parse(amount, product.BaseCurrency)
+	return a, err
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func (s *MySQLStore) Create(ctx context.Context, a Attempt) (Attempt, error) {
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO payment_attempts (order_id, idempotency_key, transaction_id, amount, status, error) VALUES (?, ?, ?, ?, ?, ?)",
+		a.OrderID, a.IdempotencyKey, nullString(a.TransactionID), a.Amount.Decimal(), a.Status, a.Error,
+	)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
+		return Attempt{}, fmt.Errorf("%w: %s", ErrDuplicateKey, a.IdempotencyKey)
+	}
+	if err != nil {
+		return Attempt{}, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Attempt{}, err
+	}
+	return s.get(ctx, "id = ?", id)
+}
+
+func (s *MySQLStore) GetByKey(ctx context.Context, key string) (Attempt, error) {
+	return s.get(ctx, "idempotency_key = ?", key)
+}
+
+func (s *MySQLStore) GetByTransaction(ctx context.Context, transactionID string) (Attempt, error) {
+	return s.get(ctx, "transaction_id = ?", transactionID)
+}
+
+func (s *MySQLStore) get(ctx context.Context, where string, arg any) (Attempt, error) {
+	return scanAttempt(s.db.QueryRowContext(ctx,
+		"SELECT "+attemptColumns+" FROM payment_attempts WHERE "+where, arg))
+}
+
+func (s *MySQLStore) ListByOrder(ctx context.Context, orderID int) ([]Attempt, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+attemptColumns+" FROM payment_attempts WHERE order_id = ? ORDER BY id", orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []Attempt{}
+	for rows.Next() {
+		a, err := scanAttempt(rows)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+
+	return attempts, rows.Err()
+}
+
+func (s *MySQLStore) Update(ctx context.Context, a Attempt) (Attempt, error) {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE payment_attempts SET transaction_id = ?, status = ?, error = ? WHERE id = ?",
+		nullString(a.TransactionID), a.Status, a.Error, a.ID,
+	)
+	if err != nil {
+		return Attempt{}, err
+	}
+	return s.get(ctx, "id = ?", a.ID)
+}
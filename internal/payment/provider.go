@@ -0,0 +1,54 @@
+package payment
+
+import (
+	"context"
+	"errors"
+
+	"lukekorsman.com/store/internal/money"
+)
+
+var (
+	// ErrDeclined is returned when the provider refuses a payment.
+	ErrDeclined = errors.New("payment declined")
+	// ErrTimeout is returned when the provider didn't answer in time. No
+	// payment was taken and the attempt may be retried.
+	ErrTimeout = errors.New("payment provider timed out")
+)
+
+// TransactionStatus is the provider's view of a transaction.
+type TransactionStatus string
+
+const (
+	TransactionPending    TransactionStatus = "pending"
+	TransactionAuthorized TransactionStatus = "authorized"
+	TransactionCaptured   TransactionStatus = "captured"
+	TransactionRefunded   TransactionStatus = "refunded"
+	TransactionVoided     TransactionStatus = "voided"
+	TransactionDeclined   TransactionStatus = "declined"
+)
+
+type AuthorizeRequest struct {
+	// IdempotencyKey is passed through so the provider can dedupe retries.
+	IdempotencyKey string
+	Amount         money.Money
+	// Token identifies the payment method, e.g. a tokenised card.
+	Token string
+}
+
+type Transaction struct {
+	ID     string
+	Status TransactionStatus
+	Amount money.Money
+	// DeclineReason is set when Status is TransactionDeclined.
+	DeclineReason string
+}
+
+// Provider is a payment gateway. Authorize holds funds, Capture collects
+// them, Void releases an uncaptured authorization and Refund returns
+// captured funds.
+type Provider interface {
+	Authorize(ctx context.Context, req AuthorizeRequest) (Transaction, error)
+	Capture(ctx context.Context, transactionID string) (Transaction, error)
+	Void(ctx context.Context, transactionID string) (Transaction, error)
+	Refund(ctx context.Context, transactionID string, amount money.Money) (Transaction, error)
+}
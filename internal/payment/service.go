@@ -0,0 +1,208 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"lukekorsman.com/store/internal/order"
+)
+
+var (
+	// ErrKeyMismatch is returned when an idempotency key is reused for a
+	// different order.
+	ErrKeyMismatch = errors.New("idempotency key was used for a different order")
+	// ErrNotPayable is returned for orders that aren't awaiting payment.
+	ErrNotPayable = errors.New("order is not awaiting payment")
+)
+
+// Service takes payments for orders through a Provider and moves the order
+// along as payments succeed or are refunded.
+type Service struct {
+	provider Provider
+	attempts Store
+	orders   *order.Service
+}
+
+func NewService(provider Provider, attempts Store, orders *order.Service) *Service {
+	return &Service{
+		provider: provider,
+		attempts: attempts,
+		orders:   orders,
+	}
+}
+
+func (s *Service) Attempts(ctx context.Context, orderID int) ([]Attempt, error) {
+	return s.attempts.ListByOrder(ctx, orderID)
+}
+
+// Pay authorizes and captures the order total. Repeating a call with the
+// same idempotency key returns the first attempt instead of charging again,
+// unless that attempt failed to reach the provider.
+func (s *Service) Pay(ctx context.Context, o order.Order, key, token string) (Attempt, error) {
+	a, err := s.attempts.GetByKey(ctx, key)
+	switch {
+	case err == nil:
+		if a.OrderID != o.ID {
+			return Attempt{}, ErrKeyMismatch
+		}
+		if a.Status != AttemptFailed {
+			return a, nil
+		}
+		if o.Status != order.StatusPending {
+			return Attempt{}, fmt.Errorf("%w: order %d is %s", ErrNotPayable, o.ID, o.Status)
+		}
+	case errors.Is(err, ErrAttemptNotFound):
+		if o.Status != order.StatusPending {
+			return Attempt{}, fmt.Errorf("%w: order %d is %s", ErrNotPayable, o.ID, o.Status)
+		}
+		a, err = s.attempts.Create(ctx, Attempt{
+			OrderID:        o.ID,
+			IdempotencyKey: key,
+			Amount:         o.Total,
+			Status:         AttemptPending,
+		})
+		if errors.Is(err, ErrDuplicateKey) {
+			// A concurrent request with the same key won the race.
+			return s.Pay(ctx, o, key, token)
+		}
+		if err != nil {
+			return Attempt{}, err
+		}
+	default:
+		return Attempt{}, err
+	}
+
+	tx, err := s.provider.Authorize(ctx, AuthorizeRequest{
+		IdempotencyKey: key,
+		Amount:         a.Amount,
+		Token:          token,
+	})
+	a.TransactionID = tx.ID
+	switch {
+	case errors.Is(err, ErrDeclined):
+		return s.finish(ctx, a, AttemptDeclined, err)
+	case err != nil:
+		return s.finish(ctx, a, AttemptFailed, err)
+	case tx.Status == TransactionPending:
+		return s.finish(ctx, a, AttemptPending, nil)
+	}
+
+	// The order may have been cancelled while we were authorizing.
+	if current, err := s.orders.Get(ctx, o.ID); err != nil || current.Status != order.StatusPending {
+		if _, err := s.provider.Void(ctx, tx.ID); err != nil {
+			fmt.Printf("Failed to void transaction %s: %v\n", tx.ID, err)
+		}
+		return s.finish(ctx, a, AttemptVoided, ErrNotPayable)
+	}
+
+	if _, err := s.provider.Capture(ctx, tx.ID); err != nil {
+		if _, err := s.provider.Void(ctx, tx.ID); err != nil {
+			fmt.Printf("Failed to void transaction %s: %v\n", tx.ID, err)
+		}
+		return s.finish(ctx, a, AttemptFailed, err)
+	}
+
+	return s.captured(ctx, a)
+}
+
+// captured records a successful capture and marks the order paid, refunding
+// the payment if the order can no longer be paid.
+func (s *Service) captured(ctx context.Context, a Attempt) (Attempt, error) {
+	a, err := s.finish(ctx, a, AttemptCaptured, nil)
+	if err != nil {
+		return a, err
+	}
+
+	if _, err := s.orders.Transition(ctx, a.OrderID, order.StatusPaid); err != nil {
+		if _, refundErr := s.provider.Refund(ctx, a.TransactionID, a.Amount); refundErr != nil {
+			fmt.Printf("Failed to refund transaction %s: %v\n", a.TransactionID, refundErr)
+		}
+		return s.finish(ctx, a, AttemptRefunded, fmt.Errorf("%w: %v", ErrNotPayable, err))
+	}
+	return a, nil
+}
+
+// finish saves the attempt's outcome and passes cause back to the caller.
+func (s *Service) finish(ctx context.Context, a Attempt, status AttemptStatus, cause error) (Attempt, error) {
+	a.Status = status
+	a.Error = ""
+	if cause != nil {
+		a.Error = cause.Error()
+	}
+
+	saved, err := s.attempts.Update(ctx, a)
+	if err != nil {
+		return a, err
+	}
+	return saved, cause
+}
+
+// Refund returns the captured payment for an order and marks it refunded.
+func (s *Service) Refund(ctx context.Context, orderID int) (Attempt, error) {
+	o, err := s.orders.Get(ctx, orderID)
+	if err != nil {
+		return Attempt{}, err
+	}
+	if !o.Status.CanTransition(order.StatusRefunded) {
+		return Attempt{}, fmt.Errorf("%w: %s to %s", order.ErrInvalidTransition, o.Status, order.StatusRefunded)
+	}
+
+	attempts, err := s.attempts.ListByOrder(ctx, orderID)
+	if err != nil {
+		return Attempt{}, err
+	}
+	for _, a := range attempts {
+		if a.Status != AttemptCaptured {
+			continue
+		}
+
+		if _, err := s.provider.Refund(ctx, a.TransactionID, a.Amount); err != nil {
+			return Attempt{}, err
+		}
+		return s.refunded(ctx, a)
+	}
+
+	return Attempt{}, fmt.Errorf("%w: no captured payment for order %d", ErrAttemptNotFound, orderID)
+}
+
+// refunded records a refund the provider has made. The order moves first so
+// that if it can't, the attempt stays captured and a retry or redelivered
+// webhook finds it again; an order that is already refunded is left alone.
+func (s *Service) refunded(ctx context.Context, a Attempt) (Attempt, error) {
+	o, err := s.orders.Get(ctx, a.OrderID)
+	if err != nil {
+		return Attempt{}, err
+	}
+	if o.Status != order.StatusRefunded {
+		if _, err := s.orders.Transition(ctx, a.OrderID, order.StatusRefunded); err != nil {
+			return Attempt{}, err
+		}
+	}
+	return s.finish(ctx, a, AttemptRefunded, nil)
+}
+
+// HandleWebhook applies a verified provider callback. Events for attempts
+// that have already moved on are ignored, so redelivery is harmless.
+func (s *Service) HandleWebhook(ctx context.Context, e Event) error {
+	a, err := s.attempts.GetByTransaction(ctx, e.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case e.Type == EventCaptured && a.Status == AttemptPending:
+		// An order that can't be paid any more has been refunded by captured.
+		if _, err = s.captured(ctx, a); errors.Is(err, ErrNotPayable) {
+			err = nil
+		}
+	case e.Type == EventDeclined && a.Status == AttemptPending:
+		_, err = s.finish(ctx, a, AttemptDeclined, fmt.Errorf("%w: %s", ErrDeclined, e.Reason))
+		if errors.Is(err, ErrDeclined) {
+			err = nil
+		}
+	case e.Type == EventRefunded && a.Status == AttemptCaptured:
+		_, err = s.refunded(ctx, a)
+	}
+	return err
+}
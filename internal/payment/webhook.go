@@ -0,0 +1,85 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"lukekorsman.com/store/internal/money"
+)
+
+// SignatureHeader carries a webhook's signature as "t=<unix>,v1=<hex>",
+// where v1 is the HMAC-SHA256 of "<unix>.<body>".
+const SignatureHeader = "X-Webhook-Signature"
+
+// SignatureTolerance is how old a signed webhook may be before it's
+// rejected as a possible replay.
+const SignatureTolerance = 5 * time.Minute
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+type EventType string
+
+const (
+	EventCaptured EventType = "payment.captured"
+	EventDeclined EventType = "payment.declined"
+	EventRefunded EventType = "payment.refunded"
+)
+
+// Event is a provider's webhook callback about a transaction.
+type Event struct {
+	ID            string      `json:"id"`
+	Type          EventType   `json:"type"`
+	TransactionID string      `json:"transaction_id"`
+	Amount        money.Money `json:"amount"`
+	Reason        string      `json:"reason,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+// Sign returns the signature header value for body sent at t.
+func Sign(secret []byte, body []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, signature(secret, ts, body))
+}
+
+// VerifySignature checks header against body and rejects signatures older
+// than SignatureTolerance.
+func VerifySignature(secret []byte, body []byte, header string, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	expected := signature(secret, ts, body)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func signature(secret []byte, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,59 @@
+package payment
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"id":"evt_1"}`)
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+		ok     bool
+	}{
+		{
+			name:   "valid",
+			header: Sign(secret, body, now),
+			body:   body,
+			ok:     true,
+		},
+		{
+			name:   "tampered body",
+			header: Sign(secret, body, now),
+			body:   []byte(`{"id":"evt_2"}`),
+		},
+		{
+			name:   "wrong secret",
+			header: Sign([]byte("other"), body, now),
+			body:   body,
+		},
+		{
+			name:   "too old",
+			header: Sign(secret, body, now.Add(-SignatureTolerance-time.Second)),
+			body:   body,
+		},
+		{
+			name:   "malformed",
+			header: "v1=abc",
+			body:   body,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(secret, tt.body, tt.header, now)
+			if tt.ok && err != nil {
+				t.Fatalf("expected a valid signature, got %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("expected ErrInvalidSignature, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package product
+
+// MaxBatchOperations bounds a batch, which is applied in a single
+// transaction.
+const MaxBatchOperations = 100
+
+type BatchOp string
+
+const (
+	BatchCreate BatchOp = "create"
+	BatchUpdate BatchOp = "update"
+	BatchDelete BatchOp = "delete"
+)
+
+type BatchMode string
+
+const (
+	// BatchAtomic saves nothing if any operation fails.
+	BatchAtomic BatchMode = "atomic"
+	// BatchBestEffort saves every operation that succeeds.
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+// BatchOperation is one step of a batch. Update and delete name the product
+// by ID and the version they expect it to be at, like If-Match does for a
+// single product.
+type BatchOperation struct {
+	Op      BatchOp  `json:"op"`
+	ID      int      `json:"id,omitempty"`
+	Version int      `json:"version,omitempty"`
+	Product *Product `json:"product,omitempty"`
+}
+
+// BatchOutcome is what a store did with one operation. Err is
+// ErrDuplicateSKU, ErrVersionMismatch or a not found error; anything else
+// fails the whole batch. Product is the saved product, or only carries the
+// ID for a delete.
+type BatchOutcome struct {
+	Product Product
+	// Before is the product as the operation found it, for updates and
+	// deletes.
+	Before *Product
+	Err    error
+}
+
+type BatchRequest struct {
+	Mode       BatchMode        `json:"mode"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchResult reports one operation with the status it would have had as a
+// single request. In a failed atomic batch the operations that would have
+// succeeded get 424 Failed Dependency.
+type BatchResult struct {
+	Index   int               `json:"index"`
+	Op      BatchOp           `json:"op"`
+	Status  int               `json:"status"`
+	ID      int               `json:"id,omitempty"`
+	Product *Product          `json:"product,omitempty"`
+	Errors  []ValidationError `json:"errors,omitempty"`
+}
+
+type BatchResponse struct {
+	Mode      BatchMode     `json:"mode"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
+}
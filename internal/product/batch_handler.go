@@ -0,0 +1,216 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"lukekorsman.com/store/internal/metrics"
+)
+
+// Batch creates, updates and deletes several products in one request. In
+// atomic mode (the default) nothing is saved unless every operation
+// succeeds; in best_effort mode each one that succeeds is saved. Either way
+// the response reports every operation.
+func (h *Handler) Batch(w http.ResponseWriter, r *http.Request) {
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if errs := validateBatchRequest(&req); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+	atomic := req.Mode == BatchAtomic
+
+	results := make([]BatchResult, len(req.Operations))
+	var valid []BatchOperation
+	var positions []int
+	for n := range req.Operations {
+		op := &req.Operations[n]
+		results[n] = BatchResult{Index: n, Op: op.Op, ID: op.ID}
+		if errs := h.prepareBatchOperation(r.Context(), op); len(errs) > 0 {
+			results[n].Status = http.StatusBadRequest
+			results[n].Errors = errs
+			continue
+		}
+		valid = append(valid, *op)
+		positions = append(positions, n)
+	}
+
+	var outcomes []BatchOutcome
+	if len(valid) > 0 && (!atomic || len(valid) == len(req.Operations)) {
+		stop := metrics.TimeDatabaseQuery("batch_products")
+		var err error
+		outcomes, err = h.store.Batch(r.Context(), valid, atomic)
+		stop()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := BatchResponse{Mode: req.Mode, Results: results}
+	for i, o := range outcomes {
+		result := &results[positions[i]]
+		if o.Err != nil {
+			result.Status, result.Errors = batchError(o.Err)
+			continue
+		}
+		result.ID = o.Product.ID
+		switch result.Op {
+		case BatchCreate:
+			result.Status = http.StatusCreated
+		case BatchUpdate:
+			result.Status = http.StatusOK
+		case BatchDelete:
+			result.Status = http.StatusNoContent
+		}
+		if result.Op != BatchDelete {
+			p := o.Product
+			result.Product = &p
+		}
+	}
+
+	for _, result := range results {
+		if result.Status == 0 || result.Status >= http.StatusBadRequest {
+			resp.Failed++
+		}
+	}
+	if atomic && resp.Failed > 0 {
+		// Everything else was held back by the failures.
+		for i := range results {
+			if results[i].Status < http.StatusBadRequest {
+				results[i].Status = http.StatusFailedDependency
+				results[i].Product = nil
+			}
+		}
+		resp.Failed = len(results)
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+	resp.Succeeded = len(results) - resp.Failed
+
+	if err := h.recordBatch(r.Context(), req.Operations, outcomes, positions, results); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func validateBatchRequest(req *BatchRequest) []ValidationError {
+	var errs []ValidationError
+	switch req.Mode {
+	case "":
+		req.Mode = BatchAtomic
+	case BatchAtomic, BatchBestEffort:
+	default:
+		errs = append(errs, ValidationError{Field: "mode", Message: "mode must be atomic or best_effort"})
+	}
+
+	switch {
+	case len(req.Operations) == 0:
+		errs = append(errs, ValidationError{Field: "operations", Message: "at least one operation is required"})
+	case len(req.Operations) > MaxBatchOperations:
+		errs = append(errs, ValidationError{Field: "operations", Message: fmt.Sprintf("a batch can have at most %d operations", MaxBatchOperations)})
+	}
+	return errs
+}
+
+// prepareBatchOperation normalizes the product of a create or update and
+// returns the operation's validation errors.
+func (h *Handler) prepareBatchOperation(ctx context.Context, op *BatchOperation) []ValidationError {
+	var errs []ValidationError
+	switch op.Op {
+	case BatchCreate:
+		if op.Product == nil {
+			return []ValidationError{{Field: "product", Message: "product is required"}}
+		}
+		return h.prepare(ctx, op.Product)
+	case BatchUpdate, BatchDelete:
+		if op.ID <= 0 {
+			errs = append(errs, ValidationError{Field: "id", Message: "id is required"})
+		}
+		if op.Version <= 0 {
+			errs = append(errs, ValidationError{Field: "version", Message: "version is required; send the product's current version"})
+		}
+		if op.Op == BatchDelete {
+			return errs
+		}
+		if op.Product == nil {
+			return append(errs, ValidationError{Field: "product", Message: "product is required"})
+		}
+		return append(errs, h.prepare(ctx, op.Product)...)
+	}
+	return []ValidationError{{Field: "op", Message: "op must be create, update or delete"}}
+}
+
+// batchError gives the status and errors a single request would have had
+// for an operation the store rejected.
+func batchError(err error) (int, []ValidationError) {
+	switch {
+	case errors.Is(err, ErrDuplicateSKU):
+		return http.StatusConflict, []ValidationError{{Field: "sku", Message: err.Error()}}
+	case errors.Is(err, ErrVersionMismatch):
+		return http.StatusPreconditionFailed, []ValidationError{{Field: "version", Message: err.Error()}}
+	}
+	return http.StatusNotFound, []ValidationError{{Field: "id", Message: err.Error()}}
+}
+
+// recordBatch updates metrics, price history and change hooks for the saved
+// operations, attaches images to the saved products and invalidates the
+// cache once for the whole batch.
+func (h *Handler) recordBatch(ctx context.Context, ops []BatchOperation, outcomes []BatchOutcome, positions []int, results []BatchResult) error {
+	var ids []int
+	var saved []*Product
+	created, deleted := 0, 0
+	for i, o := range outcomes {
+		if o.Err != nil {
+			continue
+		}
+		ids = append(ids, o.Product.ID)
+		after := o.Product
+		switch ops[positions[i]].Op {
+		case BatchCreate:
+			created++
+			h.recordPrice(ctx, PriceChange{ProductID: o.Product.ID, NewPrice: o.Product.Price})
+			h.changed(ctx, ProductChange{Action: ProductCreated, ProductID: o.Product.ID, After: &after})
+		case BatchUpdate:
+			if o.Before.Price != o.Product.Price {
+				h.recordPrice(ctx, PriceChange{ProductID: o.Product.ID, OldPrice: &o.Before.Price, NewPrice: o.Product.Price})
+			}
+			h.changed(ctx, ProductChange{Action: ProductUpdated, ProductID: o.Product.ID, Before: o.Before, After: &after})
+		case BatchDelete:
+			deleted++
+			h.changed(ctx, ProductChange{Action: ProductDeleted, ProductID: o.Product.ID, Before: o.Before})
+		}
+		if p := results[positions[i]].Product; p != nil {
+			saved = append(saved, p)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	metrics.ProductsCreated.Add(float64(created))
+	metrics.ProductsDeleted.Add(float64(deleted))
+	h.invalidate(ctx, ids...)
+
+	products := make([]Product, len(saved))
+	for i, p := range saved {
+		products[i] = *p
+	}
+	if err := h.attachImages(ctx, products); err != nil {
+		return err
+	}
+	for i, p := range saved {
+		*p = products[i]
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"lukekorsman.com/store/internal/money/moneytest"
+)
+
+func TestBatchProducts(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantStatuses []int
+		check        func(t *testing.T, store *MemoryStore, history *MemoryPriceHistoryStore)
+	}{
+		{
+			name: "atomic batch saves everything",
+			body: `{"operations":[
+				{"op":"create","product":{"sku":"CHAIR-1","name":"Chair","price":{"amount":4900},"tags":["Office"]}},
+				{"op":"update","id":1,"version":1,"product":{"sku":"LAMP-1","name":"Lamp","price":{"amount":2200}}},
+				{"op":"delete","id":2,"version":1}]}`,
+			wantStatus:   http.StatusOK,
+			wantStatuses: []int{http.StatusCreated, http.StatusOK, http.StatusNoContent},
+			check: func(t *testing.T, store *MemoryStore, history *MemoryPriceHistoryStore) {
+				result, _ := store.List(context.Background(), ListOptions{})
+				if result.Total != 2 || result.Products[1].SKU != "CHAIR-1" || result.Products[1].Tags[0] != "office" {
+					t.Fatalf("unexpected catalog %+v", result.Products)
+				}
+				if lamp, _ := store.GetByID(context.Background(), 1); lamp.Price != moneytest.USD(2200) || lamp.Version != 2 {
+					t.Fatalf("expected the lamp to be updated, got %+v", lamp)
+				}
+				if changes, _, _ := history.History(context.Background(), 1, 10, 0); len(changes) != 1 || *changes[0].OldPrice != moneytest.USD(2500) {
+					t.Fatalf("expected the price change to be recorded, got %+v", changes)
+				}
+			},
+		},
+		{
+			name: "atomic batch saves nothing if an operation fails",
+			body: `{"mode":"atomic","operations":[
+				{"op":"create","product":{"sku":"CHAIR-1","name":"Chair","price":{"amount":4900}}},
+				{"op":"delete","id":1,"version":1},
+				{"op":"update","id":2,"version":7,"product":{"name":"Desk","price":{"amount":100}}}]}`,
+			wantStatus:   http.StatusBadRequest,
+			wantStatuses: []int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusPreconditionFailed},
+			check: func(t *testing.T, store *MemoryStore, history *MemoryPriceHistoryStore) {
+				result, _ := store.List(context.Background(), ListOptions{})
+				if result.Total != 2 || result.Products[0].Version != 1 {
+					t.Fatalf("expected the catalog to be untouched, got %+v", result.Products)
+				}
+				if hits, _ := store.Search(context.Background(), SearchOptions{Query: "chair"}); hits.Total != 0 {
+					t.Fatalf("expected the rolled back product to be out of the search index, got %+v", hits.Hits)
+				}
+				if hits, _ := store.Search(context.Background(), SearchOptions{Query: "lamp"}); hits.Total != 1 {
+					t.Fatalf("expected the lamp to stay in the search index, got %+v", hits.Hits)
+				}
+			},
+		},
+		{
+			name: "atomic batch is checked before it reaches the store",
+			body: `{"operations":[
+				{"op":"create","product":{"name":"Chair","price":{"amount":4900}}},
+				{"op":"create","product":{"price":{"amount":100}}},
+				{"op":"update","id":1,"product":{"name":"Lamp","price":{"amount":100}}},
+				{"op":"move","id":1}]}`,
+			wantStatus:   http.StatusBadRequest,
+			wantStatuses: []int{http.StatusFailedDependency, http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest},
+		},
+		{
+			name: "best effort saves what it can",
+			body: `{"mode":"best_effort","operations":[
+				{"op":"create","product":{"sku":"CHAIR-1","name":"Chair","price":{"amount":4900}}},
+				{"op":"create","product":{"sku":"lamp-1","name":"Lamp","price":{"amount":100}}},
+				{"op":"delete","id":1,"version":1},
+				{"op":"update","id":1,"version":2,"product":{"name":"Lamp","price":{"amount":100}}},
+				{"op":"create","product":{"name":""}},
+				{"op":"update","id":2,"version":1,"product":{"sku":"CHAIR-1","name":"Desk","price":{"amount":100}}}]}`,
+			wantStatus:   http.StatusOK,
+			wantStatuses: []int{http.StatusCreated, http.StatusConflict, http.StatusNoContent, http.StatusNotFound, http.StatusBadRequest, http.StatusConflict},
+			check: func(t *testing.T, store *MemoryStore, history *MemoryPriceHistoryStore) {
+				result, _ := store.List(context.Background(), ListOptions{})
+				if result.Total != 2 || result.Products[0].SKU != "DESK-1" || result.Products[1].SKU != "CHAIR-1" {
+					t.Fatalf("unexpected catalog %+v", result.Products)
+				}
+			},
+		},
+		{
+			name:       "unknown mode",
+			body:       `{"mode":"sometimes","operations":[{"op":"delete","id":1,"version":1}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "no operations",
+			body:       `{"operations":[]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too many operations",
+			body:       `{"operations":[` + strings.Repeat(`{"op":"delete","id":1,"version":1},`, MaxBatchOperations) + `{"op":"delete","id":1,"version":1}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+			history := NewMemoryPriceHistoryStore()
+			store.Create(ctx, Product{SKU: "LAMP-1", Name: "Lamp", Price: moneytest.USD(2500)})
+			store.Create(ctx, Product{SKU: "DESK-1", Name: "Desk", Price: moneytest.USD(19900)})
+
+			h := NewHandler(store, NewMemoryCategoryStore(), nil, history, nil, nil)
+			r := chi.NewRouter()
+			r.Post("/products/batch", h.Batch)
+
+			req := httptest.NewRequest(http.MethodPost, "/products/batch", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatuses != nil {
+				var resp BatchResponse
+				json.NewDecoder(rec.Body).Decode(&resp)
+				got := make([]int, len(resp.Results))
+				succeeded := 0
+				for i, result := range resp.Results {
+					got[i] = result.Status
+					if result.Status < http.StatusBadRequest {
+						succeeded++
+					}
+				}
+				if fmt.Sprint(got) != fmt.Sprint(tt.wantStatuses) {
+					t.Fatalf("expected statuses %v, got %v", tt.wantStatuses, got)
+				}
+				if resp.Succeeded != succeeded || resp.Failed != len(got)-succeeded {
+					t.Fatalf("expected %d succeeded, got %d succeeded and %d failed", succeeded, resp.Succeeded, resp.Failed)
+				}
+			}
+			if tt.check != nil {
+				tt.check(t, store, history)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package product
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Category struct {
+	ID        int       `json:"id"`
+	ParentID  *int      `json:"parent_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CategoryNode is a category with its subcategories, used for browsing the
+// tree.
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+// BuildCategoryTree nests a flat category list under its roots. Siblings are
+// ordered by name.
+func BuildCategoryTree(categories []Category) []CategoryNode {
+	children := make(map[int][]Category)
+	var roots []Category
+	for _, c := range categories {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	var build func([]Category) []CategoryNode
+	build = func(level []Category) []CategoryNode {
+		sort.Slice(level, func(i, j int) bool {
+			return strings.ToLower(level[i].Name) < strings.ToLower(level[j].Name)
+		})
+
+		nodes := make([]CategoryNode, len(level))
+		for i, c := range level {
+			nodes[i] = CategoryNode{Category: c, Children: build(children[c.ID])}
+		}
+		return nodes
+	}
+
+	return build(roots)
+}
+
+// DescendantIDs returns id followed by the IDs of every category below it.
+func DescendantIDs(categories []Category, id int) []int {
+	children := make(map[int][]int)
+	for _, c := range categories {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+		}
+	}
+
+	ids := []int{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
+
+func ValidateCategory(c Category) []ValidationError {
+	var errs []ValidationError
+
+	if strings.TrimSpace(c.Name) == "" {
+		errs = append(errs, ValidationError{
+			Field:   "name",
+			Message: "name is required",
+		})
+	}
+
+	if len(c.Name) > 100 {
+		errs = append(errs, ValidationError{
+			Field:   "name",
+			Message: "name must be less than 100 characters",
+		})
+	}
+
+	return errs
+}
+
+// validateCategoryRef checks that the category referenced by field exists.
+func validateCategoryRef(categories []Category, field string, id *int) []ValidationError {
+	if id == nil {
+		return nil
+	}
+
+	for _, c := range categories {
+		if c.ID == *id {
+			return nil
+		}
+	}
+	return []ValidationError{{
+		Field:   field,
+		Message: fmt.Sprintf("category %d does not exist", *id),
+	}}
+}
+
+// validateCategoryParent checks that parentID exists and that making it the
+// parent of id would not create a cycle.
+func validateCategoryParent(categories []Category, id int, parentID *int) []ValidationError {
+	if parentID == nil {
+		return nil
+	}
+
+	if errs := validateCategoryRef(categories, "parent_id", parentID); len(errs) > 0 {
+		return errs
+	}
+
+	if id != 0 {
+		for _, descendant := range DescendantIDs(categories, id) {
+			if descendant == *parentID {
+				return []ValidationError{{
+					Field:   "parent_id",
+					Message: "a category cannot be moved under itself or its subcategories",
+				}}
+			}
+		}
+	}
+
+	return nil
+}
+
+// NormalizeTags trims, lowercases, de-duplicates and sorts tags.
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
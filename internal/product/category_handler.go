@@ -0,0 +1,178 @@
+package product
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type CategoryHandler struct {
+	categories CategoryStore
+	products   Store
+}
+
+func NewCategoryHandler(categories CategoryStore, products Store) *CategoryHandler {
+	return &CategoryHandler{
+		categories: categories,
+		products:   products,
+	}
+}
+
+// Tree returns every category nested under its parent.
+func (h *CategoryHandler) Tree(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.categories.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tree := BuildCategoryTree(categories)
+	if tree == nil {
+		tree = []CategoryNode{}
+	}
+	writeJSON(w, http.StatusOK, tree)
+}
+
+func (h *CategoryHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.categories.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, category)
+}
+
+func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var c Category
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if errs := h.validate(r, 0, c); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	created, err := h.categories.Create(r.Context(), c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var c Category
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.categories.GetByID(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if errs := h.validate(r, id, c); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	updated, err := h.categories.Update(r.Context(), id, c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// Delete removes an empty category. Categories that still hold products or
+// subcategories are rejected so nothing is orphaned.
+func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.categories.GetByID(ctx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	categories, err := h.categories.List(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var errs []ValidationError
+	if subcategories := len(DescendantIDs(categories, id)) - 1; subcategories > 0 {
+		errs = append(errs, ValidationError{
+			Field:   "category",
+			Message: fmt.Sprintf("category %d still has %d subcategories", id, subcategories),
+		})
+	}
+
+	// Archived products count too: they still point at the category and
+	// can be restored.
+	products, err := h.products.List(ctx, ListOptions{CategoryIDs: []int{id}, IncludeDeleted: true, Limit: 1})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if products.Total > 0 {
+		errs = append(errs, ValidationError{
+			Field:   "category",
+			Message: fmt.Sprintf("category %d still has %d products", id, products.Total),
+		})
+	}
+
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	if err := h.categories.Delete(ctx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CategoryHandler) validate(r *http.Request, id int, c Category) []ValidationError {
+	errs := ValidateCategory(c)
+
+	categories, err := h.categories.List(r.Context())
+	if err != nil {
+		return append(errs, ValidationError{Field: "parent_id", Message: err.Error()})
+	}
+	return append(errs, validateCategoryParent(categories, id, c.ParentID)...)
+}
@@ -0,0 +1,205 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"lukekorsman.com/store/internal/money/moneytest"
+)
+
+// seedCategories builds Electronics > Computers > Laptops plus a separate
+// Books root and returns the categories by name.
+func seedCategories(t *testing.T, store *MemoryCategoryStore) map[string]*Category {
+	t.Helper()
+	ctx := context.Background()
+
+	electronics, _ := store.Create(ctx, Category{Name: "Electronics"})
+	computers, _ := store.Create(ctx, Category{Name: "Computers", ParentID: &electronics.ID})
+	laptops, _ := store.Create(ctx, Category{Name: "Laptops", ParentID: &computers.ID})
+	books, _ := store.Create(ctx, Category{Name: "Books"})
+
+	return map[string]*Category{
+		"Electronics": &electronics,
+		"Computers":   &computers,
+		"Laptops":     &laptops,
+		"Books":       &books,
+	}
+}
+
+func TestCategoryTree(t *testing.T) {
+	categories := NewMemoryCategoryStore()
+	seedCategories(t, categories)
+
+	handler := NewCategoryHandler(categories, NewMemoryStore())
+	rec := httptest.NewRecorder()
+	handler.Tree(rec, httptest.NewRequest(http.MethodGet, "/categories", nil))
+
+	var tree []CategoryNode
+	if err := json.NewDecoder(rec.Body).Decode(&tree); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+
+	if len(tree) != 2 || tree[0].Name != "Books" || tree[1].Name != "Electronics" {
+		t.Fatalf("expected roots Books and Electronics, got %+v", tree)
+	}
+	if got := tree[1].Children[0].Children[0].Name; got != "Laptops" {
+		t.Fatalf("expected Electronics > Computers > Laptops, got %s", got)
+	}
+}
+
+func TestListProducts_CategoryAndTag(t *testing.T) {
+	ctx := context.Background()
+	categories := NewMemoryCategoryStore()
+	c := seedCategories(t, categories)
+
+	store := NewMemoryStore()
+	store.Create(ctx, Product{Name: "Desktop", Price: moneytest.USD(90000), CategoryID: &c["Computers"].ID, Tags: []string{"sale"}})
+	store.Create(ctx, Product{Name: "Ultrabook", Price: moneytest.USD(150000), CategoryID: &c["Laptops"].ID})
+	store.Create(ctx, Product{Name: "Novel", Price: moneytest.USD(1500), CategoryID: &c["Books"].ID, Tags: []string{"sale"}})
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantNames  []string
+	}{
+		{
+			name:       "category includes descendants",
+			query:      fmt.Sprintf("?category=%d", c["Electronics"].ID),
+			wantStatus: http.StatusOK,
+			wantNames:  []string{"Desktop", "Ultrabook"},
+		},
+		{
+			name:       "leaf category",
+			query:      fmt.Sprintf("?category=%d", c["Laptops"].ID),
+			wantStatus: http.StatusOK,
+			wantNames:  []string{"Ultrabook"},
+		},
+		{
+			name:       "tag",
+			query:      "?tag=SALE",
+			wantStatus: http.StatusOK,
+			wantNames:  []string{"Desktop", "Novel"},
+		},
+		{
+			name:       "category and tag",
+			query:      fmt.Sprintf("?category=%d&tag=sale", c["Electronics"].ID),
+			wantStatus: http.StatusOK,
+			wantNames:  []string{"Desktop"},
+		},
+		{
+			name:       "unknown category",
+			query:      "?category=99",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(store, categories, nil, nil, nil, nil)
+			rec := httptest.NewRecorder()
+			handler.List(rec, httptest.NewRequest(http.MethodGet, "/products"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var products []Product
+			if err := json.NewDecoder(rec.Body).Decode(&products); err != nil {
+				t.Fatalf("failed to decode JSON: %v", err)
+			}
+			var names []string
+			for _, p := range products {
+				names = append(names, p.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Fatalf("expected %v, got %v", tt.wantNames, names)
+			}
+		})
+	}
+}
+
+func TestDeleteCategory(t *testing.T) {
+	tests := []struct {
+		name       string
+		category   string
+		archived   bool
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "has subcategories",
+			category:   "Computers",
+			wantStatus: http.StatusBadRequest,
+			wantError:  "still has 1 subcategories",
+		},
+		{
+			name:       "has products",
+			category:   "Laptops",
+			wantStatus: http.StatusBadRequest,
+			wantError:  "still has 1 products",
+		},
+		{
+			name:       "has archived products",
+			category:   "Laptops",
+			archived:   true,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "still has 1 products",
+		},
+		{
+			name:       "empty",
+			category:   "Books",
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			categories := NewMemoryCategoryStore()
+			c := seedCategories(t, categories)
+
+			store := NewMemoryStore()
+			ultrabook, _ := store.Create(context.Background(), Product{Name: "Ultrabook", Price: moneytest.USD(150000), CategoryID: &c["Laptops"].ID})
+			if tt.archived {
+				store.Delete(context.Background(), ultrabook.ID, 0)
+			}
+
+			r := chi.NewRouter()
+			r.Delete("/categories/{id}", NewCategoryHandler(categories, store).Delete)
+
+			req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/categories/%d", c[tt.category].ID), nil)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantError != "" && !strings.Contains(rec.Body.String(), tt.wantError) {
+				t.Fatalf("expected error containing %q, got %s", tt.wantError, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestValidateCategoryParent_RejectsCycles(t *testing.T) {
+	categories := NewMemoryCategoryStore()
+	c := seedCategories(t, categories)
+	all, _ := categories.List(context.Background())
+
+	if errs := validateCategoryParent(all, c["Electronics"].ID, &c["Laptops"].ID); len(errs) != 1 {
+		t.Fatalf("expected moving a category under its descendant to fail, got %v", errs)
+	}
+	if errs := validateCategoryParent(all, c["Laptops"].ID, &c["Books"].ID); len(errs) != 0 {
+		t.Fatalf("expected a valid move, got %v", errs)
+	}
+}
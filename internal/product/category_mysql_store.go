@@ -0,0 +1,103 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type MySQLCategoryStore struct {
+	db *sql.DB
+}
+
+func NewMySQLCategoryStore(db *sql.DB) *MySQLCategoryStore {
+	return &MySQLCategoryStore{db: db}
+}
+
+const categoryColumns = "id, parent_id, name, created_at, updated_at"
+
+func scanCategory(row rowScanner) (Category, error) {
+	var c Category
+	var parentID sql.NullInt64
+	if err := row.Scan(&c.ID, &parentID, &c.Name, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return Category{}, err
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
+	return c, nil
+}
+
+func (s *MySQLCategoryStore) List(ctx context.Context) ([]Category, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+categoryColumns+" FROM categories ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, rows.Err()
+}
+
+func (s *MySQLCategoryStore) GetByID(ctx context.Context, id int) (Category, error) {
+	c, err := scanCategory(s.db.QueryRowContext(ctx,
+		"SELECT "+categoryColumns+" FROM categories WHERE id = ?", id))
+
+	if err == sql.ErrNoRows {
+		return Category{}, fmt.Errorf("category %d not found", id)
+	}
+	if err != nil {
+		return Category{}, err
+	}
+
+	return c, nil
+}
+
+func (s *MySQLCategoryStore) Create(ctx context.Context, c Category) (Category, error) {
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO categories (parent_id, name) VALUES (?, ?)",
+		c.ParentID, c.Name,
+	)
+	if err != nil {
+		return Category{}, err
+	}
+
+	id, _ := result.LastInsertId()
+	return s.GetByID(ctx, int(id))
+}
+
+func (s *MySQLCategoryStore) Update(ctx context.Context, id int, c Category) (Category, error) {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE categories SET parent_id = ?, name = ? WHERE id = ?",
+		c.ParentID, c.Name, id,
+	)
+	if err != nil {
+		return Category{}, err
+	}
+
+	return s.GetByID(ctx, id)
+}
+
+func (s *MySQLCategoryStore) Delete(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM categories WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("category %d not found", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type CategoryStore interface {
+	List(ctx context.Context) ([]Category, error)
+	GetByID(ctx context.Context, id int) (Category, error)
+	Create(ctx context.Context, c Category) (Category, error)
+	Update(ctx context.Context, id int, c Category) (Category, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type MemoryCategoryStore struct {
+	categories map[int]Category
+	nextID     int
+	mu         sync.RWMutex
+}
+
+func NewMemoryCategoryStore() *MemoryCategoryStore {
+	return &MemoryCategoryStore{
+		categories: make(map[int]Category),
+		nextID:     1,
+	}
+}
+
+func (s *MemoryCategoryStore) List(ctx context.Context) ([]Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	categories := make([]Category, 0, len(s.categories))
+	for _, c := range s.categories {
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+func (s *MemoryCategoryStore) GetByID(ctx context.Context, id int) (Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.categories[id]
+	if !ok {
+		return Category{}, fmt.Errorf("category %d not found", id)
+	}
+	return c, nil
+}
+
+func (s *MemoryCategoryStore) Create(ctx context.Context, c Category) (Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	c.ID = s.nextID
+	c.CreatedAt = now
+	c.UpdatedAt = now
+	s.categories[c.ID] = c
+	s.nextID++
+
+	return c, nil
+}
+
+func (s *MemoryCategoryStore) Update(ctx context.Context, id int, c Category) (Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.categories[id]
+	if !ok {
+		return Category{}, fmt.Errorf("category %d not found", id)
+	}
+
+	c.ID = id
+	c.CreatedAt = existing.CreatedAt
+	c.UpdatedAt = time.Now().UTC()
+	s.categories[id] = c
+
+	return c, nil
+}
+
+func (s *MemoryCategoryStore) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.categories[id]; !ok {
+		return fmt.Errorf("category %d not found", id)
+	}
+	delete(s.categories, id)
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/jobs"
+	"lukekorsman.com/store/internal/media"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ExportJobType is the job type of exports queued with QueueExport.
+const ExportJobType = "products.export"
+
+type exportJob struct {
+	Format ImportFormat `json:"format"`
+}
+
+// ExportResult is the result of an export job. The file is downloaded from
+// URL once the job has succeeded.
+type ExportResult struct {
+	File     string `json:"file"`
+	URL      string `json:"url"`
+	Products int    `json:"products"`
+}
+
+// QueueExport queues a job that writes the catalog to a file, answering 202
+// with the job and a Location to poll. It takes the same format parameter
+// as Export.
+func (h *Handler) QueueExport(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+	if h.jobs == nil {
+		http.Error(w, "export jobs are not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	var createdBy *int
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		createdBy = &user.ID
+	}
+	j, err := h.jobs.Enqueue(r.Context(), ExportJobType, exportJob{Format: format}, createdBy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%d", j.ID))
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// DownloadExport serves the file written by an export job. Like the job
+// itself, it is only visible to the user who queued it.
+func (h *Handler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.Atoi(chi.URLParam(r, "jobID"))
+	if err != nil {
+		http.Error(w, "invalid job ID", http.StatusBadRequest)
+		return
+	}
+	if h.jobs == nil {
+		http.Error(w, "export not found", http.StatusNotFound)
+		return
+	}
+
+	j, err := h.jobs.Get(ctx, id)
+	if err == nil && !ownsJob(r, j) {
+		err = jobs.ErrJobNotFound
+	}
+	if errors.Is(err, jobs.ErrJobNotFound) || (err == nil && j.Type != ExportJobType) {
+		http.Error(w, "export not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if j.Status != jobs.StatusSucceeded {
+		http.Error(w, fmt.Sprintf("export job is %s", j.Status), http.StatusConflict)
+		return
+	}
+
+	var job exportJob
+	var result ExportResult
+	if err := json.Unmarshal(j.Payload, &job); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := json.Unmarshal(j.Result, &result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	f, err := h.jobFiles.Get(ctx, result.File)
+	if errors.Is(err, media.ErrBlobNotFound) {
+		http.Error(w, "export file not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", exportContentType(job.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, job.Format))
+	io.Copy(w, f)
+}
+
+func ownsJob(r *http.Request, j jobs.Job) bool {
+	user, ok := auth.UserFromContext(r.Context())
+	return j.CreatedBy == nil || (ok && *j.CreatedBy == user.ID)
+}
+
+// runExportJob writes the catalog to a temporary file, then saves it in the
+// handler's jobFiles store.
+func (h *Handler) runExportJob(ctx context.Context, j jobs.Job, progress func(int)) (any, error) {
+	var job exportJob
+	if err := json.Unmarshal(j.Payload, &job); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+
+	tmp, err := os.CreateTemp("", "products-export-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	exported := 0
+	// Saving the file is the last tenth of the work.
+	err = h.exportProducts(ctx, tmp, job.Format, func(n, total int) {
+		exported = n
+		progress(n * 90 / max(total, 1))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	key := media.NewKey("exports", "."+string(job.Format))
+	if err := h.jobFiles.Put(ctx, key, tmp, size, exportContentType(job.Format)); err != nil {
+		return nil, err
+	}
+
+	return ExportResult{
+		File:     key,
+		URL:      fmt.Sprintf("/products/export/%d", j.ID),
+		Products: exported,
+	}, nil
+}
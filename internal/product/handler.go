@@ -1,57 +1,147 @@
 package product
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"lukekorsman.com/store/internal/api"
 	"lukekorsman.com/store/internal/auth"
 	"lukekorsman.com/store/internal/cache"
+	"lukekorsman.com/store/internal/jobs"
+	"lukekorsman.com/store/internal/media"
 	"lukekorsman.com/store/internal/metrics"
+	"lukekorsman.com/store/internal/money"
 )
 
+const listCacheKeyPrefix = "products:list"
+
 type Handler struct {
 	store Store
+	categories CategoryStore
+	// images supplies each product's images; nil leaves them out.
+	images ImageStore
+	// history records price changes; nil skips recording.
+	history PriceHistoryStore
 	cache *cache.RedisCache
+	// rates converts prices for ?currency=; nil allows only BaseCurrency.
+	rates *money.Rates
+	// jobs runs imports sent with ?async=true and queued exports; nil turns
+	// them off.
+	jobs *jobs.Runner
+	jobFiles media.BlobStore
+	changeHooks []func(ctx context.Context, c ProductChange)
 }
 
-func NewHandler(store Store, redisCache *cache.RedisCache) *Handler {
+func NewHandler(store Store, categories CategoryStore, images ImageStore, history PriceHistoryStore, redisCache *cache.RedisCache, rates *money.Rates) *Handler {
 	return &Handler{
 		store: store,
+		categories: categories,
+		images: images,
+		history: history,
 		cache: redisCache,
+		rates: rates,
+	}
+}
+
+// OnChange registers fn to run after every product the handler creates,
+// updates, deletes or restores, including through imports and batches.
+func (h *Handler) OnChange(fn func(ctx context.Context, c ProductChange)) {
+	h.changeHooks = append(h.changeHooks, fn)
+}
+
+func (h *Handler) changed(ctx context.Context, c ProductChange) {
+	for _, fn := range h.changeHooks {
+		fn(ctx, c)
 	}
 }
 
+// List is the public catalog listing. Archived products are only listed by
+// AdminList.
 func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, false)
+}
+
+// AdminList is List with include_deleted allowed. It's mounted under /admin
+// behind the products:read permission.
+func (h *Handler) AdminList(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, true)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, allowDeleted bool) {
 	ctx := r.Context()
-	cacheKey := "products:list"
 
-	var products []Product
+	opts, errs := ParseListOptions(r.URL.Query())
+	currency, currencyErrs := h.parseCurrency(r)
+	errs = append(errs, currencyErrs...)
+	if opts.IncludeDeleted && !allowDeleted {
+		errs = append(errs, ValidationError{
+			Field:   "include_deleted",
+			Message: "include_deleted is only available on GET /admin/products",
+		})
+	}
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	if len(opts.CategoryIDs) > 0 {
+		categories, err := h.categories.List(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if errs := validateCategoryRef(categories, "category", &opts.CategoryIDs[0]); len(errs) > 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"errors": errs,
+			})
+			return
+		}
+		opts.CategoryIDs = DescendantIDs(categories, opts.CategoryIDs[0])
+	}
+
+	cacheKey := listCacheKeyPrefix + ":" + opts.Query().Encode()
+
+	var result ListResult
 
 	if h.cache != nil {
-		err := h.cache.Get(ctx, cacheKey, &products)
+		err := h.cache.Get(ctx, cacheKey, &result)
 
 		if err == nil {
 			w.Header().Set("X-Cache", "HIT")
-			writeJSON(w, http.StatusOK, products)
+			h.writeListResult(w, r, opts, currency, result)
 			return
 		}
-		metrics.CacheMisses.WithLabelValues(cacheKey).Inc()
+		metrics.CacheMisses.WithLabelValues(listCacheKeyPrefix).Inc()
 	}
 
+	// One row more than the page shows whether there's another page.
+	fetch := opts
+	fetch.Limit++
+
 	defer metrics.TimeDatabaseQuery("list_products")()
-	products, err := h.store.List(ctx)
+	result, err := h.store.List(ctx, fetch)
 	if err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
     }
+	if err := h.attachImages(ctx, result.Products); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	if h.cache != nil {
-		if err := h.cache.Set(ctx, cacheKey, products, 5*time.Minute); err != nil {
+		if err := h.cache.Set(ctx, cacheKey, result, 5*time.Minute); err != nil {
 			fmt.Printf("Failed to cache products: %v\n", err)
 		}
 		w.Header().Set("X-Cache", "MISS")
@@ -59,7 +149,93 @@ func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Cache", "DISABLED")
 	}
 
-    writeJSON(w, http.StatusOK, products)
+	h.writeListResult(w, r, opts, currency, result)
+}
+
+// writeListResult writes one page of products priced in currency along with
+// the total count and next/prev links. result holds up to one product more
+// than the page, on the side the listing is moving towards.
+func (h *Handler) writeListResult(w http.ResponseWriter, r *http.Request, opts ListOptions, currency money.Currency, result ListResult) {
+	products := result.Products
+	if products == nil {
+		products = []Product{}
+	}
+	backwards := opts.After != nil && opts.After.Before
+	more := len(products) > opts.Limit
+	if more && backwards {
+		products = products[1:]
+	} else if more {
+		products = products[:opts.Limit]
+	}
+
+	// Links point at the first and last products, so they're built before
+	// prices are converted.
+	var links []string
+	if len(products) > 0 {
+		if backwards || more {
+			next := Cursor{Key: products[len(products)-1]}
+			links = append(links, api.PageLink(r, opts.Limit, encodeListCursor(opts.Sort, next), "next"))
+		}
+		if (opts.After == nil && opts.Offset > 0) || (opts.After != nil && (!backwards || more)) {
+			prev := Cursor{Key: products[0], Before: true}
+			links = append(links, api.PageLink(r, opts.Limit, encodeListCursor(opts.Sort, prev), "prev"))
+		}
+	}
+
+	for i := range products {
+		if err := h.localize(&products[i], currency); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(result.Total))
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	writeJSON(w, http.StatusOK, products)
+}
+
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	opts, errs := ParseSearchOptions(r.URL.Query())
+	currency, currencyErrs := h.parseCurrency(r)
+	errs = append(errs, currencyErrs...)
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	defer metrics.TimeDatabaseQuery("search_products")()
+	result, err := h.store.Search(r.Context(), opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	hits := result.Hits
+	if hits == nil {
+		hits = []SearchHit{}
+	}
+	products := make([]Product, len(hits))
+	for i := range hits {
+		products[i] = hits[i].Product
+	}
+	if err := h.attachImages(r.Context(), products); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range hits {
+		hits[i].Product = products[i]
+		if err := h.localize(&hits[i].Product, currency); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	api.SetPageHeaders(w, r, opts.Limit, opts.Offset, len(result.Hits), result.Total)
+	writeJSON(w, http.StatusOK, hits)
 }
 
 func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
@@ -75,7 +251,9 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if errs := ValidateProduct(p); len(errs) > 0 {
+	p.Tags = NormalizeTags(p.Tags)
+	normalizePrices(&p)
+	if errs := h.validate(r.Context(), p); len(errs) > 0 {
 		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
 			"errors": errs,
 		})
@@ -86,6 +264,10 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 
 	defer metrics.TimeDatabaseQuery("create_product")()
 	created, err := h.store.Create(r.Context(), p)
+	if errors.Is(err, ErrDuplicateSKU) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -93,12 +275,17 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 
 	metrics.ProductsCreated.Inc()
 
-	if h.cache != nil {
-		if err := h.cache.Delete(r.Context(), "products:list"); err != nil {
-        	fmt.Printf("Failed to invalidate cache: %v\n", err)
-    	}
+	h.recordPrice(r.Context(), PriceChange{ProductID: created.ID, NewPrice: created.Price})
+	h.invalidate(r.Context(), created.ID)
+	after := created
+	h.changed(r.Context(), ProductChange{Action: ProductCreated, ProductID: created.ID, After: &after})
+
+	if created, err = h.withImages(r.Context(), created); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	
+	w.Header().Set("ETag", productETag(created))
+
 	writeJSON(w, http.StatusCreated, created)
 }
 
@@ -111,15 +298,25 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cacheKey := fmt.Sprintf("product:%d", id)
+	currency, errs := h.parseCurrency(r)
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	cacheKey := productCacheKey(id)
 
 	var product Product
-	err = h.cache.Get(ctx, cacheKey, &product)
+	if h.cache != nil {
+		err = h.cache.Get(ctx, cacheKey, &product)
 
-	if err == nil {
-		w.Header().Set("X-Cache", "HIT")
-		writeJSON(w, http.StatusOK, product)
-		return
+		if err == nil {
+			w.Header().Set("X-Cache", "HIT")
+			h.writeProduct(w, r, product, currency)
+			return
+		}
 	}
 
 	product, err = h.store.GetByID(ctx, id)
@@ -127,13 +324,82 @@ func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
+	if product, err = h.withImages(ctx, product); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	if err := h.cache.Set(ctx, cacheKey, product, 10*time.Minute); err != nil {
-        fmt.Printf("Failed to cache product: %v\n", err)
-    }
-    
-    w.Header().Set("X-Cache", "MISS")
-    writeJSON(w, http.StatusOK, product)
+	if h.cache != nil {
+		if err := h.cache.Set(ctx, cacheKey, product, 10*time.Minute); err != nil {
+			fmt.Printf("Failed to cache product: %v\n", err)
+		}
+		w.Header().Set("X-Cache", "MISS")
+	} else {
+		w.Header().Set("X-Cache", "DISABLED")
+	}
+
+	h.writeProduct(w, r, product, currency)
+}
+
+// writeProduct answers 304 Not Modified if the request's If-None-Match
+// already names the product's version.
+func (h *Handler) writeProduct(w http.ResponseWriter, r *http.Request, p Product, currency money.Currency) {
+	w.Header().Set("ETag", productETag(p))
+	if etagMatches(r.Header.Get("If-None-Match"), p, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if err := h.localize(&p, currency); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// parseCurrency reads the currency query parameter, defaulting to
+// BaseCurrency. Currencies without a conversion rate are rejected since most
+// products could not be priced in them.
+func (h *Handler) parseCurrency(r *http.Request) (money.Currency, []ValidationError) {
+	v := r.URL.Query().Get("currency")
+	if v == "" {
+		return BaseCurrency, nil
+	}
+
+	c, err := money.ParseCurrency(v)
+	if err == nil && c != BaseCurrency && (h.rates == nil || !h.rates.Has(c)) {
+		err = fmt.Errorf("%w for %s", money.ErrNoRate, c)
+	}
+	if err != nil {
+		return "", []ValidationError{{Field: "currency", Message: err.Error()}}
+	}
+	return c, nil
+}
+
+// localize replaces p's price with its price in currency.
+func (h *Handler) localize(p *Product, currency money.Currency) error {
+	price, err := p.PriceIn(currency, h.rates)
+	if err != nil {
+		return fmt.Errorf("product %d: %w", p.ID, err)
+	}
+	p.Price = price
+	return nil
+}
+
+// normalizePrices defaults the price to BaseCurrency when the currency is
+// left out and upper-cases price list currencies.
+func normalizePrices(p *Product) {
+	normalizePrice(&p.Price)
+	for i := range p.Prices {
+		p.Prices[i].Currency = money.Currency(strings.ToUpper(string(p.Prices[i].Currency)))
+	}
+}
+
+func normalizePrice(m *money.Money) {
+	if m.Currency == "" {
+		m.Currency = BaseCurrency
+	}
+	m.Currency = money.Currency(strings.ToUpper(string(m.Currency)))
 }
 
 func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
@@ -150,28 +416,113 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if errs := ValidateProduct(p); len(errs) > 0 {
+	if errs := h.prepare(r.Context(), &p); len(errs) > 0 {
 		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
 			"errors": errs,
 		})
 		return
 	}
 
-	updated, err := h.store.Update(r.Context(), id, p)
+	before, err := h.store.GetByID(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
+	if !checkIfMatch(w, r, before) {
+		return
+	}
 
-	cacheKeys := []string{
-        "products:list",
-        fmt.Sprintf("product:%d", id),
-    }
+	h.save(w, r, before, p)
+}
 
-    if err := h.cache.Delete(r.Context(), cacheKeys...); err != nil {
-        fmt.Printf("Failed to invalidate cache: %v\n", err)
-    }
+// Patch changes part of a product. The body is a JSON Merge Patch (RFC 7396)
+// sent as application/merge-patch+json or application/json, or a JSON Patch
+// (RFC 6902) sent as application/json-patch+json. The patched product is
+// validated and saved like a full Update.
+func (h *Handler) Patch(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case MergePatchContentType, JSONPatchContentType, "application/json":
+	default:
+		w.Header().Set("Accept-Patch", MergePatchContentType+", "+JSONPatchContentType)
+		http.Error(w, "send "+MergePatchContentType+" or "+JSONPatchContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	before, err := h.store.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, before) {
+		return
+	}
+
+	p, err := applyPatch(before, mediaType, r.Body)
+	if errors.Is(err, ErrPatchTestFailed) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if errs := h.prepare(r.Context(), &p); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	h.save(w, r, before, p)
+}
+
+// prepare normalizes p and returns its validation errors.
+func (h *Handler) prepare(ctx context.Context, p *Product) []ValidationError {
+	p.Tags = NormalizeTags(p.Tags)
+	normalizePrices(p)
+	return h.validate(ctx, *p)
+}
+
+// save stores p over the product before and responds with the result,
+// recording any price change and invalidating the cache. It fails with 412
+// if the product has changed since before was read.
+func (h *Handler) save(w http.ResponseWriter, r *http.Request, before, p Product) {
+	p.Version = before.Version
+	updated, err := h.store.Update(r.Context(), before.ID, p)
+	if errors.Is(err, ErrVersionMismatch) {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if errors.Is(err, ErrDuplicateSKU) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if updated.Price != before.Price {
+		h.recordPrice(r.Context(), PriceChange{ProductID: before.ID, OldPrice: &before.Price, NewPrice: updated.Price})
+	}
+	h.invalidate(r.Context(), before.ID)
+	after := updated
+	h.changed(r.Context(), ProductChange{Action: ProductUpdated, ProductID: before.ID, Before: &before, After: &after})
 
+	if updated, err = h.withImages(r.Context(), updated); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", productETag(updated))
 	writeJSON(w, http.StatusOK, updated)
 }
 
@@ -183,25 +534,201 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	p, err := h.store.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !checkIfMatch(w, r, p) {
+		return
+	}
+
 	defer metrics.TimeDatabaseQuery("delete_product")()
-	if err := h.store.Delete(r.Context(), id); err != nil {
+	err = h.store.Delete(r.Context(), id, p.Version)
+	if errors.Is(err, ErrVersionMismatch) {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
 	metrics.ProductsDeleted.Inc()
-	
-	cacheKeys := []string{
-        "products:list",
-        fmt.Sprintf("product:%d", id),
-    }
-    if err := h.cache.Delete(r.Context(), cacheKeys...); err != nil {
-        fmt.Printf("Failed to invalidate cache: %v\n", err)
-    }
+
+	h.invalidate(r.Context(), id)
+	h.changed(r.Context(), ProductChange{Action: ProductDeleted, ProductID: id, Before: &p})
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func (h *Handler) Restore(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	restored, err := h.store.Restore(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	h.invalidate(r.Context(), id)
+	after := restored
+	h.changed(r.Context(), ProductChange{Action: ProductRestored, ProductID: id, After: &after})
+
+	if restored, err = h.withImages(r.Context(), restored); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", productETag(restored))
+	writeJSON(w, http.StatusOK, restored)
+}
+
+// validate runs ValidateProduct and checks that the product's category
+// exists. Options and variants are only saved under /products/{id}/variants,
+// so product writes that carry them are rejected rather than dropped.
+func (h *Handler) validate(ctx context.Context, p Product) []ValidationError {
+	errs := ValidateProduct(p)
+	if len(p.Options) > 0 || len(p.Variants) > 0 {
+		errs = append(errs, ValidationError{
+			Field:   "variants",
+			Message: "options and variants are managed under /products/{id}/variants",
+		})
+	}
+	if p.CategoryID == nil {
+		return errs
+	}
+
+	categories, err := h.categories.List(ctx)
+	if err != nil {
+		return append(errs, ValidationError{Field: "category_id", Message: err.Error()})
+	}
+	return append(errs, validateCategoryRef(categories, "category_id", p.CategoryID)...)
+}
+
+// attachImages fills in the ordered images of every product in one lookup.
+func (h *Handler) attachImages(ctx context.Context, products []Product) error {
+	if h.images == nil || len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	byProduct, err := h.images.ListByProducts(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range products {
+		products[i].Images = byProduct[products[i].ID]
+		if products[i].Images == nil {
+			products[i].Images = []Image{}
+		}
+	}
+	return nil
+}
+
+func (h *Handler) withImages(ctx context.Context, p Product) (Product, error) {
+	products := []Product{p}
+	err := h.attachImages(ctx, products)
+	return products[0], err
+}
+
+// recordPrice adds an entry to the price history with the current user as
+// the actor. A failure is only logged since the product is already saved.
+func (h *Handler) recordPrice(ctx context.Context, c PriceChange) {
+	if h.history == nil {
+		return
+	}
+
+	if user, ok := auth.UserFromContext(ctx); ok {
+		c.ActorID = &user.ID
+	}
+	if _, err := h.history.Record(ctx, c); err != nil {
+		fmt.Printf("Failed to record price change for product %d: %v\n", c.ProductID, err)
+	}
+}
+
+func (h *Handler) invalidate(ctx context.Context, ids ...int) {
+	invalidateProducts(ctx, h.cache, ids...)
+}
+
+// invalidateProducts drops the cached copies of the given products along with
+// every cached listing page, since any write can change filters, order and
+// totals.
+func invalidateProducts(ctx context.Context, c *cache.RedisCache, ids ...int) {
+	if c == nil {
+		return
+	}
+
+	if len(ids) > 0 {
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = productCacheKey(id)
+		}
+		if err := c.Delete(ctx, keys...); err != nil {
+			fmt.Printf("Failed to invalidate cache: %v\n", err)
+		}
+	}
+
+	if err := c.DeletePattern(ctx, listCacheKeyPrefix+":*"); err != nil {
+		fmt.Printf("Failed to invalidate cache: %v\n", err)
+	}
+}
+
+func productCacheKey(id int) string {
+	return fmt.Sprintf("product:%d", id)
+}
+
+// productETag is the strong entity tag for the product's current version.
+func productETag(p Product) string {
+	return `"` + strconv.Itoa(p.Version) + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header is "*" or
+// lists the product's ETag. Weak tags such as W/"3" only count when weak is
+// set, since If-Match uses strong comparison.
+func etagMatches(header string, p Product, weak bool) bool {
+	if header == "" {
+		return false
+	}
+	etag := productETag(p)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch requires an If-Match header naming the product's current
+// version before a write, so two clients editing the same product can't
+// silently overwrite each other. It answers 428 or 412 itself and reports
+// whether the write can go ahead.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, p Product) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		http.Error(w, "If-Match is required; send the ETag from GET /products/{id}", http.StatusPreconditionRequired)
+		return false
+	}
+	if !etagMatches(header, p, false) {
+		w.Header().Set("ETag", productETag(p))
+		http.Error(w, fmt.Sprintf("product %d has been modified", p.ID), http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
 func writeJSON(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -3,15 +3,22 @@ package product
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+
 	"lukekorsman.com/store/internal/auth"
 	apphttp "lukekorsman.com/store/internal/http"
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/money/moneytest"
 )
 
 func TestListProducts_JSON(t *testing.T) {
@@ -31,7 +38,7 @@ func TestListProducts_JSON(t *testing.T) {
 		{
 			name:			"single product",
 			seedProducts: 	[]Product{
-				{Name: "Book", Price: 10},
+				{Name: "Book", Price: moneytest.USD(1000)},
 			},
 			wantStatus:		http.StatusOK,
 			wantCount: 		1,
@@ -40,8 +47,8 @@ func TestListProducts_JSON(t *testing.T) {
 		{
 			name:			"multiple products",
 			seedProducts: 	[]Product{
-				{Name: "Book", Price: 10},
-				{Name: "Laptop", Price: 1200},
+				{Name: "Book", Price: moneytest.USD(1000)},
+				{Name: "Laptop", Price: moneytest.USD(120000)},
 			},
 			wantStatus:		http.StatusOK,
 			wantCount: 		2,
@@ -56,7 +63,7 @@ func TestListProducts_JSON(t *testing.T) {
 				store.Create(context.Background(), p)
 			}
 
-			handler := NewHandler(store, nil)
+			handler := NewHandler(store, NewMemoryCategoryStore(), nil, nil, nil, nil)
 
 			req := httptest.NewRequest(http.MethodGet, "/products", nil)
 			rec := httptest.NewRecorder()
@@ -87,6 +94,362 @@ func TestListProducts_JSON(t *testing.T) {
 	}
 }
 
+func TestListProducts_Query(t *testing.T) {
+	seed := []Product{
+		{Name: "Book", Price: moneytest.USD(1000)},
+		{Name: "Laptop", Price: moneytest.USD(120000)},
+		{Name: "Lamp", Price: moneytest.USD(4000)},
+		{Name: "Mouse", Price: moneytest.USD(2500)},
+	}
+
+	tests := []struct {
+		name		string
+		query		string
+		wantStatus	int
+		wantNames	[]string
+		wantTotal	string
+		wantNext	bool
+		wantPrev	bool
+	}{
+		{
+			name:		"first page",
+			query:		"?limit=2",
+			wantStatus:	http.StatusOK,
+			wantNames:	[]string{"Book", "Laptop"},
+			wantTotal:	"4",
+			wantNext:	true,
+		},
+		{
+			name:		"last page via offset",
+			query:		"?limit=2&offset=2",
+			wantStatus:	http.StatusOK,
+			wantNames:	[]string{"Lamp", "Mouse"},
+			wantTotal:	"4",
+			wantPrev:	true,
+		},
+		{
+			name:		"price range sorted by price descending",
+			query:		"?min_price=20&max_price=100&sort=-price",
+			wantStatus:	http.StatusOK,
+			wantNames:	[]string{"Lamp", "Mouse"},
+			wantTotal:	"2",
+		},
+		{
+			name:		"name prefix sorted by name",
+			query:		"?name=la&sort=name",
+			wantStatus:	http.StatusOK,
+			wantNames:	[]string{"Lamp", "Laptop"},
+			wantTotal:	"2",
+		},
+		{
+			name:		"invalid sort field",
+			query:		"?sort=color",
+			wantStatus:	http.StatusBadRequest,
+		},
+		{
+			name:		"invalid cursor",
+			query:		"?cursor=nope",
+			wantStatus:	http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			for _, p := range seed {
+				store.Create(context.Background(), p)
+			}
+
+			handler := NewHandler(store, NewMemoryCategoryStore(), nil, nil, nil, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/products"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			handler.List(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if total := rec.Header().Get("X-Total-Count"); total != tt.wantTotal {
+				t.Fatalf("expected X-Total-Count %s, got %s", tt.wantTotal, total)
+			}
+
+			link := rec.Header().Get("Link")
+			if got := strings.Contains(link, `rel="next"`); got != tt.wantNext {
+				t.Fatalf("expected next link %v, got Link %q", tt.wantNext, link)
+			}
+			if got := strings.Contains(link, `rel="prev"`); got != tt.wantPrev {
+				t.Fatalf("expected prev link %v, got Link %q", tt.wantPrev, link)
+			}
+
+			var products []Product
+			if err := json.NewDecoder(rec.Body).Decode(&products); err != nil {
+				t.Fatalf("failed to decode JSON: %v", err)
+			}
+
+			if len(products) != len(tt.wantNames) {
+				t.Fatalf("expected %d products, got %d", len(tt.wantNames), len(products))
+			}
+			for i, name := range tt.wantNames {
+				if products[i].Name != name {
+					t.Fatalf("expected product %d to be %s, got %s", i, name, products[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestListProducts_CursorFollowsNextLink(t *testing.T) {
+	store := NewMemoryStore()
+	for i := 0; i < 5; i++ {
+		store.Create(context.Background(), Product{Name: fmt.Sprintf("Item %d", i), Price: moneytest.USD(1000)})
+	}
+	handler := NewHandler(store, NewMemoryCategoryStore(), nil, nil, nil, nil)
+
+	seen := 0
+	target := "/products?limit=2"
+	for target != "" {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		handler.List(rec, req)
+
+		var products []Product
+		if err := json.NewDecoder(rec.Body).Decode(&products); err != nil {
+			t.Fatalf("failed to decode JSON: %v", err)
+		}
+		seen += len(products)
+
+		target = ""
+		for _, link := range strings.Split(rec.Header().Get("Link"), ", ") {
+			if strings.HasSuffix(link, `rel="next"`) {
+				target = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+			}
+		}
+	}
+
+	if seen != 5 {
+		t.Fatalf("expected to page through 5 products, got %d", seen)
+	}
+}
+
+func TestListProducts_KeysetCursor(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for i, price := range []int64{300, 500, 100, 400, 200} {
+		store.Create(ctx, Product{Name: fmt.Sprintf("Item %d", i), Price: moneytest.USD(price)})
+	}
+	handler := NewHandler(store, NewMemoryCategoryStore(), nil, nil, nil, nil)
+
+	get := func(target string) (prices []int64, links map[string]string, status int) {
+		rec := httptest.NewRecorder()
+		handler.List(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var products []Product
+		json.NewDecoder(rec.Body).Decode(&products)
+		for _, p := range products {
+			prices = append(prices, p.Price.Amount)
+		}
+		links = map[string]string{}
+		for _, link := range strings.Split(rec.Header().Get("Link"), ", ") {
+			if target, rel, ok := strings.Cut(link, ">; rel="); ok {
+				links[strings.Trim(rel, `"`)] = strings.TrimPrefix(target, "<")
+			}
+		}
+		return prices, links, rec.Code
+	}
+
+	prices, links, _ := get("/products?limit=2&sort=-price")
+	if fmt.Sprint(prices) != "[500 400]" || links["prev"] != "" {
+		t.Fatalf("unexpected first page %v with links %v", prices, links)
+	}
+
+	// A product added ahead of the cursor doesn't push rows onto the next
+	// page again, as it would with an offset.
+	store.Create(ctx, Product{Name: "New", Price: moneytest.USD(600)})
+	prices, links, _ = get(links["next"])
+	if fmt.Sprint(prices) != "[300 200]" {
+		t.Fatalf("expected the page after 400, got %v", prices)
+	}
+	prices, last, _ := get(links["next"])
+	if fmt.Sprint(prices) != "[100]" || last["next"] != "" {
+		t.Fatalf("expected the last page, got %v with links %v", prices, last)
+	}
+
+	prices, links, _ = get(last["prev"])
+	if fmt.Sprint(prices) != "[300 200]" || links["next"] == "" {
+		t.Fatalf("expected to page back to 300 and 200, got %v with links %v", prices, links)
+	}
+	prices, links, _ = get(links["prev"])
+	if fmt.Sprint(prices) != "[500 400]" || links["prev"] == "" {
+		t.Fatalf("expected 500 and 400 with the new product before them, got %v with links %v", prices, links)
+	}
+
+	cursor, _ := url.Parse(last["prev"])
+	if _, _, status := get("/products?sort=name&cursor=" + cursor.Query().Get("cursor")); status != http.StatusBadRequest {
+		t.Fatalf("expected a cursor from another sort to be rejected, got %d", status)
+	}
+}
+
+func TestListSeek(t *testing.T) {
+	opts := ListOptions{
+		Sort:  []SortField{{Field: "price", Desc: true}, {Field: "name"}},
+		After: &Cursor{Key: Product{ID: 7, Name: "Lamp", Price: moneytest.USD(1999)}},
+	}
+	seek, args := listSeek(opts)
+	want := "((price < ?) OR (price = ? AND name > ?) OR (price = ? AND name = ? AND id > ?))"
+	if seek != want || fmt.Sprint(args) != "[19.99 19.99 Lamp 19.99 Lamp 7]" {
+		t.Fatalf("unexpected seek %s %v", seek, args)
+	}
+
+	opts.After.Before = true
+	if seek, _ := listSeek(opts); !strings.HasPrefix(seek, "((price > ?) OR (price = ? AND name < ?)") {
+		t.Fatalf("expected a backwards seek to flip every comparison, got %s", seek)
+	}
+}
+
+func TestSearchProducts(t *testing.T) {
+	seed := []Product{
+		{Name: "Gaming Laptop", Price: moneytest.USD(150000)},
+		{Name: "Laptop Stand", Price: moneytest.USD(4000)},
+		{Name: "Desk Lamp", Price: moneytest.USD(2500)},
+	}
+
+	tests := []struct {
+		name			string
+		query			string
+		wantStatus		int
+		wantNames		[]string
+		wantHighlight	string
+	}{
+		{
+			name:			"missing query",
+			query:			"",
+			wantStatus:		http.StatusBadRequest,
+		},
+		{
+			name:			"natural mode matches whole words",
+			query:			"?q=laptop",
+			wantStatus:		http.StatusOK,
+			wantNames:		[]string{"Gaming Laptop", "Laptop Stand"},
+			wantHighlight:	"Gaming <mark>Laptop</mark>",
+		},
+		{
+			name:			"natural mode does not match prefixes",
+			query:			"?q=lap",
+			wantStatus:		http.StatusOK,
+			wantNames:		[]string{},
+		},
+		{
+			name:			"boolean mode prefix",
+			query:			"?mode=boolean&q=la*",
+			wantStatus:		http.StatusOK,
+			wantNames:		[]string{"Desk Lamp", "Gaming Laptop", "Laptop Stand"},
+			wantHighlight:	"Desk <mark>Lamp</mark>",
+		},
+		{
+			name:			"boolean mode required and excluded",
+			query:			"?mode=boolean&q=%2Blaptop+-gaming",
+			wantStatus:		http.StatusOK,
+			wantNames:		[]string{"Laptop Stand"},
+			wantHighlight:	"<mark>Laptop</mark> Stand",
+		},
+		{
+			name:			"invalid mode",
+			query:			"?q=laptop&mode=fuzzy",
+			wantStatus:		http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			for _, p := range seed {
+				store.Create(context.Background(), p)
+			}
+
+			handler := NewHandler(store, NewMemoryCategoryStore(), nil, nil, nil, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/products/search"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			handler.Search(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if total := rec.Header().Get("X-Total-Count"); total != fmt.Sprint(len(tt.wantNames)) {
+				t.Fatalf("expected X-Total-Count %d, got %s", len(tt.wantNames), total)
+			}
+
+			var hits []SearchHit
+			if err := json.NewDecoder(rec.Body).Decode(&hits); err != nil {
+				t.Fatalf("failed to decode JSON: %v", err)
+			}
+
+			var names []string
+			for _, hit := range hits {
+				names = append(names, hit.Product.Name)
+			}
+			if len(names) != len(tt.wantNames) {
+				t.Fatalf("expected %v, got %v", tt.wantNames, names)
+			}
+			for _, want := range tt.wantNames {
+				if !slices.Contains(names, want) {
+					t.Fatalf("expected %v, got %v", tt.wantNames, names)
+				}
+			}
+
+			if tt.wantHighlight != "" {
+				found := false
+				for _, hit := range hits {
+					if hit.Highlights["name"] == tt.wantHighlight {
+						found = true
+					}
+				}
+				if !found {
+					t.Fatalf("expected a name highlight %q in %+v", tt.wantHighlight, hits)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchProducts_RankingAndIndexUpdates(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.Create(ctx, Product{Name: "Red Shirt", Price: moneytest.USD(2000)})
+	shoes, _ := store.Create(ctx, Product{Name: "Red Shoes Red Laces", Price: moneytest.USD(6000)})
+	hat, _ := store.Create(ctx, Product{Name: "Blue Hat", Price: moneytest.USD(1500)})
+
+	result, err := store.Search(ctx, SearchOptions{Query: "red", Mode: SearchModeNatural})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if result.Total != 2 || result.Hits[0].Product.ID != shoes.ID {
+		t.Fatalf("expected the product repeating the term to rank first, got %+v", result.Hits)
+	}
+
+	store.Update(ctx, hat.ID, Product{Name: "Red Hat", Price: moneytest.USD(1500)})
+	store.Delete(ctx, shoes.ID, 0)
+
+	result, _ = store.Search(ctx, SearchOptions{Query: "red", Mode: SearchModeNatural})
+	if result.Total != 2 {
+		t.Fatalf("expected 2 hits after update and delete, got %d", result.Total)
+	}
+	for _, hit := range result.Hits {
+		if hit.Product.ID == shoes.ID {
+			t.Fatalf("deleted product %d still searchable", shoes.ID)
+		}
+	}
+}
+
 func TestCreateProduct(t *testing.T) {
 	// Setup JWT manager and user store for testing
     jwtManager := auth.NewJWTManager("test-secret", "test")
@@ -96,7 +459,7 @@ func TestCreateProduct(t *testing.T) {
     testUser, _ := userStore.Create(context.Background(), "test@example.com", "password")
     
     // Generate a valid token
-    validToken, _ := jwtManager.Generate(testUser.ID, testUser.Email, 1*time.Hour)
+    validToken, _ := jwtManager.Generate(testUser.ID, testUser.Email, testUser.Roles, 1*time.Hour)
 
 	tests := []struct {
 		name		string
@@ -107,13 +470,13 @@ func TestCreateProduct(t *testing.T) {
 		{
 			name: 		"unauthorized",
 			token:		"",
-			body:		`{"name":"Book","Price":10}`,
+			body:		`{"name":"Book","price":{"amount":1000}}`,
 			wantStatus: http.StatusUnauthorized,
 		},
 		{
             name:       "invalid_token",
             token:      "invalid-token",
-            body:       `{"name":"Book","price":10}`,
+            body:       `{"name":"Book","price":{"amount":1000}}`,
             wantStatus: http.StatusUnauthorized,
         },
 		{
@@ -125,18 +488,24 @@ func TestCreateProduct(t *testing.T) {
 		{
 			name:		"valid request",
 			token: 		validToken,
-			body: 		`{"name":"Book","Price":10}`,
+			body: 		`{"name":"Book","price":{"amount":1000}}`,
 			wantStatus: http.StatusCreated,
 		},
+		{
+			name:		"variants",
+			token: 		validToken,
+			body: 		`{"name":"Shirt","price":{"amount":1000},"options":[{"name":"size","values":["M"]}],"variants":[{"sku":"SHIRT-M","options":{"size":"M"}}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			store := NewMemoryStore()
-			handler := NewHandler(store, nil)
+			handler := NewHandler(store, NewMemoryCategoryStore(), nil, nil, nil, nil)
 
             // Wrap with JWT middleware
-            protected := apphttp.JWTAuth(jwtManager, userStore)(
+            protected := apphttp.JWTAuth(jwtManager, userStore, auth.NewMemoryRevocationStore())(
                 http.HandlerFunc(handler.Create),
             )
 
@@ -161,6 +530,253 @@ func TestCreateProduct(t *testing.T) {
 	}
 }
 
+func TestDeleteAndRestoreProduct(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	book, _ := store.Create(ctx, Product{Name: "Book", Description: "A good read", Price: moneytest.USD(1000)})
+	store.Create(ctx, Product{Name: "Lamp", Price: moneytest.USD(2500)})
+
+	handler := NewHandler(store, NewMemoryCategoryStore(), nil, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/products", handler.List)
+	r.Get("/admin/products", handler.AdminList)
+	r.Get("/products/{id}", handler.Get)
+	r.Delete("/products/{id}", handler.Delete)
+	r.Post("/products/{id}/restore", handler.Restore)
+
+	do := func(method, target string, user *auth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("If-Match", "*")
+		if user != nil {
+			req = req.WithContext(auth.ContextWithUser(req.Context(), *user))
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	admin := &auth.User{ID: 1, Email: "admin@example.com"}
+
+	if rec := do(http.MethodDelete, fmt.Sprintf("/products/%d", book.ID), admin); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected delete to return %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if rec := do(http.MethodGet, fmt.Sprintf("/products/%d", book.ID), nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected archived product to be hidden, got %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/products", nil); rec.Header().Get("X-Total-Count") != "1" {
+		t.Fatalf("expected 1 listed product, got %s", rec.Header().Get("X-Total-Count"))
+	}
+	if rec := do(http.MethodGet, "/products?include_deleted=true", admin); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected include_deleted on the public listing to return %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec := do(http.MethodGet, "/admin/products?include_deleted=true", admin)
+	var products []Product
+	json.NewDecoder(rec.Body).Decode(&products)
+	if len(products) != 2 || products[0].DeletedAt == nil {
+		t.Fatalf("expected archived product in admin listing, got %+v", products)
+	}
+
+	rec = do(http.MethodPost, fmt.Sprintf("/products/%d/restore", book.ID), admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected restore to return %d, got %d", http.StatusOK, rec.Code)
+	}
+	var restored Product
+	json.NewDecoder(rec.Body).Decode(&restored)
+	if restored.DeletedAt != nil || restored.Description != "A good read" || restored.CreatedAt.IsZero() {
+		t.Fatalf("unexpected restored product %+v", restored)
+	}
+
+	if rec := do(http.MethodPost, fmt.Sprintf("/products/%d/restore", book.ID), admin); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected restoring a live product to return %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	lamp, _ := store.Create(ctx, Product{Name: "Lamp", Price: moneytest.USD(2500)})
+	path := fmt.Sprintf("/products/%d", lamp.ID)
+
+	handler := NewHandler(store, NewMemoryCategoryStore(), nil, nil, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/products/{id}", handler.Get)
+	r.Put("/products/{id}", handler.Update)
+	r.Patch("/products/{id}", handler.Patch)
+	r.Delete("/products/{id}", handler.Delete)
+
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		value      string
+		body       string
+		wantStatus int
+		wantETag   string
+	}{
+		{"get", http.MethodGet, "", "", "", http.StatusOK, `"1"`},
+		{"get unchanged", http.MethodGet, "If-None-Match", `"0", "1"`, "", http.StatusNotModified, `"1"`},
+		{"get unchanged weak", http.MethodGet, "If-None-Match", `W/"1"`, "", http.StatusNotModified, `"1"`},
+		{"get changed", http.MethodGet, "If-None-Match", `"0"`, "", http.StatusOK, `"1"`},
+		{"put without If-Match", http.MethodPut, "", "", `{"name":"Desk Lamp","price":{"amount":2500}}`, http.StatusPreconditionRequired, ""},
+		{"put stale", http.MethodPut, "If-Match", `"0"`, `{"name":"Desk Lamp","price":{"amount":2500}}`, http.StatusPreconditionFailed, `"1"`},
+		{"put weak", http.MethodPut, "If-Match", `W/"1"`, `{"name":"Desk Lamp","price":{"amount":2500}}`, http.StatusPreconditionFailed, `"1"`},
+		{"put current", http.MethodPut, "If-Match", `"1"`, `{"name":"Desk Lamp","price":{"amount":2500}}`, http.StatusOK, `"2"`},
+		{"patch stale", http.MethodPatch, "If-Match", `"1"`, `{"name":"Floor Lamp"}`, http.StatusPreconditionFailed, `"2"`},
+		{"patch current", http.MethodPatch, "If-Match", `"2"`, `{"name":"Floor Lamp"}`, http.StatusOK, `"3"`},
+		{"delete without If-Match", http.MethodDelete, "", "", "", http.StatusPreconditionRequired, ""},
+		{"delete stale", http.MethodDelete, "If-Match", `"2"`, "", http.StatusPreconditionFailed, `"3"`},
+		{"delete current", http.MethodDelete, "If-Match", `"3"`, "", http.StatusNoContent, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("ETag"); got != tt.wantETag {
+				t.Fatalf("expected ETag %s, got %s", tt.wantETag, got)
+			}
+			if rec.Code == http.StatusNotModified && rec.Body.Len() > 0 {
+				t.Fatalf("expected an empty 304, got %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMemoryStoreVersions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	lamp, _ := store.Create(ctx, Product{Name: "Lamp", Price: moneytest.USD(2500)})
+
+	// Writers racing with the same version: exactly one wins.
+	results := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func(i int) {
+			p := lamp
+			p.Name = fmt.Sprintf("Lamp %d", i)
+			_, err := store.Update(ctx, lamp.ID, p)
+			results <- err
+		}(i)
+	}
+	won := 0
+	for i := 0; i < 10; i++ {
+		err := <-results
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, ErrVersionMismatch):
+			t.Fatalf("expected %v, got %v", ErrVersionMismatch, err)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("expected one update to win, got %d", won)
+	}
+
+	if err := store.Delete(ctx, lamp.ID, lamp.Version); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("expected a stale delete to fail, got %v", err)
+	}
+	if err := store.Delete(ctx, lamp.ID, lamp.Version+1); err != nil {
+		t.Fatal(err)
+	}
+	restored, _ := store.Restore(ctx, lamp.ID)
+	if restored.Version != lamp.Version+3 {
+		t.Fatalf("expected every write to bump the version, got %d", restored.Version)
+	}
+}
+
+func TestProductCurrency(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.Create(ctx, Product{Name: "Book", Price: moneytest.USD(1999), Prices: []money.Money{money.New(1500, money.GBP)}})
+	store.Create(ctx, Product{Name: "Lamp", Price: moneytest.USD(2500)})
+
+	rates, _ := money.NewRates(money.USD, map[money.Currency]string{"EUR": "0.92", "GBP": "0.79"})
+	handler := NewHandler(store, NewMemoryCategoryStore(), nil, nil, nil, rates)
+	r := chi.NewRouter()
+	r.Get("/products", handler.List)
+	r.Get("/products/{id}", handler.Get)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantPrices []money.Money
+	}{
+		{
+			name:       "base currency",
+			target:     "/products",
+			wantStatus: http.StatusOK,
+			wantPrices: []money.Money{moneytest.USD(1999), moneytest.USD(2500)},
+		},
+		{
+			name:       "converted",
+			target:     "/products?currency=eur",
+			wantStatus: http.StatusOK,
+			wantPrices: []money.Money{money.New(1839, money.EUR), money.New(2300, money.EUR)},
+		},
+		{
+			name:       "price list wins over conversion",
+			target:     "/products?currency=GBP",
+			wantStatus: http.StatusOK,
+			wantPrices: []money.Money{money.New(1500, money.GBP), money.New(1975, money.GBP)},
+		},
+		{
+			name:       "single product",
+			target:     "/products/2?currency=EUR",
+			wantStatus: http.StatusOK,
+			wantPrices: []money.Money{money.New(2300, money.EUR)},
+		},
+		{
+			name:       "no rate",
+			target:     "/products?currency=JPY",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown currency",
+			target:     "/products/1?currency=ABC",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var products []Product
+			if strings.HasPrefix(tt.target, "/products/") {
+				var p Product
+				json.NewDecoder(rec.Body).Decode(&p)
+				products = []Product{p}
+			} else {
+				json.NewDecoder(rec.Body).Decode(&products)
+			}
+
+			var prices []money.Money
+			for _, p := range products {
+				prices = append(prices, p.Price)
+			}
+			if !slices.Equal(prices, tt.wantPrices) {
+				t.Fatalf("expected prices %v, got %v", tt.wantPrices, prices)
+			}
+		})
+	}
+}
+
 func BenchmarkListProducts_Sizes(b *testing.B) {
 	sizes := []int{0, 10, 100, 1000}
 
@@ -168,10 +784,10 @@ func BenchmarkListProducts_Sizes(b *testing.B) {
 		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
 			store := NewMemoryStore()
 			for i := 0; i < size; i++ {
-				store.Create(context.Background(), Product{Name: "Item", Price: 10})
+				store.Create(context.Background(), Product{Name: "Item", Price: moneytest.USD(1000)})
 			}
 
-			handler := NewHandler(store, nil)
+			handler := NewHandler(store, NewMemoryCategoryStore(), nil, nil, nil, nil)
 			req := httptest.NewRequest(http.MethodGet, "/products", nil)
 
 			b.ResetTimer()
@@ -183,3 +799,12 @@ func BenchmarkListProducts_Sizes(b *testing.B) {
 		})
 	}
 }
+
+func TestUploadsFitIdempotencyLimit(t *testing.T) {
+	limits := map[string]int64{"import": MaxImportSize, "image upload": MaxImageUploadSize}
+	for route, limit := range limits {
+		if limit > apphttp.MaxIdempotentBodySize {
+			t.Errorf("%s limit %d is over the idempotency body limit %d", route, limit, apphttp.MaxIdempotentBodySize)
+		}
+	}
+}
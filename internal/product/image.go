@@ -0,0 +1,35 @@
+package product
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxImages is how many images a product can have.
+const MaxImages = 20
+
+var (
+	ErrImageNotFound = errors.New("image not found")
+	// ErrImageOrder is returned when a new order doesn't list each of the
+	// product's images exactly once.
+	ErrImageOrder = errors.New("image order must list every image of the product once")
+)
+
+// Image is an uploaded product photo. Position orders a product's images
+// from 0; the first is the main image.
+type Image struct {
+	ID           int    `json:"id"`
+	ProductID    int    `json:"product_id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	Alt          string `json:"alt"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Size         int64  `json:"size"`
+	Position     int    `json:"position"`
+	// Key and ThumbnailKey locate the files in the blob store.
+	Key          string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,262 @@
+package product
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"lukekorsman.com/store/internal/cache"
+	"lukekorsman.com/store/internal/media"
+)
+
+// MaxImageUploadSize is the largest upload form Upload reads, leaving some
+// room over the image limit for the rest of the form.
+const MaxImageUploadSize = media.MaxImageSize + 64<<10
+
+type ImageHandler struct {
+	products Store
+	images   ImageStore
+	blobs    media.BlobStore
+	cache    *cache.RedisCache
+}
+
+func NewImageHandler(products Store, images ImageStore, blobs media.BlobStore, redisCache *cache.RedisCache) *ImageHandler {
+	return &ImageHandler{
+		products: products,
+		images:   images,
+		blobs:    blobs,
+		cache:    redisCache,
+	}
+}
+
+type ReorderImagesRequest struct {
+	ImageIDs []int `json:"image_ids"`
+}
+
+func (h *ImageHandler) List(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.productID(w, r)
+	if !ok {
+		return
+	}
+
+	images, err := h.images.List(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, images)
+}
+
+// Upload takes a multipart form with the file in "image" and optional alt
+// text in "alt". The file type is sniffed from its content, and the image
+// is stored along with a generated thumbnail.
+func (h *ImageHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.productID(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImageUploadSize)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("upload must be %d bytes or less", media.MaxImageSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []ValidationError{{Field: "image", Message: "image file is required"}},
+		})
+		return
+	}
+	defer file.Close()
+
+	alt := strings.TrimSpace(r.FormValue("alt"))
+	if len(alt) > 200 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []ValidationError{{Field: "alt", Message: "alt must be 200 characters or less"}},
+		})
+		return
+	}
+
+	existing, err := h.images.List(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= MaxImages {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []ValidationError{{Field: "image", Message: fmt.Sprintf("a product can have at most %d images", MaxImages)}},
+		})
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxImageSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	processed, err := media.ProcessImage(data)
+	switch {
+	case errors.Is(err, media.ErrImageTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, media.ErrUnsupportedImage):
+		http.Error(w, err.Error()+"; use JPEG, PNG or GIF", http.StatusUnsupportedMediaType)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	img, err := h.store(r, id, alt, processed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	invalidateProducts(r.Context(), h.cache, id)
+
+	writeJSON(w, http.StatusCreated, img)
+}
+
+// store writes the image and its thumbnail to the blob store and records
+// them, removing the blobs again if a later step fails.
+func (h *ImageHandler) store(r *http.Request, productID int, alt string, processed media.Image) (Image, error) {
+	ctx := r.Context()
+	prefix := fmt.Sprintf("products/%d", productID)
+	key := media.NewKey(prefix, processed.Ext)
+	thumbKey := strings.TrimSuffix(key, processed.Ext) + "_thumb" + processed.ThumbnailExt
+
+	if err := h.blobs.Put(ctx, key, bytes.NewReader(processed.Data), int64(len(processed.Data)), processed.ContentType); err != nil {
+		return Image{}, err
+	}
+	if err := h.blobs.Put(ctx, thumbKey, bytes.NewReader(processed.Thumbnail), int64(len(processed.Thumbnail)), processed.ThumbnailContentType); err != nil {
+		h.deleteBlobs(r, key)
+		return Image{}, err
+	}
+
+	img, err := h.images.Add(ctx, Image{
+		ProductID:    productID,
+		URL:          h.blobs.URL(key),
+		ThumbnailURL: h.blobs.URL(thumbKey),
+		Alt:          alt,
+		ContentType:  processed.ContentType,
+		Width:        processed.Width,
+		Height:       processed.Height,
+		Size:         int64(len(processed.Data)),
+		Key:          key,
+		ThumbnailKey: thumbKey,
+	})
+	if err != nil {
+		h.deleteBlobs(r, key, thumbKey)
+		return Image{}, err
+	}
+	return img, nil
+}
+
+// Reorder sets the order of the product's images. The body must list every
+// image ID once; the first becomes the main image.
+func (h *ImageHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.productID(w, r)
+	if !ok {
+		return
+	}
+
+	var req ReorderImagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	images, err := h.images.Reorder(r.Context(), id, req.ImageIDs)
+	if errors.Is(err, ErrImageOrder) {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []ValidationError{{Field: "image_ids", Message: err.Error()}},
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	invalidateProducts(r.Context(), h.cache, id)
+
+	writeJSON(w, http.StatusOK, images)
+}
+
+func (h *ImageHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.productID(w, r)
+	if !ok {
+		return
+	}
+
+	imageID, err := strconv.Atoi(chi.URLParam(r, "imageID"))
+	if err != nil {
+		http.Error(w, "invalid image ID", http.StatusBadRequest)
+		return
+	}
+
+	img, err := h.images.Get(r.Context(), imageID)
+	if err == nil && img.ProductID != id {
+		err = fmt.Errorf("%w: %d", ErrImageNotFound, imageID)
+	}
+	if err == nil {
+		err = h.images.Delete(r.Context(), imageID)
+	}
+	if errors.Is(err, ErrImageNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.deleteBlobs(r, img.Key, img.ThumbnailKey)
+	invalidateProducts(r.Context(), h.cache, id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteBlobs removes files that are no longer referenced. Failures only
+// leave orphaned files behind, so they are logged.
+func (h *ImageHandler) deleteBlobs(r *http.Request, keys ...string) {
+	for _, key := range keys {
+		if err := h.blobs.Delete(r.Context(), key); err != nil {
+			fmt.Printf("Failed to delete blob %s: %v\n", key, err)
+		}
+	}
+}
+
+// productID reads the product ID from the URL and checks the product exists.
+func (h *ImageHandler) productID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	if _, err := h.products.GetByID(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return 0, false
+	}
+
+	return id, true
+}
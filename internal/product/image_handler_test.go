@@ -0,0 +1,196 @@
+package product
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"lukekorsman.com/store/internal/media"
+	"lukekorsman.com/store/internal/money/moneytest"
+)
+
+func pngBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// multipartImage builds an upload form. The declared content type is always
+// image/png so the tests show it is ignored in favour of sniffing.
+func multipartImage(t *testing.T, data []byte, alt string) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if alt != "" {
+		form.WriteField("alt", alt)
+	}
+	if data != nil {
+		part, _ := form.CreatePart(map[string][]string{
+			"Content-Disposition": {`form-data; name="image"; filename="photo.png"`},
+			"Content-Type":        {"image/png"},
+		})
+		part.Write(data)
+	}
+	form.Close()
+	return &body, form.FormDataContentType()
+}
+
+func TestProductImages(t *testing.T) {
+	ctx := context.Background()
+	products := NewMemoryStore()
+	images := NewMemoryImageStore(products)
+	blobs := media.NewS3Store(media.NewMemoryS3(), "media", "https://cdn.example.com")
+	lamp, _ := products.Create(ctx, Product{Name: "Lamp", Price: moneytest.USD(2500)})
+
+	h := NewImageHandler(products, images, blobs, nil)
+	r := chi.NewRouter()
+	r.Get("/products/{id}", NewHandler(products, NewMemoryCategoryStore(), images, nil, nil, nil).Get)
+	r.Post("/products/{id}/images", h.Upload)
+	r.Put("/products/{id}/images/order", h.Reorder)
+	r.Delete("/products/{id}/images/{imageID}", h.Delete)
+
+	base := fmt.Sprintf("/products/%d", lamp.ID)
+	upload := func(data []byte, alt string) *httptest.ResponseRecorder {
+		body, contentType := multipartImage(t, data, alt)
+		req := httptest.NewRequest(http.MethodPost, base+"/images", body)
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		alt        string
+		wantStatus int
+	}{
+		{name: "not an image", data: []byte("GIF? no, plain text"), wantStatus: http.StatusUnsupportedMediaType},
+		{name: "missing file", wantStatus: http.StatusBadRequest},
+		{name: "alt too long", data: pngBytes(t, 10, 10), alt: strings.Repeat("a", 201), wantStatus: http.StatusBadRequest},
+		{name: "front", data: pngBytes(t, 640, 480), alt: "Front", wantStatus: http.StatusCreated},
+		{name: "side", data: pngBytes(t, 100, 100), alt: "Side", wantStatus: http.StatusCreated},
+	}
+
+	var uploaded []Image
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := upload(tt.data, tt.alt)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code == http.StatusCreated {
+				var img Image
+				json.NewDecoder(rec.Body).Decode(&img)
+				uploaded = append(uploaded, img)
+			}
+		})
+	}
+
+	front, side := uploaded[0], uploaded[1]
+	if front.Width != 640 || front.Height != 480 || front.ContentType != "image/png" || front.Position != 0 || side.Position != 1 {
+		t.Fatalf("unexpected images %+v", uploaded)
+	}
+	if !strings.HasPrefix(front.URL, "https://cdn.example.com/products/") || !strings.Contains(front.ThumbnailURL, "_thumb") {
+		t.Fatalf("unexpected URLs %s and %s", front.URL, front.ThumbnailURL)
+	}
+
+	getImages := func() []Image {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, base, nil))
+		var p Product
+		json.NewDecoder(rec.Body).Decode(&p)
+		return p.Images
+	}
+
+	if got := getImages(); len(got) != 2 || got[0].ID != front.ID {
+		t.Fatalf("expected the product to list both images in upload order, got %+v", got)
+	}
+
+	reorder := func(body string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, base+"/images/order", strings.NewReader(body)))
+		return rec.Code
+	}
+	if code := reorder(fmt.Sprintf(`{"image_ids":[%d]}`, side.ID)); code != http.StatusBadRequest {
+		t.Fatalf("expected a partial order to be rejected, got %d", code)
+	}
+	if code := reorder(fmt.Sprintf(`{"image_ids":[%d,%d]}`, side.ID, front.ID)); code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, code)
+	}
+	if got := getImages(); got[0].ID != side.ID || got[1].ID != front.ID {
+		t.Fatalf("expected the side image first, got %+v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("%s/images/%d", base, side.ID), nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if got := getImages(); len(got) != 1 || got[0].ID != front.ID || got[0].Position != 0 {
+		t.Fatalf("expected only the front image at position 0, got %+v", got)
+	}
+	stored, _ := images.List(ctx, lamp.ID)
+	if _, err := blobs.Get(ctx, stored[0].Key); err != nil {
+		t.Fatalf("expected the remaining image's blob to exist: %v", err)
+	}
+}
+
+func TestImageChangesETag(t *testing.T) {
+	ctx := context.Background()
+	products := NewMemoryStore()
+	images := NewMemoryImageStore(products)
+	lamp, _ := products.Create(ctx, Product{Name: "Lamp", Price: moneytest.USD(2500)})
+
+	h := NewImageHandler(products, images, media.NewS3Store(media.NewMemoryS3(), "media", "https://cdn.example.com"), nil)
+	r := chi.NewRouter()
+	r.Get("/products/{id}", NewHandler(products, NewMemoryCategoryStore(), images, nil, nil, nil).Get)
+	r.Post("/products/{id}/images", h.Upload)
+
+	base := fmt.Sprintf("/products/%d", lamp.ID)
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, base, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	etag := get("").Header().Get("ETag")
+	if rec := get(etag); rec.Code != http.StatusNotModified {
+		t.Fatalf("expected %d, got %d", http.StatusNotModified, rec.Code)
+	}
+
+	body, contentType := multipartImage(t, pngBytes(t, 10, 10), "Front")
+	req := httptest.NewRequest(http.MethodPost, base+"/images", body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	rec = get(etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d after an image upload, got %d", http.StatusOK, rec.Code)
+	}
+	if got := rec.Header().Get("ETag"); got == etag {
+		t.Fatalf("expected a new ETag after an image upload, got %s", got)
+	}
+}
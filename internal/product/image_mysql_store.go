@@ -0,0 +1,218 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+const imageColumns = "id, product_id, url, thumbnail_url, alt, content_type, width, height, size, position, blob_key, thumbnail_key, created_at"
+
+type MySQLImageStore struct {
+	db *sql.DB
+}
+
+func NewMySQLImageStore(db *sql.DB) *MySQLImageStore {
+	return &MySQLImageStore{db: db}
+}
+
+func scanImage(row rowScanner) (Image, error) {
+	var img Image
+	err := row.Scan(&img.ID, &img.ProductID, &img.URL, &img.ThumbnailURL, &img.Alt, &img.ContentType,
+		&img.Width, &img.Height, &img.Size, &img.Position, &img.Key, &img.ThumbnailKey, &img.CreatedAt)
+	return img, err
+}
+
+// lockProduct serializes image changes for one product so positions stay
+// dense under concurrent uploads. It also bumps the product's version, since
+// the images are part of the product's representation and its ETag.
+func lockProduct(ctx context.Context, tx *sql.Tx, productID int) error {
+	var exists int
+	err := tx.QueryRowContext(ctx,
+		"SELECT 1 FROM products WHERE id = ? FOR UPDATE", productID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("product %d not found", productID)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE products SET version = version + 1 WHERE id = ?", productID)
+	return err
+}
+
+func (s *MySQLImageStore) Add(ctx context.Context, img Image) (Image, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Image{}, err
+	}
+	defer tx.Rollback()
+
+	if err := lockProduct(ctx, tx, img.ProductID); err != nil {
+		return Image{}, err
+	}
+
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM product_images WHERE product_id = ?", img.ProductID).Scan(&img.Position)
+	if err != nil {
+		return Image{}, err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO product_images (product_id, url, thumbnail_url, alt, content_type, width, height, size, position, blob_key, thumbnail_key) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		img.ProductID, img.URL, img.ThumbnailURL, img.Alt, img.ContentType, img.Width, img.Height, img.Size, img.Position, img.Key, img.ThumbnailKey,
+	)
+	if err != nil {
+		return Image{}, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Image{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Image{}, err
+	}
+
+	return s.Get(ctx, int(id))
+}
+
+func (s *MySQLImageStore) Get(ctx context.Context, id int) (Image, error) {
+	img, err := scanImage(s.db.QueryRowContext(ctx,
+		"SELECT "+imageColumns+" FROM product_images WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Image{}, fmt.Errorf("%w: %d", ErrImageNotFound, id)
+	}
+	return img, err
+}
+
+func (s *MySQLImageStore) List(ctx context.Context, productID int) ([]Image, error) {
+	byProduct, err := s.ListByProducts(ctx, []int{productID})
+	if err != nil {
+		return nil, err
+	}
+	return byProduct[productID], nil
+}
+
+func (s *MySQLImageStore) ListByProducts(ctx context.Context, productIDs []int) (map[int][]Image, error) {
+	byProduct := make(map[int][]Image, len(productIDs))
+	if len(productIDs) == 0 {
+		return byProduct, nil
+	}
+
+	args := make([]any, len(productIDs))
+	for i, id := range productIDs {
+		args[i] = id
+		byProduct[id] = []Image{}
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+imageColumns+" FROM product_images WHERE product_id IN ("+placeholders(len(args))+") ORDER BY product_id, position",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		byProduct[img.ProductID] = append(byProduct[img.ProductID], img)
+	}
+
+	return byProduct, rows.Err()
+}
+
+func (s *MySQLImageStore) Reorder(ctx context.Context, productID int, ids []int) ([]Image, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockProduct(ctx, tx, productID); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT "+imageColumns+" FROM product_images WHERE product_id = ?", productID)
+	if err != nil {
+		return nil, err
+	}
+	var images []Image
+	for rows.Next() {
+		img, err := scanImage(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	positions, err := imagePositions(images, ids)
+	if err != nil {
+		return nil, err
+	}
+	for id, pos := range positions {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE product_images SET position = ? WHERE id = ?", pos, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.List(ctx, productID)
+}
+
+func (s *MySQLImageStore) Delete(ctx context.Context, id int) error {
+	img, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockProduct(ctx, tx, img.ProductID); err != nil {
+		return err
+	}
+
+	// Read the position again under the lock in case a concurrent delete
+	// moved it.
+	err = tx.QueryRowContext(ctx,
+		"SELECT position FROM product_images WHERE id = ?", id).Scan(&img.Position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrImageNotFound, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_images WHERE id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE product_images SET position = position - 1 WHERE product_id = ? AND position > ?",
+		img.ProductID, img.Position,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
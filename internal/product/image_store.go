@@ -0,0 +1,156 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ImageStore keeps image metadata; the files themselves live in a
+// media.BlobStore. Images are always returned in position order.
+type ImageStore interface {
+	// Add appends the image after the product's existing images.
+	Add(ctx context.Context, img Image) (Image, error)
+	Get(ctx context.Context, id int) (Image, error)
+	List(ctx context.Context, productID int) ([]Image, error)
+	// ListByProducts returns the images of several products at once, keyed
+	// by product ID.
+	ListByProducts(ctx context.Context, productIDs []int) (map[int][]Image, error)
+	// Reorder sets positions from ids, which must hold each of the product's
+	// image IDs once, and fails with ErrImageOrder otherwise.
+	Reorder(ctx context.Context, productID int, ids []int) ([]Image, error)
+	// Delete removes the image and closes the gap in positions.
+	Delete(ctx context.Context, id int) error
+}
+
+// MemoryImageStore bumps the version of the product in products on every
+// write, like the MySQL store does in the same transaction.
+type MemoryImageStore struct {
+	images   []Image
+	nextID   int
+	products *MemoryStore
+	mu       sync.RWMutex
+}
+
+func NewMemoryImageStore(products *MemoryStore) *MemoryImageStore {
+	return &MemoryImageStore{nextID: 1, products: products}
+}
+
+func (s *MemoryImageStore) Add(ctx context.Context, img Image) (Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img.ID = s.nextID
+	s.nextID++
+	img.Position = len(s.list(img.ProductID))
+	img.CreatedAt = time.Now().UTC()
+	s.images = append(s.images, img)
+	s.products.touch(img.ProductID)
+	return img, nil
+}
+
+func (s *MemoryImageStore) Get(ctx context.Context, id int) (Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, img := range s.images {
+		if img.ID == id {
+			return img, nil
+		}
+	}
+	return Image{}, fmt.Errorf("%w: %d", ErrImageNotFound, id)
+}
+
+func (s *MemoryImageStore) List(ctx context.Context, productID int) ([]Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.list(productID), nil
+}
+
+func (s *MemoryImageStore) ListByProducts(ctx context.Context, productIDs []int) (map[int][]Image, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byProduct := make(map[int][]Image, len(productIDs))
+	for _, id := range productIDs {
+		byProduct[id] = s.list(id)
+	}
+	return byProduct, nil
+}
+
+// list returns a copy of the product's images in order. The caller must
+// hold the lock.
+func (s *MemoryImageStore) list(productID int) []Image {
+	images := []Image{}
+	for _, img := range s.images {
+		if img.ProductID == productID {
+			images = append(images, img)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Position < images[j].Position
+	})
+	return images
+}
+
+func (s *MemoryImageStore) Reorder(ctx context.Context, productID int, ids []int) ([]Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	positions, err := imagePositions(s.list(productID), ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range s.images {
+		if pos, ok := positions[s.images[i].ID]; ok {
+			s.images[i].Position = pos
+		}
+	}
+	s.products.touch(productID)
+	return s.list(productID), nil
+}
+
+func (s *MemoryImageStore) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, img := range s.images {
+		if img.ID != id {
+			continue
+		}
+		s.images = append(s.images[:i], s.images[i+1:]...)
+		for j := range s.images {
+			if s.images[j].ProductID == img.ProductID && s.images[j].Position > img.Position {
+				s.images[j].Position--
+			}
+		}
+		s.products.touch(img.ProductID)
+		return nil
+	}
+	return fmt.Errorf("%w: %d", ErrImageNotFound, id)
+}
+
+// imagePositions checks ids is a permutation of the images' IDs and maps
+// each ID to its new position.
+func imagePositions(images []Image, ids []int) (map[int]int, error) {
+	if len(ids) != len(images) {
+		return nil, ErrImageOrder
+	}
+
+	owned := make(map[int]bool, len(images))
+	for _, img := range images {
+		owned[img.ID] = true
+	}
+
+	positions := make(map[int]int, len(ids))
+	for i, id := range ids {
+		if _, seen := positions[id]; seen || !owned[id] {
+			return nil, ErrImageOrder
+		}
+		positions[id] = i
+	}
+	return positions, nil
+}
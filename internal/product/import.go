@@ -0,0 +1,290 @@
+package product
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"lukekorsman.com/store/internal/money"
+)
+
+const (
+	// MaxImportSize and MaxImportRows bound an import, which is applied in a
+	// single transaction.
+	MaxImportSize = 10 << 20
+	MaxImportRows = 10_000
+)
+
+type ImportFormat string
+
+const (
+	FormatCSV   ImportFormat = "csv"
+	FormatJSONL ImportFormat = "jsonl"
+)
+
+// csvColumns are the columns of an exported CSV file. Imports accept them in
+// any order; name and price are required and id is ignored.
+var csvColumns = []string{"id", "sku", "name", "description", "price", "category_id", "tags", "prices"}
+
+type ImportOptions struct {
+	// Upsert updates the product that already has a row's SKU instead of
+	// rejecting the row.
+	Upsert bool
+	// DryRun reports what an import would do without saving anything.
+	DryRun bool
+}
+
+// ImportRow is one product read from an import file. Line is the line it
+// starts on, counting a CSV header.
+type ImportRow struct {
+	Line    int
+	Product Product
+}
+
+type ImportAction string
+
+const (
+	ImportCreated ImportAction = "created"
+	ImportUpdated ImportAction = "updated"
+)
+
+// ImportedRow is the outcome of one row. ID is 0 for products a dry run
+// would create.
+type ImportedRow struct {
+	Line   int          `json:"line"`
+	ID     int          `json:"id,omitempty"`
+	SKU    string       `json:"sku,omitempty"`
+	Action ImportAction `json:"action"`
+	// Price and OldPrice let the handler record price history.
+	Price    money.Money  `json:"-"`
+	OldPrice *money.Money `json:"-"`
+}
+
+type ImportError struct {
+	Line   int               `json:"line"`
+	SKU    string            `json:"sku,omitempty"`
+	Errors []ValidationError `json:"errors"`
+}
+
+// ImportResult reports an import. Nothing is saved when Errors is non-empty.
+type ImportResult struct {
+	DryRun  bool          `json:"dry_run"`
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Rows    []ImportedRow `json:"rows"`
+	Errors  []ImportError `json:"errors"`
+}
+
+func (r *ImportResult) addRow(row ImportedRow) {
+	r.Rows = append(r.Rows, row)
+	if row.Action == ImportCreated {
+		r.Created++
+	} else {
+		r.Updated++
+	}
+}
+
+func (r *ImportResult) addError(line int, sku string, errs ...ValidationError) {
+	r.Errors = append(r.Errors, ImportError{Line: line, SKU: sku, Errors: errs})
+}
+
+// failed clears the outcomes of a rejected import so only its errors are
+// reported.
+func (r *ImportResult) failed() ImportResult {
+	slices.SortStableFunc(r.Errors, func(a, b ImportError) int { return a.Line - b.Line })
+	return ImportResult{DryRun: r.DryRun, Rows: []ImportedRow{}, Errors: r.Errors}
+}
+
+// importConflict explains why a row can't be applied to the product that
+// already has its SKU, or returns "" if it can.
+func importConflict(existing Product, upsert bool) string {
+	switch {
+	case existing.Archived():
+		return fmt.Sprintf("sku %q belongs to deleted product %d; restore it first", existing.SKU, existing.ID)
+	case !upsert:
+		return fmt.Sprintf("sku %q is already used by product %d", existing.SKU, existing.ID)
+	}
+	return ""
+}
+
+// importReader yields the rows of an import file. A row that can't be
+// decoded comes back with errors instead of failing the whole file; err is
+// only set for unreadable files and io.EOF.
+type importReader interface {
+	Next() (row ImportRow, errs []ValidationError, err error)
+}
+
+func newImportReader(format ImportFormat, r io.Reader) (importReader, error) {
+	if format == FormatJSONL {
+		return &jsonlReader{r: bufio.NewReader(r)}, nil
+	}
+	return newCSVReader(r)
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(csvColumns, name) {
+			return nil, fmt.Errorf("unknown CSV column %q; expected %s", name, strings.Join(csvColumns, ", "))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("CSV column %q appears more than once", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"name", "price"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV column %q is required", name)
+		}
+	}
+
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (c *csvReader) Next() (ImportRow, []ValidationError, error) {
+	record, err := c.r.Read()
+	if err == io.EOF {
+		return ImportRow{}, nil, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) && errors.Is(err, csv.ErrFieldCount) {
+		return ImportRow{Line: parseErr.StartLine}, []ValidationError{{
+			Field:   "row",
+			Message: fmt.Sprintf("expected %d fields, got %d", len(c.columns), len(record)),
+		}}, nil
+	}
+	if err != nil {
+		return ImportRow{}, nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	line, _ := c.r.FieldPos(0)
+	row := ImportRow{Line: line}
+	field := func(name string) string {
+		if i, ok := c.columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var errs []ValidationError
+	p := &row.Product
+	p.SKU = field("sku")
+	p.Name = field("name")
+	p.Description = field("description")
+
+	if p.Price, err = money.Parse(field("price"), BaseCurrency); err != nil {
+		errs = append(errs, ValidationError{
+			Field:   "price",
+			Message: "price must be a decimal amount such as 19.99",
+		})
+	}
+
+	if v := field("category_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "category_id",
+				Message: "category_id must be an integer",
+			})
+		}
+		p.CategoryID = &id
+	}
+
+	if v := field("tags"); v != "" {
+		p.Tags = strings.Split(v, "|")
+	}
+
+	if v := field("prices"); v != "" {
+		for _, entry := range strings.Split(v, "|") {
+			currency, amount, _ := strings.Cut(strings.TrimSpace(entry), ":")
+			price, err := money.Parse(amount, money.Currency(strings.ToUpper(currency)))
+			if err != nil {
+				errs = append(errs, ValidationError{
+					Field:   "prices",
+					Message: fmt.Sprintf("%q must look like EUR:18.50", entry),
+				})
+				continue
+			}
+			p.Prices = append(p.Prices, price)
+		}
+	}
+
+	return row, errs, nil
+}
+
+type jsonlReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (j *jsonlReader) Next() (ImportRow, []ValidationError, error) {
+	for {
+		data, err := j.r.ReadBytes('\n')
+		if len(data) == 0 && err == io.EOF {
+			return ImportRow{}, nil, io.EOF
+		}
+		if err != nil && err != io.EOF {
+			return ImportRow{}, nil, err
+		}
+		j.line++
+
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+
+		row := ImportRow{Line: j.line}
+		if err := json.Unmarshal(data, &row.Product); err != nil {
+			return row, []ValidationError{{Field: "row", Message: "invalid JSON: " + err.Error()}}, nil
+		}
+		row.Product.SKU = strings.TrimSpace(row.Product.SKU)
+		return row, nil, nil
+	}
+}
+
+// writeCSVRecord writes p in the column order of csvColumns.
+func writeCSVRecord(w *csv.Writer, p Product) error {
+	var categoryID string
+	if p.CategoryID != nil {
+		categoryID = strconv.Itoa(*p.CategoryID)
+	}
+	prices := make([]string, len(p.Prices))
+	for i, price := range p.Prices {
+		prices[i] = string(price.Currency) + ":" + price.Decimal()
+	}
+
+	return w.Write([]string{
+		strconv.Itoa(p.ID),
+		p.SKU,
+		p.Name,
+		p.Description,
+		p.Price.Decimal(),
+		categoryID,
+		strings.Join(p.Tags, "|"),
+		strings.Join(prices, "|"),
+	})
+}
@@ -0,0 +1,355 @@
+package product
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"lukekorsman.com/store/internal/metrics"
+)
+
+// exportPageSize is how many products Export loads at a time.
+const exportPageSize = 500
+
+// Import reads a CSV or JSON Lines file of products from the request body.
+// Every row is validated and all problems are reported together; the rows
+// are only saved if there are none.
+//
+// Query parameters: format (csv or jsonl, otherwise taken from the
+// Content-Type), mode (create or upsert), dry_run and async. With async the
+// file is imported by a background job and the response points at it.
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	req, errs := parseImportRequest(r)
+	if req.Async && h.jobs == nil {
+		errs = append(errs, ValidationError{Field: "async", Message: "async imports are not enabled"})
+	}
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+	if req.Format == "" {
+		http.Error(w, "send text/csv or application/x-ndjson, or set format to csv or jsonl", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportSize)
+	if req.Async {
+		h.enqueueImport(w, r, req)
+		return
+	}
+
+	result, err := h.importProducts(r.Context(), req.Format, r.Body, req.Options)
+	var fileErr importFileError
+	if errors.As(err, &fileErr) {
+		importReadError(w, fileErr.err)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(result.Errors) > 0 {
+		writeJSON(w, http.StatusBadRequest, result)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// errTooManyRows is reported for a file with more than MaxImportRows rows.
+var errTooManyRows = fmt.Errorf("an import can have at most %d rows", MaxImportRows)
+
+// importFileError is a problem with the file as a whole, such as a missing
+// header or a line that can't be parsed, rather than with one of its rows.
+type importFileError struct {
+	err error
+}
+
+func (e importFileError) Error() string { return e.err.Error() }
+func (e importFileError) Unwrap() error { return e.err }
+
+// importProducts validates every row read from body and saves them if there
+// were no problems. Problems with rows are reported in the result; the error
+// is an importFileError if the file couldn't be read.
+func (h *Handler) importProducts(ctx context.Context, format ImportFormat, body io.Reader, opts ImportOptions) (ImportResult, error) {
+	reader, err := newImportReader(format, body)
+	if err != nil {
+		return ImportResult{}, importFileError{err: err}
+	}
+
+	categories, err := h.categories.List(ctx)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	var result ImportResult
+	var rows []ImportRow
+	skuLines := make(map[string]int)
+	for {
+		row, rowErrs, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ImportResult{}, importFileError{err: err}
+		}
+		if len(rows)+len(result.Errors) >= MaxImportRows {
+			return ImportResult{}, importFileError{err: errTooManyRows}
+		}
+
+		p := &row.Product
+		p.Tags = NormalizeTags(p.Tags)
+		normalizePrices(p)
+		if len(rowErrs) == 0 {
+			rowErrs = ValidateProduct(*p)
+			if p.CategoryID != nil {
+				rowErrs = append(rowErrs, validateCategoryRef(categories, "category_id", p.CategoryID)...)
+			}
+		}
+
+		if sku := strings.ToUpper(p.SKU); sku != "" {
+			if line, ok := skuLines[sku]; ok {
+				rowErrs = append(rowErrs, ValidationError{
+					Field:   "sku",
+					Message: fmt.Sprintf("sku %q is also on line %d", p.SKU, line),
+				})
+			} else {
+				skuLines[sku] = row.Line
+			}
+		}
+
+		if len(rowErrs) > 0 {
+			result.addError(row.Line, p.SKU, rowErrs...)
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	// Rows that failed validation are left out, but the rest still go through
+	// a dry run so conflicts with the catalog are reported in the same pass.
+	invalid := result.Errors
+	stored, err := h.store.Import(ctx, rows, ImportOptions{Upsert: opts.Upsert, DryRun: opts.DryRun || len(invalid) > 0})
+	if err != nil {
+		return ImportResult{}, err
+	}
+	result = stored
+	result.DryRun = opts.DryRun
+	if len(invalid) > 0 {
+		result.Errors = append(result.Errors, invalid...)
+		result = result.failed()
+	}
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	if !opts.DryRun {
+		h.recordImport(ctx, result, rows)
+	}
+	result.Errors = []ImportError{}
+	return result, nil
+}
+
+// recordImport updates metrics, price history, change hooks and the cache
+// after a saved import. Changes carry the imported values but not the ones
+// they replaced, which the import doesn't load.
+func (h *Handler) recordImport(ctx context.Context, result ImportResult, rows []ImportRow) {
+	byLine := make(map[int]Product, len(rows))
+	for _, row := range rows {
+		byLine[row.Line] = row.Product
+	}
+
+	ids := make([]int, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		ids = append(ids, row.ID)
+		if row.OldPrice == nil || *row.OldPrice != row.Price {
+			h.recordPrice(ctx, PriceChange{ProductID: row.ID, OldPrice: row.OldPrice, NewPrice: row.Price})
+		}
+
+		after := byLine[row.Line]
+		after.ID = row.ID
+		action := ProductCreated
+		if row.Action == ImportUpdated {
+			action = ProductUpdated
+		}
+		h.changed(ctx, ProductChange{Action: action, ProductID: row.ID, After: &after})
+	}
+
+	metrics.ProductsCreated.Add(float64(result.Created))
+	h.invalidate(ctx, ids...)
+}
+
+type importRequest struct {
+	Format  ImportFormat
+	Options ImportOptions
+	Async   bool
+}
+
+func parseImportRequest(r *http.Request) (importRequest, []ValidationError) {
+	q := r.URL.Query()
+	var req importRequest
+	var errs []ValidationError
+
+	req.Format = ImportFormat(strings.ToLower(q.Get("format")))
+	switch req.Format {
+	case FormatCSV, FormatJSONL:
+	case "":
+		req.Format = formatFromContentType(r.Header.Get("Content-Type"))
+	default:
+		errs = append(errs, ValidationError{Field: "format", Message: "format must be csv or jsonl"})
+	}
+
+	switch q.Get("mode") {
+	case "", "create":
+	case "upsert":
+		req.Options.Upsert = true
+	default:
+		errs = append(errs, ValidationError{Field: "mode", Message: "mode must be create or upsert"})
+	}
+
+	if v := q.Get("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, ValidationError{Field: "dry_run", Message: "dry_run must be true or false"})
+		}
+		req.Options.DryRun = dryRun
+	}
+
+	if v := q.Get("async"); v != "" {
+		async, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, ValidationError{Field: "async", Message: "async must be true or false"})
+		}
+		req.Async = async
+	}
+
+	return req, errs
+}
+
+func formatFromContentType(contentType string) ImportFormat {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return FormatCSV
+	case "application/x-ndjson", "application/jsonl", "application/jsonlines":
+		return FormatJSONL
+	}
+	return ""
+}
+
+func importReadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("import must be %d bytes or less", MaxImportSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, errTooManyRows) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
+// Export streams the catalog as CSV (the default) or JSON Lines, one page of
+// products at a time so it is never held in memory at once. Products
+// changed while an export runs may be missed or repeated.
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormat(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", exportContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+
+	started := false
+	flusher, _ := w.(http.Flusher)
+	err := h.exportProducts(r.Context(), w, format, func(exported, total int) {
+		started = true
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+	if err != nil && !started {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		// The status line has already gone out, so all that's left is to
+		// cut the file short.
+		fmt.Printf("Failed to export products: %v\n", err)
+	}
+}
+
+// exportFormat reads the format query parameter, answering 400 if it isn't
+// csv or jsonl.
+func exportFormat(w http.ResponseWriter, r *http.Request) (ImportFormat, bool) {
+	format := ImportFormat(strings.ToLower(r.URL.Query().Get("format")))
+	switch format {
+	case "":
+		return FormatCSV, true
+	case FormatCSV, FormatJSONL:
+		return format, true
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"errors": []ValidationError{{Field: "format", Message: "format must be csv or jsonl"}},
+	})
+	return "", false
+}
+
+func exportContentType(format ImportFormat) string {
+	if format == FormatJSONL {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// exportProducts writes the catalog to w a page at a time, calling afterPage
+// with the number of products written so far and the total once each page
+// is out.
+func (h *Handler) exportProducts(ctx context.Context, w io.Writer, format ImportFormat, afterPage func(exported, total int)) error {
+	csvWriter := csv.NewWriter(w)
+	encoder := json.NewEncoder(w)
+	if format == FormatCSV {
+		csvWriter.Write(csvColumns)
+	}
+
+	exported := 0
+	for offset := 0; ; offset += exportPageSize {
+		page, err := h.store.List(ctx, ListOptions{Limit: exportPageSize, Offset: offset})
+		if err == nil && format == FormatJSONL {
+			err = h.attachImages(ctx, page.Products)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, p := range page.Products {
+			if format == FormatCSV {
+				err = writeCSVRecord(csvWriter, p)
+			} else {
+				err = encoder.Encode(p)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+
+		exported += len(page.Products)
+		afterPage(exported, page.Total)
+		if len(page.Products) < exportPageSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/jobs"
+	"lukekorsman.com/store/internal/media"
+	"lukekorsman.com/store/internal/money/moneytest"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newImportRouter(products Store, categories CategoryStore, history PriceHistoryStore) http.Handler {
+	h := NewHandler(products, categories, nil, history, nil, nil)
+	r := chi.NewRouter()
+	r.Post("/products/import", h.Import)
+	r.Get("/products/export", h.Export)
+	return r
+}
+
+func doImport(t *testing.T, router http.Handler, query, contentType, body string) (*httptest.ResponseRecorder, ImportResult) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/products/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var result ImportResult
+	if strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		json.NewDecoder(rec.Body).Decode(&result)
+	}
+	return rec, result
+}
+
+func TestImportProducts(t *testing.T) {
+	ctx := context.Background()
+	products := NewMemoryStore()
+	history := NewMemoryPriceHistoryStore()
+	router := newImportRouter(products, NewMemoryCategoryStore(), history)
+	products.Create(ctx, Product{SKU: "LAMP-1", Name: "Lamp", Price: moneytest.USD(2500)})
+
+	const csvFile = "sku,name,price,tags,prices\n" +
+		"DESK-1,Desk,199.00,office|wood,EUR:180.00\n" +
+		"lamp-1,Desk Lamp,22.50,,\n"
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCreated int
+		wantUpdated int
+		// wantErrors lists "line:field" for every reported problem.
+		wantErrors []string
+	}{
+		{
+			name:        "existing sku without upsert",
+			contentType: "text/csv",
+			body:        csvFile,
+			wantStatus:  http.StatusBadRequest,
+			wantErrors:  []string{"3:sku"},
+		},
+		{
+			name:        "invalid rows reported together",
+			query:       "?mode=upsert",
+			contentType: "text/csv",
+			body: "name,price,sku\n" +
+				",10.00,A-1\n" +
+				"Chair,ten,B-1\n" +
+				"Stool,5.00,b-1\n" +
+				"Bench,5.00\n",
+			wantStatus: http.StatusBadRequest,
+			wantErrors: []string{"2:name", "3:price", "4:sku", "5:row"},
+		},
+		{
+			name:        "dry run",
+			query:       "?mode=upsert&dry_run=true",
+			contentType: "text/csv; charset=utf-8",
+			body:        csvFile,
+			wantStatus:  http.StatusOK,
+			wantCreated: 1,
+			wantUpdated: 1,
+		},
+		{
+			name:        "upsert",
+			query:       "?mode=upsert",
+			contentType: "text/csv",
+			body:        csvFile,
+			wantStatus:  http.StatusOK,
+			wantCreated: 1,
+			wantUpdated: 1,
+		},
+		{
+			name:        "jsonl",
+			query:       "?format=jsonl",
+			contentType: "application/octet-stream",
+			body: `{"sku":"RUG-1","name":"Rug","price":{"amount":8900},"tags":["Home"]}` + "\n\n" +
+				`{"name":"Vase","price":{"amount":1500}}` + "\n",
+			wantStatus:  http.StatusOK,
+			wantCreated: 2,
+		},
+		{
+			name:        "bad jsonl line",
+			contentType: "application/x-ndjson",
+			body:        `{"name":"Mat","price":{"amount":900}}` + "\n" + `{"name":` + "\n",
+			wantStatus:  http.StatusBadRequest,
+			wantErrors:  []string{"2:row"},
+		},
+		{
+			name:        "unknown column",
+			contentType: "text/csv",
+			body:        "name,price,colour\nLamp,1.00,red\n",
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "unknown format",
+			contentType: "application/xml",
+			body:        "<products/>",
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, result := doImport(t, router, tt.query, tt.contentType, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if result.Created != tt.wantCreated || result.Updated != tt.wantUpdated {
+				t.Fatalf("expected %d created and %d updated, got %+v", tt.wantCreated, tt.wantUpdated, result)
+			}
+
+			var gotErrors []string
+			for _, e := range result.Errors {
+				for _, ve := range e.Errors {
+					gotErrors = append(gotErrors, fmt.Sprintf("%d:%s", e.Line, ve.Field))
+				}
+			}
+			if fmt.Sprint(gotErrors) != fmt.Sprint(tt.wantErrors) {
+				t.Fatalf("expected errors %v, got %v", tt.wantErrors, gotErrors)
+			}
+		})
+	}
+
+	all, _ := products.List(ctx, ListOptions{})
+	if all.Total != 4 {
+		t.Fatalf("expected only the upsert and jsonl imports to be saved, got %d products", all.Total)
+	}
+	lamp, _ := products.GetByID(ctx, 1)
+	if lamp.Name != "Desk Lamp" || lamp.Price != moneytest.USD(2250) {
+		t.Fatalf("expected the lamp to be updated by sku, got %+v", lamp)
+	}
+	desk := all.Products[1]
+	if desk.SKU != "DESK-1" || fmt.Sprint(desk.Tags) != "[office wood]" || len(desk.Prices) != 1 || desk.Prices[0].Amount != 18000 {
+		t.Fatalf("unexpected desk %+v", desk)
+	}
+
+	changes, total, _ := history.History(ctx, lamp.ID, 10, 0)
+	if total != 1 || *changes[0].OldPrice != moneytest.USD(2500) {
+		t.Fatalf("expected the lamp's price change to be recorded, got %+v", changes)
+	}
+}
+
+func TestExportProducts(t *testing.T) {
+	ctx := context.Background()
+	products := NewMemoryStore()
+	categories := NewMemoryCategoryStore()
+	router := newImportRouter(products, categories, nil)
+
+	lighting, _ := categories.Create(ctx, Category{Name: "Lighting"})
+	products.Create(ctx, Product{SKU: "LAMP-1", Name: "Lamp, brass", Price: moneytest.USD(2500), CategoryID: &lighting.ID, Tags: []string{"home", "light"}})
+	products.Create(ctx, Product{Name: "Desk", Description: "Oak\ntop", Price: moneytest.USD(19900)})
+	gone, _ := products.Create(ctx, Product{Name: "Stool", Price: moneytest.USD(500)})
+	products.Delete(ctx, gone.ID, 0)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/export", nil))
+	want := "id,sku,name,description,price,category_id,tags,prices\n" +
+		"1,LAMP-1,\"Lamp, brass\",,25.00,1,home|light,\n" +
+		"2,,Desk,\"Oak\ntop\",199.00,,,\n"
+	if rec.Body.String() != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="products.csv"` {
+		t.Fatalf("unexpected Content-Disposition %q", got)
+	}
+
+	// An export can be imported again as-is.
+	if rec, result := doImport(t, router, "?mode=upsert&dry_run=true", "text/csv", rec.Body.String()); rec.Code != http.StatusOK || result.Updated != 1 || result.Created != 1 {
+		t.Fatalf("expected the export to re-import, got %d: %+v", rec.Code, result)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/export?format=jsonl", nil))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	var first Product
+	json.Unmarshal([]byte(lines[0]), &first)
+	if len(lines) != 2 || first.SKU != "LAMP-1" || first.Price != moneytest.USD(2500) {
+		t.Fatalf("unexpected JSON Lines export %q", rec.Body.String())
+	}
+}
+
+func TestAsyncImport(t *testing.T) {
+	ctx := context.Background()
+	products := NewMemoryStore()
+	jobStore := jobs.NewMemoryStore()
+	runner := jobs.NewRunner(jobStore, 1)
+	files := media.NewS3Store(media.NewMemoryS3(), "store", "/media")
+
+	h := NewHandler(products, NewMemoryCategoryStore(), nil, nil, nil, nil)
+	h.EnableJobs(runner, files)
+	router := chi.NewRouter()
+	router.Post("/products/import", h.Import)
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  jobs.Status
+		wantCreated int
+		wantErrors  int
+	}{
+		{
+			name:        "imports in the background",
+			body:        "sku,name,price\nDESK-1,Desk,199.00\nLAMP-1,Lamp,25.00\n",
+			wantStatus:  jobs.StatusSucceeded,
+			wantCreated: 2,
+		},
+		{
+			name:       "row errors fail the job",
+			body:       "sku,name,price\nCHAIR-1,,10.00\n",
+			wantStatus: jobs.StatusFailed,
+			wantErrors: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/products/import?async=true", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "text/csv")
+			req = req.WithContext(auth.ContextWithUser(req.Context(), auth.User{ID: 7}))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			var queued jobs.Job
+			json.NewDecoder(rec.Body).Decode(&queued)
+			if rec.Code != http.StatusAccepted || rec.Header().Get("Location") != fmt.Sprintf("/jobs/%d", queued.ID) {
+				t.Fatalf("expected 202 with the job's location, got %d %q", rec.Code, rec.Header().Get("Location"))
+			}
+			if queued.Status != jobs.StatusQueued || queued.CreatedBy == nil || *queued.CreatedBy != 7 {
+				t.Fatalf("unexpected job %+v", queued)
+			}
+
+			if ran, err := runner.RunNext(ctx); !ran || err != nil {
+				t.Fatalf("expected the job to run, got %v, %v", ran, err)
+			}
+
+			j, _ := jobStore.Get(ctx, queued.ID)
+			var result ImportResult
+			json.Unmarshal(j.Result, &result)
+			if j.Status != tt.wantStatus || result.Created != tt.wantCreated || len(result.Errors) != tt.wantErrors {
+				t.Fatalf("expected %s with %d created and %d errors, got %s: %s", tt.wantStatus, tt.wantCreated, tt.wantErrors, j.Status, j.Result)
+			}
+			if j.Attempts != 1 {
+				t.Fatalf("expected a single attempt, got %d", j.Attempts)
+			}
+
+			var payload importJob
+			json.Unmarshal(j.Payload, &payload)
+			if _, err := files.Get(ctx, payload.File); !errors.Is(err, media.ErrBlobNotFound) {
+				t.Fatalf("expected the uploaded file to be removed, got %v", err)
+			}
+		})
+	}
+
+	page, _ := products.List(ctx, ListOptions{Limit: 10})
+	if page.Total != 2 {
+		t.Fatalf("expected 2 imported products, got %d", page.Total)
+	}
+
+	rec, _ := doImport(t, newImportRouter(products, NewMemoryCategoryStore(), nil), "?async=true", "text/csv", "sku,name,price\n")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a job runner, got %d", rec.Code)
+	}
+}
+
+func TestAsyncImportCancel(t *testing.T) {
+	ctx := context.Background()
+	jobStore := jobs.NewMemoryStore()
+	runner := jobs.NewRunner(jobStore, 1)
+	files := media.NewS3Store(media.NewMemoryS3(), "store", "/media")
+
+	h := NewHandler(NewMemoryStore(), NewMemoryCategoryStore(), nil, nil, nil, nil)
+	h.EnableJobs(runner, files)
+	router := chi.NewRouter()
+	router.Post("/products/import", h.Import)
+
+	req := httptest.NewRequest(http.MethodPost, "/products/import?async=true", strings.NewReader("sku,name,price\nDESK-1,Desk,199.00\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var queued jobs.Job
+	json.NewDecoder(rec.Body).Decode(&queued)
+	var payload importJob
+	json.Unmarshal(queued.Payload, &payload)
+	if _, err := files.Get(ctx, payload.File); err != nil {
+		t.Fatalf("expected the uploaded file to wait for the job, got %v", err)
+	}
+
+	if _, err := runner.Cancel(ctx, queued.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := files.Get(ctx, payload.File); !errors.Is(err, media.ErrBlobNotFound) {
+		t.Fatalf("expected the uploaded file to be removed, got %v", err)
+	}
+}
+
+func TestExportJob(t *testing.T) {
+	ctx := context.Background()
+	products := NewMemoryStore()
+	products.Create(ctx, Product{SKU: "LAMP-1", Name: "Lamp", Price: moneytest.USD(2500)})
+	jobStore := jobs.NewMemoryStore()
+	runner := jobs.NewRunner(jobStore, 1)
+	files := media.NewS3Store(media.NewMemoryS3(), "store", "/media")
+
+	h := NewHandler(products, NewMemoryCategoryStore(), nil, nil, nil, nil)
+	h.EnableJobs(runner, files)
+	router := chi.NewRouter()
+	router.Post("/products/export", h.QueueExport)
+	router.Get("/products/export/{jobID}", h.DownloadExport)
+
+	do := func(method, path string, userID int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req = req.WithContext(auth.ContextWithUser(req.Context(), auth.User{ID: userID}))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/products/export?format=jsonl", 7)
+	var queued jobs.Job
+	json.NewDecoder(rec.Body).Decode(&queued)
+	if rec.Code != http.StatusAccepted || rec.Header().Get("Location") != fmt.Sprintf("/jobs/%d", queued.ID) {
+		t.Fatalf("expected 202 with the job's location, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	download := fmt.Sprintf("/products/export/%d", queued.ID)
+	if rec := do(http.MethodGet, download, 7); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 before the job has run, got %d", rec.Code)
+	}
+
+	if ran, err := runner.RunNext(ctx); !ran || err != nil {
+		t.Fatalf("expected the job to run, got %v, %v", ran, err)
+	}
+	j, _ := jobStore.Get(ctx, queued.ID)
+	var result ExportResult
+	json.Unmarshal(j.Result, &result)
+	if j.Status != jobs.StatusSucceeded || result.Products != 1 || result.URL != download {
+		t.Fatalf("unexpected export job %s: %s", j.Status, j.Result)
+	}
+
+	rec = do(http.MethodGet, download, 7)
+	var p Product
+	json.NewDecoder(rec.Body).Decode(&p)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" || p.SKU != "LAMP-1" {
+		t.Fatalf("expected the exported file, got %d %q: %+v", rec.Code, rec.Header().Get("Content-Type"), p)
+	}
+	if rec := do(http.MethodGet, download, 8); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected other users' exports to be hidden, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/products/export?format=xml", 7); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown format, got %d", rec.Code)
+	}
+}
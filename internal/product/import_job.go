@@ -0,0 +1,160 @@
+package product
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/jobs"
+	"lukekorsman.com/store/internal/media"
+)
+
+// ImportJobType is the job type of imports sent with ?async=true.
+const ImportJobType = "products.import"
+
+// importJob is the payload of an import job. The file itself waits in the
+// handler's jobFiles store until the job is done with it or is cancelled.
+type importJob struct {
+	File   string       `json:"file"`
+	Format ImportFormat `json:"format"`
+	Upsert bool         `json:"upsert"`
+	DryRun bool         `json:"dry_run"`
+}
+
+// EnableJobs lets Import hand files to runner instead of importing them
+// during the request, and lets QueueExport write exports in the background.
+// Uploaded files are kept in files until their job has finished, and
+// exported files are written there.
+func (h *Handler) EnableJobs(runner *jobs.Runner, files media.BlobStore) {
+	h.jobs = runner
+	h.jobFiles = files
+	runner.Register(ImportJobType, h.runImportJob)
+	runner.OnCancel(ImportJobType, h.cancelImportJob)
+	runner.Register(ExportJobType, h.runExportJob)
+}
+
+// enqueueImport saves the uploaded file and queues a job to import it,
+// answering 202 with the job and a Location to poll.
+func (h *Handler) enqueueImport(w http.ResponseWriter, r *http.Request, req importRequest) {
+	ctx := r.Context()
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		importReadError(w, err)
+		return
+	}
+
+	key := media.NewKey("imports", "."+string(req.Format))
+	if err := h.jobFiles.Put(ctx, key, bytes.NewReader(data), int64(len(data)), r.Header.Get("Content-Type")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var createdBy *int
+	if user, ok := auth.UserFromContext(ctx); ok {
+		createdBy = &user.ID
+	}
+	j, err := h.jobs.Enqueue(ctx, ImportJobType, importJob{
+		File:   key,
+		Format: req.Format,
+		Upsert: req.Options.Upsert,
+		DryRun: req.Options.DryRun,
+	}, createdBy)
+	if err != nil {
+		h.removeImportFile(ctx, key)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/jobs/%d", j.ID))
+	writeJSON(w, http.StatusAccepted, j)
+}
+
+// runImportJob imports a queued file. An import with row errors fails
+// without retrying, and the ImportResult listing them becomes the job's
+// result either way.
+func (h *Handler) runImportJob(ctx context.Context, j jobs.Job, progress func(int)) (any, error) {
+	var job importJob
+	if err := json.Unmarshal(j.Payload, &job); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+	// Price history credits the changes to whoever queued the import.
+	if j.CreatedBy != nil {
+		ctx = auth.ContextWithUser(ctx, auth.User{ID: *j.CreatedBy})
+	}
+
+	data, err := h.readImportFile(ctx, job.File)
+	if errors.Is(err, media.ErrBlobNotFound) {
+		return nil, jobs.Permanent(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Reading and validating the file is most of the work; saving the rows
+	// is the rest.
+	body := &progressReader{r: bytes.NewReader(data), progress: func(read int) {
+		progress(read * 80 / max(len(data), 1))
+	}}
+	result, err := h.importProducts(ctx, job.Format, body, ImportOptions{Upsert: job.Upsert, DryRun: job.DryRun})
+	var fileErr importFileError
+	if errors.As(err, &fileErr) {
+		h.removeImportFile(ctx, job.File)
+		return nil, jobs.Permanent(err)
+	}
+	if err != nil {
+		if j.LastAttempt() {
+			h.removeImportFile(ctx, job.File)
+		}
+		return nil, err
+	}
+
+	h.removeImportFile(ctx, job.File)
+	if len(result.Errors) > 0 {
+		return result, jobs.Permanent(fmt.Errorf("%d rows have errors", len(result.Errors)))
+	}
+	return result, nil
+}
+
+// cancelImportJob deletes the file of a cancelled import.
+func (h *Handler) cancelImportJob(ctx context.Context, j jobs.Job) {
+	var job importJob
+	if err := json.Unmarshal(j.Payload, &job); err != nil {
+		return
+	}
+	h.removeImportFile(ctx, job.File)
+}
+
+func (h *Handler) readImportFile(ctx context.Context, key string) ([]byte, error) {
+	f, err := h.jobFiles.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func (h *Handler) removeImportFile(ctx context.Context, key string) {
+	if err := h.jobFiles.Delete(ctx, key); err != nil && !errors.Is(err, media.ErrBlobNotFound) {
+		fmt.Printf("Failed to delete import file %s: %v\n", key, err)
+	}
+}
+
+// progressReader reports how many bytes have been read so far.
+type progressReader struct {
+	r        io.Reader
+	read     int
+	progress func(read int)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += n
+	p.progress(p.read)
+	return n, err
+}
@@ -3,116 +3,899 @@ package product
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
-	_ "github.com/go-sql-driver/mysql"
-	"lukekorsman.com/store/internal/database"
+	"lukekorsman.com/store/internal/money"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 type MySQLStore struct {
 	db *sql.DB
 }
 
-func NewMySQLStore(connStr string) (*MySQLStore, error) {
-	db, err := sql.Open("mysql", connStr)
+// NewMySQLStore wraps a pool opened with database.Open.
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) List(ctx context.Context, opts ListOptions) (ListResult, error) {
+	where, args := listWhere(opts)
+
+	var result ListResult
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products"+where, args...).
+		Scan(&result.Total)
 	if err != nil {
-		return nil, err
+		return ListResult{}, err
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
+	offset := opts.Offset
+	if opts.After != nil {
+		seek, seekArgs := listSeek(opts)
+		if where == "" {
+			where = " WHERE " + seek
+		} else {
+			where += " AND " + seek
+		}
+		args = append(args, seekArgs...)
+		offset = 0
+	}
+
+	query := "SELECT " + productColumns + " FROM products" + where + listOrderBy(opts)
+	if opts.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, opts.Limit, offset)
 	}
 
-    if err := database.RunMigrations(db); err != nil {
-        return nil, fmt.Errorf("migration failed: %w", err)
-    }
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return ListResult{}, err
+	}
+	defer rows.Close()
+
+	result.Products = []Product{}
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return ListResult{}, err
+		}
+		result.Products = append(result.Products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return ListResult{}, err
+	}
+	if opts.After != nil && opts.After.Before {
+		// listOrderBy reversed the order to find the nearest rows first.
+		slices.Reverse(result.Products)
+	}
+
+	if err := s.attachTags(ctx, result.Products); err != nil {
+		return ListResult{}, err
+	}
+	if err := s.attachPrices(ctx, result.Products); err != nil {
+		return ListResult{}, err
+	}
 
-	return &MySQLStore{db: db}, nil
+	return result, nil
 }
 
-func (s *MySQLStore) List(ctx context.Context) ([]Product, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT id, name, price FROM products")
+// listWhere builds the WHERE clause for the option filters. The name filter
+// is a prefix LIKE so it can use idx_products_name.
+func listWhere(opts ListOptions) (string, []any) {
+	var conds []string
+	var args []any
+
+	if !opts.IncludeDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	if opts.MinPrice != nil {
+		conds = append(conds, "price >= ?")
+		args = append(args, opts.MinPrice.Decimal())
+	}
+	if opts.MaxPrice != nil {
+		conds = append(conds, "price <= ?")
+		args = append(args, opts.MaxPrice.Decimal())
+	}
+	if opts.Name != "" {
+		conds = append(conds, "name LIKE ?")
+		args = append(args, likeEscaper.Replace(opts.Name)+"%")
+	}
+	if opts.Tag != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM product_tags t WHERE t.product_id = products.id AND t.tag = ?)")
+		args = append(args, opts.Tag)
+	}
+	if len(opts.CategoryIDs) > 0 {
+		conds = append(conds, "category_id IN ("+placeholders(len(opts.CategoryIDs))+")")
+		for _, id := range opts.CategoryIDs {
+			args = append(args, id)
+		}
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// listOrderBy orders by the sort fields and then ID. Paging backwards from a
+// cursor reverses every term.
+func listOrderBy(opts ListOptions) string {
+	backwards := opts.After != nil && opts.After.Before
+	var terms []string
+	for _, s := range append(slices.Clone(opts.Sort), SortField{Field: "id"}) {
+		term := sortableFields[s.Field]
+		if s.Desc != backwards {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// listSeek builds the condition for the rows past opts.After in the listing
+// order: (a, b, id) > (?, ?, ?) written out term by term, since each sort
+// field can have its own direction.
+func listSeek(opts ListOptions) (string, []any) {
+	key := opts.After.Key
+	terms := append(slices.Clone(opts.Sort), SortField{Field: "id"})
+
+	var alternatives []string
+	var args []any
+	for i, t := range terms {
+		var conds []string
+		for _, prev := range terms[:i] {
+			conds = append(conds, sortableFields[prev.Field]+" = ?")
+			args = append(args, sortValue(key, prev.Field))
+		}
+		op := " > ?"
+		if t.Desc != opts.After.Before {
+			op = " < ?"
+		}
+		conds = append(conds, sortableFields[t.Field]+op)
+		args = append(args, sortValue(key, t.Field))
+		alternatives = append(alternatives, "("+strings.Join(conds, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// productColumns is the column list scanProduct expects.
+const productColumns = "id, COALESCE(sku, ''), version, name, COALESCE(description, ''), price, category_id, created_at, updated_at, deleted_at"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanProduct reads productColumns followed by any extra selected columns
+// into extra.
+func scanProduct(row rowScanner, extra ...any) (Product, error) {
+	var p Product
+	var price string
+	var categoryID sql.NullInt64
+	var deletedAt sql.NullTime
+	dest := append([]any{&p.ID, &p.SKU, &p.Version, &p.Name, &p.Description, &price, &categoryID, &p.CreatedAt, &p.UpdatedAt, &deletedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return Product{}, err
+	}
+
+	var err error
+	if p.Price, err = money.Parse(price, BaseCurrency); err != nil {
+		return Product{}, fmt.Errorf("product %d: %w", p.ID, err)
+	}
+
+	if categoryID.Valid {
+		id := int(categoryID.Int64)
+		p.CategoryID = &id
+	}
+	if deletedAt.Valid {
+		p.DeletedAt = &deletedAt.Time
+	}
+	p.Tags = []string{}
+	return p, nil
+}
+
+// attachTags loads the tags of every product in one query.
+func (s *MySQLStore) attachTags(ctx context.Context, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*Product, len(products))
+	args := make([]any, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+		args[i] = products[i].ID
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT product_id, tag FROM product_tags WHERE product_id IN ("+placeholders(len(args))+") ORDER BY tag",
+		args...,
+	)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var products []Product
 	for rows.Next() {
-		var p Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Price); err != nil {
-			continue 
+		var id int
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return err
 		}
-		products = append(products, p)
+		byID[id].Tags = append(byID[id].Tags, tag)
 	}
 
-	return products, nil
+	return rows.Err()
+}
+
+// attachPrices loads the price lists of every product in one query.
+func (s *MySQLStore) attachPrices(ctx context.Context, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*Product, len(products))
+	args := make([]any, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+		args[i] = products[i].ID
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT product_id, currency, price FROM product_prices WHERE product_id IN ("+placeholders(len(args))+") ORDER BY currency",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var currency, amount string
+		if err := rows.Scan(&id, &currency, &amount); err != nil {
+			return err
+		}
+		price, err := money.Parse(amount, money.Currency(currency))
+		if err != nil {
+			return fmt.Errorf("product %d: %w", id, err)
+		}
+		byID[id].Prices = append(byID[id].Prices, price)
+	}
+
+	return rows.Err()
+}
+
+// replacePrices overwrites the price list of a product inside tx.
+func replacePrices(ctx context.Context, tx *sql.Tx, id int, prices []money.Money) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_prices WHERE product_id = ?", id); err != nil {
+		return err
+	}
+
+	for _, price := range prices {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO product_prices (product_id, currency, price) VALUES (?, ?, ?)",
+			id, price.Currency, price.Decimal()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaceTags overwrites the tags of a product inside tx.
+func replaceTags(ctx context.Context, tx *sql.Tx, id int, tags []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_tags WHERE product_id = ?", id); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO product_tags (product_id, tag) VALUES (?, ?)", id, tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s *MySQLStore) GetByID(ctx context.Context, id int) (Product, error) {
-	var p Product
-	err := s.db.QueryRowContext(ctx, "SELECT id, name, price FROM products WHERE id = ?", id).
-		Scan(&p.ID, &p.Name, &p.Price)
+	p, err := scanProduct(s.db.QueryRowContext(ctx,
+		"SELECT "+productColumns+" FROM products WHERE id = ? AND deleted_at IS NULL", id))
 
 	if err == sql.ErrNoRows {
-		return Product{}, fmt.Errorf("product %d not found", id)
+		return Product{}, fmt.Errorf("%w: %d", ErrProductNotFound, id)
 	}
 	if err != nil {
-		return Product{}, nil
+		return Product{}, err
 	}
 
-	return p, nil
+	products := []Product{p}
+	if err := s.attachTags(ctx, products); err != nil {
+		return Product{}, err
+	}
+	if err := s.attachPrices(ctx, products); err != nil {
+		return Product{}, err
+	}
+
+	return products[0], nil
 }
 
 func (s *MySQLStore) Create(ctx context.Context, p Product) (Product, error) {
-	result, err := s.db.ExecContext( ctx,
-		"INSERT INTO products (name, price) VALUES (?,?)",
-		p.Name, p.Price,
-	)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Product{}, err
+	}
+	defer tx.Rollback()
 
+	id, err := insertProduct(ctx, tx, p)
 	if err != nil {
 		return Product{}, err
 	}
 
-	id, _ := result.LastInsertId()
-	p.ID = int(id)
-	return p, nil
+	if err := tx.Commit(); err != nil {
+		return Product{}, err
+	}
+
+	return s.GetByID(ctx, id)
 }
 
+// Update locks the row before writing so a missing product is detected even
+// when the new values equal the old ones, which MySQL reports as 0 rows
+// affected.
 func (s *MySQLStore) Update(ctx context.Context, id int, p Product) (Product, error) {
-	result, err := s.db.ExecContext(ctx, 
-		"UPDATE products SET name = ?, price = ?, WHERE id = ?",
-		p.Name, p.Price, id, 
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Product{}, err
+	}
+	defer tx.Rollback()
+
+	var version int
+	err = tx.QueryRowContext(ctx,
+		"SELECT version FROM products WHERE id = ? AND deleted_at IS NULL FOR UPDATE", id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return Product{}, fmt.Errorf("product %d not found", id)
+	}
+	if err != nil {
+		return Product{}, err
+	}
+	if p.Version != 0 && p.Version != version {
+		return Product{}, fmt.Errorf("%w: product %d is at version %d", ErrVersionMismatch, id, version)
+	}
+
+	if err := updateProduct(ctx, tx, id, p); err != nil {
+		return Product{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Product{}, err
+	}
+
+	return s.GetByID(ctx, id)
+}
+
+// insertProduct writes a new product with its tags and price list inside tx.
+func insertProduct(ctx context.Context, tx *sql.Tx, p Product) (int, error) {
+	id, err := insertProductRow(ctx, tx, p)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := replaceTags(ctx, tx, id, p.Tags); err != nil {
+		return 0, err
+	}
+	if err := replacePrices(ctx, tx, id, p.Prices); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// insertProductRow writes the products row alone.
+func insertProductRow(ctx context.Context, tx *sql.Tx, p Product) (int, error) {
+	result, err := tx.ExecContext( ctx,
+		"INSERT INTO products (sku, name, description, price, category_id) VALUES (?,?,?,?,?)",
+		skuOrNil(p.SKU), p.Name, p.Description, p.Price.Decimal(), p.CategoryID,
+	)
+	if err != nil {
+		return 0, duplicateSKU(err, p.SKU)
+	}
+
+	id, _ := result.LastInsertId()
+	return int(id), nil
+}
+
+// updateProduct overwrites a product with its tags and price list inside tx.
+func updateProduct(ctx context.Context, tx *sql.Tx, id int, p Product) error {
+	if err := updateProductRow(ctx, tx, id, p); err != nil {
+		return err
+	}
+
+	if err := replaceTags(ctx, tx, id, p.Tags); err != nil {
+		return err
+	}
+	return replacePrices(ctx, tx, id, p.Prices)
+}
+
+// updateProductRow overwrites the products row alone.
+func updateProductRow(ctx context.Context, tx *sql.Tx, id int, p Product) error {
+	_, err := tx.ExecContext(ctx, 
+		"UPDATE products SET sku = ?, name = ?, description = ?, price = ?, category_id = ?, version = version + 1 WHERE id = ?",
+		skuOrNil(p.SKU), p.Name, p.Description, p.Price.Decimal(), p.CategoryID, id, 
 	)
+	return duplicateSKU(err, p.SKU)
+}
+
+// skuOrNil stores a missing SKU as NULL so idx_products_sku allows any
+// number of products without one.
+func skuOrNil(sku string) any {
+	if sku == "" {
+		return nil
+	}
+	return sku
+}
 
+// duplicateSKU turns a unique key violation on idx_products_sku into
+// ErrDuplicateSKU.
+func duplicateSKU(err error, sku string) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
+		return fmt.Errorf("%w: %s", ErrDuplicateSKU, sku)
+	}
+	return err
+}
+
+// Import applies every row in one transaction, locking the products it
+// matches by SKU, and rolls back if any row conflicts or for a dry run.
+func (s *MySQLStore) Import(ctx context.Context, rows []ImportRow, opts ImportOptions) (ImportResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return Product{}, err 
+		return ImportResult{}, err
 	}
+	defer tx.Rollback()
 
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		return Product{}, fmt.Errorf("product %d not found", id)
+	result := ImportResult{DryRun: opts.DryRun, Rows: []ImportedRow{}}
+	for _, row := range rows {
+		p := row.Product
+		imported := ImportedRow{Line: row.Line, SKU: p.SKU, Action: ImportCreated, Price: p.Price}
+
+		existing, err := productBySKU(ctx, tx, p.SKU)
+		switch {
+		case err == nil:
+			if msg := importConflict(existing, opts.Upsert); msg != "" {
+				result.addError(row.Line, p.SKU, ValidationError{Field: "sku", Message: msg})
+				continue
+			}
+			imported.Action = ImportUpdated
+			imported.ID = existing.ID
+			imported.OldPrice = &existing.Price
+			err = updateProduct(ctx, tx, existing.ID, p)
+		case errors.Is(err, sql.ErrNoRows):
+			imported.ID, err = insertProduct(ctx, tx, p)
+		}
+		if errors.Is(err, ErrDuplicateSKU) {
+			result.addError(row.Line, p.SKU, ValidationError{Field: "sku", Message: err.Error()})
+			continue
+		}
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("line %d: %w", row.Line, err)
+		}
+
+		if opts.DryRun && imported.Action == ImportCreated {
+			imported.ID = 0
+		}
+		result.addRow(imported)
+	}
+
+	if len(result.Errors) > 0 {
+		return result.failed(), nil
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
+
+// productBySKU locks the product, archived or not, with the given SKU. A
+// blank SKU never matches.
+func productBySKU(ctx context.Context, tx *sql.Tx, sku string) (Product, error) {
+	if sku == "" {
+		return Product{}, sql.ErrNoRows
 	}
 
-	p.ID = id
+	var p Product
+	var price string
+	var deletedAt sql.NullTime
+	err := tx.QueryRowContext(ctx,
+		"SELECT id, sku, price, deleted_at FROM products WHERE sku = ? FOR UPDATE", sku).
+		Scan(&p.ID, &p.SKU, &price, &deletedAt)
+	if err != nil {
+		return Product{}, err
+	}
+
+	if p.Price, err = money.Parse(price, BaseCurrency); err != nil {
+		return Product{}, fmt.Errorf("product %d: %w", p.ID, err)
+	}
+	if deletedAt.Valid {
+		p.DeletedAt = &deletedAt.Time
+	}
 	return p, nil
 }
 
-func (s *MySQLStore) Delete(ctx context.Context, id int) error {
-	result, err := s.db.ExecContext(ctx,
-		"DELETE FROM products WHERE id = ?", id)
+// Batch runs the operations in one transaction. The products they update or
+// delete are locked up front in one query, each create and update writes
+// its products row as it goes, and tags, prices and deletions are written
+// with multi-row statements at the end. MySQL only rolls back the statement
+// that hit a duplicate SKU, so a best-effort batch can carry on past it.
+func (s *MySQLStore) Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchOutcome, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var ids []int
+	for _, op := range ops {
+		if op.Op != BatchCreate {
+			ids = append(ids, op.ID)
+		}
+	}
+	if err := lockProducts(ctx, tx, ids); err != nil {
+		return nil, err
+	}
+	// Nothing else can write the locked products, so reading them outside
+	// tx sees what the batch will change. current follows along as the
+	// operations change them.
+	current, err := s.productsByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for id, p := range current {
+		if p.Archived() {
+			delete(current, id)
+		}
+	}
+
+	outcomes := make([]BatchOutcome, len(ops))
+	written := make(map[int]Product)
+	var deleted []int
+	failed := false
+	for n, op := range ops {
+		o := &outcomes[n]
+		switch op.Op {
+		case BatchCreate:
+			id, err := insertProductRow(ctx, tx, *op.Product)
+			if errors.Is(err, ErrDuplicateSKU) {
+				o.Err = err
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", n, err)
+			}
+			p := *op.Product
+			p.ID, p.Version = id, 1
+			current[id] = p
+			written[id] = p
+			o.Product = Product{ID: id}
+		case BatchUpdate:
+			before, err := checkVersion(current, op.ID, op.Version)
+			if err != nil {
+				o.Err = err
+				break
+			}
+			err = updateProductRow(ctx, tx, op.ID, *op.Product)
+			if errors.Is(err, ErrDuplicateSKU) {
+				o.Err = err
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", n, err)
+			}
+			p := *op.Product
+			p.ID, p.Version, p.CreatedAt = op.ID, before.Version+1, before.CreatedAt
+			current[op.ID] = p
+			written[op.ID] = p
+			o.Before = &before
+			o.Product = Product{ID: op.ID}
+		case BatchDelete:
+			before, err := checkVersion(current, op.ID, op.Version)
+			if err != nil {
+				o.Err = err
+				break
+			}
+			delete(current, op.ID)
+			deleted = append(deleted, op.ID)
+			o.Before = &before
+			o.Product = Product{ID: op.ID}
+		default:
+			return nil, fmt.Errorf("unknown batch operation %q", op.Op)
+		}
+		failed = failed || o.Err != nil
+	}
+
+	if atomic && failed {
+		return outcomes, nil
+	}
+
+	if err := replaceTagsAndPrices(ctx, tx, written); err != nil {
+		return nil, err
+	}
+	if len(deleted) > 0 {
+		args := make([]any, len(deleted))
+		for i, id := range deleted {
+			args[i] = id
+		}
+		_, err := tx.ExecContext(ctx,
+			"UPDATE products SET deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id IN ("+placeholders(len(args))+")",
+			args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	saved := make([]int, 0, len(written))
+	for id := range written {
+		saved = append(saved, id)
+	}
+	products, err := s.productsByID(ctx, saved)
+	if err != nil {
+		return nil, err
+	}
+	for n, op := range ops {
+		if outcomes[n].Err == nil && op.Op != BatchDelete {
+			outcomes[n].Product = products[outcomes[n].Product.ID]
+		}
+	}
+	return outcomes, nil
+}
+
+// lockProducts locks the rows of the given products in one query.
+func lockProducts(ctx context.Context, tx *sql.Tx, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id FROM products WHERE id IN ("+placeholders(len(args))+") ORDER BY id FOR UPDATE", args...)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// checkVersion finds a product that isn't archived in current, checking its
+// version unless version is 0.
+func checkVersion(current map[int]Product, id, version int) (Product, error) {
+	p, ok := current[id]
+	if !ok {
+		return Product{}, fmt.Errorf("product %d not found", id)
+	}
+	if version != 0 && version != p.Version {
+		return Product{}, fmt.Errorf("%w: product %d is at version %d", ErrVersionMismatch, id, p.Version)
+	}
+	return p, nil
+}
+
+// replaceTagsAndPrices overwrites the tags and price lists of the given
+// products with one DELETE and one INSERT per table.
+func replaceTagsAndPrices(ctx context.Context, tx *sql.Tx, products map[int]Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]any, 0, len(products))
+	var tags, prices []any
+	for id, p := range products {
+		ids = append(ids, id)
+		for _, tag := range p.Tags {
+			tags = append(tags, id, tag)
+		}
+		for _, price := range p.Prices {
+			prices = append(prices, id, price.Currency, price.Decimal())
+		}
+	}
+
+	in := " WHERE product_id IN (" + placeholders(len(ids)) + ")"
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_tags"+in, ids...); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_prices"+in, ids...); err != nil {
+		return err
+	}
+
+	if len(tags) > 0 {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO product_tags (product_id, tag) VALUES "+strings.TrimSuffix(strings.Repeat("(?, ?), ", len(tags)/2), ", "),
+			tags...)
+		if err != nil {
+			return err
+		}
+	}
+	if len(prices) > 0 {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO product_prices (product_id, currency, price) VALUES "+strings.TrimSuffix(strings.Repeat("(?, ?, ?), ", len(prices)/3), ", "),
+			prices...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// productsByID loads the given products, archived or not, with their tags
+// and prices.
+func (s *MySQLStore) productsByID(ctx context.Context, ids []int) (map[int]Product, error) {
+	byID := make(map[int]Product, len(ids))
+	if len(ids) == 0 {
+		return byID, nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+productColumns+" FROM products WHERE id IN ("+placeholders(len(args))+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachTags(ctx, products); err != nil {
+		return nil, err
+	}
+	if err := s.attachPrices(ctx, products); err != nil {
+		return nil, err
+	}
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+	return byID, nil
+}
+
+// Delete archives the product by setting deleted_at. The version check is
+// part of the UPDATE so a concurrent write can't slip in between.
+func (s *MySQLStore) Delete(ctx context.Context, id, version int) error {
+	query := "UPDATE products SET deleted_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = ? AND deleted_at IS NULL"
+	args := []any{id}
+	if version != 0 {
+		query += " AND version = ?"
+		args = append(args, version)
+	}
+	result, err := s.db.ExecContext(ctx, query, args...)
 	if err != nil {
 		return err 
 	}
 
 	rows, _ := result.RowsAffected()
-	if rows == 0 {
+	if rows > 0 {
+		return nil
+	}
+
+	var current int
+	err = s.db.QueryRowContext(ctx,
+		"SELECT version FROM products WHERE id = ? AND deleted_at IS NULL", id).Scan(&current)
+	if err == sql.ErrNoRows {
 		return fmt.Errorf("product %d not found", id)
 	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: product %d is at version %d", ErrVersionMismatch, id, current)
+}
 
-	return nil
+func (s *MySQLStore) Restore(ctx context.Context, id int) (Product, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE products SET deleted_at = NULL, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return Product{}, err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return Product{}, fmt.Errorf("deleted product %d not found", id)
+	}
+
+	return s.GetByID(ctx, id)
+}
+
+// Search runs a FULLTEXT query against ft_products_name_description.
+// Highlights are built in Go from the matched rows since MySQL has no
+// snippet function.
+func (s *MySQLStore) Search(ctx context.Context, opts SearchOptions) (SearchResult, error) {
+	match := "MATCH(name, description) AGAINST (? IN NATURAL LANGUAGE MODE)"
+	if opts.Mode == SearchModeBoolean {
+		match = "MATCH(name, description) AGAINST (? IN BOOLEAN MODE)"
+	}
+
+	var result SearchResult
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products WHERE deleted_at IS NULL AND "+match, opts.Query).
+		Scan(&result.Total)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	query := "SELECT " + productColumns + ", " + match + " AS score " +
+		"FROM products WHERE deleted_at IS NULL AND " + match + " ORDER BY score DESC, id"
+	args := []any{opts.Query, opts.Query}
+	if opts.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, opts.Limit, opts.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	defer rows.Close()
+
+	terms := parseSearchQuery(opts.Query, opts.Mode)
+
+	result.Hits = []SearchHit{}
+	var products []Product
+	var scores []float64
+	for rows.Next() {
+		var score float64
+		p, err := scanProduct(rows, &score)
+		if err != nil {
+			return SearchResult{}, err
+		}
+		products = append(products, p)
+		scores = append(scores, score)
+	}
+	if err := rows.Err(); err != nil {
+		return SearchResult{}, err
+	}
+
+	if err := s.attachTags(ctx, products); err != nil {
+		return SearchResult{}, err
+	}
+	if err := s.attachPrices(ctx, products); err != nil {
+		return SearchResult{}, err
+	}
+
+	for i, p := range products {
+		result.Hits = append(result.Hits, SearchHit{
+			Product:    p,
+			Score:      scores[i],
+			Highlights: highlights(searchFields(p), terms),
+		})
+	}
+
+	return result, nil
 }
 
 func (s *MySQLStore) Close() error {
-    return s.db.Close()
+	return s.db.Close()
 }
\ No newline at end of file
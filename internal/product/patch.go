@@ -0,0 +1,350 @@
+package product
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// ErrPatchTestFailed is returned when a JSON Patch "test" operation doesn't
+// match the product.
+var ErrPatchTestFailed = errors.New("patch test failed")
+
+// patchOperation is one step of an RFC 6902 JSON Patch. Only add, remove,
+// replace and test are supported.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// applyPatch applies a merge patch or a JSON Patch, depending on mediaType,
+// to the JSON form of p and decodes the result. Read-only fields such as id,
+// version and the timestamps keep their values from p.
+func applyPatch(p Product, mediaType string, body io.Reader) (Product, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return Product{}, err
+	}
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return Product{}, err
+	}
+
+	switch mediaType {
+	case JSONPatchContentType:
+		var ops []patchOperation
+		if err := json.NewDecoder(body).Decode(&ops); err != nil {
+			return Product{}, errors.New("a JSON Patch must be an array of operations")
+		}
+		doc, err = applyJSONPatch(doc, ops)
+	default:
+		var patch any
+		if patch, err = decodeJSONReader(body); err != nil {
+			return Product{}, err
+		}
+		if _, ok := patch.(map[string]any); !ok {
+			return Product{}, errors.New("a merge patch must be a JSON object")
+		}
+		doc = mergePatch(doc, patch)
+	}
+	if err != nil {
+		return Product{}, err
+	}
+
+	patched, err := json.Marshal(doc)
+	if err != nil {
+		return Product{}, err
+	}
+	var result Product
+	if err := json.Unmarshal(patched, &result); err != nil {
+		return Product{}, fmt.Errorf("patched product is invalid: %v", err)
+	}
+
+	result.ID = p.ID
+	result.Version = p.Version
+	result.Images = p.Images
+	result.Options = p.Options
+	result.Variants = p.Variants
+	result.CreatedAt = p.CreatedAt
+	result.UpdatedAt = p.UpdatedAt
+	result.DeletedAt = p.DeletedAt
+	return result, nil
+}
+
+// decodeJSON keeps numbers as json.Number so they survive a round trip
+// exactly.
+func decodeJSON(data []byte) (any, error) {
+	return decodeJSONReader(bytes.NewReader(data))
+}
+
+func decodeJSONReader(r io.Reader) (any, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, errors.New("invalid JSON")
+	}
+	return v, nil
+}
+
+// mergePatch applies an RFC 7396 JSON Merge Patch: objects are merged
+// recursively, null removes a member and anything else replaces the target.
+func mergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = make(map[string]any)
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+		} else {
+			targetObj[k] = mergePatch(targetObj[k], v)
+		}
+	}
+	return targetObj
+}
+
+// applyJSONPatch applies RFC 6902 operations in order. If any of them fails
+// the whole patch does.
+func applyJSONPatch(doc any, ops []patchOperation) (any, error) {
+	for i, op := range ops {
+		path, err := parsePointer(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+
+		var value any
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("operation %d: %s needs a value", i, op.Op)
+			}
+			if value, err = decodeJSON(op.Value); err != nil {
+				return nil, fmt.Errorf("operation %d: %v", i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("operation %d: unsupported op %q", i, op.Op)
+		}
+
+		switch op.Op {
+		case "add":
+			doc, err = addValue(doc, path, value)
+		case "remove":
+			doc, err = removeValue(doc, path)
+		case "replace":
+			if len(path) == 0 {
+				doc = value
+			} else if doc, err = removeValue(doc, path); err == nil {
+				doc, err = addValue(doc, path, value)
+			}
+		case "test":
+			var current any
+			current, err = getValue(doc, path)
+			if err == nil && !jsonEqual(current, value) {
+				err = fmt.Errorf("%w: %s", ErrPatchTestFailed, op.Path)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, ErrPatchTestFailed) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+	}
+	return doc, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer such as "/prices/0" into
+// its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("path %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func getValue(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch v := doc.(type) {
+		case map[string]any:
+			next, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("%q not found", token)
+			}
+			doc = next
+		case []any:
+			i, err := arrayIndex(token, len(v)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[i]
+		default:
+			return nil, fmt.Errorf("%q not found", token)
+		}
+	}
+	return doc, nil
+}
+
+// addValue sets the member or inserts the array element at path. The
+// document is rebuilt on the way back up since inserting into a slice can
+// reallocate it.
+func addValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, rest := path[0], path[1:]
+
+	switch v := doc.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			v[token] = value
+			return v, nil
+		}
+		child, ok := v[token]
+		if !ok {
+			return nil, fmt.Errorf("%q not found", token)
+		}
+		updated, err := addValue(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		v[token] = updated
+		return v, nil
+	case []any:
+		if len(rest) == 0 {
+			i := len(v)
+			if token != "-" {
+				var err error
+				if i, err = arrayIndex(token, len(v)); err != nil {
+					return nil, err
+				}
+			}
+			v = append(v, nil)
+			copy(v[i+1:], v[i:])
+			v[i] = value
+			return v, nil
+		}
+		i, err := arrayIndex(token, len(v)-1)
+		if err != nil {
+			return nil, err
+		}
+		if v[i], err = addValue(v[i], rest, value); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("%q not found", token)
+}
+
+func removeValue(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.New("the whole product can't be removed")
+	}
+	token, rest := path[0], path[1:]
+
+	switch v := doc.(type) {
+	case map[string]any:
+		child, ok := v[token]
+		if !ok {
+			return nil, fmt.Errorf("%q not found", token)
+		}
+		if len(rest) == 0 {
+			delete(v, token)
+			return v, nil
+		}
+		updated, err := removeValue(child, rest)
+		if err != nil {
+			return nil, err
+		}
+		v[token] = updated
+		return v, nil
+	case []any:
+		i, err := arrayIndex(token, len(v)-1)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) == 0 {
+			return append(v[:i], v[i+1:]...), nil
+		}
+		if v[i], err = removeValue(v[i], rest); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("%q not found", token)
+}
+
+// arrayIndex parses an array index token, which can be at most maxIndex.
+func arrayIndex(token string, maxIndex int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > maxIndex {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+// jsonEqual compares decoded JSON values, treating numbers as equal when
+// they have the same value however they're written.
+func jsonEqual(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okA := new(big.Float).SetString(a.String())
+		y, okB := new(big.Float).SetString(b.String())
+		return okA && okB && x.Cmp(y) == 0
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			other, ok := b[k]
+			if !ok || !jsonEqual(v, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,247 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/money/moneytest"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	const doc = `{"name":"Lamp","tags":["a","b"],"price":{"amount":2500,"currency":"USD"},"sku":"x/y"}`
+
+	tests := []struct {
+		name    string
+		ops     string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "replace and add",
+			ops:  `[{"op":"replace","path":"/name","value":"Desk Lamp"},{"op":"add","path":"/description","value":"Brass"}]`,
+			want: `{"description":"Brass","name":"Desk Lamp","price":{"amount":2500,"currency":"USD"},"sku":"x/y","tags":["a","b"]}`,
+		},
+		{
+			name: "array insert, append and remove",
+			ops:  `[{"op":"add","path":"/tags/0","value":"z"},{"op":"add","path":"/tags/-","value":"c"},{"op":"remove","path":"/tags/1"}]`,
+			want: `{"name":"Lamp","price":{"amount":2500,"currency":"USD"},"sku":"x/y","tags":["z","b","c"]}`,
+		},
+		{
+			name: "nested replace",
+			ops:  `[{"op":"test","path":"/price/amount","value":2500.0},{"op":"replace","path":"/price/amount","value":1999}]`,
+			want: `{"name":"Lamp","price":{"amount":1999,"currency":"USD"},"sku":"x/y","tags":["a","b"]}`,
+		},
+		{
+			name: "escaped pointer",
+			ops:  `[{"op":"test","path":"/sku","value":"x/y"},{"op":"remove","path":"/sku"}]`,
+			want: `{"name":"Lamp","price":{"amount":2500,"currency":"USD"},"tags":["a","b"]}`,
+		},
+		{
+			name:    "failed test",
+			ops:     `[{"op":"replace","path":"/name","value":"Desk"},{"op":"test","path":"/tags","value":["a"]}]`,
+			wantErr: "patch test failed: /tags",
+		},
+		{
+			name:    "replace a missing member",
+			ops:     `[{"op":"replace","path":"/description","value":"x"}]`,
+			wantErr: `operation 0: "description" not found`,
+		},
+		{
+			name:    "index out of range",
+			ops:     `[{"op":"add","path":"/tags/3","value":"x"}]`,
+			wantErr: "operation 0: array index 3 out of range",
+		},
+		{
+			name:    "unsupported op",
+			ops:     `[{"op":"move","from":"/name","path":"/description"}]`,
+			wantErr: `operation 0: unsupported op "move"`,
+		},
+		{
+			name:    "missing value",
+			ops:     `[{"op":"add","path":"/description"}]`,
+			wantErr: "operation 0: add needs a value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _ := decodeJSON([]byte(doc))
+			var ops []patchOperation
+			if err := json.Unmarshal([]byte(tt.ops), &ops); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := applyJSONPatch(d, ops)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := json.Marshal(got); string(data) != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, data)
+			}
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	target, _ := decodeJSON([]byte(`{"a":"b","c":{"d":"e","f":"g"},"tags":["x"]}`))
+	patch, _ := decodeJSON([]byte(`{"a":"z","c":{"f":null},"tags":["y"],"n":1}`))
+
+	data, _ := json.Marshal(mergePatch(target, patch))
+	if want := `{"a":"z","c":{"d":"e"},"n":1,"tags":["y"]}`; string(data) != want {
+		t.Fatalf("expected %s, got %s", want, data)
+	}
+}
+
+func TestPatchProduct(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	history := NewMemoryPriceHistoryStore()
+	categories := NewMemoryCategoryStore()
+	category, _ := categories.Create(ctx, Category{Name: "Lighting"})
+	lamp, _ := store.Create(ctx, Product{
+		SKU:         "LAMP-1",
+		Name:        "Lamp",
+		Description: "Brass",
+		Price:       moneytest.USD(2500),
+		CategoryID:  &category.ID,
+		Tags:        []string{"home"},
+	})
+	store.Create(ctx, Product{SKU: "DESK-1", Name: "Desk", Price: moneytest.USD(19900)})
+
+	handler := NewHandler(store, categories, nil, history, nil, nil)
+	r := chi.NewRouter()
+	r.Patch("/products/{id}", handler.Patch)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		check       func(t *testing.T, p Product)
+	}{
+		{
+			name:        "merge patch changes only the given fields",
+			contentType: MergePatchContentType,
+			body:        `{"name":"Desk Lamp","price":{"amount":2200},"id":99}`,
+			wantStatus:  http.StatusOK,
+			check: func(t *testing.T, p Product) {
+				if p.ID != lamp.ID || p.Name != "Desk Lamp" || p.Description != "Brass" || p.Price != moneytest.USD(2200) || p.SKU != "LAMP-1" {
+					t.Fatalf("unexpected patched product %+v", p)
+				}
+			},
+		},
+		{
+			name:        "null clears a field",
+			contentType: "application/json",
+			body:        `{"category_id":null,"tags":["Home","Sale"]}`,
+			wantStatus:  http.StatusOK,
+			check: func(t *testing.T, p Product) {
+				if p.CategoryID != nil || strings.Join(p.Tags, ",") != "home,sale" {
+					t.Fatalf("unexpected patched product %+v", p)
+				}
+			},
+		},
+		{
+			name:        "json patch",
+			contentType: JSONPatchContentType,
+			body: `[{"op":"test","path":"/name","value":"Desk Lamp"},
+				{"op":"add","path":"/prices","value":[{"amount":2000,"currency":"eur"}]},
+				{"op":"remove","path":"/tags/0"}]`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, p Product) {
+				if len(p.Prices) != 1 || p.Prices[0] != (money.Money{Amount: 2000, Currency: "EUR"}) || strings.Join(p.Tags, ",") != "sale" {
+					t.Fatalf("unexpected patched product %+v", p)
+				}
+			},
+		},
+		{
+			name:        "failed test",
+			contentType: JSONPatchContentType,
+			body:        `[{"op":"test","path":"/name","value":"Lamp"},{"op":"replace","path":"/name","value":"Other"}]`,
+			wantStatus:  http.StatusConflict,
+		},
+		{
+			name:        "result is validated",
+			contentType: JSONPatchContentType,
+			body:        `[{"op":"remove","path":"/name"}]`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "wrong type",
+			contentType: MergePatchContentType,
+			body:        `{"price":"cheap"}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "merge patch must be an object",
+			contentType: MergePatchContentType,
+			body:        `["name"]`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "sku taken",
+			contentType: MergePatchContentType,
+			body:        `{"sku":"desk-1"}`,
+			wantStatus:  http.StatusConflict,
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "text/plain",
+			body:        `{"name":"x"}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/products/%d", lamp.ID), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("If-Match", "*")
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.check == nil {
+				return
+			}
+			var p Product
+			json.NewDecoder(rec.Body).Decode(&p)
+			tt.check(t, p)
+		})
+	}
+
+	changes, _, _ := history.History(ctx, lamp.ID, 10, 0)
+	if len(changes) != 1 || changes[0].NewPrice != moneytest.USD(2200) {
+		t.Fatalf("expected the price change to be recorded, got %+v", changes)
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/products/99", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", MergePatchContentType)
+	req.Header.Set("If-Match", "*")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for a missing product, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	if _, err := applyPatch(lamp, JSONPatchContentType, strings.NewReader(`{"op":"add"}`)); err == nil || errors.Is(err, ErrPatchTestFailed) {
+		t.Fatalf("expected a JSON Patch that isn't an array to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package product
+
+import (
+	"errors"
+	"time"
+
+	"lukekorsman.com/store/internal/money"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("scheduled price not found")
+	// ErrScheduleChanged is returned when a scheduled price is no longer in
+	// the status the caller read, because it was applied or cancelled first.
+	ErrScheduleChanged = errors.New("scheduled price changed concurrently")
+)
+
+// PriceChange is one entry in a product's price history. Only the base
+// price is tracked; price-list entries are not.
+type PriceChange struct {
+	ID        int `json:"id"`
+	ProductID int `json:"product_id"`
+	// OldPrice is nil for the price a product was created with.
+	OldPrice *money.Money `json:"old_price"`
+	NewPrice money.Money  `json:"new_price"`
+	ActorID  *int         `json:"actor_id"`
+	// ScheduleID is set when the change was applied from a ScheduledPrice.
+	ScheduleID *int      `json:"schedule_id,omitempty"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+type ScheduleStatus string
+
+const (
+	SchedulePending   ScheduleStatus = "pending"
+	ScheduleApplied   ScheduleStatus = "applied"
+	ScheduleCancelled ScheduleStatus = "cancelled"
+	// ScheduleFailed is set when the product couldn't be updated, e.g.
+	// because it was deleted before the change was due.
+	ScheduleFailed ScheduleStatus = "failed"
+)
+
+// ScheduledPrice is a future change to a product's base price.
+type ScheduledPrice struct {
+	ID          int            `json:"id"`
+	ProductID   int            `json:"product_id"`
+	Price       money.Money    `json:"price"`
+	EffectiveAt time.Time      `json:"effective_at"`
+	Status      ScheduleStatus `json:"status"`
+	ActorID     *int           `json:"actor_id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+func ValidateScheduledPrice(sp ScheduledPrice, now time.Time) []ValidationError {
+	errs := validatePrice("price", sp.Price)
+
+	if sp.EffectiveAt.IsZero() {
+		errs = append(errs, ValidationError{
+			Field:   "effective_at",
+			Message: "effective_at is required",
+		})
+	} else if !sp.EffectiveAt.After(now) {
+		errs = append(errs, ValidationError{
+			Field:   "effective_at",
+			Message: "effective_at must be in the future",
+		})
+	}
+
+	return errs
+}
@@ -0,0 +1,169 @@
+package product
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"lukekorsman.com/store/internal/api"
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/money"
+)
+
+type PriceHistoryHandler struct {
+	products Store
+	history  PriceHistoryStore
+}
+
+func NewPriceHistoryHandler(products Store, history PriceHistoryStore) *PriceHistoryHandler {
+	return &PriceHistoryHandler{
+		products: products,
+		history:  history,
+	}
+}
+
+type SchedulePriceRequest struct {
+	Price       money.Money `json:"price"`
+	EffectiveAt time.Time   `json:"effective_at"`
+}
+
+// History lists the product's price changes, newest first.
+func (h *PriceHistoryHandler) History(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.productID(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, errs := api.ParsePage(r.URL.Query())
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	changes, total, err := h.history.History(r.Context(), id, limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	api.SetPageHeaders(w, r, limit, offset, len(changes), total)
+	writeJSON(w, http.StatusOK, changes)
+}
+
+func (h *PriceHistoryHandler) Schedules(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.productID(w, r)
+	if !ok {
+		return
+	}
+
+	schedules, err := h.history.Schedules(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, schedules)
+}
+
+// Schedule queues a price change for the scheduler to apply at effective_at.
+func (h *PriceHistoryHandler) Schedule(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.productID(w, r)
+	if !ok {
+		return
+	}
+
+	var req SchedulePriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	sp := ScheduledPrice{
+		ProductID:   id,
+		Price:       req.Price,
+		EffectiveAt: req.EffectiveAt,
+	}
+	normalizePrice(&sp.Price)
+
+	if errs := ValidateScheduledPrice(sp, time.Now()); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		sp.ActorID = &user.ID
+	}
+
+	scheduled, err := h.history.Schedule(r.Context(), sp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, scheduled)
+}
+
+// Cancel withdraws a scheduled change that hasn't been applied yet.
+func (h *PriceHistoryHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.productID(w, r)
+	if !ok {
+		return
+	}
+
+	scheduleID, err := strconv.Atoi(chi.URLParam(r, "scheduleID"))
+	if err != nil {
+		http.Error(w, "invalid scheduled price ID", http.StatusBadRequest)
+		return
+	}
+
+	sp, err := h.history.GetSchedule(r.Context(), scheduleID)
+	if err == nil && sp.ProductID != id {
+		err = ErrScheduleNotFound
+	}
+	if err != nil {
+		http.Error(w, err.Error(), scheduleErrorStatus(err))
+		return
+	}
+
+	if _, err := h.history.UpdateScheduleStatus(r.Context(), scheduleID, SchedulePending, ScheduleCancelled); err != nil {
+		http.Error(w, err.Error(), scheduleErrorStatus(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// productID reads the product ID from the URL and checks the product exists.
+func (h *PriceHistoryHandler) productID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	if _, err := h.products.GetByID(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return 0, false
+	}
+
+	return id, true
+}
+
+func scheduleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrScheduleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrScheduleChanged):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,163 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/money/moneytest"
+)
+
+func newPriceRouter(products Store, history PriceHistoryStore) http.Handler {
+	h := NewHandler(products, NewMemoryCategoryStore(), nil, history, nil, nil)
+	prices := NewPriceHistoryHandler(products, history)
+	r := chi.NewRouter()
+	r.Post("/products", h.Create)
+	r.Put("/products/{id}", h.Update)
+	r.Get("/products/{id}/price-history", prices.History)
+	r.Get("/products/{id}/scheduled-prices", prices.Schedules)
+	r.Post("/products/{id}/scheduled-prices", prices.Schedule)
+	r.Delete("/products/{id}/scheduled-prices/{scheduleID}", prices.Cancel)
+	return r
+}
+
+func doPrice(t *testing.T, router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req = req.WithContext(auth.ContextWithUser(req.Context(), auth.User{ID: 7, Email: "editor@example.com"}))
+	// Versions are covered by TestConditionalRequests.
+	req.Header.Set("If-Match", "*")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPriceHistory(t *testing.T) {
+	router := newPriceRouter(NewMemoryStore(), NewMemoryPriceHistoryStore())
+
+	rec := doPrice(t, router, http.MethodPost, "/products", `{"name":"Lamp","price":{"amount":2500}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var lamp Product
+	json.NewDecoder(rec.Body).Decode(&lamp)
+
+	path := fmt.Sprintf("/products/%d", lamp.ID)
+	doPrice(t, router, http.MethodPut, path, `{"name":"Lamp","price":{"amount":2000}}`)
+	// Changes that leave the price alone aren't recorded.
+	doPrice(t, router, http.MethodPut, path, `{"name":"Desk Lamp","price":{"amount":2000}}`)
+
+	rec = doPrice(t, router, http.MethodGet, path+"/price-history", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-Total-Count"); got != "2" {
+		t.Fatalf("expected 2 changes, got %s", got)
+	}
+
+	var changes []PriceChange
+	json.NewDecoder(rec.Body).Decode(&changes)
+	latest, first := changes[0], changes[1]
+	if latest.OldPrice == nil || *latest.OldPrice != moneytest.USD(2500) || latest.NewPrice != moneytest.USD(2000) {
+		t.Fatalf("unexpected latest change %+v", latest)
+	}
+	if latest.ActorID == nil || *latest.ActorID != 7 {
+		t.Fatalf("expected actor 7, got %v", latest.ActorID)
+	}
+	if first.OldPrice != nil || first.NewPrice != moneytest.USD(2500) {
+		t.Fatalf("expected the initial price first, got %+v", first)
+	}
+
+	if rec := doPrice(t, router, http.MethodGet, "/products/99/price-history", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for a missing product, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestScheduledPrices(t *testing.T) {
+	ctx := context.Background()
+	products := NewMemoryStore()
+	history := NewMemoryPriceHistoryStore()
+	router := newPriceRouter(products, history)
+	lamp, _ := products.Create(ctx, Product{Name: "Lamp", Price: moneytest.USD(2500)})
+	chair, _ := products.Create(ctx, Product{Name: "Chair", Price: moneytest.USD(9000)})
+
+	schedule := func(id int, amount int64, at time.Time) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"price":{"amount":%d},"effective_at":%q}`, amount, at.Format(time.RFC3339))
+		return doPrice(t, router, http.MethodPost, fmt.Sprintf("/products/%d/scheduled-prices", id), body)
+	}
+
+	now := time.Now().UTC()
+	tests := []struct {
+		name       string
+		amount     int64
+		at         time.Time
+		wantStatus int
+	}{
+		{name: "in the past", amount: 1999, at: now.Add(-time.Hour), wantStatus: http.StatusBadRequest},
+		{name: "invalid price", amount: 0, at: now.Add(time.Hour), wantStatus: http.StatusBadRequest},
+		{name: "valid", amount: 1999, at: now.Add(time.Hour), wantStatus: http.StatusCreated},
+		{name: "later", amount: 1499, at: now.Add(3 * time.Hour), wantStatus: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := schedule(lamp.ID, tt.amount, tt.at); rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	rec := schedule(chair.ID, 8000, now.Add(time.Hour))
+	var cancelled ScheduledPrice
+	json.NewDecoder(rec.Body).Decode(&cancelled)
+	cancelPath := fmt.Sprintf("/products/%d/scheduled-prices/%d", chair.ID, cancelled.ID)
+	if rec := doPrice(t, router, http.MethodDelete, fmt.Sprintf("/products/%d/scheduled-prices/%d", lamp.ID, cancelled.ID), ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected another product's schedule to be hidden, got %d", rec.Code)
+	}
+	if rec := doPrice(t, router, http.MethodDelete, cancelPath, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+	if rec := doPrice(t, router, http.MethodDelete, cancelPath, ""); rec.Code != http.StatusConflict {
+		t.Fatalf("expected cancelling twice to conflict, got %d", rec.Code)
+	}
+
+	scheduler := NewPriceScheduler(products, history, nil)
+	changes, err := scheduler.ApplyDue(ctx, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].ProductID != lamp.ID || changes[0].ScheduleID == nil || changes[0].ActorID == nil {
+		t.Fatalf("expected only the lamp's first change to apply, got %+v", changes)
+	}
+	if p, _ := products.GetByID(ctx, lamp.ID); p.Price != moneytest.USD(1999) {
+		t.Fatalf("expected the lamp to cost %v, got %v", moneytest.USD(1999), p.Price)
+	}
+	if p, _ := products.GetByID(ctx, chair.ID); p.Price != moneytest.USD(9000) {
+		t.Fatalf("expected the cancelled change to be skipped, got %v", p.Price)
+	}
+
+	if changes, _ := scheduler.ApplyDue(ctx, now.Add(2*time.Hour)); len(changes) != 0 {
+		t.Fatalf("expected applied changes not to run again, got %+v", changes)
+	}
+
+	// A product deleted before its change is due marks the change failed.
+	products.Delete(ctx, lamp.ID, 0)
+	if _, err := scheduler.ApplyDue(ctx, now.Add(4*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	schedules, _ := history.Schedules(ctx, lamp.ID)
+	var statuses []ScheduleStatus
+	for _, sp := range schedules {
+		statuses = append(statuses, sp.Status)
+	}
+	if fmt.Sprint(statuses) != "[applied failed]" {
+		t.Fatalf("expected [applied failed], got %v", statuses)
+	}
+}
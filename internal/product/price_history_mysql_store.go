@@ -0,0 +1,204 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lukekorsman.com/store/internal/money"
+)
+
+const (
+	priceChangeColumns    = "id, product_id, old_price, new_price, actor_id, schedule_id, changed_at"
+	scheduledPriceColumns = "id, product_id, price, effective_at, status, actor_id, created_at, updated_at"
+)
+
+type MySQLPriceHistoryStore struct {
+	db *sql.DB
+}
+
+func NewMySQLPriceHistoryStore(db *sql.DB) *MySQLPriceHistoryStore {
+	return &MySQLPriceHistoryStore{db: db}
+}
+
+func scanPriceChange(row rowScanner) (PriceChange, error) {
+	var c PriceChange
+	var oldPrice sql.NullString
+	var newPrice string
+	var actorID, scheduleID sql.NullInt64
+	err := row.Scan(&c.ID, &c.ProductID, &oldPrice, &newPrice, &actorID, &scheduleID, &c.ChangedAt)
+	if err != nil {
+		return PriceChange{}, err
+	}
+
+	if c.NewPrice, err = money.Parse(newPrice, BaseCurrency); err != nil {
+		return PriceChange{}, fmt.Errorf("price change %d: %w", c.ID, err)
+	}
+	if oldPrice.Valid {
+		m, err := money.Parse(oldPrice.String, BaseCurrency)
+		if err != nil {
+			return PriceChange{}, fmt.Errorf("price change %d: %w", c.ID, err)
+		}
+		c.OldPrice = &m
+	}
+	c.ActorID = nullableInt(actorID)
+	c.ScheduleID = nullableInt(scheduleID)
+	return c, nil
+}
+
+func scanScheduledPrice(row rowScanner) (ScheduledPrice, error) {
+	var sp ScheduledPrice
+	var price string
+	var actorID sql.NullInt64
+	err := row.Scan(&sp.ID, &sp.ProductID, &price, &sp.EffectiveAt, &sp.Status, &actorID, &sp.CreatedAt, &sp.UpdatedAt)
+	if err != nil {
+		return ScheduledPrice{}, err
+	}
+
+	if sp.Price, err = money.Parse(price, BaseCurrency); err != nil {
+		return ScheduledPrice{}, fmt.Errorf("scheduled price %d: %w", sp.ID, err)
+	}
+	sp.ActorID = nullableInt(actorID)
+	return sp, nil
+}
+
+func nullableInt(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	id := int(n.Int64)
+	return &id
+}
+
+func (s *MySQLPriceHistoryStore) Record(ctx context.Context, c PriceChange) (PriceChange, error) {
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO price_history (product_id, old_price, new_price, actor_id, schedule_id) VALUES (?, ?, ?, ?, ?)",
+		c.ProductID, decimalOrNil(c.OldPrice), c.NewPrice.Decimal(), c.ActorID, c.ScheduleID,
+	)
+	if err != nil {
+		return PriceChange{}, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return PriceChange{}, err
+	}
+
+	return scanPriceChange(s.db.QueryRowContext(ctx,
+		"SELECT "+priceChangeColumns+" FROM price_history WHERE id = ?", id))
+}
+
+func (s *MySQLPriceHistoryStore) History(ctx context.Context, productID, limit, offset int) ([]PriceChange, int, error) {
+	var total int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM price_history WHERE product_id = ?", productID,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+priceChangeColumns+" FROM price_history WHERE product_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		productID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	changes := []PriceChange{}
+	for rows.Next() {
+		c, err := scanPriceChange(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, total, rows.Err()
+}
+
+func (s *MySQLPriceHistoryStore) Schedule(ctx context.Context, sp ScheduledPrice) (ScheduledPrice, error) {
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO scheduled_prices (product_id, price, effective_at, status, actor_id) VALUES (?, ?, ?, ?, ?)",
+		sp.ProductID, sp.Price.Decimal(), sp.EffectiveAt.UTC(), SchedulePending, sp.ActorID,
+	)
+	if err != nil {
+		return ScheduledPrice{}, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return ScheduledPrice{}, err
+	}
+
+	return s.GetSchedule(ctx, int(id))
+}
+
+func (s *MySQLPriceHistoryStore) GetSchedule(ctx context.Context, id int) (ScheduledPrice, error) {
+	sp, err := scanScheduledPrice(s.db.QueryRowContext(ctx,
+		"SELECT "+scheduledPriceColumns+" FROM scheduled_prices WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ScheduledPrice{}, fmt.Errorf("%w: %d", ErrScheduleNotFound, id)
+	}
+	return sp, err
+}
+
+func (s *MySQLPriceHistoryStore) Schedules(ctx context.Context, productID int) ([]ScheduledPrice, error) {
+	return s.querySchedules(ctx,
+		"SELECT "+scheduledPriceColumns+" FROM scheduled_prices WHERE product_id = ? ORDER BY effective_at, id",
+		productID,
+	)
+}
+
+func (s *MySQLPriceHistoryStore) Due(ctx context.Context, now time.Time) ([]ScheduledPrice, error) {
+	return s.querySchedules(ctx,
+		"SELECT "+scheduledPriceColumns+" FROM scheduled_prices WHERE status = ? AND effective_at <= ? ORDER BY effective_at, id",
+		SchedulePending, now.UTC(),
+	)
+}
+
+func (s *MySQLPriceHistoryStore) querySchedules(ctx context.Context, query string, args ...any) ([]ScheduledPrice, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []ScheduledPrice{}
+	for rows.Next() {
+		sp, err := scanScheduledPrice(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, sp)
+	}
+
+	return schedules, rows.Err()
+}
+
+func (s *MySQLPriceHistoryStore) UpdateScheduleStatus(ctx context.Context, id int, from, to ScheduleStatus) (ScheduledPrice, error) {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE scheduled_prices SET status = ? WHERE id = ? AND status = ?",
+		to, id, from,
+	)
+	if err != nil {
+		return ScheduledPrice{}, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return ScheduledPrice{}, err
+	}
+
+	sp, err := s.GetSchedule(ctx, id)
+	if err != nil {
+		return ScheduledPrice{}, err
+	}
+	if rows == 0 {
+		return ScheduledPrice{}, fmt.Errorf("%w: scheduled price %d is %s", ErrScheduleChanged, id, sp.Status)
+	}
+	return sp, nil
+}
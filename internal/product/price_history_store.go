@@ -0,0 +1,154 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PriceHistoryStore keeps the price history ledger and the scheduled price
+// changes waiting to be applied.
+type PriceHistoryStore interface {
+	Record(ctx context.Context, c PriceChange) (PriceChange, error)
+	// History lists a product's price changes, newest first.
+	History(ctx context.Context, productID, limit, offset int) ([]PriceChange, int, error)
+
+	Schedule(ctx context.Context, sp ScheduledPrice) (ScheduledPrice, error)
+	GetSchedule(ctx context.Context, id int) (ScheduledPrice, error)
+	// Schedules lists every scheduled change for a product, soonest first.
+	Schedules(ctx context.Context, productID int) ([]ScheduledPrice, error)
+	// Due lists pending changes whose effective time is at or before now.
+	Due(ctx context.Context, now time.Time) ([]ScheduledPrice, error)
+	// UpdateScheduleStatus moves a scheduled change from one status to
+	// another and fails with ErrScheduleChanged if it's no longer in from.
+	UpdateScheduleStatus(ctx context.Context, id int, from, to ScheduleStatus) (ScheduledPrice, error)
+}
+
+type MemoryPriceHistoryStore struct {
+	changes        []PriceChange
+	schedules      []ScheduledPrice
+	nextChangeID   int
+	nextScheduleID int
+	mu             sync.RWMutex
+}
+
+func NewMemoryPriceHistoryStore() *MemoryPriceHistoryStore {
+	return &MemoryPriceHistoryStore{
+		nextChangeID:   1,
+		nextScheduleID: 1,
+	}
+}
+
+func (s *MemoryPriceHistoryStore) Record(ctx context.Context, c PriceChange) (PriceChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.ID = s.nextChangeID
+	s.nextChangeID++
+	c.ChangedAt = time.Now().UTC()
+	s.changes = append(s.changes, c)
+	return c, nil
+}
+
+func (s *MemoryPriceHistoryStore) History(ctx context.Context, productID, limit, offset int) ([]PriceChange, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Newest first.
+	var matched []PriceChange
+	for i := len(s.changes) - 1; i >= 0; i-- {
+		if s.changes[i].ProductID == productID {
+			matched = append(matched, s.changes[i])
+		}
+	}
+
+	total := len(matched)
+	if offset > total {
+		offset = total
+	}
+	end := min(offset+limit, total)
+	return append([]PriceChange{}, matched[offset:end]...), total, nil
+}
+
+func (s *MemoryPriceHistoryStore) Schedule(ctx context.Context, sp ScheduledPrice) (ScheduledPrice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	sp.ID = s.nextScheduleID
+	s.nextScheduleID++
+	sp.Status = SchedulePending
+	sp.CreatedAt = now
+	sp.UpdatedAt = now
+	s.schedules = append(s.schedules, sp)
+	return sp, nil
+}
+
+func (s *MemoryPriceHistoryStore) GetSchedule(ctx context.Context, id int) (ScheduledPrice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, sp := range s.schedules {
+		if sp.ID == id {
+			return sp, nil
+		}
+	}
+	return ScheduledPrice{}, fmt.Errorf("%w: %d", ErrScheduleNotFound, id)
+}
+
+func (s *MemoryPriceHistoryStore) Schedules(ctx context.Context, productID int) ([]ScheduledPrice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := []ScheduledPrice{}
+	for _, sp := range s.schedules {
+		if sp.ProductID == productID {
+			matched = append(matched, sp)
+		}
+	}
+	sortSchedules(matched)
+	return matched, nil
+}
+
+func (s *MemoryPriceHistoryStore) Due(ctx context.Context, now time.Time) ([]ScheduledPrice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var due []ScheduledPrice
+	for _, sp := range s.schedules {
+		if sp.Status == SchedulePending && !sp.EffectiveAt.After(now) {
+			due = append(due, sp)
+		}
+	}
+	sortSchedules(due)
+	return due, nil
+}
+
+func (s *MemoryPriceHistoryStore) UpdateScheduleStatus(ctx context.Context, id int, from, to ScheduleStatus) (ScheduledPrice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, sp := range s.schedules {
+		if sp.ID != id {
+			continue
+		}
+		if sp.Status != from {
+			return ScheduledPrice{}, fmt.Errorf("%w: scheduled price %d is %s", ErrScheduleChanged, id, sp.Status)
+		}
+		s.schedules[i].Status = to
+		s.schedules[i].UpdatedAt = time.Now().UTC()
+		return s.schedules[i], nil
+	}
+	return ScheduledPrice{}, fmt.Errorf("%w: %d", ErrScheduleNotFound, id)
+}
+
+func sortSchedules(schedules []ScheduledPrice) {
+	sort.SliceStable(schedules, func(i, j int) bool {
+		if !schedules[i].EffectiveAt.Equal(schedules[j].EffectiveAt) {
+			return schedules[i].EffectiveAt.Before(schedules[j].EffectiveAt)
+		}
+		return schedules[i].ID < schedules[j].ID
+	})
+}
@@ -0,0 +1,150 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"lukekorsman.com/store/internal/auth"
+	"lukekorsman.com/store/internal/cache"
+	"lukekorsman.com/store/internal/money"
+)
+
+// PriceScheduler applies scheduled price changes once they fall due.
+type PriceScheduler struct {
+	store   Store
+	history PriceHistoryStore
+	cache   *cache.RedisCache
+
+	changeHooks []func(ctx context.Context, c ProductChange)
+}
+
+func NewPriceScheduler(store Store, history PriceHistoryStore, redisCache *cache.RedisCache) *PriceScheduler {
+	return &PriceScheduler{
+		store:   store,
+		history: history,
+		cache:   redisCache,
+	}
+}
+
+// OnChange registers fn to run after every product the scheduler updates.
+// The context carries the user who scheduled the change, if known.
+func (s *PriceScheduler) OnChange(fn func(ctx context.Context, c ProductChange)) {
+	s.changeHooks = append(s.changeHooks, fn)
+}
+
+// Run applies due changes every interval until ctx is cancelled.
+func (s *PriceScheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.ApplyDue(ctx, time.Now().UTC()); err != nil {
+			fmt.Printf("Failed to apply scheduled prices: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ApplyDue applies every pending change effective at or before now and
+// returns the resulting price history entries. Each change is claimed by
+// moving it to applied first, so two API instances never apply it twice.
+func (s *PriceScheduler) ApplyDue(ctx context.Context, now time.Time) ([]PriceChange, error) {
+	due, err := s.history.Due(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []PriceChange{}
+	for _, sp := range due {
+		_, err := s.history.UpdateScheduleStatus(ctx, sp.ID, SchedulePending, ScheduleApplied)
+		if errors.Is(err, ErrScheduleChanged) {
+			continue
+		}
+		if err != nil {
+			return changes, err
+		}
+
+		change, err := s.apply(ctx, sp)
+		if err != nil {
+			fmt.Printf("Failed to apply scheduled price %d for product %d: %v\n", sp.ID, sp.ProductID, err)
+			if _, err := s.history.UpdateScheduleStatus(ctx, sp.ID, ScheduleApplied, ScheduleFailed); err != nil {
+				return changes, err
+			}
+			continue
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+
+	return changes, nil
+}
+
+// applyAttempts is how many times the scheduler re-reads a product that
+// another write changed underneath it.
+const applyAttempts = 3
+
+// apply sets the product's price and records the change, if there was one.
+func (s *PriceScheduler) apply(ctx context.Context, sp ScheduledPrice) (*PriceChange, error) {
+	before, after, err := s.setPrice(ctx, sp.ProductID, sp.Price)
+	if err != nil || before == nil {
+		return nil, err
+	}
+	old := &before.Price
+	invalidateProducts(ctx, s.cache, sp.ProductID)
+
+	hookCtx := ctx
+	if sp.ActorID != nil {
+		hookCtx = auth.ContextWithUser(ctx, auth.User{ID: *sp.ActorID})
+	}
+	for _, fn := range s.changeHooks {
+		fn(hookCtx, ProductChange{Action: ProductUpdated, ProductID: sp.ProductID, Before: before, After: after})
+	}
+
+	change, err := s.history.Record(ctx, PriceChange{
+		ProductID:  sp.ProductID,
+		OldPrice:   old,
+		NewPrice:   sp.Price,
+		ActorID:    sp.ActorID,
+		ScheduleID: &sp.ID,
+	})
+	if err != nil {
+		// The price is already live; only the ledger entry is missing.
+		fmt.Printf("Failed to record price change for product %d: %v\n", sp.ProductID, err)
+		return nil, nil
+	}
+	return &change, nil
+}
+
+// setPrice changes a product's price, trying again if the product is
+// modified between reading and writing it. It returns the product before
+// and after, or nils if the product already had this price.
+func (s *PriceScheduler) setPrice(ctx context.Context, id int, price money.Money) (before, after *Product, err error) {
+	for attempt := 1; ; attempt++ {
+		p, err := s.store.GetByID(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if p.Price == price {
+			return nil, nil, nil
+		}
+
+		old := p
+		p.Price = price
+		updated, err := s.store.Update(ctx, id, p)
+		if errors.Is(err, ErrVersionMismatch) && attempt < applyAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return &old, &updated, nil
+	}
+}
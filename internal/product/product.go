@@ -1,7 +1,86 @@
 package product
 
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"lukekorsman.com/store/internal/money"
+)
+
+// BaseCurrency is the currency catalog prices are stored in. Other
+// currencies come from a product's price list or from conversion.
+const BaseCurrency = money.USD
+
+// ErrProductNotFound is returned for products that don't exist or are
+// archived.
+var ErrProductNotFound = errors.New("product not found")
+
+// ErrVersionMismatch is returned when a product is written with a Version
+// that is no longer its current one.
+var ErrVersionMismatch = errors.New("product has been modified")
+
 type Product struct {
-	ID int			`json:"id"`
-	Name string 	`json:"name"`
-	Price float64	`json:"price"`
-}
\ No newline at end of file
+	ID int `json:"id"`
+	// SKU is optional but unique across products; imports match on it.
+	SKU string `json:"sku,omitempty"`
+	// Version goes up with every write to the product and is its ETag.
+	Version     int         `json:"version"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	// Prices lists explicit prices in currencies other than BaseCurrency,
+	// which take precedence over converting Price.
+	Prices     []money.Money `json:"prices,omitempty"`
+	CategoryID *int          `json:"category_id"`
+	Tags       []string      `json:"tags"`
+	// Images are managed under /products/{id}/images, in display order.
+	Images []Image `json:"images"`
+	// Options and Variants are managed under /products/{id}/variants and are
+	// only populated there.
+	Options   []Option   `json:"options,omitempty"`
+	Variants  []Variant  `json:"variants,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type ChangeAction string
+
+const (
+	ProductCreated  ChangeAction = "created"
+	ProductUpdated  ChangeAction = "updated"
+	ProductDeleted  ChangeAction = "deleted"
+	ProductRestored ChangeAction = "restored"
+)
+
+// ProductChange describes a saved write to a product. Before is nil for a
+// create or restore and After is nil for a delete.
+type ProductChange struct {
+	Action    ChangeAction
+	ProductID int
+	Before    *Product
+	After     *Product
+}
+
+// Archived reports whether the product has been soft-deleted.
+func (p Product) Archived() bool {
+	return p.DeletedAt != nil
+}
+
+// PriceIn returns the product's price in currency c: its own price, an entry
+// from its price list, or Price converted with rates.
+func (p Product) PriceIn(c money.Currency, rates *money.Rates) (money.Money, error) {
+	if c == p.Price.Currency {
+		return p.Price, nil
+	}
+	for _, price := range p.Prices {
+		if price.Currency == c {
+			return price, nil
+		}
+	}
+	if rates == nil {
+		return money.Money{}, fmt.Errorf("%w for %s", money.ErrNoRate, c)
+	}
+	return rates.Convert(p.Price, c)
+}
@@ -0,0 +1,364 @@
+package product
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"lukekorsman.com/store/internal/api"
+	"lukekorsman.com/store/internal/money"
+)
+
+// sortableFields maps the public sort keys to their column names.
+var sortableFields = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"price":      "price",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions describes a filtered, sorted page of the catalog.
+type ListOptions struct {
+	Limit  int
+	Offset int
+	// MinPrice and MaxPrice are in BaseCurrency.
+	MinPrice *money.Money
+	MaxPrice *money.Money
+	Name     string
+	// CategoryIDs matches products in any of the categories. The handler
+	// expands a requested category into it and its descendants.
+	CategoryIDs []int
+	Tag         string
+	Sort        []SortField
+	// After, if set, continues the listing from a cursor instead of Offset.
+	After *Cursor
+	// IncludeDeleted also returns archived products.
+	IncludeDeleted bool
+}
+
+// Cursor marks a position in a sorted listing: the sort fields and ID of
+// the row it was taken from. A listing continues with the rows after Key,
+// or with those before it if Before is set, so rows added or removed
+// elsewhere don't shift the page the way they shift an offset.
+type Cursor struct {
+	Key    Product
+	Before bool
+}
+
+type ListResult struct {
+	Products []Product `json:"products"`
+	Total    int       `json:"total"`
+}
+
+// ParseListOptions reads limit, offset, cursor, min_price, max_price, name
+// and sort from a query string. A cursor takes precedence over offset.
+func ParseListOptions(q url.Values) (ListOptions, []ValidationError) {
+	var opts ListOptions
+	var errs []ValidationError
+
+	opts.Limit, opts.Offset, errs = api.ParseLimitOffset(q)
+
+	opts.MinPrice = parsePrice(q, "min_price", &errs)
+	opts.MaxPrice = parsePrice(q, "max_price", &errs)
+	if opts.MinPrice != nil && opts.MaxPrice != nil && opts.MinPrice.Amount > opts.MaxPrice.Amount {
+		errs = append(errs, ValidationError{
+			Field:   "min_price",
+			Message: "min_price must not be greater than max_price",
+		})
+	}
+
+	opts.Name = strings.TrimSpace(q.Get("name"))
+	opts.Tag = strings.ToLower(strings.TrimSpace(q.Get("tag")))
+
+	if v := q.Get("category"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			errs = append(errs, ValidationError{
+				Field:   "category",
+				Message: "category must be a positive integer",
+			})
+		} else {
+			opts.CategoryIDs = []int{id}
+		}
+	}
+
+	if v := q.Get("include_deleted"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "include_deleted",
+				Message: "include_deleted must be true or false",
+			})
+		}
+		opts.IncludeDeleted = include
+	}
+
+	if v := q.Get("sort"); v != "" {
+		for _, key := range strings.Split(v, ",") {
+			key = strings.TrimSpace(key)
+			field := SortField{Field: strings.TrimPrefix(key, "-"), Desc: strings.HasPrefix(key, "-")}
+			if _, ok := sortableFields[field.Field]; !ok {
+				errs = append(errs, ValidationError{
+					Field:   "sort",
+					Message: fmt.Sprintf("cannot sort by %q", key),
+				})
+				continue
+			}
+			opts.Sort = append(opts.Sort, field)
+		}
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeListCursor(v, opts.Sort)
+		if err != nil {
+			errs = append(errs, ValidationError{
+				Field:   "cursor",
+				Message: "cursor is invalid for this sort",
+			})
+		} else {
+			opts.After = &c
+		}
+	}
+
+	return opts, errs
+}
+
+// parsePrice reads a decimal amount in BaseCurrency, such as 19.99.
+func parsePrice(q url.Values, key string, errs *[]ValidationError) *money.Money {
+	v := q.Get(key)
+	if v == "" {
+		return nil
+	}
+
+	price, err := money.Parse(v, BaseCurrency)
+	if err != nil || price.Amount < 0 {
+		*errs = append(*errs, ValidationError{
+			Field:   key,
+			Message: key + " must be a non-negative amount",
+		})
+		return nil
+	}
+	return &price
+}
+
+// Query encodes the options back into their canonical query string form,
+// which doubles as the cache key suffix for a listing.
+func (o ListOptions) Query() url.Values {
+	q := url.Values{}
+	q.Set("limit", strconv.Itoa(o.Limit))
+	q.Set("offset", strconv.Itoa(o.Offset))
+	if o.MinPrice != nil {
+		q.Set("min_price", o.MinPrice.Decimal())
+	}
+	if o.MaxPrice != nil {
+		q.Set("max_price", o.MaxPrice.Decimal())
+	}
+	if o.Name != "" {
+		q.Set("name", o.Name)
+	}
+	if o.Tag != "" {
+		q.Set("tag", o.Tag)
+	}
+	if len(o.CategoryIDs) > 0 {
+		ids := make([]string, len(o.CategoryIDs))
+		for i, id := range o.CategoryIDs {
+			ids[i] = strconv.Itoa(id)
+		}
+		q.Set("category_ids", strings.Join(ids, ","))
+	}
+	if len(o.Sort) > 0 {
+		q.Set("sort", sortQuery(o.Sort))
+	}
+	if o.After != nil {
+		q.Set("cursor", encodeListCursor(o.Sort, *o.After))
+	}
+	if o.IncludeDeleted {
+		q.Set("include_deleted", "true")
+	}
+	return q
+}
+
+// matches reports whether p passes the option filters. The name filter is a
+// case-insensitive prefix match, mirroring the LIKE 'x%' query MySQLStore runs
+// against idx_products_name.
+func (o ListOptions) matches(p Product) bool {
+	if p.Archived() && !o.IncludeDeleted {
+		return false
+	}
+	if o.MinPrice != nil && p.Price.Amount < o.MinPrice.Amount {
+		return false
+	}
+	if o.MaxPrice != nil && p.Price.Amount > o.MaxPrice.Amount {
+		return false
+	}
+	if o.Name != "" && !strings.HasPrefix(strings.ToLower(p.Name), strings.ToLower(o.Name)) {
+		return false
+	}
+	if o.Tag != "" && !slices.Contains(p.Tags, o.Tag) {
+		return false
+	}
+	if len(o.CategoryIDs) > 0 && (p.CategoryID == nil || !slices.Contains(o.CategoryIDs, *p.CategoryID)) {
+		return false
+	}
+	return true
+}
+
+// less orders a before b according to the sort fields, falling back to ID so
+// pages are stable.
+func (o ListOptions) less(a, b Product) bool {
+	return o.compare(a, b) < 0
+}
+
+func (o ListOptions) compare(a, b Product) int {
+	for _, s := range o.Sort {
+		var c int
+		switch s.Field {
+		case "id":
+			c = cmp.Compare(a.ID, b.ID)
+		case "name":
+			c = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		case "price":
+			c = cmp.Compare(a.Price.Amount, b.Price.Amount)
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "updated_at":
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		}
+		if s.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// beyond reports whether p comes after the cursor, or before it for a
+// Before cursor.
+func (o ListOptions) beyond(p Product) bool {
+	c := o.compare(p, o.After.Key)
+	if o.After.Before {
+		return c < 0
+	}
+	return c > 0
+}
+
+func sortQuery(sort []SortField) string {
+	keys := make([]string, len(sort))
+	for i, s := range sort {
+		keys[i] = s.Field
+		if s.Desc {
+			keys[i] = "-" + s.Field
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+// listCursor is the encoded form of a Cursor. It records the sort it was
+// taken under, since its keys mean nothing under another one.
+type listCursor struct {
+	Sort   string   `json:"s"`
+	Keys   []string `json:"k"`
+	ID     int      `json:"id"`
+	Before bool     `json:"b,omitempty"`
+}
+
+func encodeListCursor(sort []SortField, c Cursor) string {
+	lc := listCursor{Sort: sortQuery(sort), ID: c.Key.ID, Before: c.Before}
+	for _, s := range sort {
+		lc.Keys = append(lc.Keys, sortKey(c.Key, s.Field))
+	}
+	data, _ := json.Marshal(lc)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(cursor string, sort []SortField) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Cursor{}, err
+	}
+
+	var lc listCursor
+	if err := json.Unmarshal(raw, &lc); err != nil {
+		return Cursor{}, err
+	}
+	if lc.Sort != sortQuery(sort) || len(lc.Keys) != len(sort) || lc.ID < 1 {
+		return Cursor{}, fmt.Errorf("malformed cursor")
+	}
+
+	c := Cursor{Key: Product{ID: lc.ID}, Before: lc.Before}
+	for i, s := range sort {
+		if err := setSortKey(&c.Key, s.Field, lc.Keys[i]); err != nil {
+			return Cursor{}, fmt.Errorf("malformed cursor")
+		}
+	}
+	return c, nil
+}
+
+// sortKey formats p's value for a sort field. Prices are in BaseCurrency.
+func sortKey(p Product, field string) string {
+	switch field {
+	case "id":
+		return strconv.Itoa(p.ID)
+	case "name":
+		return p.Name
+	case "price":
+		return p.Price.Decimal()
+	case "created_at":
+		return p.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return p.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	return ""
+}
+
+func setSortKey(p *Product, field, v string) error {
+	var err error
+	switch field {
+	case "id":
+		var id int
+		id, err = strconv.Atoi(v)
+		if err == nil && id != p.ID {
+			err = fmt.Errorf("cursor IDs disagree")
+		}
+	case "name":
+		p.Name = v
+	case "price":
+		p.Price, err = money.Parse(v, BaseCurrency)
+	case "created_at":
+		p.CreatedAt, err = time.Parse(time.RFC3339Nano, v)
+	case "updated_at":
+		p.UpdatedAt, err = time.Parse(time.RFC3339Nano, v)
+	}
+	return err
+}
+
+// sortValue is p's value for a sort field as MySQLStore compares it.
+func sortValue(p Product, field string) any {
+	switch field {
+	case "id":
+		return p.ID
+	case "name":
+		return p.Name
+	case "price":
+		return p.Price.Decimal()
+	case "created_at":
+		return p.CreatedAt
+	case "updated_at":
+		return p.UpdatedAt
+	}
+	return nil
+}
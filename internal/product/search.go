@@ -0,0 +1,200 @@
+package product
+
+import (
+	"html"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"lukekorsman.com/store/internal/api"
+)
+
+type SearchMode string
+
+const (
+	SearchModeNatural SearchMode = "natural"
+	SearchModeBoolean SearchMode = "boolean"
+)
+
+// snippetRadius is how many characters of context a highlight keeps on each
+// side of the first match.
+const snippetRadius = 60
+
+type SearchOptions struct {
+	Query  string
+	Mode   SearchMode
+	Limit  int
+	Offset int
+}
+
+type SearchHit struct {
+	Product    Product           `json:"product"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+type SearchResult struct {
+	Hits  []SearchHit `json:"hits"`
+	Total int         `json:"total"`
+}
+
+// ParseSearchOptions reads q and mode along with the same limit, offset and
+// cursor parameters ListOptions accepts.
+func ParseSearchOptions(q url.Values) (SearchOptions, []ValidationError) {
+	var opts SearchOptions
+	var errs []ValidationError
+
+	opts.Query = strings.TrimSpace(q.Get("q"))
+	if opts.Query == "" {
+		errs = append(errs, ValidationError{
+			Field:   "q",
+			Message: "q is required",
+		})
+	}
+
+	opts.Mode = SearchMode(q.Get("mode"))
+	switch opts.Mode {
+	case "":
+		opts.Mode = SearchModeNatural
+	case SearchModeNatural, SearchModeBoolean:
+	default:
+		errs = append(errs, ValidationError{
+			Field:   "mode",
+			Message: "mode must be natural or boolean",
+		})
+	}
+
+	limit, offset, pageErrs := api.ParsePage(q)
+	opts.Limit, opts.Offset = limit, offset
+	errs = append(errs, pageErrs...)
+
+	return opts, errs
+}
+
+// searchTerm is one word of a parsed query. In boolean mode a leading + makes
+// the term required, a leading - excludes it and a trailing * turns it into a
+// prefix match, following MySQL's boolean full-text syntax.
+type searchTerm struct {
+	text     string
+	prefix   bool
+	required bool
+	excluded bool
+}
+
+func parseSearchQuery(query string, mode SearchMode) []searchTerm {
+	var terms []searchTerm
+
+	for _, word := range strings.Fields(query) {
+		var term searchTerm
+		if mode == SearchModeBoolean {
+			switch {
+			case strings.HasPrefix(word, "+"):
+				term.required = true
+			case strings.HasPrefix(word, "-"):
+				term.excluded = true
+			}
+			term.prefix = strings.HasSuffix(word, "*")
+		}
+
+		for _, token := range tokenize(word) {
+			t := term
+			t.text = token
+			terms = append(terms, t)
+		}
+	}
+
+	return terms
+}
+
+func (t searchTerm) matches(token string) bool {
+	if t.prefix {
+		return strings.HasPrefix(token, t.text)
+	}
+	return token == t.text
+}
+
+// tokenize lowercases s and splits it into runs of letters and digits.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchFields returns the text of p that takes part in full-text search,
+// keyed by JSON field name.
+func searchFields(p Product) map[string]string {
+	return map[string]string{
+		"name":        p.Name,
+		"description": p.Description,
+	}
+}
+
+// highlights builds a snippet for every field that contains a non-excluded
+// query term.
+func highlights(fields map[string]string, terms []searchTerm) map[string]string {
+	out := make(map[string]string)
+	for field, text := range fields {
+		if snippet, ok := highlight(text, terms); ok {
+			out[field] = snippet
+		}
+	}
+	return out
+}
+
+// highlight wraps every word of text that matches a term in <mark> tags and
+// trims the result to a window around the first match. The surrounding text
+// is HTML-escaped so the snippet is safe to render as-is.
+func highlight(text string, terms []searchTerm) (string, bool) {
+	runes := []rune(text)
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+	type span struct{ start, end int }
+	var marks []span
+	for i := 0; i < len(runes); {
+		if !isWord(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWord(runes[j]) {
+			j++
+		}
+		token := strings.ToLower(string(runes[i:j]))
+		for _, t := range terms {
+			if !t.excluded && t.matches(token) {
+				marks = append(marks, span{i, j})
+				break
+			}
+		}
+		i = j
+	}
+
+	if len(marks) == 0 {
+		return "", false
+	}
+
+	from := max(marks[0].start-snippetRadius, 0)
+	to := min(marks[0].end+snippetRadius, len(runes))
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, m := range marks {
+		if m.start < from || m.end > to {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:m.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[m.start:m.end])))
+		b.WriteString("</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:to])))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+
+	return b.String(), true
+}
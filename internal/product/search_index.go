@@ -0,0 +1,141 @@
+package product
+
+import (
+	"math"
+	"slices"
+	"strings"
+)
+
+// fieldWeights boosts matches in some fields over others. Fields not listed
+// weigh 1.
+var fieldWeights = map[string]float64{
+	"name": 2,
+}
+
+// searchIndex is an in-memory inverted index over product text. It gives
+// MemoryStore the same search behaviour the FULLTEXT index gives MySQLStore.
+type searchIndex struct {
+	// postings maps a token to the weighted term frequency per product ID.
+	postings map[string]map[int]float64
+	// docs remembers the distinct tokens of each product so it can be removed.
+	docs map[int][]string
+	// terms holds every indexed token in sorted order for prefix lookups.
+	terms []string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[int]float64),
+		docs:     make(map[int][]string),
+	}
+}
+
+func (ix *searchIndex) add(p Product) {
+	ix.remove(p.ID)
+
+	var tokens []string
+	for field, text := range searchFields(p) {
+		weight, ok := fieldWeights[field]
+		if !ok {
+			weight = 1
+		}
+
+		for _, token := range tokenize(text) {
+			docs, ok := ix.postings[token]
+			if !ok {
+				docs = make(map[int]float64)
+				ix.postings[token] = docs
+				i, _ := slices.BinarySearch(ix.terms, token)
+				ix.terms = slices.Insert(ix.terms, i, token)
+			}
+			if _, seen := docs[p.ID]; !seen {
+				tokens = append(tokens, token)
+			}
+			docs[p.ID] += weight
+		}
+	}
+
+	ix.docs[p.ID] = tokens
+}
+
+func (ix *searchIndex) remove(id int) {
+	for _, token := range ix.docs[id] {
+		docs := ix.postings[token]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(ix.postings, token)
+			if i, found := slices.BinarySearch(ix.terms, token); found {
+				ix.terms = slices.Delete(ix.terms, i, i+1)
+			}
+		}
+	}
+	delete(ix.docs, id)
+}
+
+// expand returns the indexed tokens a term matches.
+func (ix *searchIndex) expand(t searchTerm) []string {
+	if !t.prefix {
+		if _, ok := ix.postings[t.text]; ok {
+			return []string{t.text}
+		}
+		return nil
+	}
+
+	var tokens []string
+	start, _ := slices.BinarySearch(ix.terms, t.text)
+	for i := start; i < len(ix.terms); i++ {
+		if !strings.HasPrefix(ix.terms[i], t.text) {
+			break
+		}
+		tokens = append(tokens, ix.terms[i])
+	}
+	return tokens
+}
+
+// search scores every product that satisfies terms using TF-IDF. Required
+// terms must all match, excluded terms must not, and the remaining terms
+// contribute to the score.
+func (ix *searchIndex) search(terms []searchTerm) map[int]float64 {
+	n := float64(len(ix.docs))
+	scores := make(map[int]float64)
+	excluded := make(map[int]bool)
+	var required []map[int]bool
+
+	for _, t := range terms {
+		matched := make(map[int]bool)
+		for _, token := range ix.expand(t) {
+			docs := ix.postings[token]
+			idf := math.Log(1 + n/float64(len(docs)))
+			for id, tf := range docs {
+				matched[id] = true
+				if !t.excluded {
+					scores[id] += tf * idf
+				}
+			}
+		}
+
+		switch {
+		case t.excluded:
+			for id := range matched {
+				excluded[id] = true
+			}
+		case t.required:
+			required = append(required, matched)
+		}
+	}
+
+	for id := range scores {
+		if excluded[id] {
+			delete(scores, id)
+			continue
+		}
+		for _, req := range required {
+			if !req[id] {
+				delete(scores, id)
+				break
+			}
+		}
+	}
+
+	return scores
+}
@@ -3,35 +3,87 @@ package product
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lukekorsman.com/store/internal/money"
 )
 
 type Store interface {
-	List(ctx context.Context) ([]Product, error)
+	List(ctx context.Context, opts ListOptions) (ListResult, error)
 	Create(ctx context.Context, p Product) (Product, error)
 	GetByID(ctx context.Context, id int) (Product, error)
+	// Update overwrites the product. If p.Version is set it must be the
+	// product's current version, or Update fails with ErrVersionMismatch.
 	Update(ctx context.Context, id int, p Product) (Product, error)
-	Delete(ctx context.Context, id int) error
+	// Delete archives the product, checking version like Update unless it
+	// is 0.
+	Delete(ctx context.Context, id, version int) error
+	Restore(ctx context.Context, id int) (Product, error)
+	Search(ctx context.Context, opts SearchOptions) (SearchResult, error)
+	// Import creates the rows, or with opts.Upsert updates the product with
+	// the same SKU, all or nothing. Rows that conflict with existing
+	// products are reported in the result's Errors and nothing is saved.
+	Import(ctx context.Context, rows []ImportRow, opts ImportOptions) (ImportResult, error)
+	// Batch applies the operations in order in one transaction. Operations
+	// that fail are reported in their BatchOutcome; with atomic set nothing
+	// is saved if any of them does.
+	Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchOutcome, error)
 }
 
 type MemoryStore struct {
 	products []Product
 	nextID   int
+	index    *searchIndex
+	mu       sync.RWMutex
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		nextID: 1,
+		index:  newSearchIndex(),
 	}
 }
 
-func (s *MemoryStore) List(ctx context.Context) ([]Product, error) {
+func (s *MemoryStore) List(ctx context.Context, opts ListOptions) (ListResult, error) {
 	select {
     case <-ctx.Done():
-        return nil, ctx.Err()
+        return ListResult{}, ctx.Err()
     default:
 	}
 
-	return s.products, nil
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := make([]Product, 0, len(s.products))
+	for _, p := range s.products {
+		if opts.matches(p) {
+			matched = append(matched, p)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return opts.less(matched[i], matched[j])
+	})
+
+	result := ListResult{Total: len(matched)}
+	if opts.After != nil {
+		matched = slices.DeleteFunc(matched, func(p Product) bool {
+			return !opts.beyond(p)
+		})
+		// Paging backwards takes the rows just before the cursor.
+		if opts.After.Before && opts.Limit > 0 && len(matched) > opts.Limit {
+			matched = matched[len(matched)-opts.Limit:]
+		}
+		opts.Offset = 0
+	}
+	start, end := pageBounds(opts.Offset, opts.Limit, len(matched))
+	result.Products = matched[start:end]
+
+	return result, nil
 }
 
 func (s *MemoryStore) Create(ctx context.Context, p Product) (Product, error) {
@@ -41,10 +93,42 @@ func (s *MemoryStore) Create(ctx context.Context, p Product) (Product, error) {
     default:
     }
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexBySKU(p.SKU) >= 0 {
+		return Product{}, fmt.Errorf("%w: %s", ErrDuplicateSKU, p.SKU)
+	}
+	return s.create(p), nil
+}
+
+func (s *MemoryStore) create(p Product) Product {
+	now := time.Now().UTC()
 	p.ID = s.nextID
+	p.Version = 1
+	p.CreatedAt = now
+	p.UpdatedAt = now
+	p.DeletedAt = nil
+	p.Tags = append([]string{}, p.Tags...)
+	p.Prices = clonePrices(p.Prices)
 	s.nextID++
 	s.products = append(s.products, p)
-	return p, nil
+	s.index.add(p)
+	return p
+}
+
+// indexBySKU finds the product, archived or not, with the given SKU. SKUs
+// compare case-insensitively like the MySQL unique index.
+func (s *MemoryStore) indexBySKU(sku string) int {
+	if sku == "" {
+		return -1
+	}
+	for i, p := range s.products {
+		if strings.EqualFold(p.SKU, sku) {
+			return i
+		}
+	}
+	return -1
 }
 
 func (s *MemoryStore) GetByID(ctx context.Context, id int) (Product, error) {
@@ -54,12 +138,15 @@ func (s *MemoryStore) GetByID(ctx context.Context, id int) (Product, error) {
     default:
     }
 
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	for _, p := range s.products {
-		if p.ID == id {
+		if p.ID == id && !p.Archived() {
 			return p, nil
 		}
 	}
-	return Product{}, fmt.Errorf("product %d not found", id)
+	return Product{}, fmt.Errorf("%w: %d", ErrProductNotFound, id)
 }
 
 func (s *MemoryStore) Update(ctx context.Context, id int, updated Product) (Product, error) {
@@ -69,28 +156,291 @@ func (s *MemoryStore) Update(ctx context.Context, id int, updated Product) (Prod
     default:
     }
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.live(id, updated.Version)
+	if err != nil {
+		return Product{}, err
+	}
+	if j := s.indexBySKU(updated.SKU); j >= 0 && j != i {
+		return Product{}, fmt.Errorf("%w: %s", ErrDuplicateSKU, updated.SKU)
+	}
+	return s.update(i, updated), nil
+}
+
+// live finds the product unless it's archived, checking its version unless
+// version is 0.
+func (s *MemoryStore) live(id, version int) (int, error) {
+	for i, p := range s.products {
+		if p.ID == id && !p.Archived() {
+			if version != 0 && version != p.Version {
+				return -1, fmt.Errorf("%w: product %d is at version %d", ErrVersionMismatch, id, p.Version)
+			}
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("product %d not found", id)
+}
+
+func (s *MemoryStore) update(i int, updated Product) Product {
+	p := s.products[i]
+	updated.ID = p.ID
+	updated.Version = p.Version + 1
+	updated.CreatedAt = p.CreatedAt
+	updated.UpdatedAt = time.Now().UTC()
+	updated.DeletedAt = nil
+	updated.Tags = append([]string{}, updated.Tags...)
+	updated.Prices = clonePrices(updated.Prices)
+	s.products[i] = updated
+	s.index.add(updated)
+	return updated
+}
+
+// touch bumps the product's version after a change stored outside it, such
+// as its images.
+func (s *MemoryStore) touch(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	for i, p := range s.products {
 		if p.ID == id {
-			updated.ID = id
-			s.products[i] = updated
-			return updated, nil
+			s.products[i].Version++
+			s.products[i].UpdatedAt = time.Now().UTC()
+			return
+		}
+	}
+}
+
+// Import checks every row before writing any, so a conflict leaves the
+// catalog untouched.
+func (s *MemoryStore) Import(ctx context.Context, rows []ImportRow, opts ImportOptions) (ImportResult, error) {
+	select {
+	case <-ctx.Done():
+		return ImportResult{}, ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := ImportResult{DryRun: opts.DryRun, Rows: []ImportedRow{}}
+	targets := make([]int, len(rows))
+	for n, row := range rows {
+		targets[n] = s.indexBySKU(row.Product.SKU)
+		if targets[n] < 0 {
+			continue
 		}
+		if msg := importConflict(s.products[targets[n]], opts.Upsert); msg != "" {
+			result.addError(row.Line, row.Product.SKU, ValidationError{Field: "sku", Message: msg})
+		}
+	}
+	if len(result.Errors) > 0 {
+		return result.failed(), nil
+	}
+
+	for n, row := range rows {
+		imported := ImportedRow{Line: row.Line, SKU: row.Product.SKU, Action: ImportCreated, Price: row.Product.Price}
+		if i := targets[n]; i >= 0 {
+			old := s.products[i].Price
+			imported.Action = ImportUpdated
+			imported.ID = s.products[i].ID
+			imported.OldPrice = &old
+			if !opts.DryRun {
+				s.update(i, row.Product)
+			}
+		} else if !opts.DryRun {
+			imported.ID = s.create(row.Product).ID
+		}
+		result.addRow(imported)
 	}
-	return Product{}, fmt.Errorf("product %d not found", id)
+
+	return result, nil
 }
 
-func (s *MemoryStore) Delete(ctx context.Context, id int) error {
+// Delete archives the product by setting DeletedAt. Archived products are
+// hidden from GetByID, Search and List unless IncludeDeleted is set.
+func (s *MemoryStore) Delete(ctx context.Context, id, version int) error {
 	select {
     case <-ctx.Done():
         return ctx.Err()
     default:
     }
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.live(id, version)
+	if err != nil {
+		return err
+	}
+	s.archive(i)
+	return nil
+}
+
+func (s *MemoryStore) archive(i int) {
+	now := time.Now().UTC()
+	s.products[i].Version++
+	s.products[i].DeletedAt = &now
+	s.products[i].UpdatedAt = now
+	s.index.remove(s.products[i].ID)
+}
+
+// Batch applies the operations to the catalog as it goes and puts back the
+// previous copy if an atomic batch fails.
+func (s *MemoryStore) Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchOutcome, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	products, nextID := slices.Clone(s.products), s.nextID
+	outcomes := make([]BatchOutcome, len(ops))
+	failed := false
+	for n, op := range ops {
+		o := &outcomes[n]
+		switch op.Op {
+		case BatchCreate:
+			if s.indexBySKU(op.Product.SKU) >= 0 {
+				o.Err = fmt.Errorf("%w: %s", ErrDuplicateSKU, op.Product.SKU)
+				break
+			}
+			o.Product = s.create(*op.Product)
+		case BatchUpdate:
+			i, err := s.live(op.ID, op.Version)
+			if err != nil {
+				o.Err = err
+				break
+			}
+			if j := s.indexBySKU(op.Product.SKU); j >= 0 && j != i {
+				o.Err = fmt.Errorf("%w: %s", ErrDuplicateSKU, op.Product.SKU)
+				break
+			}
+			before := s.products[i]
+			o.Before = &before
+			o.Product = s.update(i, *op.Product)
+		case BatchDelete:
+			i, err := s.live(op.ID, op.Version)
+			if err != nil {
+				o.Err = err
+				break
+			}
+			before := s.products[i]
+			o.Before = &before
+			s.archive(i)
+			o.Product = Product{ID: op.ID}
+		default:
+			return nil, fmt.Errorf("unknown batch operation %q", op.Op)
+		}
+		failed = failed || o.Err != nil
+	}
+
+	if atomic && failed {
+		s.products, s.nextID = products, nextID
+		for _, o := range outcomes {
+			if o.Err == nil {
+				s.reindex(o.Product.ID)
+			}
+		}
+	}
+	return outcomes, nil
+}
+
+// reindex brings the search index in line with the stored product.
+func (s *MemoryStore) reindex(id int) {
+	for _, p := range s.products {
+		if p.ID == id && !p.Archived() {
+			s.index.add(p)
+			return
+		}
+	}
+	s.index.remove(id)
+}
+
+// Restore brings back a soft-deleted product.
+func (s *MemoryStore) Restore(ctx context.Context, id int) (Product, error) {
+	select {
+	case <-ctx.Done():
+		return Product{}, ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	for i, p := range s.products {
-		if p.ID == id {
-			s.products = append(s.products[:i], s.products[i+1:]...)
-			return nil
+		if p.ID == id && p.Archived() {
+			s.products[i].Version++
+			s.products[i].DeletedAt = nil
+			s.products[i].UpdatedAt = time.Now().UTC()
+			s.index.add(s.products[i])
+			return s.products[i], nil
+		}
+	}
+	return Product{}, fmt.Errorf("deleted product %d not found", id)
+}
+
+func (s *MemoryStore) Search(ctx context.Context, opts SearchOptions) (SearchResult, error) {
+	select {
+	case <-ctx.Done():
+		return SearchResult{}, ctx.Err()
+	default:
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms := parseSearchQuery(opts.Query, opts.Mode)
+	scores := s.index.search(terms)
+
+	hits := make([]SearchHit, 0, len(scores))
+	for _, p := range s.products {
+		if score, ok := scores[p.ID]; ok {
+			hits = append(hits, SearchHit{Product: p, Score: score})
 		}
 	}
-	return fmt.Errorf("product %d not found", id)
-}
\ No newline at end of file
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Product.ID < hits[j].Product.ID
+	})
+
+	result := SearchResult{Total: len(hits)}
+	start, end := pageBounds(opts.Offset, opts.Limit, len(hits))
+	result.Hits = hits[start:end]
+
+	for i := range result.Hits {
+		result.Hits[i].Highlights = highlights(searchFields(result.Hits[i].Product), terms)
+	}
+
+	return result, nil
+}
+
+// pageBounds clamps an offset/limit window to a slice of length n. A zero
+// limit means no limit.
+func pageBounds(offset, limit, n int) (start, end int) {
+	start = min(offset, n)
+	end = n
+	if limit > 0 {
+		end = min(start+limit, n)
+	}
+	return start, end
+}
+// clonePrices copies a price list sorted by currency, the order MySQLStore
+// returns it in.
+func clonePrices(prices []money.Money) []money.Money {
+	if len(prices) == 0 {
+		return nil
+	}
+	prices = slices.Clone(prices)
+	slices.SortFunc(prices, func(a, b money.Money) int {
+		return strings.Compare(string(a.Currency), string(b.Currency))
+	})
+	return prices
+}
@@ -1,17 +1,14 @@
 package product
 
 import (
+	"fmt"
 	"strings"
+
+	"lukekorsman.com/store/internal/api"
+	"lukekorsman.com/store/internal/money"
 )
 
-type ValidationError struct {
-	Field string
-	Message string
-}
-
-func (v ValidationError) Error() string {
-	return v.Field + ": " + v.Message
-}
+type ValidationError = api.ValidationError
 
 func ValidateProduct(p Product) []ValidationError {
 	var errs []ValidationError
@@ -30,19 +27,266 @@ func ValidateProduct(p Product) []ValidationError {
 		})
 	}
 
-	if p.Price <= 0 {
+	if p.SKU != "" {
+		errs = append(errs, validateSKU("sku", p.SKU)...)
+	}
+
+	if len(p.Description) > 5000 {
 		errs = append(errs, ValidationError{
-			Field: "price",
-			Message: "price must be greater than 0",
+			Field: "description",
+			Message: "description must be less than 5000 characters",
 		})
 	}
 
-	if p.Price > 999999.99 {
+	if len(p.Tags) > 20 {
 		errs = append(errs, ValidationError{
-			Field: "price",
-			Message: "price is too large",
+			Field: "tags",
+			Message: "a product can have at most 20 tags",
+		})
+	}
+
+	for _, tag := range p.Tags {
+		if strings.TrimSpace(tag) == "" || len(tag) > 50 {
+			errs = append(errs, ValidationError{
+				Field: "tags",
+				Message: "tags must be between 1 and 50 characters",
+			})
+			break
+		}
+	}
+
+	errs = append(errs, validatePrice("price", p.Price)...)
+
+	currencies := map[money.Currency]bool{BaseCurrency: true}
+	for i, price := range p.Prices {
+		field := fmt.Sprintf("prices[%d]", i)
+		if price.Currency == BaseCurrency {
+			errs = append(errs, ValidationError{
+				Field: field + ".currency",
+				Message: fmt.Sprintf("the %s price is set by price", BaseCurrency),
+			})
+			continue
+		}
+		if currencies[price.Currency] {
+			errs = append(errs, ValidationError{
+				Field: field + ".currency",
+				Message: fmt.Sprintf("%s is listed more than once", price.Currency),
+			})
+			continue
+		}
+		currencies[price.Currency] = true
+		errs = append(errs, validateAmount(field, price)...)
+	}
+
+	errs = append(errs, validateOptions(p.Options)...)
+	errs = append(errs, validateVariants(p.Options, p.Variants)...)
+
+	return errs
+}
+
+const (
+	maxOptions  = 3
+	maxVariants = 100
+)
+
+func validateOptions(options []Option) []ValidationError {
+	var errs []ValidationError
+
+	if len(options) > maxOptions {
+		errs = append(errs, ValidationError{
+			Field: "options",
+			Message: fmt.Sprintf("a product can have at most %d options", maxOptions),
 		})
 	}
 
+	names := make(map[string]bool)
+	for i, option := range options {
+		field := fmt.Sprintf("options[%d]", i)
+		name := strings.ToLower(strings.TrimSpace(option.Name))
+
+		if name == "" || len(name) > 50 {
+			errs = append(errs, ValidationError{
+				Field: field + ".name",
+				Message: "option name must be between 1 and 50 characters",
+			})
+		} else if names[name] {
+			errs = append(errs, ValidationError{
+				Field: field + ".name",
+				Message: fmt.Sprintf("option %q is defined more than once", option.Name),
+			})
+		}
+		names[name] = true
+
+		if len(option.Values) == 0 {
+			errs = append(errs, ValidationError{
+				Field: field + ".values",
+				Message: "option must have at least one value",
+			})
+		}
+
+		values := make(map[string]bool)
+		for _, value := range option.Values {
+			v := strings.ToLower(strings.TrimSpace(value))
+			if v == "" || values[v] {
+				errs = append(errs, ValidationError{
+					Field: field + ".values",
+					Message: "option values must be non-empty and unique",
+				})
+				break
+			}
+			values[v] = true
+		}
+	}
+
 	return errs
+}
+
+// validateVariants checks that SKU codes are unique and that every variant
+// picks exactly one value of each option, with no two variants sharing a
+// combination.
+func validateVariants(options []Option, variants []Variant) []ValidationError {
+	var errs []ValidationError
+
+	if len(variants) > maxVariants {
+		errs = append(errs, ValidationError{
+			Field: "variants",
+			Message: fmt.Sprintf("a product can have at most %d variants", maxVariants),
+		})
+	}
+
+	allowed := make(map[string]map[string]bool, len(options))
+	for _, option := range options {
+		values := make(map[string]bool, len(option.Values))
+		for _, value := range option.Values {
+			values[strings.ToLower(value)] = true
+		}
+		allowed[strings.ToLower(option.Name)] = values
+	}
+
+	skus := make(map[string]bool)
+	combinations := make(map[string]bool)
+	for i, v := range variants {
+		field := fmt.Sprintf("variants[%d]", i)
+
+		sku := strings.ToUpper(v.SKU)
+		if skuErrs := validateSKU(field+".sku", v.SKU); len(skuErrs) > 0 {
+			errs = append(errs, skuErrs...)
+		} else if skus[sku] {
+			errs = append(errs, ValidationError{
+				Field: field + ".sku",
+				Message: fmt.Sprintf("sku %q is used by more than one variant", v.SKU),
+			})
+		}
+		skus[sku] = true
+
+		valid := len(v.Options) == len(options)
+		for name, value := range v.Options {
+			if !allowed[strings.ToLower(name)][strings.ToLower(value)] {
+				valid = false
+			}
+		}
+		if !valid {
+			errs = append(errs, ValidationError{
+				Field: field + ".options",
+				Message: "variant must pick exactly one defined value for every option",
+			})
+		} else if key := combinationKey(v.Options); combinations[key] {
+			errs = append(errs, ValidationError{
+				Field: field + ".options",
+				Message: "another variant already has this option combination",
+			})
+		} else {
+			combinations[key] = true
+		}
+
+		if v.Price != nil {
+			errs = append(errs, validatePrice(field+".price", *v.Price)...)
+		}
+
+		if v.Stock < 0 {
+			errs = append(errs, ValidationError{
+				Field: field + ".stock",
+				Message: "stock cannot be negative",
+			})
+		}
+
+		if v.Barcode != "" && !isBarcode(v.Barcode) {
+			errs = append(errs, ValidationError{
+				Field: field + ".barcode",
+				Message: "barcode must be 8, 12, 13 or 14 digits",
+			})
+		}
+	}
+
+	return errs
+}
+
+func validateSKU(field, sku string) []ValidationError {
+	if sku == "" || len(sku) > 64 {
+		return []ValidationError{{
+			Field: field,
+			Message: "sku must be between 1 and 64 characters",
+		}}
+	}
+	if strings.IndexFunc(sku, func(r rune) bool { return !isSKURune(r) }) >= 0 {
+		return []ValidationError{{
+			Field: field,
+			Message: "sku may only contain letters, digits, '-', '_' and '.'",
+		}}
+	}
+	return nil
+}
+
+// maxPriceAmount is the largest price in minor units, 999999.99 in a
+// two-decimal currency, which keeps it within the DECIMAL(10,2) columns.
+const maxPriceAmount = 99999999
+
+// validatePrice checks a catalog price, which must be in BaseCurrency.
+func validatePrice(field string, m money.Money) []ValidationError {
+	if m.Currency != BaseCurrency {
+		return []ValidationError{{
+			Field: field + ".currency",
+			Message: fmt.Sprintf("price must be in %s", BaseCurrency),
+		}}
+	}
+	return validateAmount(field, m)
+}
+
+// validateAmount checks a price's currency is supported and its amount is
+// greater than zero and no larger than maxPriceAmount.
+func validateAmount(field string, m money.Money) []ValidationError {
+	if !m.Currency.Valid() {
+		return []ValidationError{{
+			Field: field + ".currency",
+			Message: fmt.Sprintf("currency %q is not supported", m.Currency),
+		}}
+	}
+	if m.Amount <= 0 {
+		return []ValidationError{{
+			Field: field,
+			Message: "price must be greater than 0",
+		}}
+	}
+	if m.Amount > maxPriceAmount {
+		return []ValidationError{{
+			Field: field,
+			Message: "price is too large",
+		}}
+	}
+	return nil
+}
+
+// isBarcode accepts the GTIN family: EAN-8, UPC-A, EAN-13 and GTIN-14.
+func isBarcode(s string) bool {
+	switch len(s) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
 }
\ No newline at end of file
@@ -1,9 +1,18 @@
 package product
 
 import (
+	"strings"
 	"testing"
+
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/money/moneytest"
 )
 
+var sizeAndColor = []Option{
+	{Name: "size", Values: []string{"M", "L"}},
+	{Name: "color", Values: []string{"red"}},
+}
+
 func TestValidateProduct(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -12,24 +21,124 @@ func TestValidateProduct(t *testing.T) {
 	}{
 		{
 			name:     "validate product",
-			product:  Product{Name: "Book", Price: 10.99},
+			product:  Product{Name: "Book", Price: moneytest.USD(1099)},
 			wantErrs: 0,
 		},
 		{
 			name:     "empty name",
-			product:  Product{Name: "", Price: 10},
+			product:  Product{Name: "", Price: moneytest.USD(1000)},
 			wantErrs: 1,
 		},
 		{
 			name:     "zero price",
-			product:  Product{Name: "Book", Price: 0},
+			product:  Product{Name: "Book", Price: moneytest.USD(0)},
+			wantErrs: 1,
+		},
+		{
+			name:     "description too long",
+			product:  Product{Name: "Book", Description: strings.Repeat("a", 5001), Price: moneytest.USD(1000)},
+			wantErrs: 1,
+		},
+		{
+			name: "valid variants",
+			product: Product{
+				Name:    "T-Shirt",
+				Price:   moneytest.USD(2000),
+				Options: sizeAndColor,
+				Variants: []Variant{
+					{SKU: "TS-M-RED", Options: map[string]string{"size": "M", "color": "red"}},
+					{SKU: "TS-L-RED", Options: map[string]string{"size": "L", "color": "red"}, Barcode: "0123456789012"},
+				},
+			},
+			wantErrs: 0,
+		},
+		{
+			name: "duplicate sku",
+			product: Product{
+				Name:    "T-Shirt",
+				Price:   moneytest.USD(2000),
+				Options: sizeAndColor,
+				Variants: []Variant{
+					{SKU: "TS-1", Options: map[string]string{"size": "M", "color": "red"}},
+					{SKU: "ts-1", Options: map[string]string{"size": "L", "color": "red"}},
+				},
+			},
+			wantErrs: 1,
+		},
+		{
+			name: "duplicate option combination",
+			product: Product{
+				Name:    "T-Shirt",
+				Price:   moneytest.USD(2000),
+				Options: sizeAndColor,
+				Variants: []Variant{
+					{SKU: "TS-1", Options: map[string]string{"size": "M", "color": "red"}},
+					{SKU: "TS-2", Options: map[string]string{"color": "RED", "size": "m"}},
+				},
+			},
+			wantErrs: 1,
+		},
+		{
+			name: "undefined and missing option values",
+			product: Product{
+				Name:    "T-Shirt",
+				Price:   moneytest.USD(2000),
+				Options: sizeAndColor,
+				Variants: []Variant{
+					{SKU: "TS-1", Options: map[string]string{"size": "XXL", "color": "red"}},
+					{SKU: "TS-2", Options: map[string]string{"size": "M"}},
+				},
+			},
+			wantErrs: 2,
+		},
+		{
+			name: "duplicate option name",
+			product: Product{
+				Name:    "T-Shirt",
+				Price:   moneytest.USD(2000),
+				Options: []Option{{Name: "Size", Values: []string{"M"}}, {Name: "size", Values: []string{"L"}}},
+			},
 			wantErrs: 1,
 		},
 		{
 			name:     "multiple errors",
-			product:  Product{Name: "", Price: -5},
+			product:  Product{Name: "", Price: moneytest.USD(-500)},
 			wantErrs: 2,
 		},
+		{
+			name:     "price not in base currency",
+			product:  Product{Name: "Book", Price: money.New(1000, money.EUR)},
+			wantErrs: 1,
+		},
+		{
+			name:     "price too large",
+			product:  Product{Name: "Book", Price: moneytest.USD(100000000)},
+			wantErrs: 1,
+		},
+		{
+			name: "price list",
+			product: Product{
+				Name:   "Book",
+				Price:  moneytest.USD(1000),
+				Prices: []money.Money{money.New(900, money.EUR), money.New(1500, money.JPY)},
+			},
+			wantErrs: 0,
+		},
+		{
+			name: "invalid price list",
+			product: Product{
+				Name:  "Book",
+				Price: moneytest.USD(1000),
+				Prices: []money.Money{
+					money.New(1000, money.USD),
+					money.New(900, money.EUR),
+					money.New(950, money.EUR),
+					money.New(0, money.GBP),
+					money.New(100, "XYZ"),
+				},
+			},
+			wantErrs: 4,
+		},
 	}
 
 	for _, tt := range tests {
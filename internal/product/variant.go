@@ -0,0 +1,118 @@
+package product
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"lukekorsman.com/store/internal/money"
+)
+
+// ErrDuplicateSKU is returned by a VariantStore when a SKU code is already
+// used by another product's variant.
+var ErrDuplicateSKU = errors.New("sku already in use")
+
+// Option is one axis a product varies along, such as size or color.
+type Option struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// Variant is a sellable combination of option values with its own SKU.
+type Variant struct {
+	ID        int               `json:"id"`
+	ProductID int               `json:"product_id"`
+	SKU       string            `json:"sku"`
+	Options   map[string]string `json:"options"`
+	// Price overrides the product price when set.
+	Price     *money.Money `json:"price"`
+	Stock     int          `json:"stock"`
+	Barcode   string       `json:"barcode"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// EffectivePrice is the variant's override price, or the product price when
+// there is none.
+func (v Variant) EffectivePrice(p Product) money.Money {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return p.Price
+}
+
+// combinationKey identifies a variant's option values independent of map
+// order, e.g. "color=red|size=m".
+func combinationKey(options map[string]string) string {
+	parts := make([]string, 0, len(options))
+	for name, value := range options {
+		parts = append(parts, strings.ToLower(name)+"="+strings.ToLower(value))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "|")
+}
+
+// GenerateVariants returns one variant for every combination of option
+// values. Existing variants whose combination is still valid are kept as-is
+// so their IDs, stock and prices survive; new combinations get a SKU derived
+// from the product ID and option values.
+func GenerateVariants(productID int, options []Option, existing []Variant) []Variant {
+	byKey := make(map[string]Variant, len(existing))
+	for _, v := range existing {
+		byKey[combinationKey(v.Options)] = v
+	}
+
+	combinations := []map[string]string{{}}
+	for _, option := range options {
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, value := range option.Values {
+				c := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					c[k] = v
+				}
+				c[option.Name] = value
+				next = append(next, c)
+			}
+		}
+		combinations = next
+	}
+
+	variants := make([]Variant, 0, len(combinations))
+	for _, combination := range combinations {
+		if v, ok := byKey[combinationKey(combination)]; ok {
+			variants = append(variants, v)
+			continue
+		}
+
+		sku := fmt.Sprintf("P%d", productID)
+		for _, option := range options {
+			sku += "-" + skuSegment(combination[option.Name])
+		}
+		variants = append(variants, Variant{
+			ProductID: productID,
+			SKU:       sku,
+			Options:   combination,
+		})
+	}
+
+	return variants
+}
+
+// skuSegment uppercases value and keeps only characters valid in a SKU.
+func skuSegment(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(value) {
+		if isSKURune(r) && r != '-' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isSKURune(r rune) bool {
+	return (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') ||
+		r == '-' || r == '_' || r == '.'
+}
@@ -0,0 +1,223 @@
+package product
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type VariantHandler struct {
+	products Store
+	variants VariantStore
+}
+
+func NewVariantHandler(products Store, variants VariantStore) *VariantHandler {
+	return &VariantHandler{
+		products: products,
+		variants: variants,
+	}
+}
+
+type VariantsResponse struct {
+	Options  []Option  `json:"options"`
+	Variants []Variant `json:"variants"`
+}
+
+type GenerateVariantsRequest struct {
+	Options []Option `json:"options"`
+}
+
+func (h *VariantHandler) List(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, VariantsResponse{Options: p.Options, Variants: p.Variants})
+}
+
+// Replace swaps the product's options and variants for the ones in the body.
+func (h *VariantHandler) Replace(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	var req VariantsResponse
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Only IDs the product already owns may be kept.
+	owned := make(map[int]bool)
+	for _, v := range p.Variants {
+		owned[v.ID] = true
+	}
+	for i := range req.Variants {
+		if !owned[req.Variants[i].ID] {
+			req.Variants[i].ID = 0
+		}
+	}
+
+	p.Options = req.Options
+	p.Variants = req.Variants
+	h.save(w, r, p, http.StatusOK)
+}
+
+// Generate sets the option axes and creates a variant for every combination
+// of their values, keeping existing variants that still fit.
+func (h *VariantHandler) Generate(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	var req GenerateVariantsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if errs := validateOptions(req.Options); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	p.Options = req.Options
+	p.Variants = GenerateVariants(p.ID, req.Options, p.Variants)
+	h.save(w, r, p, http.StatusOK)
+}
+
+func (h *VariantHandler) Create(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	var v Variant
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	v.ID = 0
+	p.Variants = append(p.Variants, v)
+	h.save(w, r, p, http.StatusCreated)
+}
+
+func (h *VariantHandler) Update(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	i, ok := variantIndex(w, r, p)
+	if !ok {
+		return
+	}
+
+	var v Variant
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	v.ID = p.Variants[i].ID
+	p.Variants[i] = v
+	h.save(w, r, p, http.StatusOK)
+}
+
+func (h *VariantHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	i, ok := variantIndex(w, r, p)
+	if !ok {
+		return
+	}
+
+	p.Variants = append(p.Variants[:i], p.Variants[i+1:]...)
+	if _, err := h.variants.Save(r.Context(), p.ID, p.Options, p.Variants); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// load fetches the product named in the URL together with its options and
+// variants.
+func (h *VariantHandler) load(w http.ResponseWriter, r *http.Request) (Product, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return Product{}, false
+	}
+
+	p, err := h.products.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return Product{}, false
+	}
+
+	p.Options, p.Variants, err = h.variants.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return Product{}, false
+	}
+
+	return p, true
+}
+
+// save validates the product with its new variants and persists them. A
+// created variant is answered with the variant itself, anything else with
+// the full set.
+func (h *VariantHandler) save(w http.ResponseWriter, r *http.Request, p Product, status int) {
+	if errs := ValidateProduct(p); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	saved, err := h.variants.Save(r.Context(), p.ID, p.Options, p.Variants)
+	if errors.Is(err, ErrDuplicateSKU) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if status == http.StatusCreated {
+		writeJSON(w, status, saved[len(saved)-1])
+		return
+	}
+	writeJSON(w, status, VariantsResponse{Options: p.Options, Variants: saved})
+}
+
+func variantIndex(w http.ResponseWriter, r *http.Request, p Product) (int, bool) {
+	variantID, err := strconv.Atoi(chi.URLParam(r, "variantID"))
+	if err != nil {
+		http.Error(w, "invalid variant ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	for i, v := range p.Variants {
+		if v.ID == variantID {
+			return i, true
+		}
+	}
+
+	http.Error(w, "variant not found", http.StatusNotFound)
+	return 0, false
+}
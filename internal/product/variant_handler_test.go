@@ -0,0 +1,137 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"lukekorsman.com/store/internal/money/moneytest"
+)
+
+func newVariantRouter(products Store, variants VariantStore) http.Handler {
+	h := NewVariantHandler(products, variants)
+	r := chi.NewRouter()
+	r.Get("/products/{id}/variants", h.List)
+	r.Put("/products/{id}/variants", h.Replace)
+	r.Post("/products/{id}/variants", h.Create)
+	r.Post("/products/{id}/variants/generate", h.Generate)
+	r.Put("/products/{id}/variants/{variantID}", h.Update)
+	r.Delete("/products/{id}/variants/{variantID}", h.Delete)
+	return r
+}
+
+func TestGenerateVariants(t *testing.T) {
+	ctx := context.Background()
+	products := NewMemoryStore()
+	shirt, _ := products.Create(ctx, Product{Name: "T-Shirt", Price: moneytest.USD(2000)})
+	router := newVariantRouter(products, NewMemoryVariantStore())
+
+	generate := func(body string) VariantsResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/products/%d/variants/generate", shirt.ID), strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+
+		var resp VariantsResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return resp
+	}
+
+	first := generate(`{"options":[{"name":"size","values":["S","M"]},{"name":"color","values":["Red","Navy Blue"]}]}`)
+	if len(first.Variants) != 4 {
+		t.Fatalf("expected 4 variants, got %d", len(first.Variants))
+	}
+	wantSKU := fmt.Sprintf("P%d-M-NAVYBLUE", shirt.ID)
+	var kept Variant
+	for _, v := range first.Variants {
+		if v.SKU == wantSKU {
+			kept = v
+		}
+	}
+	if kept.ID == 0 {
+		t.Fatalf("expected a variant with SKU %s in %+v", wantSKU, first.Variants)
+	}
+
+	// Dropping size S keeps the surviving variants and their IDs.
+	second := generate(`{"options":[{"name":"size","values":["M","L"]},{"name":"color","values":["Red","Navy Blue"]}]}`)
+	if len(second.Variants) != 4 {
+		t.Fatalf("expected 4 variants, got %d", len(second.Variants))
+	}
+	found := false
+	for _, v := range second.Variants {
+		if v.Options["size"] == "S" {
+			t.Fatalf("variant for removed size S survived: %+v", v)
+		}
+		if v.ID == kept.ID && v.SKU == kept.SKU {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected variant %d to be kept", kept.ID)
+	}
+}
+
+func TestCreateVariant(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "valid",
+			body:       `{"sku":"MUG-BLUE","options":{"color":"blue"},"price":{"amount":1250,"currency":"USD"},"stock":3}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "sku used by another product",
+			body:       `{"sku":"CAP-RED","options":{"color":"blue"}}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "combination already taken",
+			body:       `{"sku":"MUG-RED-2","options":{"color":"red"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown option value",
+			body:       `{"sku":"MUG-GREEN","options":{"color":"green"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "negative stock",
+			body:       `{"sku":"MUG-BLUE","options":{"color":"blue"},"stock":-1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			products := NewMemoryStore()
+			mug, _ := products.Create(ctx, Product{Name: "Mug", Price: moneytest.USD(1000)})
+			cap, _ := products.Create(ctx, Product{Name: "Cap", Price: moneytest.USD(1500)})
+
+			colors := []Option{{Name: "color", Values: []string{"red", "blue"}}}
+			variants := NewMemoryVariantStore()
+			variants.Save(ctx, mug.ID, colors, []Variant{{SKU: "MUG-RED", Options: map[string]string{"color": "red"}}})
+			variants.Save(ctx, cap.ID, colors, []Variant{{SKU: "CAP-RED", Options: map[string]string{"color": "red"}}})
+
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/products/%d/variants", mug.ID), strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			newVariantRouter(products, variants).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
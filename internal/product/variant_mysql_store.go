@@ -0,0 +1,172 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"lukekorsman.com/store/internal/money"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlDuplicateEntry is the MySQL error number for a unique key violation.
+const mysqlDuplicateEntry = 1062
+
+type MySQLVariantStore struct {
+	db *sql.DB
+}
+
+func NewMySQLVariantStore(db *sql.DB) *MySQLVariantStore {
+	return &MySQLVariantStore{db: db}
+}
+
+func (s *MySQLVariantStore) Get(ctx context.Context, productID int) ([]Option, []Variant, error) {
+	options, err := s.options(ctx, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT id, product_id, sku, options, price, stock, COALESCE(barcode, ''), created_at, updated_at "+
+			"FROM product_variants WHERE product_id = ? ORDER BY id",
+		productID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	variants := []Variant{}
+	for rows.Next() {
+		var v Variant
+		var optionsJSON []byte
+		var price sql.NullString
+		err := rows.Scan(&v.ID, &v.ProductID, &v.SKU, &optionsJSON, &price, &v.Stock, &v.Barcode, &v.CreatedAt, &v.UpdatedAt)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal(optionsJSON, &v.Options); err != nil {
+			return nil, nil, fmt.Errorf("variant %d: %w", v.ID, err)
+		}
+		if price.Valid {
+			m, err := money.Parse(price.String, BaseCurrency)
+			if err != nil {
+				return nil, nil, fmt.Errorf("variant %d: %w", v.ID, err)
+			}
+			v.Price = &m
+		}
+		variants = append(variants, v)
+	}
+
+	return options, variants, rows.Err()
+}
+
+func (s *MySQLVariantStore) options(ctx context.Context, productID int) ([]Option, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT name, option_values FROM product_options WHERE product_id = ? ORDER BY position",
+		productID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := []Option{}
+	for rows.Next() {
+		var o Option
+		var valuesJSON []byte
+		if err := rows.Scan(&o.Name, &valuesJSON); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(valuesJSON, &o.Values); err != nil {
+			return nil, fmt.Errorf("option %s: %w", o.Name, err)
+		}
+		options = append(options, o)
+	}
+
+	return options, rows.Err()
+}
+
+func (s *MySQLVariantStore) Save(ctx context.Context, productID int, options []Option, variants []Variant) ([]Variant, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_options WHERE product_id = ?", productID); err != nil {
+		return nil, err
+	}
+	for i, o := range options {
+		values, _ := json.Marshal(o.Values)
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO product_options (product_id, name, position, option_values) VALUES (?, ?, ?, ?)",
+			productID, o.Name, i, values,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Drop removed variants first so a kept variant can take over a SKU that
+	// a removed one used.
+	keep := []any{productID}
+	for _, v := range variants {
+		if v.ID != 0 {
+			keep = append(keep, v.ID)
+		}
+	}
+	query := "DELETE FROM product_variants WHERE product_id = ?"
+	if len(keep) > 1 {
+		query += " AND id NOT IN (" + placeholders(len(keep)-1) + ")"
+	}
+	if _, err := tx.ExecContext(ctx, query, keep...); err != nil {
+		return nil, err
+	}
+
+	for _, v := range variants {
+		optionsJSON, _ := json.Marshal(v.Options)
+		var barcode *string
+		if v.Barcode != "" {
+			barcode = &v.Barcode
+		}
+
+		if v.ID != 0 {
+			_, err = tx.ExecContext(ctx,
+				"UPDATE product_variants SET sku = ?, options = ?, price = ?, stock = ?, barcode = ? "+
+					"WHERE id = ? AND product_id = ?",
+				v.SKU, optionsJSON, decimalOrNil(v.Price), v.Stock, barcode, v.ID, productID,
+			)
+		} else {
+			_, err = tx.ExecContext(ctx,
+				"INSERT INTO product_variants (product_id, sku, options, price, stock, barcode) VALUES (?, ?, ?, ?, ?, ?)",
+				productID, v.SKU, optionsJSON, decimalOrNil(v.Price), v.Stock, barcode,
+			)
+		}
+		if err != nil {
+			var mysqlErr *mysql.MySQLError
+			if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
+				return nil, fmt.Errorf("%w: %s", ErrDuplicateSKU, v.SKU)
+			}
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	_, saved, err := s.Get(ctx, productID)
+	return saved, err
+}
+
+// decimalOrNil is the DECIMAL column value for an optional price.
+func decimalOrNil(m *money.Money) any {
+	if m == nil {
+		return nil
+	}
+	return m.Decimal()
+}
@@ -0,0 +1,84 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VariantStore persists a product's option axes and variants. Save replaces
+// the whole set: variants with a known ID are updated, variants without one
+// are created and any variant left out is deleted.
+type VariantStore interface {
+	Get(ctx context.Context, productID int) ([]Option, []Variant, error)
+	Save(ctx context.Context, productID int, options []Option, variants []Variant) ([]Variant, error)
+}
+
+type MemoryVariantStore struct {
+	options  map[int][]Option
+	variants map[int][]Variant
+	nextID   int
+	mu       sync.RWMutex
+}
+
+func NewMemoryVariantStore() *MemoryVariantStore {
+	return &MemoryVariantStore{
+		options:  make(map[int][]Option),
+		variants: make(map[int][]Variant),
+		nextID:   1,
+	}
+}
+
+func (s *MemoryVariantStore) Get(ctx context.Context, productID int) ([]Option, []Variant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	options := append([]Option{}, s.options[productID]...)
+	variants := append([]Variant{}, s.variants[productID]...)
+	return options, variants, nil
+}
+
+func (s *MemoryVariantStore) Save(ctx context.Context, productID int, options []Option, variants []Variant) ([]Variant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for otherID, others := range s.variants {
+		if otherID == productID {
+			continue
+		}
+		for _, other := range others {
+			for _, v := range variants {
+				if strings.EqualFold(other.SKU, v.SKU) {
+					return nil, fmt.Errorf("%w: %s", ErrDuplicateSKU, v.SKU)
+				}
+			}
+		}
+	}
+
+	existing := make(map[int]Variant)
+	for _, v := range s.variants[productID] {
+		existing[v.ID] = v
+	}
+
+	now := time.Now().UTC()
+	saved := make([]Variant, len(variants))
+	for i, v := range variants {
+		v.ProductID = productID
+		if old, ok := existing[v.ID]; ok && v.ID != 0 {
+			v.CreatedAt = old.CreatedAt
+		} else {
+			v.ID = s.nextID
+			s.nextID++
+			v.CreatedAt = now
+		}
+		v.UpdatedAt = now
+		saved[i] = v
+	}
+
+	s.options[productID] = append([]Option{}, options...)
+	s.variants[productID] = saved
+
+	return append([]Variant{}, saved...), nil
+}
@@ -0,0 +1,174 @@
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"lukekorsman.com/store/internal/api"
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/product"
+)
+
+type Kind string
+
+const (
+	// KindPercentage takes Value percent off eligible lines.
+	KindPercentage Kind = "percentage"
+	// KindFixed takes Amount off eligible lines, spread by line subtotal.
+	KindFixed Kind = "fixed"
+	// KindBuyXGetY discounts the cheapest GetQuantity units by Value percent
+	// for every BuyQuantity+GetQuantity eligible units.
+	KindBuyXGetY Kind = "buy_x_get_y"
+)
+
+var (
+	ErrCouponNotFound = errors.New("coupon not found")
+	// ErrNotApplicable is returned when a coupon exists but can't be used on
+	// the cart, e.g. because it expired or the minimum spend isn't met.
+	ErrNotApplicable = errors.New("coupon not applicable")
+	ErrDuplicateCode = errors.New("coupon code already exists")
+)
+
+type Coupon struct {
+	ID          int    `json:"id"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Kind        Kind   `json:"kind"`
+	// Value is the percentage off for percentage and buy_x_get_y coupons.
+	Value float64 `json:"value,omitempty"`
+	// Amount is the discount of a fixed coupon.
+	Amount      *money.Money `json:"amount,omitempty"`
+	BuyQuantity int          `json:"buy_quantity,omitempty"`
+	GetQuantity int          `json:"get_quantity,omitempty"`
+	// MinSpend is the cart subtotal needed before the coupon applies.
+	MinSpend *money.Money `json:"min_spend,omitempty"`
+	// CategoryIDs limits the coupon to products in these categories or
+	// their subcategories. Empty means every product.
+	CategoryIDs []int      `json:"category_ids"`
+	StartsAt    *time.Time `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at"`
+	// UsageLimitPerUser caps how many orders one user may place with the
+	// coupon. Zero means unlimited.
+	UsageLimitPerUser int       `json:"usage_limit_per_user"`
+	Active            bool      `json:"active"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// NormalizeCode is the form codes are stored and looked up in.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func ValidateCoupon(c Coupon) []api.ValidationError {
+	var errs []api.ValidationError
+
+	if len(c.Code) < 3 || len(c.Code) > 32 || strings.IndexFunc(c.Code, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r != '-' && r != '_'
+	}) >= 0 {
+		errs = append(errs, api.ValidationError{
+			Field:   "code",
+			Message: "code must be 3-32 letters, digits, - or _",
+		})
+	}
+
+	if len(c.Description) > 255 {
+		errs = append(errs, api.ValidationError{
+			Field:   "description",
+			Message: "description must be 255 characters or less",
+		})
+	}
+
+	switch c.Kind {
+	case KindPercentage:
+		if c.Value <= 0 || c.Value > 100 {
+			errs = append(errs, api.ValidationError{
+				Field:   "value",
+				Message: "percentage must be greater than 0 and at most 100",
+			})
+		}
+	case KindFixed:
+		if c.Amount == nil {
+			errs = append(errs, api.ValidationError{
+				Field:   "amount",
+				Message: "amount is required for a fixed discount",
+			})
+		} else {
+			errs = append(errs, validateMoney("amount", *c.Amount, 1)...)
+		}
+	case KindBuyXGetY:
+		if c.BuyQuantity < 1 || c.GetQuantity < 1 {
+			errs = append(errs, api.ValidationError{
+				Field:   "buy_quantity",
+				Message: "buy_quantity and get_quantity must be at least 1",
+			})
+		}
+		if c.Value <= 0 || c.Value > 100 {
+			errs = append(errs, api.ValidationError{
+				Field:   "value",
+				Message: "percentage off the free items must be greater than 0 and at most 100",
+			})
+		}
+	default:
+		errs = append(errs, api.ValidationError{
+			Field:   "kind",
+			Message: "kind must be one of percentage, fixed, buy_x_get_y",
+		})
+	}
+
+	if c.MinSpend != nil {
+		errs = append(errs, validateMoney("min_spend", *c.MinSpend, 0)...)
+	}
+
+	if c.StartsAt != nil && c.EndsAt != nil && !c.EndsAt.After(*c.StartsAt) {
+		errs = append(errs, api.ValidationError{
+			Field:   "ends_at",
+			Message: "ends_at must be after starts_at",
+		})
+	}
+
+	if c.UsageLimitPerUser < 0 {
+		errs = append(errs, api.ValidationError{
+			Field:   "usage_limit_per_user",
+			Message: "usage_limit_per_user must be 0 or greater",
+		})
+	}
+
+	return errs
+}
+
+// usable reports why c can't be used at now, if it can't.
+func (c Coupon) usable(now time.Time) error {
+	switch {
+	case !c.Active:
+		return fmt.Errorf("%w: coupon %s is inactive", ErrNotApplicable, c.Code)
+	case c.StartsAt != nil && now.Before(*c.StartsAt):
+		return fmt.Errorf("%w: coupon %s is not valid yet", ErrNotApplicable, c.Code)
+	case c.EndsAt != nil && !now.Before(*c.EndsAt):
+		return fmt.Errorf("%w: coupon %s has expired", ErrNotApplicable, c.Code)
+	}
+	return nil
+}
+
+// maxAmount matches the DECIMAL(10,2) columns amounts are stored in.
+const maxAmount = 99999999
+
+// validateMoney checks an amount is in the catalog currency and between
+// minimum and maxAmount minor units.
+func validateMoney(field string, m money.Money, minimum int64) []api.ValidationError {
+	if m.Currency != product.BaseCurrency {
+		return []api.ValidationError{{
+			Field:   field + ".currency",
+			Message: fmt.Sprintf("%s must be in %s", field, product.BaseCurrency),
+		}}
+	}
+	if m.Amount < minimum || m.Amount > maxAmount {
+		return []api.ValidationError{{
+			Field:   field,
+			Message: fmt.Sprintf("%s must be between %s and %s", field, money.New(minimum, m.Currency).Decimal(), money.New(maxAmount, m.Currency).Decimal()),
+		}}
+	}
+	return nil
+}
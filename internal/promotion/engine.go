@@ -0,0 +1,126 @@
+package promotion
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/product"
+)
+
+// Line is a priced cart line as the engine sees it.
+type Line struct {
+	ProductID  int
+	CategoryID *int
+	UnitPrice  money.Money
+	Quantity   int
+}
+
+func (l Line) subtotal() money.Money {
+	return l.UnitPrice.Mul(l.Quantity)
+}
+
+type LineDiscount struct {
+	ProductID int         `json:"product_id"`
+	Discount  money.Money `json:"discount"`
+}
+
+// Result is a coupon's discount on a cart, broken down by line.
+type Result struct {
+	Code     string         `json:"code"`
+	Lines    []LineDiscount `json:"lines"`
+	Discount money.Money    `json:"discount"`
+}
+
+// Apply works out c's discount on lines. eligible reports whether a line
+// falls within the coupon's categories; nil means every line qualifies.
+func (c Coupon) Apply(lines []Line, eligible func(Line) bool, now time.Time) (Result, error) {
+	if err := c.usable(now); err != nil {
+		return Result{}, err
+	}
+
+	subtotal := money.New(0, product.BaseCurrency)
+	var matched []int
+	for i, l := range lines {
+		subtotal = subtotal.Add(l.subtotal())
+		if eligible == nil || eligible(l) {
+			matched = append(matched, i)
+		}
+	}
+
+	if c.MinSpend != nil && subtotal.Amount < c.MinSpend.Amount {
+		return Result{}, fmt.Errorf("%w: minimum spend of %s not met", ErrNotApplicable, c.MinSpend.Decimal())
+	}
+	if len(matched) == 0 {
+		return Result{}, fmt.Errorf("%w: no items in the cart qualify for coupon %s", ErrNotApplicable, c.Code)
+	}
+
+	discounts := make([]money.Money, len(lines))
+	for i := range discounts {
+		discounts[i] = money.New(0, product.BaseCurrency)
+	}
+	switch c.Kind {
+	case KindPercentage:
+		for _, i := range matched {
+			discounts[i] = lines[i].subtotal().Percent(c.Value)
+		}
+	case KindFixed:
+		c.spread(lines, matched, discounts)
+	case KindBuyXGetY:
+		c.buyXGetY(lines, matched, discounts)
+	}
+
+	result := Result{Code: c.Code, Lines: []LineDiscount{}, Discount: money.New(0, product.BaseCurrency)}
+	for i, d := range discounts {
+		if d.IsPositive() {
+			result.Lines = append(result.Lines, LineDiscount{ProductID: lines[i].ProductID, Discount: d})
+			result.Discount = result.Discount.Add(d)
+		}
+	}
+
+	if result.Discount.IsZero() {
+		return Result{}, fmt.Errorf("%w: coupon %s gives no discount on this cart", ErrNotApplicable, c.Code)
+	}
+	return result, nil
+}
+
+// spread shares a fixed discount across the matched lines in proportion to
+// their subtotals, capped at their total so a line never goes negative.
+func (c Coupon) spread(lines []Line, matched []int, discounts []money.Money) {
+	eligible := money.New(0, product.BaseCurrency)
+	weights := make([]int64, len(matched))
+	for n, i := range matched {
+		eligible = eligible.Add(lines[i].subtotal())
+		weights[n] = lines[i].subtotal().Amount
+	}
+
+	shares := c.Amount.Min(eligible).Allocate(weights)
+	for n, i := range matched {
+		discounts[i] = shares[n]
+	}
+}
+
+// buyXGetY discounts the cheapest units: for every BuyQuantity+GetQuantity
+// matched units, GetQuantity of them are Value percent off.
+func (c Coupon) buyXGetY(lines []Line, matched []int, discounts []money.Money) {
+	type unit struct {
+		line  int
+		price money.Money
+	}
+
+	var units []unit
+	for _, i := range matched {
+		for range lines[i].Quantity {
+			units = append(units, unit{line: i, price: lines[i].UnitPrice})
+		}
+	}
+	sort.SliceStable(units, func(a, b int) bool {
+		return units[a].price.Amount < units[b].price.Amount
+	})
+
+	free := len(units) / (c.BuyQuantity + c.GetQuantity) * c.GetQuantity
+	for _, u := range units[:free] {
+		discounts[u.line] = discounts[u.line].Add(u.price.Percent(c.Value))
+	}
+}
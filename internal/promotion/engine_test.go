@@ -0,0 +1,146 @@
+package promotion
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/money/moneytest"
+)
+
+func amount(cents int64) *money.Money {
+	m := moneytest.USD(cents)
+	return &m
+}
+
+func TestApply(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+	tomorrow := now.Add(24 * time.Hour)
+	books, toys := 1, 2
+
+	lines := []Line{
+		{ProductID: 1, CategoryID: &books, UnitPrice: moneytest.USD(1000), Quantity: 2},
+		{ProductID: 2, CategoryID: &toys, UnitPrice: moneytest.USD(500), Quantity: 1},
+		{ProductID: 3, UnitPrice: moneytest.USD(333), Quantity: 3},
+	}
+	onlyBooks := func(l Line) bool { return l.CategoryID != nil && *l.CategoryID == books }
+
+	tests := []struct {
+		name     string
+		coupon   Coupon
+		eligible func(Line) bool
+		want     []LineDiscount
+		err      error
+	}{
+		{
+			name:   "percentage",
+			coupon: Coupon{Kind: KindPercentage, Value: 10},
+			want:   []LineDiscount{{1, moneytest.USD(200)}, {2, moneytest.USD(50)}, {3, moneytest.USD(100)}},
+		},
+		{
+			name:     "percentage in category",
+			coupon:   Coupon{Kind: KindPercentage, Value: 25},
+			eligible: onlyBooks,
+			want:     []LineDiscount{{1, moneytest.USD(500)}},
+		},
+		{
+			name:   "fixed spread by subtotal",
+			coupon: Coupon{Kind: KindFixed, Amount: amount(700)},
+			want:   []LineDiscount{{1, moneytest.USD(400)}, {2, moneytest.USD(100)}, {3, moneytest.USD(200)}},
+		},
+		{
+			name:     "fixed capped at eligible subtotal",
+			coupon:   Coupon{Kind: KindFixed, Amount: amount(5000)},
+			eligible: onlyBooks,
+			want:     []LineDiscount{{1, moneytest.USD(2000)}},
+		},
+		{
+			name:   "buy two get one free",
+			coupon: Coupon{Kind: KindBuyXGetY, Value: 100, BuyQuantity: 2, GetQuantity: 1},
+			want:   []LineDiscount{{3, moneytest.USD(666)}},
+		},
+		{
+			name:     "buy one get one half off",
+			coupon:   Coupon{Kind: KindBuyXGetY, Value: 50, BuyQuantity: 1, GetQuantity: 1},
+			eligible: onlyBooks,
+			want:     []LineDiscount{{1, moneytest.USD(500)}},
+		},
+		{
+			name:   "minimum spend met",
+			coupon: Coupon{Kind: KindFixed, Amount: amount(100), MinSpend: amount(3499)},
+			want:   []LineDiscount{{1, moneytest.USD(57)}, {2, moneytest.USD(14)}, {3, moneytest.USD(29)}},
+		},
+		{
+			name:   "minimum spend not met",
+			coupon: Coupon{Kind: KindFixed, Amount: amount(100), MinSpend: amount(3500)},
+			err:    ErrNotApplicable,
+		},
+		{
+			name:     "no eligible lines",
+			coupon:   Coupon{Kind: KindPercentage, Value: 10},
+			eligible: func(Line) bool { return false },
+			err:      ErrNotApplicable,
+		},
+		{
+			name:   "too few units for buy x get y",
+			coupon: Coupon{Kind: KindBuyXGetY, Value: 100, BuyQuantity: 6, GetQuantity: 1},
+			err:    ErrNotApplicable,
+		},
+		{
+			name:   "within window",
+			coupon: Coupon{Kind: KindPercentage, Value: 10, StartsAt: &yesterday, EndsAt: &tomorrow},
+			want:   []LineDiscount{{1, moneytest.USD(200)}, {2, moneytest.USD(50)}, {3, moneytest.USD(100)}},
+		},
+		{
+			name:   "not started",
+			coupon: Coupon{Kind: KindPercentage, Value: 10, StartsAt: &tomorrow},
+			err:    ErrNotApplicable,
+		},
+		{
+			name:   "expired",
+			coupon: Coupon{Kind: KindPercentage, Value: 10, EndsAt: &yesterday},
+			err:    ErrNotApplicable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.coupon
+			c.Code = "TEST"
+			c.Active = true
+
+			result, err := c.Apply(lines, tt.eligible, now)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(result.Lines, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, result.Lines)
+			}
+			sum := moneytest.USD(0)
+			for _, d := range tt.want {
+				sum = sum.Add(d.Discount)
+			}
+			if result.Discount != sum {
+				t.Fatalf("expected a total discount of %v, got %v", sum, result.Discount)
+			}
+		})
+	}
+}
+
+func TestApply_Inactive(t *testing.T) {
+	c := Coupon{Code: "OFF", Kind: KindPercentage, Value: 10}
+	_, err := c.Apply([]Line{{ProductID: 1, UnitPrice: moneytest.USD(1000), Quantity: 1}}, nil, time.Now())
+	if !errors.Is(err, ErrNotApplicable) {
+		t.Fatalf("expected ErrNotApplicable, got %v", err)
+	}
+}
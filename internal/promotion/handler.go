@@ -0,0 +1,176 @@
+package promotion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"lukekorsman.com/store/internal/api"
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/product"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler serves the coupon admin API.
+type Handler struct {
+	store      Store
+	categories product.CategoryStore
+}
+
+func NewHandler(store Store, categories product.CategoryStore) *Handler {
+	return &Handler{
+		store:      store,
+		categories: categories,
+	}
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	coupons, err := h.store.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, coupons)
+}
+
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	c, err := h.store.GetByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c)
+}
+
+// Create adds a coupon. Coupons are active unless the body says otherwise.
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	c := Coupon{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	normalize(&c)
+	if errs := h.validate(r, c); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	created, err := h.store.Create(r.Context(), c)
+	if errors.Is(err, ErrDuplicateCode) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var c Coupon
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	normalize(&c)
+	if errs := h.validate(r, c); len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": errs,
+		})
+		return
+	}
+
+	updated, err := h.store.Update(r.Context(), id, c)
+	if errors.Is(err, ErrCouponNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, ErrDuplicateCode) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.Delete(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// normalize upper-cases the code and puts amounts without a currency in the
+// catalog currency.
+func normalize(c *Coupon) {
+	c.Code = NormalizeCode(c.Code)
+	for _, m := range []*money.Money{c.Amount, c.MinSpend} {
+		if m != nil && m.Currency == "" {
+			m.Currency = product.BaseCurrency
+		}
+	}
+}
+
+// validate runs ValidateCoupon and checks every category exists.
+func (h *Handler) validate(r *http.Request, c Coupon) []api.ValidationError {
+	errs := ValidateCoupon(c)
+	if len(c.CategoryIDs) == 0 {
+		return errs
+	}
+
+	categories, err := h.categories.List(r.Context())
+	if err != nil {
+		return append(errs, api.ValidationError{Field: "category_ids", Message: err.Error()})
+	}
+	for _, id := range c.CategoryIDs {
+		if !slices.ContainsFunc(categories, func(cat product.Category) bool { return cat.ID == id }) {
+			errs = append(errs, api.ValidationError{
+				Field:   "category_ids",
+				Message: fmt.Sprintf("category %d does not exist", id),
+			})
+		}
+	}
+	return errs
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
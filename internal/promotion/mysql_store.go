@@ -0,0 +1,251 @@
+package promotion
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"lukekorsman.com/store/internal/money"
+	"lukekorsman.com/store/internal/product"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlDuplicateEntry is the MySQL error number for a unique key violation.
+const mysqlDuplicateEntry = 1062
+
+const couponColumns = "id, code, description, kind, value, buy_quantity, get_quantity, min_spend, category_ids, " +
+	"starts_at, ends_at, usage_limit_per_user, active, created_at, updated_at"
+
+type MySQLStore struct {
+	db *sql.DB
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCoupon(row rowScanner) (Coupon, error) {
+	var c Coupon
+	var value, minSpend string
+	var categoryIDs []byte
+	var startsAt, endsAt sql.NullTime
+	err := row.Scan(&c.ID, &c.Code, &c.Description, &c.Kind, &value, &c.BuyQuantity, &c.GetQuantity, &minSpend,
+		&categoryIDs, &startsAt, &endsAt, &c.UsageLimitPerUser, &c.Active, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return Coupon{}, err
+	}
+
+	// value holds a fixed coupon's amount and every other kind's percentage.
+	if c.Kind == KindFixed {
+		amount, err := money.Parse(value, product.BaseCurrency)
+		if err != nil {
+			return Coupon{}, fmt.Errorf("coupon %d: %w", c.ID, err)
+		}
+		c.Amount = &amount
+	} else if c.Value, err = strconv.ParseFloat(value, 64); err != nil {
+		return Coupon{}, fmt.Errorf("coupon %d: %w", c.ID, err)
+	}
+	spend, err := money.Parse(minSpend, product.BaseCurrency)
+	if err != nil {
+		return Coupon{}, fmt.Errorf("coupon %d: %w", c.ID, err)
+	}
+	if spend.IsPositive() {
+		c.MinSpend = &spend
+	}
+
+	if err := json.Unmarshal(categoryIDs, &c.CategoryIDs); err != nil {
+		return Coupon{}, fmt.Errorf("coupon %d: %w", c.ID, err)
+	}
+	if startsAt.Valid {
+		c.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		c.EndsAt = &endsAt.Time
+	}
+	return c, nil
+}
+
+func (s *MySQLStore) List(ctx context.Context) ([]Coupon, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+couponColumns+" FROM coupons ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coupons := []Coupon{}
+	for rows.Next() {
+		c, err := scanCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, c)
+	}
+
+	return coupons, rows.Err()
+}
+
+func (s *MySQLStore) GetByID(ctx context.Context, id int) (Coupon, error) {
+	c, err := scanCoupon(s.db.QueryRowContext(ctx, "SELECT "+couponColumns+" FROM coupons WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Coupon{}, fmt.Errorf("%w: %d", ErrCouponNotFound, id)
+	}
+	return c, err
+}
+
+func (s *MySQLStore) GetByCode(ctx context.Context, code string) (Coupon, error) {
+	c, err := scanCoupon(s.db.QueryRowContext(ctx, "SELECT "+couponColumns+" FROM coupons WHERE code = ?", code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Coupon{}, fmt.Errorf("%w: %s", ErrCouponNotFound, code)
+	}
+	return c, err
+}
+
+func couponArgs(c Coupon) []any {
+	categoryIDs, _ := json.Marshal(c.CategoryIDs)
+	if c.CategoryIDs == nil {
+		categoryIDs = []byte("[]")
+	}
+	value := strconv.FormatFloat(c.Value, 'f', 2, 64)
+	if c.Kind == KindFixed && c.Amount != nil {
+		value = c.Amount.Decimal()
+	}
+	minSpend := "0"
+	if c.MinSpend != nil {
+		minSpend = c.MinSpend.Decimal()
+	}
+	return []any{c.Code, c.Description, c.Kind, value, c.BuyQuantity, c.GetQuantity, minSpend,
+		categoryIDs, c.StartsAt, c.EndsAt, c.UsageLimitPerUser, c.Active}
+}
+
+func duplicateCode(err error, code string) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
+		return fmt.Errorf("%w: %s", ErrDuplicateCode, code)
+	}
+	return err
+}
+
+func (s *MySQLStore) Create(ctx context.Context, c Coupon) (Coupon, error) {
+	result, err := s.db.ExecContext(ctx,
+		"INSERT INTO coupons (code, description, kind, value, buy_quantity, get_quantity, min_spend, category_ids, "+
+			"starts_at, ends_at, usage_limit_per_user, active) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		couponArgs(c)...,
+	)
+	if err != nil {
+		return Coupon{}, duplicateCode(err, c.Code)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Coupon{}, err
+	}
+	return s.GetByID(ctx, int(id))
+}
+
+func (s *MySQLStore) Update(ctx context.Context, id int, c Coupon) (Coupon, error) {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE coupons SET code = ?, description = ?, kind = ?, value = ?, buy_quantity = ?, get_quantity = ?, "+
+			"min_spend = ?, category_ids = ?, starts_at = ?, ends_at = ?, usage_limit_per_user = ?, active = ? WHERE id = ?",
+		append(couponArgs(c), id)...,
+	)
+	if err != nil {
+		return Coupon{}, duplicateCode(err, c.Code)
+	}
+
+	// MySQL reports 0 affected rows for an unchanged row, so the read is what
+	// tells a missing coupon apart.
+	return s.GetByID(ctx, id)
+}
+
+func (s *MySQLStore) Delete(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM coupons WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: %d", ErrCouponNotFound, id)
+	}
+	return nil
+}
+
+func (s *MySQLStore) Redemptions(ctx context.Context, couponID, userID int) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = ? AND user_id = ?",
+		couponID, userID,
+	).Scan(&n)
+	return n, err
+}
+
+// Redeem locks the coupon row so concurrent checkouts by the same user
+// can't both slip under the limit.
+func (s *MySQLStore) Redeem(ctx context.Context, c Coupon, userID int) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked int
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM coupons WHERE id = ? FOR UPDATE", c.ID).Scan(&locked); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%w: %s", ErrCouponNotFound, c.Code)
+		}
+		return 0, err
+	}
+
+	if c.UsageLimitPerUser > 0 {
+		var n int
+		err := tx.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM coupon_redemptions WHERE coupon_id = ? AND user_id = ?",
+			c.ID, userID,
+		).Scan(&n)
+		if err != nil {
+			return 0, err
+		}
+		if n >= c.UsageLimitPerUser {
+			return 0, usageLimitError(c)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO coupon_redemptions (coupon_id, user_id) VALUES (?, ?)", c.ID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), tx.Commit()
+}
+
+func (s *MySQLStore) AttachOrder(ctx context.Context, redemptionID, orderID int) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE coupon_redemptions SET order_id = ? WHERE id = ?", orderID, redemptionID)
+	return err
+}
+
+func (s *MySQLStore) CancelRedemption(ctx context.Context, redemptionID int) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM coupon_redemptions WHERE id = ?", redemptionID)
+	return err
+}
+
+func (s *MySQLStore) CancelOrderRedemption(ctx context.Context, orderID int) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM coupon_redemptions WHERE order_id = ?", orderID)
+	return err
+}
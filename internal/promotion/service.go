@@ -0,0 +1,95 @@
+package promotion
+
+import (
+	"context"
+	"time"
+
+	"lukekorsman.com/store/internal/product"
+)
+
+// Service evaluates coupons against carts and records their use.
+type Service struct {
+	store      Store
+	categories product.CategoryStore
+	now        func() time.Time
+}
+
+func NewService(store Store, categories product.CategoryStore) *Service {
+	return &Service{
+		store:      store,
+		categories: categories,
+		now:        time.Now,
+	}
+}
+
+// Evaluate applies the coupon with code to lines for userID. Usage limits
+// are only checked for logged-in users (userID > 0); they're enforced again
+// when the order is placed.
+func (s *Service) Evaluate(ctx context.Context, code string, userID int, lines []Line) (Result, error) {
+	c, err := s.store.GetByCode(ctx, NormalizeCode(code))
+	if err != nil {
+		return Result{}, err
+	}
+
+	if userID > 0 && c.UsageLimitPerUser > 0 {
+		n, err := s.store.Redemptions(ctx, c.ID, userID)
+		if err != nil {
+			return Result{}, err
+		}
+		if n >= c.UsageLimitPerUser {
+			return Result{}, usageLimitError(c)
+		}
+	}
+
+	eligible, err := s.eligible(ctx, c)
+	if err != nil {
+		return Result{}, err
+	}
+	return c.Apply(lines, eligible, s.now())
+}
+
+// Redeem records that userID used the coupon with code and returns the
+// redemption ID.
+func (s *Service) Redeem(ctx context.Context, code string, userID int) (int, error) {
+	c, err := s.store.GetByCode(ctx, NormalizeCode(code))
+	if err != nil {
+		return 0, err
+	}
+	return s.store.Redeem(ctx, c, userID)
+}
+
+func (s *Service) AttachOrder(ctx context.Context, redemptionID, orderID int) error {
+	return s.store.AttachOrder(ctx, redemptionID, orderID)
+}
+
+func (s *Service) CancelRedemption(ctx context.Context, redemptionID int) error {
+	return s.store.CancelRedemption(ctx, redemptionID)
+}
+
+func (s *Service) CancelOrderRedemption(ctx context.Context, orderID int) error {
+	return s.store.CancelOrderRedemption(ctx, orderID)
+}
+
+// eligible matches lines in the coupon's categories or any category below
+// them. A coupon without categories matches everything.
+func (s *Service) eligible(ctx context.Context, c Coupon) (func(Line) bool, error) {
+	if len(c.CategoryIDs) == 0 {
+		return nil, nil
+	}
+
+	categories, err := s.categories.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[int]bool)
+	for _, id := range c.CategoryIDs {
+		for _, descendant := range product.DescendantIDs(categories, id) {
+			allowed[descendant] = true
+		}
+	}
+
+	return func(l Line) bool {
+		return l.CategoryID != nil && allowed[*l.CategoryID]
+	}, nil
+}
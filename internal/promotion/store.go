@@ -0,0 +1,228 @@
+package promotion
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	List(ctx context.Context) ([]Coupon, error)
+	GetByID(ctx context.Context, id int) (Coupon, error)
+	GetByCode(ctx context.Context, code string) (Coupon, error)
+	Create(ctx context.Context, c Coupon) (Coupon, error)
+	Update(ctx context.Context, id int, c Coupon) (Coupon, error)
+	Delete(ctx context.Context, id int) error
+
+	// Redemptions counts the orders userID has placed with the coupon.
+	Redemptions(ctx context.Context, couponID, userID int) (int, error)
+	// Redeem records a use of c by userID, failing with ErrNotApplicable if
+	// the user has reached the coupon's limit. The check and the insert are
+	// atomic.
+	Redeem(ctx context.Context, c Coupon, userID int) (int, error)
+	// AttachOrder links a redemption to the order it was used for.
+	AttachOrder(ctx context.Context, redemptionID, orderID int) error
+	// CancelRedemption removes a redemption whose order wasn't placed.
+	CancelRedemption(ctx context.Context, redemptionID int) error
+	// CancelOrderRedemption removes the redemption attached to a cancelled
+	// order, so the use no longer counts against the user's limit.
+	CancelOrderRedemption(ctx context.Context, orderID int) error
+}
+
+type redemption struct {
+	ID       int
+	CouponID int
+	UserID   int
+	OrderID  int
+}
+
+type MemoryStore struct {
+	coupons          map[int]Coupon
+	redemptions      map[int]redemption
+	nextID           int
+	nextRedemptionID int
+	mu               sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		coupons:          make(map[int]Coupon),
+		redemptions:      make(map[int]redemption),
+		nextID:           1,
+		nextRedemptionID: 1,
+	}
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]Coupon, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	coupons := make([]Coupon, 0, len(s.coupons))
+	for _, c := range s.coupons {
+		coupons = append(coupons, c)
+	}
+	sort.Slice(coupons, func(i, j int) bool {
+		return coupons[i].ID < coupons[j].ID
+	})
+	return coupons, nil
+}
+
+func (s *MemoryStore) GetByID(ctx context.Context, id int) (Coupon, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.coupons[id]
+	if !ok {
+		return Coupon{}, fmt.Errorf("%w: %d", ErrCouponNotFound, id)
+	}
+	return c, nil
+}
+
+func (s *MemoryStore) GetByCode(ctx context.Context, code string) (Coupon, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range s.coupons {
+		if c.Code == code {
+			return c, nil
+		}
+	}
+	return Coupon{}, fmt.Errorf("%w: %s", ErrCouponNotFound, code)
+}
+
+// codeTaken reports whether another coupon uses code. The caller must hold
+// the lock.
+func (s *MemoryStore) codeTaken(code string, id int) bool {
+	for _, c := range s.coupons {
+		if c.Code == code && c.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) Create(ctx context.Context, c Coupon) (Coupon, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.codeTaken(c.Code, 0) {
+		return Coupon{}, fmt.Errorf("%w: %s", ErrDuplicateCode, c.Code)
+	}
+
+	now := time.Now().UTC()
+	c.ID = s.nextID
+	s.nextID++
+	c.CategoryIDs = slices.Clone(c.CategoryIDs)
+	c.CreatedAt = now
+	c.UpdatedAt = now
+	s.coupons[c.ID] = c
+	return c, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, id int, c Coupon) (Coupon, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.coupons[id]
+	if !ok {
+		return Coupon{}, fmt.Errorf("%w: %d", ErrCouponNotFound, id)
+	}
+	if s.codeTaken(c.Code, id) {
+		return Coupon{}, fmt.Errorf("%w: %s", ErrDuplicateCode, c.Code)
+	}
+
+	c.ID = id
+	c.CategoryIDs = slices.Clone(c.CategoryIDs)
+	c.CreatedAt = existing.CreatedAt
+	c.UpdatedAt = time.Now().UTC()
+	s.coupons[id] = c
+	return c, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.coupons[id]; !ok {
+		return fmt.Errorf("%w: %d", ErrCouponNotFound, id)
+	}
+	delete(s.coupons, id)
+	for rid, r := range s.redemptions {
+		if r.CouponID == id {
+			delete(s.redemptions, rid)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Redemptions(ctx context.Context, couponID, userID int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.count(couponID, userID), nil
+}
+
+// count must be called with the lock held.
+func (s *MemoryStore) count(couponID, userID int) int {
+	n := 0
+	for _, r := range s.redemptions {
+		if r.CouponID == couponID && r.UserID == userID {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *MemoryStore) Redeem(ctx context.Context, c Coupon, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.UsageLimitPerUser > 0 && s.count(c.ID, userID) >= c.UsageLimitPerUser {
+		return 0, usageLimitError(c)
+	}
+
+	id := s.nextRedemptionID
+	s.nextRedemptionID++
+	s.redemptions[id] = redemption{ID: id, CouponID: c.ID, UserID: userID}
+	return id, nil
+}
+
+func (s *MemoryStore) AttachOrder(ctx context.Context, redemptionID, orderID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.redemptions[redemptionID]
+	if !ok {
+		return fmt.Errorf("redemption %d not found", redemptionID)
+	}
+	r.OrderID = orderID
+	s.redemptions[redemptionID] = r
+	return nil
+}
+
+func (s *MemoryStore) CancelRedemption(ctx context.Context, redemptionID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.redemptions, redemptionID)
+	return nil
+}
+
+func (s *MemoryStore) CancelOrderRedemption(ctx context.Context, orderID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, r := range s.redemptions {
+		if r.OrderID == orderID {
+			delete(s.redemptions, id)
+		}
+	}
+	return nil
+}
+
+func usageLimitError(c Coupon) error {
+	return fmt.Errorf("%w: coupon %s has reached its limit of %d uses per customer", ErrNotApplicable, c.Code, c.UsageLimitPerUser)
+}
@@ -0,0 +1 @@
+DROP INDEX ft_products_name_description ON products;
\ No newline at end of file
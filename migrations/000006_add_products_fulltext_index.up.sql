@@ -0,0 +1 @@
+CREATE FULLTEXT INDEX ft_products_name_description ON products(name, description);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE products DROP INDEX idx_products_deleted_at, DROP COLUMN deleted_at;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL, ADD INDEX idx_products_deleted_at (deleted_at);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE products DROP FOREIGN KEY fk_products_category, DROP COLUMN category_id;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN category_id INT NULL AFTER description, ADD CONSTRAINT fk_products_category FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS product_tags;
\ No newline at end of file
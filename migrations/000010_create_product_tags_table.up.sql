@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS product_tags (
+    product_id INT NOT NULL,
+    tag VARCHAR(50) NOT NULL,
+    PRIMARY KEY (product_id, tag),
+    INDEX idx_product_tags_tag (tag),
+    CONSTRAINT fk_product_tags_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS product_options (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    option_values JSON NOT NULL,
+    UNIQUE KEY uq_product_options_name (product_id, name),
+    CONSTRAINT fk_product_options_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
\ No newline at end of file
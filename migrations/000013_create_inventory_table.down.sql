@@ -0,0 +1 @@
+DROP TABLE IF EXISTS inventory;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS inventory (
+    product_id INT PRIMARY KEY,
+    on_hand INT NOT NULL DEFAULT 0,
+    reserved INT NOT NULL DEFAULT 0,
+    low_stock_threshold INT NOT NULL DEFAULT 5,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT chk_inventory_reserved CHECK (reserved >= 0 AND reserved <= on_hand),
+    CONSTRAINT fk_inventory_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
\ No newline at end of file
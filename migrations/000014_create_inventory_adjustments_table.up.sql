@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS inventory_adjustments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    product_id INT NOT NULL,
+    delta INT NOT NULL,
+    reason VARCHAR(20) NOT NULL,
+    note VARCHAR(500) NOT NULL DEFAULT '',
+    actor_id INT NULL,
+    on_hand INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_inventory_adjustments_product (product_id, id),
+    CONSTRAINT fk_inventory_adjustments_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
\ No newline at end of file
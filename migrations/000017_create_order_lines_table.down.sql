@@ -0,0 +1 @@
+DROP TABLE IF EXISTS order_lines;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS payment_attempts;
\ No newline at end of file
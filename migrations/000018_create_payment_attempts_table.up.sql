@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS payment_attempts (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    order_id INT NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL UNIQUE,
+    transaction_id VARCHAR(255) NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_payment_attempts_order (order_id),
+    INDEX idx_payment_attempts_transaction (transaction_id),
+    CONSTRAINT fk_payment_attempts_order FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
\ No newline at end of file
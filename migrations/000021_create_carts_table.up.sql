@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS carts (
+    user_id INT PRIMARY KEY,
+    coupon_code VARCHAR(32) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
\ No newline at end of file
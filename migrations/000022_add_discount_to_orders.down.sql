@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    DROP COLUMN coupon_code,
+    DROP COLUMN discount;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    ADD COLUMN discount DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER subtotal,
+    ADD COLUMN coupon_code VARCHAR(32) NOT NULL DEFAULT '' AFTER discount;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE order_lines DROP COLUMN discount;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE order_lines ADD COLUMN discount DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER subtotal;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS product_prices (
+    product_id INT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    price DECIMAL(10,2) NOT NULL,
+    PRIMARY KEY (product_id, currency),
+    CONSTRAINT fk_product_prices_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS price_history;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE products DROP INDEX idx_products_sku, DROP COLUMN sku;
\ No newline at end of file
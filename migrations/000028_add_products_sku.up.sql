@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN sku VARCHAR(64) NULL AFTER id, ADD UNIQUE INDEX idx_products_sku (sku);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE products DROP COLUMN version;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN version INT NOT NULL DEFAULT 1 AFTER sku;
\ No newline at end of file
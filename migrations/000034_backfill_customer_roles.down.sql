@@ -0,0 +1 @@
+DELETE FROM user_roles WHERE role = 'customer';
\ No newline at end of file
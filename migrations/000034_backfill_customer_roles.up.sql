@@ -0,0 +1 @@
+INSERT IGNORE INTO user_roles (user_id, role) SELECT id, 'customer' FROM users;
\ No newline at end of file